@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ai-form-filler/cli/internal/messaging"
+	"github.com/ai-form-filler/cli/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// bridgeCmd represents the bridge command
+var bridgeCmd = &cobra.Command{
+	Use:   "bridge",
+	Short: "Serve the browser extension over a local WebSocket",
+	Long: `Serve the native messaging protocol over a WebSocket on this machine, for
+browsers where native messaging is disabled by policy. The extension falls
+back to it when it can't start the native messaging host.
+
+Only extensions named with --allow-origin may connect, as an origin such as
+chrome-extension://<id> or moz-extension://<uuid>, or as a Chromium
+extension ID. The bridge shares pairings with native messaging: an
+extension paired with 'native-messaging pair' authenticates the same way,
+and unpaired extensions can't reach profiles, forms or executions.`,
+	Args: cobra.NoArgs,
+	Run:  runBridge,
+}
+
+var (
+	bridgeAddress        string
+	bridgeAllowedOrigins []string
+	bridgeMaxConnections int
+)
+
+func init() {
+	rootCmd.AddCommand(bridgeCmd)
+
+	bridgeCmd.Flags().StringVar(&bridgeAddress, "listen", messaging.DefaultBridgeAddress, "Loopback address to listen on")
+	bridgeCmd.Flags().StringSliceVar(&bridgeAllowedOrigins, "allow-origin", nil, "Extension allowed to connect, as an origin or Chromium extension ID (repeatable)")
+	bridgeCmd.Flags().IntVar(&bridgeMaxConnections, "max-connections", 4, "Connections served at the same time")
+	bridgeCmd.Flags().Bool("debug", false, "Log at debug level and copy the log to stderr")
+	bridgeCmd.Flags().String("log-level", "info", "Least severe messages logged: debug, info, warn or error")
+	bridgeCmd.Flags().String("log-file", nativeMessagingLogPath(), "Log file, rotated as it grows")
+	bridgeCmd.Flags().String("templates-dir", defaultTemplatesDir(), "Directory for template history")
+	bridgeCmd.Flags().String("db", storage.DefaultDatabaseConfig().DatabasePath, "Database holding form templates")
+}
+
+func runBridge(cmd *cobra.Command, args []string) {
+	debug, _ := cmd.Flags().GetBool("debug")
+	templatesDir, _ := cmd.Flags().GetString("templates-dir")
+	databasePath, _ := cmd.Flags().GetString("db")
+	logLevel, _ := cmd.Flags().GetString("log-level")
+	logFile, _ := cmd.Flags().GetString("log-file")
+
+	if len(bridgeAllowedOrigins) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: Name the extension allowed to connect with --allow-origin\n")
+		os.Exit(1)
+	}
+
+	logger, closeLog, err := openHostLog(logFile, logLevel, debug)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer closeLog()
+	logger = logger.With("pid", os.Getpid(), "bridge", bridgeAddress)
+
+	dataDir, err := getDataDirectory()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	pairings := messaging.NewPairingStore(pairingDir())
+	register, cleanup, err := setupMessageHandlers(pairings, dataDir, templatesDir, databasePath, executionFeedDir())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer cleanup()
+
+	config := messaging.DefaultBridgeConfig()
+	config.Address = bridgeAddress
+	config.AllowedOrigins = bridgeAllowedOrigins
+	config.MaxConnections = bridgeMaxConnections
+	config.Host.Pairings = pairings
+	config.Host.Logger = logger
+	config.Logf = func(format string, args ...interface{}) {
+		logger.Info(fmt.Sprintf(format, args...))
+	}
+	bridge := messaging.NewBridge(config, register)
+
+	// Handle graceful shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		bridge.Shutdown(ctx)
+	}()
+
+	fmt.Printf("Bridge listening on ws://%s%s\n", bridgeAddress, messaging.BridgePath)
+	for _, origin := range bridgeAllowedOrigins {
+		fmt.Printf("Allowed: %s\n", messaging.NormalizeOrigin(origin))
+	}
+
+	if err := bridge.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		cleanup()
+		closeLog()
+		os.Exit(1)
+	}
+}
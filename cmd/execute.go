@@ -7,10 +7,12 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 
 	"github.com/ai-form-filler/cli/internal/automation"
 	"github.com/ai-form-filler/cli/internal/models"
 	"github.com/ai-form-filler/cli/internal/services"
+	"github.com/ai-form-filler/cli/internal/storage"
 	"github.com/ai-form-filler/cli/internal/ui"
 )
 
@@ -38,6 +40,8 @@ var (
 	executeHeadless    bool
 	executeTimeout     int
 	executeProfile     string
+	executeTrace       bool
+	executeHAR         bool
 )
 
 func init() {
@@ -48,6 +52,8 @@ func init() {
 	executeCmd.Flags().BoolVar(&executeHeadless, "headless", true, "Run browsers in headless mode")
 	executeCmd.Flags().IntVar(&executeTimeout, "timeout", 30, "Timeout in seconds for each form")
 	executeCmd.Flags().StringVar(&executeProfile, "profile", "", "Profile name to use (overrides positional argument)")
+	executeCmd.Flags().BoolVar(&executeTrace, "trace", false, "Save a Playwright trace with each URL's artifacts")
+	executeCmd.Flags().BoolVar(&executeHAR, "har", false, "Save a HAR file of network traffic with each URL's artifacts")
 }
 
 func runExecuteCommand(cmd *cobra.Command, args []string) {
@@ -115,7 +121,39 @@ func runExecuteCommand(cmd *cobra.Command, args []string) {
 	}
 	}
 	}
+	}
 	
+	// Apply retry policy from the config file, e.g.
+	//   retry:
+	//     max_retries: 5
+	//     initial_interval: 2s
+	//     retryable_errors: [network_error, timeout_error]
+	if viper.IsSet("retry") {
+		if err := viper.UnmarshalKey("retry", config.RetryPolicy); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid retry configuration: %v\n", err)
+			os.Exit(1)
+		}
+		config.RetryAttempts = config.RetryPolicy.MaxRetries
+	}
+
+	// Apply per-host politeness limits from the config file, e.g.
+	//   host_limits:
+	//     max_concurrency: 2
+	//     min_interval: 3s
+	if viper.IsSet("host_limits") {
+		if err := viper.UnmarshalKey("host_limits", &config.HostLimits); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid host limits configuration: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Capture traces and HAR files when the flags or the config file ask, e.g.
+	//   artifacts:
+	//     capture_trace: true
+	//     capture_har: true
+	config.Artifacts.CaptureTrace = executeTrace || viper.GetBool("artifacts.capture_trace")
+	config.Artifacts.CaptureHAR = executeHAR || viper.GetBool("artifacts.capture_har")
+
 	// Override with command line if specified
 	if executeConcurrency > 0 {
 	config.MaxConcurrency = executeConcurrency
@@ -144,6 +182,9 @@ func runExecuteCommand(cmd *cobra.Command, args []string) {
 	}
 	defer executionEngine.Close()
 
+	// Share progress with the browser extension through the native messaging host
+	executionEngine.Subscribe(automation.NewExecutionFeed(executionFeedDir()).Publish)
+
 	// Create execution session
 	sessionConfig := models.ExecutionConfig{
 		MaxConcurrency:   config.MaxConcurrency,
@@ -172,15 +213,23 @@ func runExecuteCommand(cmd *cobra.Command, args []string) {
 		},
 	}
 
-	// Create basic templates for URLs (in a real implementation, these would be loaded from storage)
+	// Fill each URL with its best stored template and record how it did;
+	// forms on URLs without one are detected on the page
+	db, templateManager := openTemplateStore()
+	defer db.Close()
+	executionEngine.SetTemplateManager(templateManager)
+
+	// Templates whose selectors stop matching are healed, and heals are logged
+	// as learning sessions
+	executionEngine.SetTemplateHealer(automation.NewTemplateHealer(
+		automation.NewFormDetector(browserManager, nil), templateManager, storage.NewLearningSessionStore(db), nil))
 	templates := make(map[string]*automation.FormTemplate)
 	for _, url := range urls {
-		templates[url] = &automation.FormTemplate{
-			ID:     fmt.Sprintf("auto_%s", url),
-			URL:    url,
-			Fields: []automation.FormField{}, // Will be detected dynamically
+		if template, err := templateManager.FindBestTemplate(url); err == nil {
+			templates[url] = template
 		}
 	}
+	fmt.Printf("Stored templates match %d of %d URLs\n", len(templates), len(urls))
 
 	// Create progress view
 	progressView := ui.NewProgressViewModel(session)
@@ -287,6 +336,9 @@ func printExecutionResults(session *models.ExecutionSession) {
 			}
 			fmt.Printf("  %s %s (%d/%d fields, %s)\n", 
 				status, result.URL, result.FilledFields, result.TotalFields, result.ExecutionTime)
+			if result.Artifacts != nil {
+				fmt.Printf("     Artifacts: %s\n", result.Artifacts.Dir)
+			}
 		}
 	}
 	
@@ -294,6 +346,9 @@ func printExecutionResults(session *models.ExecutionSession) {
 		fmt.Printf("\nErrors:\n")
 		for _, err := range session.Errors {
 			fmt.Printf("  ❌ %s: %s\n", err.URL, err.Message)
+			if err.Artifacts != nil {
+				fmt.Printf("     Artifacts: %s\n", err.Artifacts.Dir)
+			}
 		}
 	}
 }
\ No newline at end of file
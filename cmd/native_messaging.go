@@ -1,17 +1,29 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/ai-form-filler/cli/internal/automation"
 	"github.com/ai-form-filler/cli/internal/messaging"
+	"github.com/ai-form-filler/cli/internal/services"
+	"github.com/ai-form-filler/cli/internal/storage"
 	"github.com/spf13/cobra"
 )
 
+// nativeHostVersion is reported to the extension in handshakes and status
+const nativeHostVersion = "1.0.0"
+
 // nativeMessagingCmd represents the native messaging command
 var nativeMessagingCmd = &cobra.Command{
 	Use:   "native-messaging",
@@ -24,185 +36,982 @@ and should not be run manually unless for debugging purposes.`,
 	Run: runNativeMessaging,
 }
 
+var nativeMessagingInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Register the native messaging host with installed browsers",
+	Long: `Write the native messaging host manifest for Chrome, Chromium, Brave,
+Edge and Firefox so the browser extension can start this CLI.
+
+Manifests go to each browser's per-user NativeMessagingHosts directory and
+point at a small launcher script next to the default database, which runs
+this binary's native-messaging command. Browsers whose configuration
+directory does not exist are skipped unless named with --browser.
+
+Chromium extension IDs (32 letters a-p) are written to allowed_origins;
+Firefox add-on IDs (name@example.com or a GUID) to allowed_extensions.`,
+	Args: cobra.NoArgs,
+	Run:  runNativeMessagingInstall,
+}
+
+var nativeMessagingUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove the native messaging host manifests",
+	Args:  cobra.NoArgs,
+	Run:   runNativeMessagingUninstall,
+}
+
+var nativeMessagingStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show where the native messaging host is registered",
+	Args:  cobra.NoArgs,
+	Run:   runNativeMessagingStatus,
+}
+
+var nativeMessagingSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the message protocol as JSON Schema or TypeScript",
+	Long: `Print the native messaging protocol spoken with the browser extension:
+every message type with the schema of its data, the error codes and the
+capabilities negotiated in the handshake.
+
+The JSON Schema is what the host validates requests against; the TypeScript
+definitions describe the same types for the extension's code.`,
+	Args: cobra.NoArgs,
+	Run:  runNativeMessagingSchema,
+}
+
+var nativeMessagingPairCmd = &cobra.Command{
+	Use:   "pair",
+	Short: "Issue a one-time code to pair the browser extension",
+	Long: `Print a one-time code to enter in the browser extension. The extension
+sends it with a PAIR message and receives a secret it uses to authenticate
+later sessions. Until then it can't read or change profiles, fill or train
+forms, or follow executions.
+
+The code can be used once and expires after --ttl.`,
+	Args: cobra.NoArgs,
+	Run:  runNativeMessagingPair,
+}
+
+var nativeMessagingPairingsCmd = &cobra.Command{
+	Use:   "pairings",
+	Short: "List paired browser extensions",
+	Args:  cobra.NoArgs,
+	Run:   runNativeMessagingPairings,
+}
+
+var nativeMessagingUnpairCmd = &cobra.Command{
+	Use:   "unpair [pairing-id]",
+	Short: "Revoke a paired browser extension",
+	Long: `Revoke a pairing listed by 'native-messaging pairings', or every pairing
+with --all. A connected extension loses access with its next sensitive
+message and has to pair again.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runNativeMessagingUnpair,
+}
+
+var nativeMessagingReplayCmd = &cobra.Command{
+	Use:   "replay <recording>",
+	Short: "Replay a recorded native messaging session and compare the responses",
+	Long: `Send the requests of a session recorded with 'native-messaging --record'
+to a new host and compare what it sends back with the recording.
+
+The host runs the real handlers against --data-dir, which holds profiles,
+templates, the database and execution feeds as in ~/.ai-form-filler. By
+default it is an empty temporary directory, so record sessions starting
+from no profiles or templates. Pairing is not checked and recorded pairing
+messages are skipped; their secrets are not recorded.
+
+Times and other values that differ on every run are not compared, and IDs
+the host generates are matched up with the recorded ones. Exits with status
+1 if any message differs.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runNativeMessagingReplay,
+}
+
+var nativeMessagingLogsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Show the native messaging host log",
+	Long: `Print the last records of the log written by native messaging hosts,
+one line each. With --follow, keep printing records as hosts write them,
+across log rotations, until interrupted.
+
+Hosts log at --log-level info by default; start them with --log-level
+debug to also log every message. Profile values and pairing secrets in
+message data are redacted and URLs are cut to their site.`,
+	Args: cobra.NoArgs,
+	Run:  runNativeMessagingLogs,
+}
+
+var (
+	nativeMessagingLogsFile   string
+	nativeMessagingLogsLines  int
+	nativeMessagingLogsFollow bool
+	nativeMessagingLogsLevel  string
+	nativeMessagingLogsJSON   bool
+)
+
+var (
+	nativeMessagingReplayDataDir string
+	nativeMessagingReplayIgnore  []string
+	nativeMessagingReplayTimeout time.Duration
+	nativeMessagingReplayJSON    bool
+)
+
+var (
+	nativeMessagingPairName  string
+	nativeMessagingPairTTL   time.Duration
+	nativeMessagingUnpairAll bool
+)
+
+var (
+	nativeMessagingSchemaFormat string
+	nativeMessagingSchemaOutput string
+)
+
+var (
+	nativeMessagingExtensionIDs []string
+	nativeMessagingBrowsers     []string
+	nativeMessagingDryRun       bool
+)
+
 func init() {
 	rootCmd.AddCommand(nativeMessagingCmd)
+	nativeMessagingCmd.AddCommand(nativeMessagingInstallCmd)
+	nativeMessagingCmd.AddCommand(nativeMessagingUninstallCmd)
+	nativeMessagingCmd.AddCommand(nativeMessagingStatusCmd)
+	nativeMessagingCmd.AddCommand(nativeMessagingSchemaCmd)
+	nativeMessagingCmd.AddCommand(nativeMessagingPairCmd)
+	nativeMessagingCmd.AddCommand(nativeMessagingPairingsCmd)
+	nativeMessagingCmd.AddCommand(nativeMessagingUnpairCmd)
+	nativeMessagingCmd.AddCommand(nativeMessagingReplayCmd)
+	nativeMessagingCmd.AddCommand(nativeMessagingLogsCmd)
 	
 	nativeMessagingCmd.Flags().Duration("timeout", 30*time.Minute, "Timeout for native messaging host")
-	nativeMessagingCmd.Flags().Bool("debug", false, "Enable debug logging")
+	nativeMessagingCmd.Flags().Bool("debug", false, "Log at debug level and copy the log to stderr")
+	nativeMessagingCmd.Flags().String("log-level", "info", "Least severe messages logged: debug, info, warn or error")
+	nativeMessagingCmd.Flags().String("log-file", nativeMessagingLogPath(), "Log file, rotated as it grows")
+	nativeMessagingCmd.Flags().String("templates-dir", defaultTemplatesDir(), "Directory for template history")
+	nativeMessagingCmd.Flags().String("db", storage.DefaultDatabaseConfig().DatabasePath, "Database holding form templates")
+	nativeMessagingCmd.Flags().String("record", "", "Append every message read and written to this file, for 'native-messaging replay'")
+
+	nativeMessagingInstallCmd.Flags().StringSliceVar(&nativeMessagingExtensionIDs, "extension-id", nil, "Extension allowed to connect (repeatable)")
+	nativeMessagingInstallCmd.MarkFlagRequired("extension-id")
+	for _, command := range []*cobra.Command{nativeMessagingInstallCmd, nativeMessagingUninstallCmd} {
+		command.Flags().StringSliceVar(&nativeMessagingBrowsers, "browser", nil, "Only these browsers: chrome, chromium, brave, edge, firefox")
+		command.Flags().BoolVar(&nativeMessagingDryRun, "dry-run", false, "Print the files that would change without changing them")
+	}
+
+	nativeMessagingReplayCmd.Flags().StringVar(&nativeMessagingReplayDataDir, "data-dir", "", "Directory the replayed host keeps its data in (default: a new temporary directory)")
+	nativeMessagingReplayCmd.Flags().StringSliceVar(&nativeMessagingReplayIgnore, "ignore", nil, "More fields not to compare (repeatable)")
+	nativeMessagingReplayCmd.Flags().DurationVar(&nativeMessagingReplayTimeout, "timeout", 30*time.Second, "How long to wait for the responses to each request")
+	nativeMessagingReplayCmd.Flags().BoolVar(&nativeMessagingReplayJSON, "json", false, "Print the result as JSON")
+
+	nativeMessagingLogsCmd.Flags().StringVar(&nativeMessagingLogsFile, "log-file", nativeMessagingLogPath(), "Log file to read")
+	nativeMessagingLogsCmd.Flags().IntVarP(&nativeMessagingLogsLines, "lines", "n", 50, "Number of records to print")
+	nativeMessagingLogsCmd.Flags().BoolVarP(&nativeMessagingLogsFollow, "follow", "f", false, "Keep printing new records")
+	nativeMessagingLogsCmd.Flags().StringVar(&nativeMessagingLogsLevel, "level", "debug", "Least severe records printed: debug, info, warn or error")
+	nativeMessagingLogsCmd.Flags().BoolVar(&nativeMessagingLogsJSON, "json", false, "Print records as the JSON they are stored as")
+
+	nativeMessagingPairCmd.Flags().StringVar(&nativeMessagingPairName, "name", "", "Label for the pairing list, such as the browser it is for")
+	nativeMessagingPairCmd.Flags().DurationVar(&nativeMessagingPairTTL, "ttl", 5*time.Minute, "How long the code can be used")
+	nativeMessagingUnpairCmd.Flags().BoolVar(&nativeMessagingUnpairAll, "all", false, "Revoke every pairing")
+
+	nativeMessagingSchemaCmd.Flags().StringVar(&nativeMessagingSchemaFormat, "format", "json", "Output format: json or ts")
+	nativeMessagingSchemaCmd.Flags().StringVarP(&nativeMessagingSchemaOutput, "output", "o", "", "Write to a file instead of stdout")
 }
 
 func runNativeMessaging(cmd *cobra.Command, args []string) {
 	timeout, _ := cmd.Flags().GetDuration("timeout")
 	debug, _ := cmd.Flags().GetBool("debug")
-
-	if debug {
-		log.SetOutput(os.Stderr)
-		log.Println("Starting native messaging host in debug mode")
-	} else {
-		// Disable logging for production to avoid interfering with native messaging
-		log.SetOutput(os.NewFile(0, os.DevNull))
+	templatesDir, _ := cmd.Flags().GetString("templates-dir")
+	databasePath, _ := cmd.Flags().GetString("db")
+	recordPath, _ := cmd.Flags().GetString("record")
+	logLevel, _ := cmd.Flags().GetString("log-level")
+	logFile, _ := cmd.Flags().GetString("log-file")
+
+	// Create native messaging host. The browser passes the calling
+	// extension as arguments, which pairings are bound to.
+	hostConfig := messaging.DefaultNativeHostConfig()
+	hostConfig.Pairings = messaging.NewPairingStore(pairingDir())
+	hostConfig.Origin = messaging.CallerOrigin(args)
+
+	// Browsers discard the host's stderr, so logs go to a file. Several
+	// hosts may share it; the process ID tells them apart.
+	logger, closeLog, err := openHostLog(logFile, logLevel, debug)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer closeLog()
+	logger = logger.With("pid", os.Getpid(), "origin", hostConfig.Origin)
+	slog.SetDefault(logger)
+	hostConfig.Logger = logger
+	logger.Info("native messaging host started", "version", nativeHostVersion)
+
+	if recordPath != "" {
+		recording, err := os.OpenFile(recordPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			logger.Error("failed to open recording", "error", err)
+			os.Exit(1)
+		}
+		defer recording.Close()
+		hostConfig.Recorder = messaging.NewRecorder(recording)
 	}
+	host := messaging.NewNativeHost(hostConfig)
 
-	// Create native messaging host
-	host := messaging.NewNativeHost()
+	// The host keeps the real stdout for messages; anything else printed,
+	// such as warnings from automation, goes to stderr where the browser logs it
+	os.Stdout = os.Stderr
 
 	// Set timeout
 	if timeout > 0 {
 		host.SetTimeout(timeout)
 	}
 
-	// Register message handlers
-	setupMessageHandlers(host)
+	// Register message handlers. Stdout carries messages, so errors only go to the log.
+	dataDir, err := getDataDirectory()
+	if err != nil {
+		logger.Error("failed to find data directory", "error", err)
+		os.Exit(1)
+	}
+	register, cleanup, err := setupMessageHandlers(hostConfig.Pairings, dataDir, templatesDir, databasePath, executionFeedDir())
+	if err != nil {
+		logger.Error("failed to set up handlers", "error", err)
+		os.Exit(1)
+	}
+	defer cleanup()
+	register(host)
 
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
-		<-sigChan
-		if debug {
-			log.Println("Received shutdown signal, stopping native messaging host")
-		}
+		sig := <-sigChan
+		logger.Info("received shutdown signal, stopping native messaging host", "signal", sig.String())
 		host.Stop()
 	}()
 
 	// Start the native messaging host
 	if err := host.Start(); err != nil {
-		if debug {
-			log.Printf("Native messaging host error: %v", err)
-		}
+		logger.Error("native messaging host failed", "error", err)
+		cleanup()
+		closeLog()
 		os.Exit(1)
 	}
 
+	logger.Info("native messaging host stopped")
+}
+
+// openHostLog creates the JSON logger of hosts, writing to a rotating file
+// and, with debug, to stderr as well. A file that can't be opened is
+// reported on stderr, where logs then go, so the host still starts.
+func openHostLog(path, levelName string, debug bool) (*slog.Logger, func(), error) {
+	level, err := messaging.ParseLogLevel(levelName)
+	if err != nil {
+		return nil, nil, err
+	}
 	if debug {
-		log.Println("Native messaging host stopped")
+		level = slog.LevelDebug
 	}
+
+	var output io.Writer = os.Stderr
+	closeLog := func() {}
+	file, err := messaging.OpenRotatingFile(path, messaging.DefaultLogMaxSize, messaging.DefaultLogMaxFiles)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	} else {
+		output = file
+		if debug {
+			output = io.MultiWriter(file, os.Stderr)
+		}
+		closeLog = func() { file.Close() }
+	}
+	return slog.New(slog.NewJSONHandler(output, &slog.HandlerOptions{Level: level})), closeLog, nil
 }
 
-func setupMessageHandlers(host *messaging.NativeHost) {
+// setupMessageHandlers creates the services behind the handlers. It
+// returns a function registering the handlers with a host, which the bridge
+// calls for every connection, and a function that releases what the
+// services hold.
+func setupMessageHandlers(pairings *messaging.PairingStore, dataDir, templatesDir, databasePath, feedDir string) (func(*messaging.NativeHost), func(), error) {
 	// Create service implementations
-	profileService := &ProfileServiceImpl{}
-	formService := &FormServiceImpl{}
-	statusService := &StatusServiceImpl{}
+	profileService, err := services.NewProfileService(dataDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open profiles: %w", err)
+	}
 
-	// Register handlers
-	host.RegisterHandler("HANDSHAKE", messaging.NewHandshakeHandler("1.0.0"))
-	
-	profileHandler := messaging.NewProfileHandler(profileService)
-	host.RegisterHandler("GET_PROFILES", profileHandler)
-	host.RegisterHandler("CREATE_PROFILE", profileHandler)
-	host.RegisterHandler("UPDATE_PROFILE", profileHandler)
-	host.RegisterHandler("DELETE_PROFILE", profileHandler)
+	db, err := storage.NewDatabaseManager(&storage.DatabaseConfig{
+		DatabasePath: databasePath,
+		CreateTables: true,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open database: %w", err)
+	}
 
-	formHandler := messaging.NewFormHandler(formService)
-	host.RegisterHandler("FILL_FORM", formHandler)
-	host.RegisterHandler("TRAIN_FORM", formHandler)
-	host.RegisterHandler("FORMS_DETECTED", formHandler)
-	host.RegisterHandler("TRAINING_DATA", formHandler)
+	templateManager, err := automation.NewStoredTemplateManager(templatesDir, db)
+	if err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("failed to load templates: %w", err)
+	}
 
+	formService := services.NewFormService(profileService, templateManager, automation.NewFormDetector(nil, nil))
+	statusService := services.NewStatusService(nativeHostVersion, profileService, templateManager)
+	executionService := services.NewExecutionService(feedDir)
+
+	handshakeHandler := messaging.NewHandshakeHandler(nativeHostVersion)
+	profileHandler := messaging.NewProfileHandler(profileService)
+	formHandler := messaging.NewFormHandler(formService)
 	statusHandler := messaging.NewStatusHandler(statusService)
-	host.RegisterHandler("GET_STATUS", statusHandler)
-	host.RegisterHandler("OPEN_CLI_DASHBOARD", statusHandler)
+
+	register := func(host *messaging.NativeHost) {
+		host.RegisterHandler("HANDSHAKE", handshakeHandler)
+
+		// Wrong pairing codes are counted per connection, so each has its own
+		pairingHandler := messaging.NewPairingHandler(pairings)
+		host.RegisterHandler("PAIR", pairingHandler)
+		host.RegisterHandler("AUTHENTICATE", pairingHandler)
+
+		host.RegisterHandler("GET_PROFILES", profileHandler)
+		host.RegisterHandler("CREATE_PROFILE", profileHandler)
+		host.RegisterHandler("UPDATE_PROFILE", profileHandler)
+		host.RegisterHandler("DELETE_PROFILE", profileHandler)
+
+		host.RegisterHandler("FILL_FORM", formHandler)
+		host.RegisterHandler("TRAIN_FORM", formHandler)
+		host.RegisterHandler("FORMS_DETECTED", formHandler)
+		host.RegisterHandler("TRAINING_DATA", formHandler)
+
+		host.RegisterHandler("GET_STATUS", statusHandler)
+		host.RegisterHandler("OPEN_CLI_DASHBOARD", statusHandler)
+
+		// Subscriptions are named by request ID, so each connection has its own
+		executionHandler := messaging.NewExecutionHandler(executionService)
+		host.RegisterHandler("SUBSCRIBE_EXECUTIONS", executionHandler)
+		host.RegisterHandler("UNSUBSCRIBE_EXECUTIONS", executionHandler)
+	}
+
+	cleanup := func() {
+		formService.Close()
+		db.Close()
+	}
+	return register, cleanup, nil
+}
+
+// executionFeedDir returns where execution sessions publish their progress
+// for the native messaging host
+func executionFeedDir() string {
+	return filepath.Join(filepath.Dir(storage.DefaultDatabaseConfig().DatabasePath), "executions")
+}
+
+// defaultTemplatesDir returns the template history directory next to the
+// default database; the browser starts the host in an unknown directory
+func defaultTemplatesDir() string {
+	return filepath.Join(filepath.Dir(storage.DefaultDatabaseConfig().DatabasePath), "templates")
+}
+
+// nativeMessagingLogPath returns where hosts write their log
+func nativeMessagingLogPath() string {
+	return filepath.Join(filepath.Dir(storage.DefaultDatabaseConfig().DatabasePath), "logs", "native-messaging.log")
+}
+
+// pairingDir returns where pairing codes and paired extensions are kept
+func pairingDir() string {
+	return filepath.Join(filepath.Dir(storage.DefaultDatabaseConfig().DatabasePath), "pairing")
+}
+
+// hostLauncherPath returns where the launcher script the manifests point at is written
+func hostLauncherPath() string {
+	return filepath.Join(filepath.Dir(storage.DefaultDatabaseConfig().DatabasePath), "native-messaging-host")
+}
+
+// nativeMessagingTargets returns the browsers to register with. Without
+// --browser, only browsers whose configuration directory exists are used.
+func nativeMessagingTargets(detect bool) ([]messaging.Browser, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("registering the native messaging host is only supported on Linux")
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	browsers := messaging.LinuxBrowsers(homeDir)
+	if len(nativeMessagingBrowsers) == 0 {
+		if !detect {
+			return browsers, nil
+		}
+		var detected []messaging.Browser
+		for _, browser := range browsers {
+			if _, err := os.Stat(filepath.Dir(browser.ManifestDir)); err == nil {
+				detected = append(detected, browser)
+			}
+		}
+		return detected, nil
+	}
+
+	var selected []messaging.Browser
+	for _, name := range nativeMessagingBrowsers {
+		found := false
+		for _, browser := range browsers {
+			if browser.Name == strings.ToLower(name) {
+				selected = append(selected, browser)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown browser %q: expected chrome, chromium, brave, edge or firefox", name)
+		}
+	}
+	return selected, nil
+}
+
+func runNativeMessagingInstall(cmd *cobra.Command, args []string) {
+	chromiumIDs, firefoxIDs, err := messaging.SplitExtensionIDs(nativeMessagingExtensionIDs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	browsers, err := nativeMessagingTargets(true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(browsers) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: No supported browser found; name one with --browser\n")
+		os.Exit(1)
+	}
+
+	binary, err := os.Executable()
+	if err == nil {
+		binary, err = filepath.EvalSymlinks(binary)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to locate the CLI binary: %v\n", err)
+		os.Exit(1)
+	}
+
+	launcherPath := hostLauncherPath()
+	launcher := messaging.HostLauncher(binary)
+
+	type manifestFile struct {
+		browser messaging.Browser
+		data    []byte
+	}
+	var manifests []manifestFile
+	for _, browser := range browsers {
+		manifest := messaging.NewHostManifest(browser, launcherPath, chromiumIDs, firefoxIDs)
+		if manifest == nil {
+			kind := "Chromium"
+			if browser.Firefox {
+				kind = "Firefox"
+			}
+			fmt.Printf("Skipping %s: no %s extension ID given\n", browser.Name, kind)
+			continue
+		}
+		data, err := manifest.Marshal()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		manifests = append(manifests, manifestFile{browser: browser, data: data})
+	}
+	if len(manifests) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: No manifest to install\n")
+		os.Exit(1)
+	}
+
+	if nativeMessagingDryRun {
+		fmt.Printf("Would write %s:\n%s\n", launcherPath, launcher)
+		for _, manifest := range manifests {
+			fmt.Printf("Would write %s (%s):\n%s\n", manifest.browser.ManifestPath(), manifest.browser.Name, manifest.data)
+		}
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(launcherPath), 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to create launcher directory: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(launcherPath, []byte(launcher), 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to write launcher: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Launcher: %s -> %s\n", launcherPath, binary)
+
+	for _, manifest := range manifests {
+		path := manifest.browser.ManifestPath()
+		if err := os.MkdirAll(manifest.browser.ManifestDir, 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to create %s: %v\n", manifest.browser.ManifestDir, err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(path, manifest.data, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to write manifest: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Installed for %s: %s\n", manifest.browser.Name, path)
+	}
+	fmt.Println("Restart the browser if it was running so it finds the host.")
 }
 
-// Service implementations (these would be replaced with actual implementations)
+func runNativeMessagingUninstall(cmd *cobra.Command, args []string) {
+	browsers, err := nativeMessagingTargets(false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	verb := "Removed"
+	if nativeMessagingDryRun {
+		verb = "Would remove"
+	}
 
-type ProfileServiceImpl struct{}
+	removed := 0
+	removedPaths := make(map[string]bool)
+	for _, browser := range browsers {
+		path := browser.ManifestPath()
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if !nativeMessagingDryRun {
+			if err := os.Remove(path); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: Failed to remove %s: %v\n", path, err)
+				os.Exit(1)
+			}
+		}
+		fmt.Printf("%s %s (%s)\n", verb, path, browser.Name)
+		removedPaths[path] = true
+		removed++
+	}
 
-func (s *ProfileServiceImpl) GetProfiles() ([]interface{}, error) {
-	// TODO: Implement actual profile retrieval
-	return []interface{}{
-		map[string]interface{}{
-			"id":   "profile1",
-			"name": "Personal Profile",
-			"personalData": map[string]interface{}{
-				"firstName": "John",
-				"lastName":  "Doe",
-				"email":     "john.doe@example.com",
-			},
-		},
-	}, nil
+	// Keep the launcher while another browser's manifest still uses it
+	homeDir, _ := os.UserHomeDir()
+	for _, browser := range messaging.LinuxBrowsers(homeDir) {
+		path := browser.ManifestPath()
+		if _, err := os.Stat(path); err == nil && !removedPaths[path] {
+			return
+		}
+	}
+
+	launcherPath := hostLauncherPath()
+	if _, err := os.Stat(launcherPath); err == nil {
+		if !nativeMessagingDryRun {
+			if err := os.Remove(launcherPath); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: Failed to remove %s: %v\n", launcherPath, err)
+				os.Exit(1)
+			}
+		}
+		fmt.Printf("%s %s\n", verb, launcherPath)
+		removed++
+	}
+
+	if removed == 0 {
+		fmt.Println("The native messaging host is not installed")
+	}
 }
 
-func (s *ProfileServiceImpl) CreateProfile(data map[string]interface{}) (interface{}, error) {
-	// TODO: Implement actual profile creation
-	profile := map[string]interface{}{
-		"id":        fmt.Sprintf("profile_%d", time.Now().Unix()),
-		"name":      data["name"],
-		"personalData": data["personalData"],
-		"createdAt": time.Now(),
-		"updatedAt": time.Now(),
+func runNativeMessagingStatus(cmd *cobra.Command, args []string) {
+	browsers, err := nativeMessagingTargets(false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Host: %s\n\n", messaging.HostName)
+	for _, browser := range browsers {
+		path := browser.ManifestPath()
+		manifest, err := messaging.ReadHostManifest(path)
+		switch {
+		case os.IsNotExist(err):
+			fmt.Printf("%-9s not installed\n", browser.Name)
+			continue
+		case err != nil:
+			fmt.Printf("%-9s broken: %v\n", browser.Name, err)
+			continue
+		}
+
+		fmt.Printf("%-9s installed: %s\n", browser.Name, path)
+		allowed := manifest.AllowedOrigins
+		if browser.Firefox {
+			allowed = manifest.AllowedExtensions
+		}
+		fmt.Printf("          allowed: %s\n", strings.Join(allowed, ", "))
+
+		if info, err := os.Stat(manifest.Path); err != nil {
+			fmt.Printf("          host: %s (missing)\n", manifest.Path)
+		} else if info.Mode()&0111 == 0 {
+			fmt.Printf("          host: %s (not executable)\n", manifest.Path)
+		} else {
+			fmt.Printf("          host: %s\n", manifest.Path)
+		}
 	}
-	return profile, nil
 }
 
-func (s *ProfileServiceImpl) UpdateProfile(data map[string]interface{}) (interface{}, error) {
-	// TODO: Implement actual profile update
-	data["updatedAt"] = time.Now()
-	return data, nil
+func runNativeMessagingSchema(cmd *cobra.Command, args []string) {
+	var output []byte
+	switch nativeMessagingSchemaFormat {
+	case "json":
+		data, err := json.MarshalIndent(messaging.ProtocolSchema(), "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to marshal schema: %v\n", err)
+			os.Exit(1)
+		}
+		output = append(data, '\n')
+	case "ts":
+		output = []byte(messaging.ProtocolTypeScript())
+	default:
+		fmt.Fprintf(os.Stderr, "Error: Unknown format %q: expected json or ts\n", nativeMessagingSchemaFormat)
+		os.Exit(1)
+	}
+
+	if nativeMessagingSchemaOutput == "" {
+		os.Stdout.Write(output)
+		return
+	}
+	if err := os.WriteFile(nativeMessagingSchemaOutput, output, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to write schema: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %s\n", nativeMessagingSchemaOutput)
 }
 
-func (s *ProfileServiceImpl) DeleteProfile(profileID string) error {
-	// TODO: Implement actual profile deletion
-	return nil
+func runNativeMessagingPair(cmd *cobra.Command, args []string) {
+	if nativeMessagingPairTTL <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: --ttl must be positive\n")
+		os.Exit(1)
+	}
+
+	code, expiresAt, err := messaging.NewPairingStore(pairingDir()).CreateCode(nativeMessagingPairName, nativeMessagingPairTTL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Pairing code: %s\n", code)
+	fmt.Printf("Enter it in the browser extension before %s. It works once.\n", expiresAt.Format("15:04:05"))
 }
 
-func (s *ProfileServiceImpl) GetProfile(profileID string) (interface{}, error) {
-	// TODO: Implement actual profile retrieval
-	return map[string]interface{}{
-		"id":   profileID,
-		"name": "Sample Profile",
-	}, nil
+func runNativeMessagingPairings(cmd *cobra.Command, args []string) {
+	pairings, err := messaging.NewPairingStore(pairingDir()).List()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(pairings) == 0 {
+		fmt.Println("No paired extensions. Pair one with 'native-messaging pair'.")
+		return
+	}
+
+	fmt.Printf("%-16s %-20s %-20s %-20s %s\n", "ID", "NAME", "PAIRED", "LAST USED", "EXTENSION")
+	for _, pairing := range pairings {
+		name := pairing.Name
+		if name == "" {
+			name = "-"
+		}
+		origin := pairing.Origin
+		if origin == "" {
+			origin = "(unknown)"
+		}
+		fmt.Printf("%-16s %-20.20s %-20s %-20s %s\n", pairing.ID, name,
+			pairing.CreatedAt.Format("2006-01-02 15:04"), pairing.LastUsed.Format("2006-01-02 15:04"), origin)
+	}
 }
 
-type FormServiceImpl struct{}
+func runNativeMessagingUnpair(cmd *cobra.Command, args []string) {
+	store := messaging.NewPairingStore(pairingDir())
+
+	var ids []string
+	switch {
+	case nativeMessagingUnpairAll && len(args) == 0:
+		pairings, err := store.List()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, pairing := range pairings {
+			ids = append(ids, pairing.ID)
+		}
+	case !nativeMessagingUnpairAll && len(args) == 1:
+		ids = args
+	default:
+		fmt.Fprintf(os.Stderr, "Error: Give a pairing ID or --all\n")
+		os.Exit(1)
+	}
 
-func (s *FormServiceImpl) FillForm(data map[string]interface{}) (interface{}, error) {
-	// TODO: Implement actual form filling logic
-	return map[string]interface{}{
-		"status":       "completed",
-		"filledFields": 5,
-		"totalFields":  7,
-		"successRate":  71.4,
-		"timestamp":    time.Now(),
-	}, nil
+	for _, id := range ids {
+		if err := store.Revoke(id); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Revoked %s\n", id)
+	}
+	if len(ids) == 0 {
+		fmt.Println("No paired extensions")
+	}
 }
 
-func (s *FormServiceImpl) TrainForm(data map[string]interface{}) (interface{}, error) {
-	// TODO: Implement actual form training logic
-	return map[string]interface{}{
-		"status":        "training_started",
-		"formsAnalyzed": 1,
-		"timestamp":     time.Now(),
-	}, nil
+func runNativeMessagingReplay(cmd *cobra.Command, args []string) {
+	file, err := os.Open(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	frames, err := messaging.ReadRecording(file)
+	file.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	dataDir := nativeMessagingReplayDataDir
+	if dataDir == "" {
+		dataDir, err = os.MkdirTemp("", "ai-form-filler-replay-")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to create data directory: %v\n", err)
+			os.Exit(1)
+		}
+		defer os.RemoveAll(dataDir)
+	}
+	profilesDir := filepath.Join(dataDir, "profiles")
+	if err := os.MkdirAll(profilesDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to create data directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	register, cleanup, err := setupMessageHandlers(messaging.NewPairingStore(filepath.Join(dataDir, "pairing")),
+		profilesDir, filepath.Join(dataDir, "templates"), filepath.Join(dataDir, "forms.db"), filepath.Join(dataDir, "executions"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Handlers print warnings; keep them apart from the report
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+
+	config := messaging.DefaultReplayConfig()
+	config.StepTimeout = nativeMessagingReplayTimeout
+	config.Ignore = append(config.Ignore, nativeMessagingReplayIgnore...)
+	result, err := messaging.Replay(frames, func(input io.Reader, output io.Writer) *messaging.NativeHost {
+		host := messaging.NewNativeHostWithIO(nil, input, output)
+		register(host)
+		return host
+	}, config)
+	cleanup()
+	os.Stdout = stdout
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if nativeMessagingReplayJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(result)
+	} else {
+		for _, diff := range result.Diffs {
+			fmt.Println(diff)
+		}
+		fmt.Printf("Replayed %d requests: %d of %d messages alike", result.Requests, result.Messages-countMessageDiffs(result.Diffs), result.Messages)
+		if result.Skipped > 0 {
+			fmt.Printf(", %d pairing messages skipped", result.Skipped)
+		}
+		fmt.Println()
+	}
+
+	if len(result.Diffs) > 0 {
+		os.Exit(1)
+	}
 }
 
-func (s *FormServiceImpl) AnalyzeForms(data map[string]interface{}) (interface{}, error) {
-	// TODO: Implement actual form analysis logic
-	return map[string]interface{}{
-		"status":        "analyzed",
-		"formsDetected": len(data["forms"].([]interface{})),
-		"timestamp":     time.Now(),
-	}, nil
+// countMessageDiffs counts the replayed messages that differ from their
+// recording, however many fields differ
+func countMessageDiffs(diffs []messaging.ReplayDiff) int {
+	frames := make(map[int]bool)
+	for _, diff := range diffs {
+		if diff.Frame != 0 && diff.Path != "" {
+			frames[diff.Frame] = true
+		}
+	}
+	return len(frames)
 }
 
-func (s *FormServiceImpl) ProcessTrainingData(data map[string]interface{}) error {
-	// TODO: Implement actual training data processing
-	return nil
+func runNativeMessagingLogs(cmd *cobra.Command, args []string) {
+	minLevel, err := messaging.ParseLogLevel(nativeMessagingLogsLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Records below --level are skipped; lines that aren't records are printed as they are
+	printRecord := func(line []byte) bool {
+		if len(bytes.TrimSpace(line)) == 0 {
+			return false
+		}
+		level, text, ok := formatLogRecord(line)
+		if ok && level < minLevel {
+			return false
+		}
+		if nativeMessagingLogsJSON {
+			text = string(line)
+		}
+		fmt.Println(text)
+		return true
+	}
+
+	file, err := os.Open(nativeMessagingLogsFile)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Error: Failed to open log: %v\n", err)
+		os.Exit(1)
+	}
+	if file == nil && !nativeMessagingLogsFollow {
+		fmt.Printf("No log at %s yet\n", nativeMessagingLogsFile)
+		return
+	}
+
+	if file != nil {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to read log: %v\n", err)
+			os.Exit(1)
+		}
+
+		// A record being written when the log was read is printed by --follow
+		pending := []byte{}
+		if end := bytes.LastIndexByte(data, '\n'); end < len(data)-1 {
+			pending = data[end+1:]
+			data = data[:end+1]
+		}
+		lines := bytes.Split(data, []byte("\n"))
+		var shown [][]byte
+		for i := len(lines) - 1; i >= 0 && len(shown) < nativeMessagingLogsLines; i-- {
+			if level, _, ok := formatLogRecord(lines[i]); len(bytes.TrimSpace(lines[i])) > 0 && (!ok || level >= minLevel) {
+				shown = append(shown, lines[i])
+			}
+		}
+		for i := len(shown) - 1; i >= 0; i-- {
+			printRecord(shown[i])
+		}
+
+		if !nativeMessagingLogsFollow {
+			file.Close()
+			return
+		}
+		followLog(nativeMessagingLogsFile, file, pending, printRecord)
+		return
+	}
+	followLog(nativeMessagingLogsFile, nil, nil, printRecord)
 }
 
-type StatusServiceImpl struct{}
+// followLog prints the records appended to a log until interrupted. A host
+// rotating the log renames it, so the file at path is reopened when it
+// changes, once the rest of the renamed one is read.
+func followLog(path string, file *os.File, pending []byte, printRecord func([]byte) bool) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	buffer := make([]byte, 64*1024)
+	drain := func() {
+		for file != nil {
+			n, err := file.Read(buffer)
+			pending = append(pending, buffer[:n]...)
+			if n == 0 || err != nil {
+				break
+			}
+		}
+		for {
+			end := bytes.IndexByte(pending, '\n')
+			if end < 0 {
+				return
+			}
+			printRecord(pending[:end])
+			pending = pending[end+1:]
+		}
+	}
+
+	for {
+		drain()
+		if info, err := os.Stat(path); err == nil {
+			if current, err := statFile(file); err != nil || !os.SameFile(info, current) {
+				drain()
+				if file != nil {
+					file.Close()
+				}
+				file, pending = nil, nil
+				if file, err = os.Open(path); err != nil {
+					file = nil
+				}
+				continue
+			}
+		}
+
+		select {
+		case <-sigChan:
+			if file != nil {
+				file.Close()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
 
-func (s *StatusServiceImpl) GetStatus() (interface{}, error) {
-	return map[string]interface{}{
-		"status":    "running",
-		"version":   "1.0.0",
-		"uptime":    time.Since(time.Now().Add(-time.Hour)).Seconds(),
-		"timestamp": time.Now(),
-	}, nil
+// statFile returns the FileInfo of an open file
+func statFile(file *os.File) (os.FileInfo, error) {
+	if file == nil {
+		return nil, os.ErrNotExist
+	}
+	return file.Stat()
 }
 
-func (s *StatusServiceImpl) OpenDashboard() error {
-	// TODO: Implement dashboard opening logic
-	return nil
-}
\ No newline at end of file
+// formatLogRecord renders a JSON log record as one line: time, level,
+// message and the record's other attributes in the order they were
+// written. ok is false for lines that aren't records.
+func formatLogRecord(line []byte) (level slog.Level, text string, ok bool) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return 0, string(line), false
+	}
+
+	var when time.Time
+	var message string
+	var attrs []string
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return 0, string(line), false
+		}
+		key, _ := token.(string)
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return 0, string(line), false
+		}
+
+		switch key {
+		case slog.TimeKey:
+			json.Unmarshal(value, &when)
+		case slog.LevelKey:
+			json.Unmarshal(value, &level)
+		case slog.MessageKey:
+			json.Unmarshal(value, &message)
+		default:
+			var s string
+			if json.Unmarshal(value, &s) == nil && s != "" && !strings.ContainsAny(s, " \t\"=") {
+				attrs = append(attrs, key+"="+s)
+			} else {
+				attrs = append(attrs, key+"="+string(value))
+			}
+		}
+	}
+
+	text = fmt.Sprintf("%s %-5s %s", when.Local().Format("2006-01-02 15:04:05.000"), level, message)
+	if len(attrs) > 0 {
+		text += " " + strings.Join(attrs, " ")
+	}
+	return level, text, true
+}
@@ -0,0 +1,526 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ai-form-filler/cli/internal/automation"
+	"github.com/ai-form-filler/cli/internal/storage"
+)
+
+var (
+	templatesDir        string
+	templatesDatabase   string
+	templatesRollbackTo string
+	templatesDomain     string
+	templatesMarkStale  bool
+	templatesHeadless   bool
+	templatesOutput     string
+)
+
+// templatesCmd represents the templates command
+var templatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "Manage form templates",
+	Long: `Manage the form templates used to fill known sites.
+
+Templates are kept in the form_templates table of the database. JSON templates
+left in the templates directory are imported the first time it is used.
+
+Every change to a template's fields or selectors is saved as a new version
+together with what made the change (detector, heal, manual, import or
+extension) and why.`,
+}
+
+// templatesHistoryCmd lists the versions of a template
+var templatesHistoryCmd = &cobra.Command{
+	Use:   "history <template-id>",
+	Short: "Show the version history of a template",
+	Args:  cobra.ExactArgs(1),
+	Run:   runTemplatesHistory,
+}
+
+// templatesDiffCmd compares two versions of a template
+var templatesDiffCmd = &cobra.Command{
+	Use:     "diff <template-id> <from-version> <to-version>",
+	Short:   "Show field and selector changes between two template versions",
+	Example: `  ai-form-filler templates diff template_example_com_registration v3 v5`,
+	Args:    cobra.ExactArgs(3),
+	Run:     runTemplatesDiff,
+}
+
+// templatesRollbackCmd restores an earlier version of a template
+var templatesRollbackCmd = &cobra.Command{
+	Use:   "rollback <template-id> --to <version>",
+	Short: "Restore an earlier version of a template",
+	Long: `Restore the fields and selectors of an earlier template version.
+
+The restored template is saved as a new version, so the rollback itself can be
+undone with another rollback.`,
+	Example: `  ai-form-filler templates rollback template_example_com_registration --to v3`,
+	Args:    cobra.ExactArgs(1),
+	Run:     runTemplatesRollback,
+}
+
+// templatesCheckCmd reports templates whose forms changed on the live site
+var templatesCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check templates for drift against their live forms",
+	Long: `Load each template's page, detect its form again and compare it with the
+stored template. Missing fields, new required fields, changed field types and
+selectors that no longer match are reported with a severity.
+
+With --mark-stale, templates with high severity drift are marked stale so they
+are no longer picked automatically, and templates without drift are cleared.`,
+	Example: `  ai-form-filler templates check --domain example.com --mark-stale`,
+	Args:    cobra.NoArgs,
+	Run:     runTemplatesCheck,
+}
+
+// templatesMatchCmd explains which template would be used for a URL
+var templatesMatchCmd = &cobra.Command{
+	Use:   "match <url>",
+	Short: "Show which template would be chosen for a URL and why",
+	Long: `List every template that matches a URL, best first, and explain the choice.
+
+A template learned on the exact URL wins, then the most specific URL pattern,
+then templates without patterns on the same host. Templates whose recent runs
+mostly failed rank after healthy ones. Ties go to the higher score and then the
+most recently updated template. Stale templates are listed but never chosen.`,
+	Example: `  ai-form-filler templates match "https://shop.example.com/account/billing?tab=cards"`,
+	Args:    cobra.ExactArgs(1),
+	Run:     runTemplatesMatch,
+}
+
+// templatesShowCmd prints a template with its run statistics
+var templatesShowCmd = &cobra.Command{
+	Use:   "show <template-id>",
+	Short: "Show a template with its success statistics",
+	Long: `Show a template's fields together with how it has performed: attempts,
+complete fills, submissions, verified submissions, the decayed score used to
+rank templates and how often each field failed to fill.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runTemplatesShow,
+}
+
+// templatesValidateCmd checks YAML templates without saving them
+var templatesValidateCmd = &cobra.Command{
+	Use:   "validate <file.yaml>...",
+	Short: "Validate hand-written YAML templates",
+	Long: `Check YAML templates for unknown keys, wrong value types, missing selectors,
+unknown profile bindings or transforms, steps that reference missing fields and
+invalid patterns. Every problem is reported with its line and column.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runTemplatesValidate,
+}
+
+// templatesImportCmd saves YAML templates into the template store
+var templatesImportCmd = &cobra.Command{
+	Use:   "import <file.yaml>...",
+	Short: "Import YAML templates",
+	Args:  cobra.MinimumNArgs(1),
+	Run:   runTemplatesImport,
+}
+
+// templatesExportCmd renders a stored template as YAML
+var templatesExportCmd = &cobra.Command{
+	Use:   "export <template-id>",
+	Short: "Export a template as YAML for editing",
+	Args:  cobra.ExactArgs(1),
+	Run:   runTemplatesExport,
+}
+
+func init() {
+	rootCmd.AddCommand(templatesCmd)
+	templatesCmd.AddCommand(templatesHistoryCmd)
+	templatesCmd.AddCommand(templatesDiffCmd)
+	templatesCmd.AddCommand(templatesRollbackCmd)
+	templatesCmd.AddCommand(templatesCheckCmd)
+	templatesCmd.AddCommand(templatesMatchCmd)
+	templatesCmd.AddCommand(templatesShowCmd)
+	templatesCmd.AddCommand(templatesValidateCmd)
+	templatesCmd.AddCommand(templatesImportCmd)
+	templatesCmd.AddCommand(templatesExportCmd)
+
+	templatesCmd.PersistentFlags().StringVar(&templatesDir, "dir", defaultTemplatesDir(), "Directory for template history; JSON templates found here are imported once")
+	templatesCmd.PersistentFlags().StringVar(&templatesDatabase, "db", storage.DefaultDatabaseConfig().DatabasePath, "Database holding form templates")
+	templatesRollbackCmd.Flags().StringVar(&templatesRollbackTo, "to", "", "Version to restore, e.g. v3")
+	templatesRollbackCmd.MarkFlagRequired("to")
+	templatesCheckCmd.Flags().StringVar(&templatesDomain, "domain", "", "Only check templates for this domain")
+	templatesCheckCmd.Flags().BoolVar(&templatesMarkStale, "mark-stale", false, "Mark templates with breaking drift as stale")
+	templatesCheckCmd.Flags().BoolVar(&templatesHeadless, "headless", true, "Run the browser in headless mode")
+	templatesExportCmd.Flags().StringVarP(&templatesOutput, "output", "o", "", "Write to a file instead of stdout")
+}
+
+// openTemplateStore opens the template database or exits on failure. The
+// caller closes the database.
+func openTemplateStore() (*storage.DatabaseManager, *automation.TemplateManager) {
+	db, err := storage.NewDatabaseManager(&storage.DatabaseConfig{
+		DatabasePath: templatesDatabase,
+		CreateTables: true,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to open database: %v\n", err)
+		os.Exit(1)
+	}
+
+	templateManager, err := automation.NewStoredTemplateManager(templatesDir, db)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to load templates: %v\n", err)
+		os.Exit(1)
+	}
+	return db, templateManager
+}
+
+func runTemplatesHistory(cmd *cobra.Command, args []string) {
+	db, templateManager := openTemplateStore()
+	defer db.Close()
+
+	history, err := templateManager.TemplateHistory(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(history) == 0 {
+		fmt.Printf("No history recorded for template %s\n", args[0])
+		return
+	}
+
+	current, _ := templateManager.LoadTemplate(args[0])
+
+	fmt.Printf("History for template %s:\n\n", args[0])
+	fmt.Printf("%-8s %-20s %-10s %-7s %s\n", "VERSION", "SAVED", "AUTHOR", "FIELDS", "REASON")
+	for _, revision := range history {
+		marker := " "
+		if current != nil && current.Version == revision.Version {
+			marker = "*"
+		}
+
+		fmt.Printf("%s%-7s %-20s %-10s %-7d %s\n",
+			marker,
+			fmt.Sprintf("v%d", revision.Version),
+			revision.SavedAt.Format("2006-01-02 15:04:05"),
+			revision.Author,
+			len(revision.Template.Fields),
+			revision.Reason,
+		)
+	}
+}
+
+func runTemplatesDiff(cmd *cobra.Command, args []string) {
+	db, templateManager := openTemplateStore()
+	defer db.Close()
+
+	fromVersion, err := automation.ParseTemplateVersion(args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	toVersion, err := automation.ParseTemplateVersion(args[2])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	from, err := templateManager.LoadTemplateRevision(args[0], fromVersion)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	to, err := templateManager.LoadTemplateRevision(args[0], toVersion)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	diff := automation.DiffTemplates(from.Template, to.Template)
+
+	fmt.Printf("Template %s: v%d -> v%d\n", args[0], diff.FromVersion, diff.ToVersion)
+	if len(diff.Changes) == 0 {
+		fmt.Println("No field or selector changes")
+		return
+	}
+
+	for _, change := range diff.Changes {
+		field := change.Field
+		if field == "" {
+			field = "(template)"
+		}
+
+		switch change.Kind {
+		case automation.ChangeAdded:
+			fmt.Printf("  + %s: %s\n", field, change.New)
+		case automation.ChangeRemoved:
+			fmt.Printf("  - %s: %s\n", field, change.Old)
+		default:
+			fmt.Printf("  ~ %s %s: %q -> %q\n", field, change.Property, change.Old, change.New)
+		}
+	}
+}
+
+func runTemplatesRollback(cmd *cobra.Command, args []string) {
+	db, templateManager := openTemplateStore()
+	defer db.Close()
+
+	version, err := automation.ParseTemplateVersion(templatesRollbackTo)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	restored, err := templateManager.RollbackTemplate(args[0], version)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to roll back template: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Restored template %s to v%d (saved as v%d)\n", args[0], version, restored.Version)
+}
+
+func runTemplatesCheck(cmd *cobra.Command, args []string) {
+	db, templateManager := openTemplateStore()
+	defer db.Close()
+
+	templates, err := templateManager.FindTemplates(automation.TemplateSearchCriteria{Domain: templatesDomain})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(templates) == 0 {
+		fmt.Println("No templates to check")
+		return
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].ID < templates[j].ID
+	})
+
+	browserConfig := automation.DefaultBrowserConfig()
+	browserConfig.Headless = templatesHeadless
+
+	browserManager, err := automation.NewBrowserManager(browserConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to initialize browser manager: %v\n", err)
+		os.Exit(1)
+	}
+	defer browserManager.Close()
+
+	formDetector := automation.NewFormDetector(browserManager, nil)
+	ctx := context.Background()
+
+	drifted := 0
+	for _, template := range templates {
+		fmt.Printf("%s (v%d) %s\n", template.ID, template.Version, template.URL)
+
+		report, err := formDetector.CheckTemplateDrift(ctx, template)
+		if err != nil {
+			fmt.Printf("  check failed: %v\n\n", err)
+			continue
+		}
+
+		if len(report.Issues) == 0 {
+			fmt.Printf("  no drift\n")
+		} else {
+			drifted++
+			fmt.Printf("  drift severity: %s\n", report.Severity)
+			for _, issue := range report.Issues {
+				field := issue.Field
+				if field == "" {
+					field = "(form)"
+				}
+				fmt.Printf("  [%s] %s %s: %s\n", issue.Severity, issue.Kind, field, issue.Detail)
+			}
+		}
+
+		if templatesMarkStale {
+			stale := report.Breaking()
+			if stale != template.Stale {
+				if err := templateManager.MarkTemplateStale(template.ID, stale); err != nil {
+					fmt.Printf("  failed to update stale flag: %v\n", err)
+				} else if stale {
+					fmt.Printf("  marked stale\n")
+				} else {
+					fmt.Printf("  no longer stale\n")
+				}
+			}
+		}
+
+		fmt.Println()
+	}
+
+	fmt.Printf("Checked %d templates, %d drifted\n", len(templates), drifted)
+}
+
+func runTemplatesValidate(cmd *cobra.Command, args []string) {
+	failed := false
+	for _, path := range args {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			failed = true
+			continue
+		}
+
+		errs := automation.ValidateTemplateYAML(data)
+		if len(errs) == 0 {
+			fmt.Printf("%s: ok\n", path)
+			continue
+		}
+
+		failed = true
+		for _, err := range errs {
+			if err.Path == "" {
+				fmt.Printf("%s:%d:%d: %s\n", path, err.Line, err.Column, err.Message)
+			} else {
+				fmt.Printf("%s:%d:%d: %s: %s\n", path, err.Line, err.Column, err.Path, err.Message)
+			}
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func runTemplatesImport(cmd *cobra.Command, args []string) {
+	db, templateManager := openTemplateStore()
+	defer db.Close()
+
+	for _, path := range args {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		template, err := templateManager.ImportTemplateYAML(data, filepath.Base(path))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to import %s:\n%v\n", path, err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Imported %s as template %s v%d\n", path, template.ID, template.Version)
+	}
+}
+
+func runTemplatesExport(cmd *cobra.Command, args []string) {
+	db, templateManager := openTemplateStore()
+	defer db.Close()
+
+	template, err := templateManager.LoadTemplate(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	data, err := automation.MarshalTemplateYAML(template)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if templatesOutput == "" {
+		fmt.Print(string(data))
+		return
+	}
+
+	if err := ioutil.WriteFile(templatesOutput, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to write %s: %v\n", templatesOutput, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Exported template %s to %s\n", template.ID, templatesOutput)
+}
+
+func runTemplatesMatch(cmd *cobra.Command, args []string) {
+	db, templateManager := openTemplateStore()
+	defer db.Close()
+
+	matches, err := templateManager.MatchTemplates(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(matches) == 0 {
+		fmt.Printf("No template matches %s\n", args[0])
+		os.Exit(1)
+	}
+
+	fmt.Printf("Templates matching %s:\n\n", args[0])
+	fmt.Printf("%-4s %-40s %-10s %-6s %-20s %s\n", "RANK", "TEMPLATE", "MATCH", "SCORE", "UPDATED", "REASON")
+	for i, match := range matches {
+		marker := " "
+		if match.Chosen {
+			marker = "*"
+		}
+
+		fmt.Printf("%s%-3d %-40s %-10s %-6s %-20s %s\n",
+			marker,
+			i+1,
+			match.TemplateID,
+			match.Kind,
+			fmt.Sprintf("%.0f%%", match.Score*100),
+			match.Template.LastUpdated.Format("2006-01-02 15:04:05"),
+			match.Reason,
+		)
+	}
+}
+
+func runTemplatesShow(cmd *cobra.Command, args []string) {
+	db, templateManager := openTemplateStore()
+	defer db.Close()
+
+	template, err := templateManager.LoadTemplate(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	now := time.Now()
+	fmt.Printf("Template:      %s\n", template.ID)
+	fmt.Printf("URL:           %s\n", template.URL)
+	fmt.Printf("Form type:     %s\n", template.FormType)
+	fmt.Printf("Version:       v%d\n", template.Version)
+	fmt.Printf("Updated:       %s\n", template.LastUpdated.Format("2006-01-02 15:04:05"))
+	if template.Stale {
+		fmt.Printf("Stale:         yes\n")
+	}
+	fmt.Printf("Score:         %.0f%%", template.Score(now)*100)
+	if template.Failing(now) {
+		fmt.Printf(" (recent outcomes poor, ranked after healthy templates)")
+	}
+	fmt.Println()
+
+	stats := template.Stats
+	if stats == nil || stats.Attempts == 0 {
+		fmt.Printf("Runs:          none recorded\n")
+		stats = &automation.TemplateStats{}
+	} else {
+		fmt.Printf("Attempts:      %d\n", stats.Attempts)
+		fmt.Printf("Full fills:    %d\n", stats.Fills)
+		fmt.Printf("Submissions:   %d (%d verified)\n", stats.Submissions, stats.VerifiedSubmissions)
+		fmt.Printf("Last outcome:  %s\n", stats.LastOutcome.Format("2006-01-02 15:04:05"))
+	}
+
+	fmt.Printf("\n%-25s %-12s %-40s %-9s %s\n", "FIELD", "TYPE", "SELECTOR", "FAILURES", "RATE")
+	for _, field := range template.Fields {
+		fmt.Printf("%-25s %-12s %-40s %-9d %s\n",
+			field.Name,
+			field.Type,
+			field.Selector,
+			stats.FieldFailures[field.Name],
+			fmt.Sprintf("%.0f%%", stats.FieldFailureRate(field.Name)*100),
+		)
+	}
+}
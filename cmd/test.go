@@ -7,6 +7,7 @@ import (
 
 	"github.com/ai-form-filler/cli/internal/automation"
 	"github.com/ai-form-filler/cli/internal/models"
+	"github.com/ai-form-filler/cli/internal/storage"
 	"github.com/spf13/cobra"
 )
 
@@ -21,11 +22,15 @@ func init() {
 	rootCmd.AddCommand(testCmd)
 	testCmd.Flags().StringP("url", "u", "file://./test-form.html", "URL to test")
 	testCmd.Flags().BoolP("headless", "h", false, "Run in headless mode")
+	testCmd.Flags().Bool("submit", false, "Submit the filled form and check that the submission succeeded")
+	testCmd.Flags().Bool("verify-submission", true, "Wait for the template's success rules after submitting")
 }
 
 func runTest(cmd *cobra.Command, args []string) {
 	url, _ := cmd.Flags().GetString("url")
 	headless, _ := cmd.Flags().GetBool("headless")
+	submit, _ := cmd.Flags().GetBool("submit")
+	verifySubmission, _ := cmd.Flags().GetBool("verify-submission")
 
 	fmt.Printf("Testing form filling on: %s\n", url)
 
@@ -90,15 +95,17 @@ func runTest(cmd *cobra.Command, args []string) {
 			},
 		}
 
-		// Create template manager and profile form filler
-		templateManager, err := automation.NewTemplateManager("./templates")
-		if err != nil {
-			log.Printf("Warning: Failed to create template manager: %v", err)
-			return
-		}
+		// Create template manager and profile form filler. Templates live in
+		// the database the templates command and the extension read.
+		db, templateManager := openTemplateStore()
+		defer db.Close()
 
 		formFiller := automation.NewFormFiller(browserManager, nil)
-		profileFormFiller := automation.NewProfileFormFiller(formFiller, formDetector, templateManager, nil)
+		fillerConfig := automation.DefaultProfileFormFillerConfig()
+		fillerConfig.Submit = submit
+		fillerConfig.VerifySubmission = verifySubmission
+		profileFormFiller := automation.NewProfileFormFiller(formFiller, formDetector, templateManager, fillerConfig)
+		profileFormFiller.SetLearningStore(storage.NewLearningSessionStore(db))
 
 		// Test filling
 		result, err := profileFormFiller.FillFormWithProfile(ctx, url, testProfile)
@@ -117,5 +124,22 @@ func runTest(cmd *cobra.Command, args []string) {
 		if len(result.Errors) > 0 {
 			fmt.Printf("- Errors: %v\n", result.Errors)
 		}
+
+		if len(result.VerificationErrors) > 0 {
+			fmt.Printf("- Not submitted: %v\n", result.VerificationErrors)
+		}
+		if submission := result.SubmissionResult; submission != nil {
+			if submission.Verified {
+				fmt.Printf("- Submitted: success=%v after %v\n", submission.Success, submission.SubmissionTime)
+			} else {
+				fmt.Printf("- Submitted without verification after %v\n", submission.SubmissionTime)
+			}
+			if len(submission.SuccessIndicators) > 0 {
+				fmt.Printf("- Success indicators: %v\n", submission.SuccessIndicators)
+			}
+			if len(submission.ErrorMessages) > 0 {
+				fmt.Printf("- Submission errors: %v\n", submission.ErrorMessages)
+			}
+		}
 	}
 }
\ No newline at end of file
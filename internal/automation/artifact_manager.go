@@ -0,0 +1,243 @@
+package automation
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Artifact stages for screenshots captured around a fill
+const (
+	ArtifactStageBeforeFill = "before-fill"
+	ArtifactStageAfterFill  = "after-fill"
+)
+
+// ArtifactManager stores debugging artifacts (screenshots, traces, HAR files)
+// per execution session and URL under a predictable directory layout:
+//
+//	<baseDir>/<session>/<url-slug>/before-fill.png
+//	<baseDir>/<session>/<url-slug>/after-fill.png
+//	<baseDir>/<session>/<url-slug>/trace.zip
+//	<baseDir>/<session>/<url-slug>/network.har
+type ArtifactManager struct {
+	config *ArtifactConfig
+	mutex  sync.Mutex
+}
+
+// ArtifactConfig holds configuration for artifact capture and retention
+type ArtifactConfig struct {
+	BaseDir      string        `json:"baseDir"`
+	CaptureTrace bool          `json:"captureTrace"`
+	CaptureHAR   bool          `json:"captureHar"`
+	MaxSessions  int           `json:"maxSessions"`
+	MaxAge       time.Duration `json:"maxAge"`
+	MaxTotalMB   int64         `json:"maxTotalMb"`
+}
+
+// URLArtifacts lists the artifact paths recorded for a single URL
+type URLArtifacts struct {
+	Dir              string `json:"dir"`
+	BeforeScreenshot string `json:"beforeScreenshot,omitempty"`
+	AfterScreenshot  string `json:"afterScreenshot,omitempty"`
+	Trace            string `json:"trace,omitempty"`
+	HAR              string `json:"har,omitempty"`
+}
+
+// DefaultArtifactConfig returns sensible defaults
+func DefaultArtifactConfig() *ArtifactConfig {
+	homeDir, _ := os.UserHomeDir()
+
+	return &ArtifactConfig{
+		BaseDir:      filepath.Join(homeDir, ".ai-form-filler", "artifacts"),
+		CaptureTrace: false,
+		CaptureHAR:   false,
+		MaxSessions:  50,
+		MaxAge:       7 * 24 * time.Hour,
+		MaxTotalMB:   1024,
+	}
+}
+
+// NewArtifactManager creates a new artifact manager
+func NewArtifactManager(config *ArtifactConfig) (*ArtifactManager, error) {
+	if config == nil {
+		config = DefaultArtifactConfig()
+	}
+
+	if err := os.MkdirAll(config.BaseDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+
+	return &ArtifactManager{config: config}, nil
+}
+
+// Config returns the artifact configuration
+func (am *ArtifactManager) Config() *ArtifactConfig {
+	return am.config
+}
+
+// SessionDir returns the artifact directory for a session
+func (am *ArtifactManager) SessionDir(sessionID string) string {
+	return filepath.Join(am.config.BaseDir, sanitizePathComponent(sessionID))
+}
+
+// PrepareURL creates the artifact directory for a URL and returns the paths
+// that artifacts for it should be written to
+func (am *ArtifactManager) PrepareURL(sessionID, pageURL string) (*URLArtifacts, error) {
+	dir := filepath.Join(am.SessionDir(sessionID), urlSlug(pageURL))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create artifact directory: %w", err)
+	}
+
+	artifacts := &URLArtifacts{Dir: dir}
+	if am.config.CaptureTrace {
+		artifacts.Trace = filepath.Join(dir, "trace.zip")
+	}
+	if am.config.CaptureHAR {
+		artifacts.HAR = filepath.Join(dir, "network.har")
+	}
+
+	return artifacts, nil
+}
+
+// ScreenshotPath returns the screenshot path for the given stage
+func (am *ArtifactManager) ScreenshotPath(artifacts *URLArtifacts, stage string) string {
+	return filepath.Join(artifacts.Dir, stage+".png")
+}
+
+// LoadURLArtifacts returns the artifacts that exist on disk for a session and URL
+func (am *ArtifactManager) LoadURLArtifacts(sessionID, pageURL string) (*URLArtifacts, error) {
+	dir := filepath.Join(am.SessionDir(sessionID), urlSlug(pageURL))
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("no artifacts found for %s: %w", pageURL, err)
+	}
+
+	artifacts := &URLArtifacts{Dir: dir}
+	existing := func(name string) string {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		return ""
+	}
+
+	artifacts.BeforeScreenshot = existing(ArtifactStageBeforeFill + ".png")
+	artifacts.AfterScreenshot = existing(ArtifactStageAfterFill + ".png")
+	artifacts.Trace = existing("trace.zip")
+	artifacts.HAR = existing("network.har")
+
+	return artifacts, nil
+}
+
+// ApplyRetention removes session directories that exceed the configured
+// count, age or total size limits, oldest first
+func (am *ArtifactManager) ApplyRetention() error {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	entries, err := os.ReadDir(am.config.BaseDir)
+	if err != nil {
+		return fmt.Errorf("failed to read artifacts directory: %w", err)
+	}
+
+	type sessionInfo struct {
+		path    string
+		modTime time.Time
+		size    int64
+	}
+
+	var sessions []sessionInfo
+	var totalSize int64
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		path := filepath.Join(am.config.BaseDir, entry.Name())
+		size := directorySize(path)
+		totalSize += size
+		sessions = append(sessions, sessionInfo{path: path, modTime: info.ModTime(), size: size})
+	}
+
+	// Newest first, so everything past the limits is at the tail
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].modTime.After(sessions[j].modTime)
+	})
+
+	maxBytes := am.config.MaxTotalMB * 1024 * 1024
+	cutoff := time.Now().Add(-am.config.MaxAge)
+
+	for i := len(sessions) - 1; i >= 0; i-- {
+		session := sessions[i]
+
+		expired := am.config.MaxAge > 0 && session.modTime.Before(cutoff)
+		overCount := am.config.MaxSessions > 0 && i >= am.config.MaxSessions
+		overSize := maxBytes > 0 && totalSize > maxBytes
+
+		if !expired && !overCount && !overSize {
+			continue
+		}
+
+		if err := os.RemoveAll(session.path); err != nil {
+			return fmt.Errorf("failed to remove artifacts %s: %w", session.path, err)
+		}
+		totalSize -= session.size
+	}
+
+	return nil
+}
+
+// directorySize returns the total size of regular files below a directory
+func directorySize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// urlSlug turns a URL into a readable, collision-resistant directory name
+func urlSlug(pageURL string) string {
+	hash := sha1.Sum([]byte(pageURL))
+	suffix := hex.EncodeToString(hash[:])[:8]
+
+	name := pageURL
+	if parsed, err := url.Parse(pageURL); err == nil && parsed.Host != "" {
+		name = parsed.Host + parsed.Path
+	}
+
+	name = sanitizePathComponent(name)
+	if len(name) > 60 {
+		name = name[:60]
+	}
+
+	return fmt.Sprintf("%s_%s", strings.Trim(name, "_"), suffix)
+}
+
+// sanitizePathComponent replaces characters that are unsafe in file names
+func sanitizePathComponent(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,112 @@
+package automation
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestURLSlug(t *testing.T) {
+	slug := urlSlug("https://example.com/contact?ref=1")
+
+	if !strings.HasPrefix(slug, "example.com_contact_") {
+		t.Errorf("Expected slug to start with host and path, got %s", slug)
+	}
+
+	if strings.ContainsAny(slug, "/?:=") {
+		t.Errorf("Expected slug to contain no path separators, got %s", slug)
+	}
+
+	other := urlSlug("https://example.com/contact?ref=2")
+	if slug == other {
+		t.Error("Expected different URLs to produce different slugs")
+	}
+}
+
+func TestPrepareURL(t *testing.T) {
+	tempDir := t.TempDir()
+
+	am, err := NewArtifactManager(&ArtifactConfig{
+		BaseDir:      tempDir,
+		CaptureTrace: true,
+		CaptureHAR:   false,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create artifact manager: %v", err)
+	}
+
+	artifacts, err := am.PrepareURL("session-1", "https://example.com/signup")
+	if err != nil {
+		t.Fatalf("Failed to prepare URL artifacts: %v", err)
+	}
+
+	if !strings.HasPrefix(artifacts.Dir, filepath.Join(tempDir, "session-1")) {
+		t.Errorf("Expected artifacts under session directory, got %s", artifacts.Dir)
+	}
+
+	if _, err := os.Stat(artifacts.Dir); err != nil {
+		t.Errorf("Expected artifact directory to exist: %v", err)
+	}
+
+	if artifacts.Trace == "" {
+		t.Error("Expected trace path when tracing is enabled")
+	}
+
+	if artifacts.HAR != "" {
+		t.Errorf("Expected no HAR path when HAR capture is disabled, got %s", artifacts.HAR)
+	}
+
+	screenshot := am.ScreenshotPath(artifacts, ArtifactStageBeforeFill)
+	if filepath.Base(screenshot) != "before-fill.png" {
+		t.Errorf("Expected before-fill.png, got %s", filepath.Base(screenshot))
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	tempDir := t.TempDir()
+
+	am, err := NewArtifactManager(&ArtifactConfig{
+		BaseDir:     tempDir,
+		MaxSessions: 2,
+		MaxAge:      24 * time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create artifact manager: %v", err)
+	}
+
+	now := time.Now()
+	sessions := map[string]time.Time{
+		"newest":  now,
+		"middle":  now.Add(-1 * time.Hour),
+		"oldest":  now.Add(-2 * time.Hour),
+		"expired": now.Add(-48 * time.Hour),
+	}
+
+	for name, modTime := range sessions {
+		dir := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create session dir: %v", err)
+		}
+		if err := os.Chtimes(dir, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set session dir time: %v", err)
+		}
+	}
+
+	if err := am.ApplyRetention(); err != nil {
+		t.Fatalf("Unexpected error applying retention: %v", err)
+	}
+
+	for name, shouldExist := range map[string]bool{
+		"newest":  true,
+		"middle":  true,
+		"oldest":  false,
+		"expired": false,
+	} {
+		_, err := os.Stat(filepath.Join(tempDir, name))
+		if exists := err == nil; exists != shouldExist {
+			t.Errorf("Session %s: expected exists=%v, got %v", name, shouldExist, exists)
+		}
+	}
+}
@@ -14,6 +14,7 @@ type BrowserManager struct {
 	pw          *playwright.Playwright
 	browsers    []*playwright.Browser
 	maxBrowsers int
+	monitor     *ResourceMonitor
 	mutex       sync.RWMutex
 	ctx         context.Context
 	cancel      context.CancelFunc
@@ -111,7 +112,7 @@ func (bm *BrowserManager) createBrowser(config *BrowserConfig) (*playwright.Brow
 
 	browser, err := bm.pw.Chromium.Launch(launchOptions)
 	if err != nil {
-		return nil, fmt.Errorf("failed to launch browser: %w", err)
+		return nil, newAutomationError(ErrBrowserCrashed, "launch browser", err)
 	}
 
 	return &browser, nil
@@ -123,7 +124,7 @@ func (bm *BrowserManager) GetBrowser() (*playwright.Browser, error) {
 	defer bm.mutex.RUnlock()
 
 	if len(bm.browsers) == 0 {
-		return nil, fmt.Errorf("no browsers available")
+		return nil, &AutomationError{Kind: ErrBrowserCrashed, Op: "get browser", Err: fmt.Errorf("no browsers available")}
 	}
 
 	// Return the first available browser
@@ -131,27 +132,56 @@ func (bm *BrowserManager) GetBrowser() (*playwright.Browser, error) {
 	return bm.browsers[0], nil
 }
 
+// PageRecording configures optional trace and HAR capture for a page's browser context
+type PageRecording struct {
+	TracePath string
+	HARPath   string
+}
+
 // CreatePage creates a new page with default settings
 func (bm *BrowserManager) CreatePage(config *BrowserConfig) (*playwright.Page, error) {
+	return bm.CreateRecordedPage(config, nil)
+}
+
+// CreateRecordedPage creates a new page whose browser context records a
+// Playwright trace and/or HAR file. The recording is only flushed to disk by
+// CloseRecordedPage.
+func (bm *BrowserManager) CreateRecordedPage(config *BrowserConfig, recording *PageRecording) (*playwright.Page, error) {
 	browser, err := bm.GetBrowser()
 	if err != nil {
 		return nil, err
 	}
 
-	context, err := (*browser).NewContext(playwright.BrowserNewContextOptions{
+	contextOptions := playwright.BrowserNewContextOptions{
 		Viewport: &playwright.Size{
 			Width:  config.ViewportWidth,
 			Height: config.ViewportHeight,
 		},
 		UserAgent: &config.UserAgent,
-	})
+	}
+	if recording != nil && recording.HARPath != "" {
+		contextOptions.RecordHarPath = playwright.String(recording.HARPath)
+	}
+
+	context, err := (*browser).NewContext(contextOptions)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create browser context: %w", err)
+		return nil, newAutomationError(ErrBrowserCrashed, "create browser context", err)
+	}
+
+	if recording != nil && recording.TracePath != "" {
+		err = context.Tracing().Start(playwright.TracingStartOptions{
+			Screenshots: playwright.Bool(true),
+			Snapshots:   playwright.Bool(true),
+		})
+		if err != nil {
+			context.Close()
+			return nil, fmt.Errorf("failed to start tracing: %w", err)
+		}
 	}
 
 	page, err := context.NewPage()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create page: %w", err)
+		return nil, newAutomationError(ErrBrowserCrashed, "create page", err)
 	}
 
 	// Set default timeout
@@ -160,6 +190,28 @@ func (bm *BrowserManager) CreatePage(config *BrowserConfig) (*playwright.Page, e
 	return &page, nil
 }
 
+// CloseRecordedPage stops tracing and closes the page's browser context so
+// that trace and HAR files are written out
+func (bm *BrowserManager) CloseRecordedPage(page *playwright.Page, recording *PageRecording) error {
+	context := (*page).Context()
+
+	var traceErr error
+	if recording != nil && recording.TracePath != "" {
+		traceErr = context.Tracing().Stop(recording.TracePath)
+	}
+
+	// Closing the context flushes the HAR file
+	if err := context.Close(); err != nil {
+		return fmt.Errorf("failed to close browser context: %w", err)
+	}
+
+	if traceErr != nil {
+		return fmt.Errorf("failed to save trace: %w", traceErr)
+	}
+
+	return nil
+}
+
 // ExecuteInParallel executes multiple tasks in parallel using available browsers
 func (bm *BrowserManager) ExecuteInParallel(tasks []func(*playwright.Page) error, config *BrowserConfig) []error {
 	if config == nil {
@@ -196,15 +248,30 @@ func (bm *BrowserManager) ExecuteInParallel(tasks []func(*playwright.Page) error
 	return results
 }
 
+// SetResourceMonitor shares a resource monitor with the browser manager
+func (bm *BrowserManager) SetResourceMonitor(monitor *ResourceMonitor) {
+	bm.mutex.Lock()
+	defer bm.mutex.Unlock()
+
+	bm.monitor = monitor
+}
+
 // GetSystemResources returns current system resource usage
 func (bm *BrowserManager) GetSystemResources() (*SystemResources, error) {
-	// This is a placeholder implementation
-	// In a real implementation, you'd use system monitoring libraries
+	bm.mutex.Lock()
+	if bm.monitor == nil {
+		bm.monitor = NewResourceMonitor()
+	}
+	monitor := bm.monitor
+	activeBrowsers := len(bm.browsers)
+	bm.mutex.Unlock()
+
+	resources := monitor.GetCurrentResources()
 	return &SystemResources{
-		CPUUsage:      25.5,
-		MemoryUsage:   45.2,
-		ActiveBrowsers: len(bm.browsers),
-		MaxBrowsers:   bm.maxBrowsers,
+		CPUUsage:       resources.CPUUsage,
+		MemoryUsage:    resources.MemoryUsage,
+		ActiveBrowsers: activeBrowsers,
+		MaxBrowsers:    bm.maxBrowsers,
 	}, nil
 }
 
@@ -278,7 +345,7 @@ func (bm *BrowserManager) HealthCheck() error {
 		// Try to create a context to verify browser is responsive
 		ctx, err := (*browser).NewContext()
 		if err != nil {
-			return fmt.Errorf("browser %d is not responsive: %w", i, err)
+			return newAutomationError(ErrBrowserCrashed, fmt.Sprintf("health check browser %d", i), err)
 		}
 		ctx.Close()
 	}
@@ -0,0 +1,140 @@
+package automation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// Sentinel errors classifying automation failures. Errors returned by
+// FormFiller, FormDetector and BrowserManager wrap one of these, so callers
+// can use errors.Is to decide how to report and whether to retry.
+var (
+	ErrNavigation         = errors.New("navigation failed")
+	ErrTimeout            = errors.New("operation timed out")
+	ErrSelectorMissing    = errors.New("selector not found")
+	ErrFormNotFound       = errors.New("form not found")
+	ErrValidationRejected = errors.New("validation rejected")
+	ErrSubmissionFailed   = errors.New("submission failed")
+	ErrBrowserCrashed     = errors.New("browser crashed")
+)
+
+// AutomationError is a classified error raised by the automation layer
+type AutomationError struct {
+	Kind     error  // One of the sentinel errors above
+	Op       string // Operation that failed, e.g. "navigate" or "fill field"
+	URL      string
+	Selector string
+	Err      error // Underlying cause, if any
+}
+
+// Error implements the error interface
+func (e *AutomationError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Op)
+	b.WriteString(": ")
+	b.WriteString(e.Kind.Error())
+
+	if e.Selector != "" {
+		b.WriteString(fmt.Sprintf(" (selector %s)", e.Selector))
+	}
+	if e.URL != "" {
+		b.WriteString(fmt.Sprintf(" at %s", e.URL))
+	}
+	if e.Err != nil {
+		b.WriteString(": ")
+		b.WriteString(e.Err.Error())
+	}
+
+	return b.String()
+}
+
+// Is reports whether the error belongs to the given sentinel class
+func (e *AutomationError) Is(target error) bool {
+	return e.Kind == target
+}
+
+// Unwrap returns the underlying cause
+func (e *AutomationError) Unwrap() error {
+	return e.Err
+}
+
+// newAutomationError creates a classified error. Playwright timeouts and
+// closed targets take precedence over the given kind, because they describe
+// what actually went wrong better than the operation that was running.
+func newAutomationError(kind error, op string, cause error) *AutomationError {
+	return &AutomationError{
+		Kind: classifyCause(cause, kind),
+		Op:   op,
+		Err:  cause,
+	}
+}
+
+// classifyCause maps low-level errors onto the sentinel classes
+func classifyCause(cause error, fallback error) error {
+	if cause == nil {
+		return fallback
+	}
+
+	switch {
+	case errors.Is(cause, playwright.ErrTargetClosed):
+		return ErrBrowserCrashed
+	case errors.Is(cause, context.DeadlineExceeded):
+		return ErrTimeout
+	case errors.Is(cause, playwright.ErrTimeout):
+		// A selector that never appears surfaces as a Playwright timeout
+		if fallback == ErrSelectorMissing {
+			return ErrSelectorMissing
+		}
+		return ErrTimeout
+	}
+
+	message := strings.ToLower(cause.Error())
+	if strings.Contains(message, "browser has been closed") ||
+		strings.Contains(message, "target crashed") ||
+		strings.Contains(message, "page crashed") {
+		return ErrBrowserCrashed
+	}
+
+	return fallback
+}
+
+// Error types understood by the TUI error reporter
+const (
+	ErrorTypeNetwork          = "network_error"
+	ErrorTypeTimeout          = "timeout_error"
+	ErrorTypeFieldNotFound    = "field_not_found"
+	ErrorTypeFormNotFound     = "form_not_found"
+	ErrorTypeValidationFailed = "validation_failed"
+	ErrorTypeSubmissionFailed = "submission_failed"
+	ErrorTypeBrowserCrashed   = "browser_crashed"
+	ErrorTypeCancelled        = "cancelled"
+	ErrorTypeExecution        = "execution_error"
+)
+
+// ClassifyError maps an error onto an ExecutionError type and severity
+func ClassifyError(err error) (errorType string, severity string) {
+	switch {
+	case errors.Is(err, ErrBrowserCrashed):
+		return ErrorTypeBrowserCrashed, "critical"
+	case errors.Is(err, ErrSubmissionFailed):
+		return ErrorTypeSubmissionFailed, "high"
+	case errors.Is(err, ErrNavigation):
+		return ErrorTypeNetwork, "high"
+	case errors.Is(err, ErrFormNotFound):
+		return ErrorTypeFormNotFound, "high"
+	case errors.Is(err, ErrSelectorMissing):
+		return ErrorTypeFieldNotFound, "medium"
+	case errors.Is(err, ErrValidationRejected):
+		return ErrorTypeValidationFailed, "medium"
+	case errors.Is(err, ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return ErrorTypeTimeout, "medium"
+	case errors.Is(err, context.Canceled):
+		return ErrorTypeCancelled, "low"
+	default:
+		return ErrorTypeExecution, "high"
+	}
+}
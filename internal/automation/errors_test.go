@@ -0,0 +1,74 @@
+package automation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+func TestAutomationErrorIs(t *testing.T) {
+	err := fmt.Errorf("failed to fill form: %w", newAutomationError(ErrNavigation, "navigate", errors.New("net::ERR_NAME_NOT_RESOLVED")))
+
+	if !errors.Is(err, ErrNavigation) {
+		t.Error("Expected wrapped error to match ErrNavigation")
+	}
+
+	if errors.Is(err, ErrTimeout) {
+		t.Error("Expected wrapped error not to match ErrTimeout")
+	}
+
+	var automationErr *AutomationError
+	if !errors.As(err, &automationErr) {
+		t.Fatal("Expected errors.As to find AutomationError")
+	}
+
+	if automationErr.Op != "navigate" {
+		t.Errorf("Expected op navigate, got %s", automationErr.Op)
+	}
+}
+
+func TestClassifyCause(t *testing.T) {
+	tests := []struct {
+		name     string
+		cause    error
+		fallback error
+		expected error
+	}{
+		{"playwright timeout", playwright.ErrTimeout, ErrNavigation, ErrTimeout},
+		{"missing selector", playwright.ErrTimeout, ErrSelectorMissing, ErrSelectorMissing},
+		{"target closed", playwright.ErrTargetClosed, ErrNavigation, ErrBrowserCrashed},
+		{"crash message", errors.New("Target crashed"), ErrValidationRejected, ErrBrowserCrashed},
+		{"deadline", context.DeadlineExceeded, ErrNavigation, ErrTimeout},
+		{"other", errors.New("boom"), ErrFormNotFound, ErrFormNotFound},
+	}
+
+	for _, tt := range tests {
+		if kind := classifyCause(tt.cause, tt.fallback); kind != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, kind)
+		}
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err              error
+		expectedType     string
+		expectedSeverity string
+	}{
+		{&AutomationError{Kind: ErrBrowserCrashed, Op: "launch"}, ErrorTypeBrowserCrashed, "critical"},
+		{&AutomationError{Kind: ErrSelectorMissing, Op: "wait for field"}, ErrorTypeFieldNotFound, "medium"},
+		{&AutomationError{Kind: ErrSubmissionFailed, Op: "submit form"}, ErrorTypeSubmissionFailed, "high"},
+		{context.Canceled, ErrorTypeCancelled, "low"},
+		{errors.New("unknown"), ErrorTypeExecution, "high"},
+	}
+
+	for _, tt := range tests {
+		errorType, severity := ClassifyError(tt.err)
+		if errorType != tt.expectedType || severity != tt.expectedSeverity {
+			t.Errorf("%v: expected %s/%s, got %s/%s", tt.err, tt.expectedType, tt.expectedSeverity, errorType, severity)
+		}
+	}
+}
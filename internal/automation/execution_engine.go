@@ -2,9 +2,12 @@ package automation
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ai-form-filler/cli/internal/models"
@@ -14,10 +17,19 @@ import (
 type ExecutionEngine struct {
 	browserManager   *BrowserManager
 	formFiller      *FormFiller
+	detector        *FormDetector // Detects the forms of URLs without a template
 	resourceMonitor *ResourceMonitor
+	artifactManager *ArtifactManager
 	config          *ExecutionConfig
 	activeJobs      map[string]*ExecutionJob
 	jobsMutex       sync.RWMutex
+	limitsMutex     sync.RWMutex // Guards config.MaxConcurrency, which adjustResourceLimits changes
+	runningTasks    int64        // URL tasks currently holding a browser page
+	healer          *TemplateHealer
+	templates       *TemplateManager // Receives the outcome of each URL task when set
+	listeners       map[int]ExecutionListener
+	nextListener    int
+	listenersMutex  sync.Mutex
 	ctx             context.Context
 	cancel          context.CancelFunc
 }
@@ -29,10 +41,13 @@ type ExecutionConfig struct {
 	ResourceThresholds  ResourceThresholds `json:"resourceThresholds"`
 	DefaultTimeout      time.Duration `json:"defaultTimeout"`
 	RetryAttempts       int           `json:"retryAttempts"`
+	RetryPolicy         *RetryPolicy  `json:"retryPolicy,omitempty"`
+	HostLimits          HostLimits    `json:"hostLimits"`
 	DelayBetweenJobs    time.Duration `json:"delayBetweenJobs"`
 	MonitoringInterval  time.Duration `json:"monitoringInterval"`
 	EnableScreenshots   bool          `json:"enableScreenshots"`
 	EnableErrorRecovery bool          `json:"enableErrorRecovery"`
+	Artifacts           *ArtifactConfig `json:"artifacts,omitempty"`
 }
 
 // ResourceThresholds defines system resource limits
@@ -56,6 +71,9 @@ type ExecutionJob struct {
 	Errors      []models.ExecutionError   `json:"errors"`
 	Context     context.Context           `json:"-"`
 	Cancel      context.CancelFunc        `json:"-"`
+
+	completedURLs int // URL tasks finished so far, counted as they finish
+	failedURLs    int
 }
 
 // DefaultExecutionConfig returns sensible defaults
@@ -75,6 +93,9 @@ func DefaultExecutionConfig() *ExecutionConfig {
 		MonitoringInterval:  5 * time.Second,
 		EnableScreenshots:   true,
 		EnableErrorRecovery: true,
+		Artifacts:           DefaultArtifactConfig(),
+		RetryPolicy:         DefaultRetryPolicy(),
+		HostLimits:          DefaultHostLimits(),
 	}
 }
 
@@ -84,17 +105,34 @@ func NewExecutionEngine(browserManager *BrowserManager, config *ExecutionConfig)
 		config = DefaultExecutionConfig()
 	}
 
-	formFiller := NewFormFiller(browserManager, DefaultFormFillerConfig())
+	if config.RetryPolicy == nil {
+		config.RetryPolicy = DefaultRetryPolicy()
+		config.RetryPolicy.MaxRetries = config.RetryAttempts
+	}
+
+	artifactManager, err := NewArtifactManager(config.Artifacts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize artifact manager: %w", err)
+	}
+
+	fillerConfig := DefaultFormFillerConfig()
+	fillerConfig.TakeScreenshots = config.EnableScreenshots
+	formFiller := NewFormFiller(browserManager, fillerConfig)
+	formFiller.SetArtifactManager(artifactManager)
 	resourceMonitor := NewResourceMonitor()
+	browserManager.SetResourceMonitor(resourceMonitor)
 
 	ctx, cancel := context.WithCancel(context.Background())
 
 	engine := &ExecutionEngine{
 		browserManager:  browserManager,
 		formFiller:     formFiller,
+		detector:       NewFormDetector(browserManager, nil),
 		resourceMonitor: resourceMonitor,
+		artifactManager: artifactManager,
 		config:         config,
 		activeJobs:     make(map[string]*ExecutionJob),
+		listeners:      make(map[int]ExecutionListener),
 		ctx:            ctx,
 		cancel:         cancel,
 	}
@@ -107,6 +145,17 @@ func NewExecutionEngine(browserManager *BrowserManager, config *ExecutionConfig)
 	return engine, nil
 }
 
+// SetTemplateHealer enables healing templates whose selectors stop matching
+func (ee *ExecutionEngine) SetTemplateHealer(healer *TemplateHealer) {
+	ee.healer = healer
+}
+
+// SetTemplateManager enables recording each URL task's outcome in its
+// template's statistics
+func (ee *ExecutionEngine) SetTemplateManager(templates *TemplateManager) {
+	ee.templates = templates
+}
+
 // ExecuteSession executes a complete execution session with parallel processing
 func (ee *ExecutionEngine) ExecuteSession(session *models.ExecutionSession, profileData *ProfileData, templates map[string]*FormTemplate) error {
 	// Create execution job
@@ -133,19 +182,26 @@ func (ee *ExecutionEngine) ExecuteSession(session *models.ExecutionSession, prof
 		job.Cancel()
 	}()
 
+	// Enforce artifact retention before this session adds more.
+	// Failing to prune old artifacts must not block execution.
+	if err := ee.artifactManager.ApplyRetention(); err != nil {
+		fmt.Printf("Warning: failed to apply artifact retention: %v\n", err)
+	}
+
 	// Start session
 	session.Start()
+	ee.publishJobUpdate(job.ID, ExecutionStarted, "", nil)
 
 	// Create URL processing tasks
 	urlTasks := make([]URLTask, 0, len(session.URLs))
 	for _, url := range session.URLs {
 		template, exists := templates[url]
 		if !exists {
-			// Create a basic template if none exists
+			// The form is detected on the page when the task runs
 			template = &FormTemplate{
 				ID:     fmt.Sprintf("auto_%s", url),
 				URL:    url,
-				Fields: []FormField{}, // Will be detected dynamically
+				Fields: []FormField{},
 			}
 		}
 
@@ -154,6 +210,7 @@ func (ee *ExecutionEngine) ExecuteSession(session *models.ExecutionSession, prof
 			Template:    template,
 			ProfileData: profileData,
 			JobID:       job.ID,
+			Detected:    !exists,
 		})
 	}
 
@@ -164,12 +221,16 @@ func (ee *ExecutionEngine) ExecuteSession(session *models.ExecutionSession, prof
 	for i, result := range results {
 		if result.Error != nil {
 			// Add error to job
+			errorType, severity := ClassifyError(result.Error)
 			execError := models.ExecutionError{
 				URL:       urlTasks[i].URL,
-				ErrorType: "execution_error",
+				ErrorType: errorType,
 				Message:   result.Error.Error(),
 				Timestamp: time.Now(),
-				Severity:  "high",
+				Severity:  severity,
+			}
+			if result.FillResult != nil {
+				execError.Artifacts = executionArtifacts(result.FillResult.Artifacts)
 			}
 			job.Errors = append(job.Errors, execError)
 			session.AddError(execError)
@@ -181,8 +242,12 @@ func (ee *ExecutionEngine) ExecuteSession(session *models.ExecutionSession, prof
 				FilledFields:  result.FillResult.FilledFields,
 				TotalFields:   result.FillResult.TotalFields,
 				ExecutionTime: result.FillResult.ExecutionTime,
+				Artifacts:     executionArtifacts(result.FillResult.Artifacts),
 				Timestamp:     result.FillResult.Timestamp,
 			}
+			if execResult.Artifacts != nil {
+				execResult.ScreenshotPath = execResult.Artifacts.AfterScreenshot
+			}
 			job.Results = append(job.Results, execResult)
 			session.AddResult(execResult)
 		}
@@ -190,6 +255,7 @@ func (ee *ExecutionEngine) ExecuteSession(session *models.ExecutionSession, prof
 
 	// Complete session
 	now := time.Now()
+	ee.jobsMutex.Lock()
 	job.EndTime = &now
 	if len(job.Errors) == 0 {
 		job.Status = models.StatusCompleted
@@ -198,6 +264,8 @@ func (ee *ExecutionEngine) ExecuteSession(session *models.ExecutionSession, prof
 		job.Status = models.StatusFailed
 		session.Fail()
 	}
+	ee.jobsMutex.Unlock()
+	ee.publishJobUpdate(job.ID, ExecutionFinished, "", nil)
 
 	return nil
 }
@@ -208,6 +276,7 @@ type URLTask struct {
 	Template    *FormTemplate
 	ProfileData *ProfileData
 	JobID       string
+	Detected    bool // No template was given; the form is detected and its template has no statistics
 }
 
 // URLTaskResult represents the result of processing a URL task
@@ -217,48 +286,81 @@ type URLTaskResult struct {
 	Error      error
 }
 
-// executeURLTasksParallel executes URL tasks in parallel with resource monitoring
+// executeURLTasksParallel executes URL tasks in parallel with resource monitoring.
+// Tasks are interleaved across hosts so the global limit stays saturated
+// without exceeding any host's concurrency cap or minimum interval.
 func (ee *ExecutionEngine) executeURLTasksParallel(ctx context.Context, tasks []URLTask) []URLTaskResult {
 	results := make([]URLTaskResult, len(tasks))
-	semaphore := make(chan struct{}, ee.getCurrentConcurrencyLimit())
-	var wg sync.WaitGroup
-
-	for i, task := range tasks {
-		wg.Add(1)
-		go func(index int, urlTask URLTask) {
-			defer wg.Done()
-
-			// Acquire semaphore
-			select {
-			case semaphore <- struct{}{}:
-				defer func() { <-semaphore }()
-			case <-ctx.Done():
+	scheduler := newHostScheduler(tasks, ee.config.HostLimits)
+	finished := make(chan int)
+	running := 0
+
+	for scheduler.remaining > 0 || running > 0 {
+		// Re-evaluated on every dispatch so the limit follows resource pressure
+		concurrencyLimit := max(1, ee.getCurrentConcurrencyLimit())
+
+		var wait time.Duration
+		if running < concurrencyLimit && scheduler.remaining > 0 {
+			index, hostWait, ok := scheduler.next(time.Now())
+			if ok {
+				running++
+				go func(index int, urlTask URLTask) {
+					atomic.AddInt64(&ee.runningTasks, 1)
+					defer func() {
+						atomic.AddInt64(&ee.runningTasks, -1)
+						finished <- index
+					}()
+
+					// Check if we should pause due to resource constraints
+					if ee.shouldPauseExecution() {
+						time.Sleep(ee.config.DelayBetweenJobs * 2)
+					}
+
+					// Execute the task
+					fillResult, err := ee.executeURLTask(ctx, urlTask)
+					if !urlTask.Detected {
+						ee.recordTemplateOutcome(urlTask.Template, fillResult)
+					}
+					results[index] = URLTaskResult{
+						URL:        urlTask.URL,
+						FillResult: fillResult,
+						Error:      err,
+					}
+					ee.finishURLTask(urlTask.JobID, urlTask.URL, err)
+
+					// Add delay between tasks
+					time.Sleep(ee.config.DelayBetweenJobs)
+				}(index, tasks[index])
+				continue
+			}
+			wait = hostWait
+		}
+
+		// Wait for a slot to free up or a host interval to elapse
+		var timer <-chan time.Time
+		if wait > 0 {
+			timer = time.After(wait)
+		}
+
+		select {
+		case index := <-finished:
+			running--
+			scheduler.release(index)
+		case <-timer:
+		case <-ctx.Done():
+			for _, index := range scheduler.drain() {
 				results[index] = URLTaskResult{
-					URL:   urlTask.URL,
+					URL:   tasks[index].URL,
 					Error: ctx.Err(),
 				}
-				return
 			}
-
-			// Check if we should pause due to resource constraints
-			if ee.shouldPauseExecution() {
-				time.Sleep(ee.config.DelayBetweenJobs * 2)
+			// Let running tasks observe the cancellation and finish
+			for ; running > 0; running-- {
+				<-finished
 			}
-
-			// Execute the task
-			fillResult, err := ee.executeURLTask(ctx, urlTask)
-			results[index] = URLTaskResult{
-				URL:        urlTask.URL,
-				FillResult: fillResult,
-				Error:      err,
-			}
-
-			// Add delay between tasks
-			time.Sleep(ee.config.DelayBetweenJobs)
-		}(i, task)
+		}
 	}
 
-	wg.Wait()
 	return results
 }
 
@@ -266,29 +368,119 @@ func (ee *ExecutionEngine) executeURLTasksParallel(ctx context.Context, tasks []
 func (ee *ExecutionEngine) executeURLTask(ctx context.Context, task URLTask) (*FillResult, error) {
 	// Update job progress
 	ee.updateJobProgress(task.JobID, task.URL)
+	ee.publishJobUpdate(task.JobID, ExecutionURLStarted, task.URL, nil)
+
+	policy := ee.config.RetryPolicy
+	if task.Template.RetryPolicy != nil {
+		policy = policy.Merge(task.Template.RetryPolicy)
+	}
 
 	// Execute with retry logic
+	startTime := time.Now()
+	template := task.Template
+	healed := false
+	detected := false
+	var lastResult *FillResult
 	var lastErr error
-	for attempt := 0; attempt <= ee.config.RetryAttempts; attempt++ {
-		if attempt > 0 {
-			// Wait before retry
-			time.Sleep(time.Duration(attempt) * time.Second)
+	for retry := 0; ; retry++ {
+		var result *FillResult
+		var err error
+		if task.Detected && !detected {
+			var found *FormTemplate
+			if found, err = ee.detectTemplate(ctx, task.URL); err == nil {
+				template, detected = found, true
+			}
+		}
+
+		// The template may have been learned on another URL its patterns or
+		// domain match, so the task's URL is filled
+		if err == nil {
+			result, err = ee.formFiller.FillFormForSession(ctx, task.JobID, task.URL, template, task.ProfileData)
+		}
+
+		// Heal a stored template once if fields went missing, then fill again
+		// without spending a retry
+		if !healed && !task.Detected && ee.shouldHeal(result) {
+			healed = true
+			if patched, _, healErr := ee.healer.Heal(ctx, template, task.URL); healErr == nil {
+				template = patched
+				retry--
+				continue
+			}
 		}
 
-		result, err := ee.formFiller.FillForm(ctx, task.Template, task.ProfileData)
 		if err == nil {
 			return result, nil
 		}
 
+		// Keep the failed result so its artifacts can be linked from the error
+		lastResult = result
 		lastErr = err
 
-		// Check if we should retry based on error type
-		if !ee.shouldRetryError(err) {
+		if !ee.config.EnableErrorRecovery {
 			break
 		}
+
+		// Never retry once the form may have been submitted
+		submissionAttempted := result != nil && result.SubmissionAttempted
+		wait, ok := policy.NextBackoff(retry+1, time.Since(startTime), err, submissionAttempted)
+		if !ok {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return lastResult, lastErr
+		case <-time.After(wait):
+		}
+	}
+
+	return lastResult, lastErr
+}
+
+// recordTemplateOutcome adds a task's result to its template's statistics.
+// Tasks whose fill failed before producing a result count as empty fills.
+func (ee *ExecutionEngine) recordTemplateOutcome(template *FormTemplate, result *FillResult) {
+	if ee.templates == nil || template == nil || template.ID == "" {
+		return
+	}
+
+	if err := ee.templates.RecordTemplateOutcome(template.ID, NewTemplateOutcome(result, nil)); err != nil {
+		fmt.Printf("Warning: failed to update template statistics: %v\n", err)
+	}
+}
+
+// detectTemplate builds a template from the most confident form on the page
+func (ee *ExecutionEngine) detectTemplate(ctx context.Context, pageURL string) (*FormTemplate, error) {
+	analysis, err := ee.detector.AnalyzePage(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(analysis.Forms) == 0 {
+		return nil, &AutomationError{Kind: ErrFormNotFound, Op: "detect forms", URL: pageURL}
+	}
+	return ee.detector.GenerateFormTemplate(analysis.Forms[0], pageURL)
+}
+
+// shouldHeal reports whether a fill result shows selectors that stopped
+// matching and the form can safely be filled again
+func (ee *ExecutionEngine) shouldHeal(result *FillResult) bool {
+	return ee.healer != nil && result != nil && len(result.MissingFields) > 0 && !result.SubmissionAttempted
+}
+
+// executionArtifacts converts recorded URL artifacts for session results
+func executionArtifacts(artifacts *URLArtifacts) *models.ExecutionArtifacts {
+	if artifacts == nil {
+		return nil
 	}
 
-	return nil, lastErr
+	return &models.ExecutionArtifacts{
+		Dir:              artifacts.Dir,
+		BeforeScreenshot: artifacts.BeforeScreenshot,
+		AfterScreenshot:  artifacts.AfterScreenshot,
+		Trace:            artifacts.Trace,
+		HAR:              artifacts.HAR,
+	}
 }
 
 // updateJobProgress updates the progress of a specific job
@@ -307,20 +499,23 @@ func (ee *ExecutionEngine) updateJobProgress(jobID, currentURL string) {
 
 // getCurrentConcurrencyLimit returns the current concurrency limit
 func (ee *ExecutionEngine) getCurrentConcurrencyLimit() int {
+	ee.limitsMutex.RLock()
+	maxConcurrency := ee.config.MaxConcurrency
+	ee.limitsMutex.RUnlock()
+
 	if !ee.config.AutoAdjustLimits {
-		return ee.config.MaxConcurrency
+		return maxConcurrency
 	}
 
 	resources := ee.resourceMonitor.GetCurrentResources()
 	
 	// Adjust based on resource usage
-	if resources.CPUUsage > ee.config.ResourceThresholds.MaxCPUPercent ||
-	   resources.MemoryUsage > ee.config.ResourceThresholds.MaxMemoryPercent {
+	if ee.underResourcePressure(resources, 1.0) {
 		// Reduce concurrency
-		return max(1, ee.config.MaxConcurrency/2)
+		return max(1, maxConcurrency/2)
 	}
 
-	return ee.config.MaxConcurrency
+	return maxConcurrency
 }
 
 // shouldPauseExecution checks if execution should be paused due to resource constraints
@@ -331,32 +526,18 @@ func (ee *ExecutionEngine) shouldPauseExecution() bool {
 
 	resources := ee.resourceMonitor.GetCurrentResources()
 	
-	return resources.CPUUsage > ee.config.ResourceThresholds.MaxCPUPercent*1.2 ||
-		   resources.MemoryUsage > ee.config.ResourceThresholds.MaxMemoryPercent*1.2
+	return ee.underResourcePressure(resources, 1.2)
 }
 
-// shouldRetryError determines if an error should trigger a retry
-func (ee *ExecutionEngine) shouldRetryError(err error) bool {
-	if !ee.config.EnableErrorRecovery {
-		return false
-	}
-
-	// Define retryable error patterns
-	retryablePatterns := []string{
-		"timeout",
-		"network",
-		"connection",
-		"temporary",
-	}
-
-	errStr := err.Error()
-	for _, pattern := range retryablePatterns {
-		if contains(errStr, pattern) {
-			return true
-		}
-	}
+// underResourcePressure checks usage against the configured thresholds,
+// scaled by factor, and the minimum free memory
+func (ee *ExecutionEngine) underResourcePressure(resources *SystemResourceUsage, factor float64) bool {
+	thresholds := ee.config.ResourceThresholds
+	freeMB := resources.MemoryFree / (1024 * 1024)
 
-	return false
+	return resources.CPUUsage > thresholds.MaxCPUPercent*factor ||
+		   resources.MemoryUsage > thresholds.MaxMemoryPercent*factor ||
+		   (thresholds.MinFreeMB > 0 && freeMB < int64(float64(thresholds.MinFreeMB)/factor))
 }
 
 // monitorResources continuously monitors system resources and adjusts limits
@@ -377,22 +558,40 @@ func (ee *ExecutionEngine) monitorResources() {
 // adjustResourceLimits adjusts execution limits based on current resource usage
 func (ee *ExecutionEngine) adjustResourceLimits() {
 	resources := ee.resourceMonitor.GetCurrentResources()
-	
-	// Adjust max concurrency based on resource usage
-	if resources.CPUUsage > ee.config.ResourceThresholds.MaxCPUPercent {
+	thresholds := ee.config.ResourceThresholds
+
+	ee.limitsMutex.Lock()
+	// Back off under CPU or memory pressure; only grow when both have headroom
+	if ee.underResourcePressure(resources, 1.0) {
 		if ee.config.MaxConcurrency > 1 {
 			ee.config.MaxConcurrency--
 		}
-	} else if resources.CPUUsage < ee.config.ResourceThresholds.MaxCPUPercent*0.5 {
-		if ee.config.MaxConcurrency < ee.config.ResourceThresholds.MaxBrowsers {
+	} else if resources.CPUUsage < thresholds.MaxCPUPercent*0.5 &&
+		resources.MemoryUsage < thresholds.MaxMemoryPercent*0.8 &&
+		ee.hasMemoryForBrowser(resources) {
+		if ee.config.MaxConcurrency < thresholds.MaxBrowsers {
 			ee.config.MaxConcurrency++
 		}
 	}
+	ee.limitsMutex.Unlock()
 
 	// Adjust browser manager limits
 	ee.browserManager.OptimizeConcurrency()
 }
 
+// hasMemoryForBrowser checks that another browser page fits in free memory
+// without dropping below the configured minimum, using the measured
+// footprint of the pages that are already running
+func (ee *ExecutionEngine) hasMemoryForBrowser(resources *SystemResourceUsage) bool {
+	perPage := int64(256 * 1024 * 1024)
+	if running := atomic.LoadInt64(&ee.runningTasks); running > 0 && resources.BrowserMemory/running > perPage {
+		perPage = resources.BrowserMemory / running
+	}
+
+	minFree := ee.config.ResourceThresholds.MinFreeMB * 1024 * 1024
+	return resources.MemoryFree-perPage > minFree
+}
+
 // GetActiveJobs returns currently active jobs
 func (ee *ExecutionEngine) GetActiveJobs() []*ExecutionJob {
 	ee.jobsMutex.RLock()
@@ -509,4 +708,19 @@ func SaveExecutionConfig(config *ExecutionConfig, filePath string) error {
 	encoder := json.NewEncoder(file)
 	encoder.SetIndent("", "  ")
 	return encoder.Encode(config)
-}
\ No newline at end of file
+}
+
+// LoadExecutionConfig loads an execution configuration saved by SaveExecutionConfig
+func LoadExecutionConfig(filePath string) (*ExecutionConfig, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	config := DefaultExecutionConfig()
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse execution config: %w", err)
+	}
+
+	return config, nil
+}
@@ -0,0 +1,53 @@
+package automation
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestExecutionEngine returns an engine with no browsers, storing
+// artifacts in a temporary directory and never retrying
+func newTestExecutionEngine(t *testing.T) *ExecutionEngine {
+	config := DefaultExecutionConfig()
+	config.AutoAdjustLimits = false
+	config.EnableErrorRecovery = false
+	config.Artifacts.BaseDir = t.TempDir()
+
+	engine, err := NewExecutionEngine(&BrowserManager{}, config)
+	if err != nil {
+		t.Fatalf("Failed to create execution engine: %v", err)
+	}
+	t.Cleanup(func() { engine.Close() })
+	return engine
+}
+
+func TestExecuteURLTaskFillsTaskURL(t *testing.T) {
+	engine := newTestExecutionEngine(t)
+
+	// A template learned on the signup page, matched to another page by pattern
+	template := &FormTemplate{
+		ID:          "signup",
+		URL:         "https://example.com/signup",
+		URLPatterns: []string{"https://example.com/*"},
+		Fields:      []FormField{{Name: "email", Selector: "#email"}},
+	}
+	task := URLTask{URL: "https://example.com/join", Template: template, JobID: "job-1"}
+
+	result, err := engine.executeURLTask(context.Background(), task)
+	if err == nil {
+		t.Fatal("Expected the fill to fail without a browser")
+	}
+	if result == nil || result.URL != task.URL {
+		t.Errorf("Expected the result of %s, got %+v", task.URL, result)
+	}
+
+	sessionDir := engine.artifactManager.SessionDir(task.JobID)
+	if _, err := os.Stat(filepath.Join(sessionDir, urlSlug(task.URL))); err != nil {
+		t.Errorf("Expected artifacts of the task's URL: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(sessionDir, urlSlug(template.URL))); !os.IsNotExist(err) {
+		t.Errorf("Expected no artifacts of the template's URL, got %v", err)
+	}
+}
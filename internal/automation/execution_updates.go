@@ -0,0 +1,259 @@
+package automation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ai-form-filler/cli/internal/models"
+)
+
+// Execution update events
+const (
+	ExecutionStarted     = "started"      // The session started
+	ExecutionURLStarted  = "url_started"  // A URL task began filling
+	ExecutionURLFinished = "url_finished" // A URL task succeeded or failed
+	ExecutionFinished    = "finished"     // Every URL task finished
+)
+
+// ExecutionUpdate reports a change in a running execution session
+type ExecutionUpdate struct {
+	Event       string                   `json:"event"`
+	SessionID   string                   `json:"sessionId"`
+	ProfileName string                   `json:"profileName"`
+	Status      models.ExecutionStatus   `json:"status"`
+	Progress    models.ExecutionProgress `json:"progress"`
+	URL         string                   `json:"url,omitempty"`
+	Error       string                   `json:"error,omitempty"`
+	Timestamp   time.Time                `json:"timestamp"`
+}
+
+// ExecutionListener receives execution updates. It is called from the
+// engine's workers and must not block.
+type ExecutionListener func(update ExecutionUpdate)
+
+// Subscribe registers a listener for updates of every session the engine
+// runs and returns a function that removes it
+func (ee *ExecutionEngine) Subscribe(listener ExecutionListener) func() {
+	ee.listenersMutex.Lock()
+	defer ee.listenersMutex.Unlock()
+
+	id := ee.nextListener
+	ee.nextListener++
+	ee.listeners[id] = listener
+
+	return func() {
+		ee.listenersMutex.Lock()
+		defer ee.listenersMutex.Unlock()
+		delete(ee.listeners, id)
+	}
+}
+
+// publishJobUpdate sends a job's current progress to the listeners
+func (ee *ExecutionEngine) publishJobUpdate(jobID, event, url string, err error) {
+	ee.jobsMutex.RLock()
+	job, exists := ee.activeJobs[jobID]
+	if !exists {
+		ee.jobsMutex.RUnlock()
+		return
+	}
+
+	update := ExecutionUpdate{
+		Event:     event,
+		SessionID: job.ID,
+		Status:    job.Status,
+		URL:       url,
+		Timestamp: time.Now(),
+	}
+	if job.Session != nil {
+		update.ProfileName = job.Session.ProfileName
+		update.Progress = job.Session.Progress
+		update.Progress.TotalURLs = len(job.Session.URLs)
+	}
+	update.Progress.CompletedURLs = job.completedURLs
+	update.Progress.FailedURLs = job.failedURLs
+	if update.Progress.TotalURLs > 0 {
+		update.Progress.Percentage = float64(job.completedURLs+job.failedURLs) / float64(update.Progress.TotalURLs) * 100
+	}
+	ee.jobsMutex.RUnlock()
+
+	if err != nil {
+		update.Error = err.Error()
+	}
+
+	ee.listenersMutex.Lock()
+	listeners := make([]ExecutionListener, 0, len(ee.listeners))
+	for _, listener := range ee.listeners {
+		listeners = append(listeners, listener)
+	}
+	ee.listenersMutex.Unlock()
+
+	for _, listener := range listeners {
+		listener(update)
+	}
+}
+
+// finishURLTask counts a finished URL task and publishes the job's progress
+func (ee *ExecutionEngine) finishURLTask(jobID, url string, err error) {
+	ee.jobsMutex.Lock()
+	if job, exists := ee.activeJobs[jobID]; exists {
+		if err != nil {
+			job.failedURLs++
+		} else {
+			job.completedURLs++
+		}
+	}
+	ee.jobsMutex.Unlock()
+
+	ee.publishJobUpdate(jobID, ExecutionURLFinished, url, err)
+}
+
+// ExecutionFeed shares execution updates between processes, such as a
+// batch run from the CLI and the native messaging host. The latest update
+// of each session is kept as a file in the feed's directory.
+type ExecutionFeed struct {
+	dir       string
+	retention time.Duration // Finished sessions older than this are removed
+}
+
+// NewExecutionFeed creates an execution feed stored in dir
+func NewExecutionFeed(dir string) *ExecutionFeed {
+	return &ExecutionFeed{dir: dir, retention: 24 * time.Hour}
+}
+
+// Publish records an update as its session's latest. It can be passed to
+// ExecutionEngine.Subscribe; failures are only reported as warnings so a
+// broken feed never stops a batch.
+func (f *ExecutionFeed) Publish(update ExecutionUpdate) {
+	if err := f.write(update); err != nil {
+		fmt.Printf("Warning: failed to publish execution update: %v\n", err)
+	}
+	if update.Event == ExecutionStarted {
+		f.prune(time.Now())
+	}
+}
+
+// write replaces the session's file atomically so readers never see half an update
+func (f *ExecutionFeed) write(update ExecutionUpdate) error {
+	if err := os.MkdirAll(f.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create feed directory: %w", err)
+	}
+
+	data, err := json.Marshal(update)
+	if err != nil {
+		return fmt.Errorf("failed to marshal update: %w", err)
+	}
+
+	temp, err := os.CreateTemp(f.dir, ".update-*")
+	if err != nil {
+		return fmt.Errorf("failed to write update: %w", err)
+	}
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return fmt.Errorf("failed to write update: %w", err)
+	}
+	temp.Close()
+
+	if err := os.Rename(temp.Name(), f.sessionPath(update.SessionID)); err != nil {
+		os.Remove(temp.Name())
+		return fmt.Errorf("failed to write update: %w", err)
+	}
+	return nil
+}
+
+// sessionPath returns the file holding a session's latest update
+func (f *ExecutionFeed) sessionPath(sessionID string) string {
+	return filepath.Join(f.dir, sanitizePathComponent(sessionID)+".json")
+}
+
+// Latest returns the latest update of every session in the feed, oldest first
+func (f *ExecutionFeed) Latest() ([]ExecutionUpdate, error) {
+	entries, err := os.ReadDir(f.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed directory: %w", err)
+	}
+
+	var updates []ExecutionUpdate
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(f.dir, entry.Name()))
+		if err != nil {
+			continue // Removed since the directory was read
+		}
+		var update ExecutionUpdate
+		if err := json.Unmarshal(data, &update); err != nil {
+			continue
+		}
+		updates = append(updates, update)
+	}
+
+	sort.Slice(updates, func(i, j int) bool {
+		return updates[i].Timestamp.Before(updates[j].Timestamp)
+	})
+	return updates, nil
+}
+
+// Watch polls the feed and passes each new update to fn until the context
+// ends or fn returns an error. Sessions still running when the watch
+// starts are passed first.
+func (f *ExecutionFeed) Watch(ctx context.Context, interval time.Duration, fn func(ExecutionUpdate) error) error {
+	seen := make(map[string]time.Time)
+	first := true
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		updates, err := f.Latest()
+		if err != nil {
+			return err
+		}
+
+		for _, update := range updates {
+			if last, ok := seen[update.SessionID]; ok && !update.Timestamp.After(last) {
+				continue
+			}
+			seen[update.SessionID] = update.Timestamp
+
+			// Finished sessions from before the watch are history, not news
+			if first && update.Event == ExecutionFinished {
+				continue
+			}
+			if err := fn(update); err != nil {
+				return err
+			}
+		}
+		first = false
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// prune removes finished sessions older than the feed's retention
+func (f *ExecutionFeed) prune(now time.Time) {
+	updates, err := f.Latest()
+	if err != nil {
+		return
+	}
+	for _, update := range updates {
+		if update.Event == ExecutionFinished && now.Sub(update.Timestamp) > f.retention {
+			os.Remove(f.sessionPath(update.SessionID))
+		}
+	}
+}
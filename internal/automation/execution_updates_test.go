@@ -0,0 +1,88 @@
+package automation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ai-form-filler/cli/internal/models"
+)
+
+func TestExecutionFeedWatch(t *testing.T) {
+	feed := NewExecutionFeed(t.TempDir())
+	now := time.Now()
+
+	feed.Publish(ExecutionUpdate{Event: ExecutionFinished, SessionID: "old", Status: models.StatusCompleted, Timestamp: now.Add(-time.Minute)})
+	feed.Publish(ExecutionUpdate{Event: ExecutionURLStarted, SessionID: "running", Status: models.StatusRunning, URL: "https://example.com/a", Timestamp: now})
+
+	updates := make(chan ExecutionUpdate, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- feed.Watch(ctx, 20*time.Millisecond, func(update ExecutionUpdate) error {
+			updates <- update
+			return nil
+		})
+	}()
+
+	// Running sessions are passed at once, finished ones are not
+	if update := <-updates; update.SessionID != "running" {
+		t.Errorf("Expected the running session first, got %+v", update)
+	}
+
+	feed.Publish(ExecutionUpdate{Event: ExecutionURLFinished, SessionID: "running", Status: models.StatusRunning, URL: "https://example.com/a",
+		Progress: models.ExecutionProgress{TotalURLs: 2, CompletedURLs: 1, Percentage: 50}, Timestamp: now.Add(time.Second)})
+
+	select {
+	case update := <-updates:
+		if update.Event != ExecutionURLFinished || update.Progress.CompletedURLs != 1 {
+			t.Errorf("Expected the URL to finish, got %+v", update)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the new update to be watched")
+	}
+
+	select {
+	case update := <-updates:
+		t.Errorf("Expected each update once, got %+v again", update)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+	if err := <-watchErr; err != nil {
+		t.Errorf("Expected the watch to end cleanly, got %v", err)
+	}
+}
+
+func TestExecutionFeedWatchStopsOnError(t *testing.T) {
+	feed := NewExecutionFeed(t.TempDir())
+	feed.Publish(ExecutionUpdate{Event: ExecutionStarted, SessionID: "s1", Timestamp: time.Now()})
+
+	closed := errors.New("extension disconnected")
+	err := feed.Watch(context.Background(), 10*time.Millisecond, func(update ExecutionUpdate) error {
+		return closed
+	})
+	if !errors.Is(err, closed) {
+		t.Errorf("Expected the send error, got %v", err)
+	}
+}
+
+func TestExecutionFeedPrune(t *testing.T) {
+	feed := NewExecutionFeed(t.TempDir())
+	now := time.Now()
+
+	feed.Publish(ExecutionUpdate{Event: ExecutionFinished, SessionID: "yesterday", Timestamp: now.Add(-25 * time.Hour)})
+	feed.Publish(ExecutionUpdate{Event: ExecutionFinished, SessionID: "today", Timestamp: now.Add(-time.Hour)})
+	feed.Publish(ExecutionUpdate{Event: ExecutionStarted, SessionID: "new", Timestamp: now})
+
+	updates, err := feed.Latest()
+	if err != nil {
+		t.Fatalf("Failed to read feed: %v", err)
+	}
+	if len(updates) != 2 || updates[0].SessionID != "today" || updates[1].SessionID != "new" {
+		t.Errorf("Expected only the old finished session to be pruned, got %+v", updates)
+	}
+}
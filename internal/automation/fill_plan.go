@@ -0,0 +1,92 @@
+package automation
+
+import (
+	"github.com/ai-form-filler/cli/internal/models"
+)
+
+// Sources of planned field values
+const (
+	PlanSourceTemplate = "template" // A constant or profile binding declared by the template
+	PlanSourceProfile  = "profile"  // Matched to the profile by the field's name or label
+)
+
+// FillPlan lists the value to fill into each field of a form. It is built
+// without a browser for clients that fill the live page themselves, such as
+// the browser extension.
+type FillPlan struct {
+	URL             string         `json:"url"`
+	TemplateID      string         `json:"templateId,omitempty"`
+	TemplateVersion int            `json:"templateVersion,omitempty"`
+	ProfileID       string         `json:"profileId"`
+	Fields          []PlannedField `json:"fields"`
+	Steps           []TemplateStep `json:"steps,omitempty"`
+	Submit          *SubmitAction  `json:"submit,omitempty"`
+	UnmappedFields  []string       `json:"unmappedFields"`
+	Confidence      float64        `json:"confidence"` // Share of fields with a value, in percent
+}
+
+// PlannedField is one field of a fill plan
+type PlannedField struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Selectors []string `json:"selectors"` // Tried in order until one matches
+	Value     string   `json:"value"`
+	Source    string   `json:"source"`
+}
+
+// PlanFill works out the value of every template field for a profile.
+// Template constants and bindings come first, then the field mapper's name
+// and label matching, then the form filler's name heuristics.
+func PlanFill(template *FormTemplate, profile *models.ClientProfile, mapper *FieldMapper) *FillPlan {
+	if mapper == nil {
+		mapper = NewFieldMapper()
+	}
+
+	plan := &FillPlan{
+		URL:             template.URL,
+		TemplateID:      template.ID,
+		TemplateVersion: template.Version,
+		ProfileID:       profile.ID,
+		Fields:          []PlannedField{},
+		Steps:           template.Steps,
+		Submit:          template.Submit,
+		UnmappedFields:  []string{},
+	}
+
+	mappings, _ := mapper.MapProfileToFields(profile, template.Fields)
+	profileData := newProfileData(profile)
+	filler := &FormFiller{}
+
+	for i := range template.Fields {
+		field := &template.Fields[i]
+
+		value, source := "", PlanSourceProfile
+		switch {
+		case field.Value != "" || field.Binding != "":
+			value, source = filler.getFieldValue(field, profileData), PlanSourceTemplate
+		case mappings[field.Name] != "":
+			value = applyTransforms(mappings[field.Name], field.Transforms)
+		default:
+			value = filler.getFieldValue(field, profileData)
+		}
+
+		if value == "" {
+			plan.UnmappedFields = append(plan.UnmappedFields, field.Name)
+			continue
+		}
+
+		plan.Fields = append(plan.Fields, PlannedField{
+			Name:      field.Name,
+			Type:      field.Type,
+			Selectors: fieldSelectors(field),
+			Value:     value,
+			Source:    source,
+		})
+	}
+
+	if len(template.Fields) > 0 {
+		plan.Confidence = float64(len(plan.Fields)) / float64(len(template.Fields)) * 100
+	}
+
+	return plan
+}
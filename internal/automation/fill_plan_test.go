@@ -0,0 +1,70 @@
+package automation
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ai-form-filler/cli/internal/models"
+)
+
+func TestPlanFill(t *testing.T) {
+	profile := models.NewClientProfile("Personal")
+	profile.ID = "profile_1"
+	profile.PersonalData.FirstName = "Jane"
+	profile.PersonalData.LastName = "Doe"
+	profile.PersonalData.Email = "jane@example.com"
+	profile.PersonalData.Phone = "+1 (555) 010-2000"
+	profile.PersonalData.Address.City = "Springfield"
+
+	template := &FormTemplate{
+		ID:      "signup",
+		URL:     "https://example.com/signup",
+		Version: 3,
+		Fields: []FormField{
+			{Name: "q1", Label: "Given name", Type: "text", Selector: "#q1"},
+			{Name: "contact", Type: "text", Selector: "#contact", Binding: "email",
+				WorkingSelector: "input[name=contact]", Fallbacks: []string{"#contact"}},
+			{Name: "tel", Type: "tel", Selector: "#tel", Binding: "phone", Transforms: []string{"digits"}},
+			{Name: "source", Type: "hidden", Selector: "#source", Value: "extension"},
+			{Name: "town", Type: "text", Selector: "#town"},
+			{Name: "company", Type: "text", Selector: "#company"},
+		},
+		Submit: &SubmitAction{Selector: "button[type=submit]"},
+	}
+
+	plan := PlanFill(template, profile, nil)
+
+	if plan.TemplateID != "signup" || plan.TemplateVersion != 3 || plan.ProfileID != "profile_1" {
+		t.Errorf("Expected the plan to name its template and profile, got %+v", plan)
+	}
+	if plan.Submit == nil || plan.Submit.Selector != "button[type=submit]" {
+		t.Errorf("Expected the template's submit action, got %+v", plan.Submit)
+	}
+
+	expected := map[string]PlannedField{
+		"q1":      {Value: "Jane", Source: PlanSourceProfile},
+		"contact": {Value: "jane@example.com", Source: PlanSourceTemplate},
+		"tel":     {Value: "15550102000", Source: PlanSourceTemplate},
+		"source":  {Value: "extension", Source: PlanSourceTemplate},
+		"town":    {Value: "Springfield", Source: PlanSourceProfile},
+	}
+	if len(plan.Fields) != len(expected) {
+		t.Fatalf("Expected %d planned fields, got %+v", len(expected), plan.Fields)
+	}
+	for _, field := range plan.Fields {
+		want := expected[field.Name]
+		if field.Value != want.Value || field.Source != want.Source {
+			t.Errorf("Expected %s to be %q from %s, got %q from %s", field.Name, want.Value, want.Source, field.Value, field.Source)
+		}
+		if field.Name == "contact" && !reflect.DeepEqual(field.Selectors, []string{"input[name=contact]", "#contact"}) {
+			t.Errorf("Expected the working selector before the others, got %v", field.Selectors)
+		}
+	}
+
+	if !reflect.DeepEqual(plan.UnmappedFields, []string{"company"}) {
+		t.Errorf("Expected company to be unmapped, got %v", plan.UnmappedFields)
+	}
+	if plan.Confidence < 83 || plan.Confidence > 84 {
+		t.Errorf("Expected confidence of 5/6 fields, got %.1f", plan.Confidence)
+	}
+}
@@ -0,0 +1,40 @@
+package automation
+
+import "context"
+
+// Kinds of fill progress events
+const (
+	FillEventField = "field" // A template field was filled or failed
+	FillEventStep  = "step"  // A template step other than fill ran
+)
+
+// FillEvent reports progress while a page is filled
+type FillEvent struct {
+	Kind      string `json:"kind"`
+	Field     string `json:"field,omitempty"`
+	Step      int    `json:"step,omitempty"` // Position in the template's steps, from 1
+	Action    string `json:"action,omitempty"`
+	Selector  string `json:"selector,omitempty"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error,omitempty"`
+	Completed int    `json:"completed"` // Fields attempted so far
+	Total     int    `json:"total"`
+}
+
+// FillProgressFunc receives fill progress events
+type FillProgressFunc func(event FillEvent)
+
+// fillProgressKey holds the FillProgressFunc of a context
+type fillProgressKey struct{}
+
+// WithFillProgress returns a context whose fills report their progress to fn
+func WithFillProgress(ctx context.Context, fn FillProgressFunc) context.Context {
+	return context.WithValue(ctx, fillProgressKey{}, fn)
+}
+
+// reportFillProgress passes an event to the context's progress function, if any
+func reportFillProgress(ctx context.Context, event FillEvent) {
+	if fn, ok := ctx.Value(fillProgressKey{}).(FillProgressFunc); ok && fn != nil {
+		fn(event)
+	}
+}
@@ -13,8 +13,9 @@ import (
 
 // FormDetector handles form detection and analysis on web pages
 type FormDetector struct {
-	browserManager *BrowserManager
-	config         *FormDetectorConfig
+	browserManager    *BrowserManager
+	selectorGenerator *SelectorGenerator
+	config            *FormDetectorConfig
 }
 
 // FormDetectorConfig holds configuration for form detection
@@ -23,6 +24,7 @@ type FormDetectorConfig struct {
 	AnalysisTimeout time.Duration
 	MaxForms        int
 	MinConfidence   float64
+	StabilityChecks int // Page reloads used to score selector stability, 0 to skip
 }
 
 // DefaultFormDetectorConfig returns sensible defaults
@@ -32,6 +34,7 @@ func DefaultFormDetectorConfig() *FormDetectorConfig {
 		AnalysisTimeout: 30 * time.Second,
 		MaxForms:        10,
 		MinConfidence:   0.5,
+		StabilityChecks: 0,
 	}
 }
 
@@ -67,6 +70,7 @@ type DetectedField struct {
 	Placeholder       string `json:"placeholder,omitempty"`
 	ValidationPattern string `json:"validationPattern,omitempty"`
 	Value             string `json:"value,omitempty"`
+	Candidates        []SelectorCandidate `json:"candidates,omitempty"` // Ranked, best first
 }
 
 // SubmitButton represents a form submit button
@@ -96,8 +100,9 @@ func NewFormDetector(browserManager *BrowserManager, config *FormDetectorConfig)
 	}
 
 	return &FormDetector{
-		browserManager: browserManager,
-		config:         config,
+		browserManager:    browserManager,
+		selectorGenerator: NewSelectorGenerator(),
+		config:            config,
 	}
 }
 
@@ -105,31 +110,32 @@ func NewFormDetector(browserManager *BrowserManager, config *FormDetectorConfig)
 func (fd *FormDetector) AnalyzePage(ctx context.Context, pageURL string) (*FormAnalysisResult, error) {
 	startTime := time.Now()
 
-	// Create a new page
-	page, err := fd.browserManager.CreatePage(DefaultBrowserConfig())
+	page, err := fd.openPage(pageURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create page: %w", err)
+		return nil, err
 	}
 	defer (*page).Close()
 
-	// Navigate to the page
-	_, err = (*page).Goto(pageURL, playwright.PageGotoOptions{
-		WaitUntil: playwright.WaitUntilStateNetworkidle,
-		Timeout:   playwright.Float(float64(fd.config.WaitTimeout.Milliseconds())),
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to navigate to %s: %w", pageURL, err)
-	}
+	return fd.analyzePage(ctx, page, pageURL, startTime)
+}
 
-	// Wait for page to stabilize
-	time.Sleep(2 * time.Second)
+// AnalyzeOpenPage detects forms on a page that is already loaded, such as
+// the page of a PageSession
+func (fd *FormDetector) AnalyzeOpenPage(ctx context.Context, page *playwright.Page, pageURL string) (*FormAnalysisResult, error) {
+	return fd.analyzePage(ctx, page, pageURL, time.Now())
+}
 
+// analyzePage detects and ranks the forms on a loaded page
+func (fd *FormDetector) analyzePage(ctx context.Context, page *playwright.Page, pageURL string, startTime time.Time) (*FormAnalysisResult, error) {
 	// Inject form detection script and analyze
 	forms, err := fd.detectForms(ctx, page)
 	if err != nil {
 		return nil, fmt.Errorf("failed to detect forms: %w", err)
 	}
 
+	// Replace script-generated selectors with ranked candidates
+	fd.rankFieldSelectors(ctx, page, forms)
+
 	// Calculate metrics
 	totalFields := 0
 	for _, form := range forms {
@@ -149,6 +155,31 @@ func (fd *FormDetector) AnalyzePage(ctx context.Context, pageURL string) (*FormA
 	}, nil
 }
 
+// openPage creates a page, navigates to the URL and waits for it to settle
+func (fd *FormDetector) openPage(pageURL string) (*playwright.Page, error) {
+	page, err := fd.browserManager.CreatePage(DefaultBrowserConfig())
+	if err != nil {
+		return nil, newAutomationError(ErrBrowserCrashed, "create page", err)
+	}
+
+	// Navigate to the page
+	_, err = (*page).Goto(pageURL, playwright.PageGotoOptions{
+		WaitUntil: playwright.WaitUntilStateNetworkidle,
+		Timeout:   playwright.Float(float64(fd.config.WaitTimeout.Milliseconds())),
+	})
+	if err != nil {
+		(*page).Close()
+		navErr := newAutomationError(ErrNavigation, "navigate", err)
+		navErr.URL = pageURL
+		return nil, navErr
+	}
+
+	// Wait for page to stabilize
+	time.Sleep(2 * time.Second)
+
+	return page, nil
+}
+
 // detectForms detects all forms on the current page
 func (fd *FormDetector) detectForms(ctx context.Context, page *playwright.Page) ([]DetectedForm, error) {
 	// Inject form detection JavaScript
@@ -156,12 +187,17 @@ func (fd *FormDetector) detectForms(ctx context.Context, page *playwright.Page)
 	
 	result, err := (*page).Evaluate(script)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute form detection script: %w", err)
+		return nil, newAutomationError(ErrFormNotFound, "run form detection script", err)
+	}
+
+	// Parse the result; round-trip through JSON to normalize Playwright's value types
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse form detection result: %w", err)
 	}
 
-	// Parse the result
 	var rawForms []map[string]interface{}
-	if err := json.Unmarshal([]byte(fmt.Sprintf("%v", result)), &rawForms); err != nil {
+	if err := json.Unmarshal(data, &rawForms); err != nil {
 		return nil, fmt.Errorf("failed to parse form detection result: %w", err)
 	}
 
@@ -191,6 +227,43 @@ func (fd *FormDetector) detectForms(ctx context.Context, page *playwright.Page)
 	return filteredForms, nil
 }
 
+// rankFieldSelectors generates ranked selector candidates for every detected
+// field using the selector generator. Fields whose element cannot be located
+// keep the selector produced by the detection script.
+func (fd *FormDetector) rankFieldSelectors(ctx context.Context, page *playwright.Page, forms []DetectedForm) {
+	var ranked [][]SelectorCandidate
+	var rankedFields []*DetectedField
+
+	for f := range forms {
+		for i := range forms[f].Fields {
+			field := &forms[f].Fields[i]
+
+			element, err := (*page).QuerySelector(field.Selector)
+			if err != nil || element == nil {
+				continue
+			}
+
+			candidates, err := fd.selectorGenerator.GenerateCandidates(ctx, page, element)
+			element.Dispose()
+			if err != nil {
+				continue
+			}
+
+			ranked = append(ranked, candidates)
+			rankedFields = append(rankedFields, field)
+		}
+	}
+
+	// Stability checks reload the page, so run them once all elements are
+	// handled. On failure the candidates keep their uniqueness-based scores.
+	fd.selectorGenerator.MeasureStability(ctx, page, ranked, fd.config.StabilityChecks)
+
+	for i, field := range rankedFields {
+		field.Candidates = ranked[i]
+		field.Selector = ranked[i][0].Selector
+	}
+}
+
 // parseDetectedForm converts raw form data to DetectedForm struct
 func (fd *FormDetector) parseDetectedForm(index int, rawForm map[string]interface{}) (*DetectedForm, error) {
 	form := &DetectedForm{
@@ -519,6 +592,7 @@ func (fd *FormDetector) GenerateFormTemplate(detectedForm DetectedForm, pageURL
 			Required:          detectedField.Required,
 			ValidationPattern: detectedField.ValidationPattern,
 			DefaultValue:      detectedField.Placeholder,
+			Candidates:        detectedField.Candidates,
 		}
 
 		selectors[detectedField.Name] = detectedField.Selector
@@ -636,7 +710,7 @@ func (fd *FormDetector) OptimizeTemplate(ctx context.Context, template *FormTemp
 	// Create a new page to test the template
 	page, err := fd.browserManager.CreatePage(DefaultBrowserConfig())
 	if err != nil {
-		return nil, fmt.Errorf("failed to create page: %w", err)
+		return nil, newAutomationError(ErrBrowserCrashed, "create page", err)
 	}
 	defer (*page).Close()
 
@@ -646,7 +720,9 @@ func (fd *FormDetector) OptimizeTemplate(ctx context.Context, template *FormTemp
 		Timeout:   playwright.Float(float64(fd.config.WaitTimeout.Milliseconds())),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to navigate to template URL: %w", err)
+		navErr := newAutomationError(ErrNavigation, "navigate", err)
+		navErr.URL = template.URL
+		return nil, navErr
 	}
 
 	optimizedTemplate := *template
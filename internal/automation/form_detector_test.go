@@ -298,6 +298,35 @@ func TestGenerateFormTemplate(t *testing.T) {
 	}
 }
 
+func TestGenerateFormTemplateCandidates(t *testing.T) {
+	detector := &FormDetector{}
+
+	candidates := []SelectorCandidate{
+		{Selector: "#email", Strategy: "ID", Stability: 100},
+		{Selector: "[name=\"email\"]", Strategy: "Name", Stability: 90},
+	}
+
+	detectedForm := DetectedForm{
+		Fields: []DetectedField{
+			{Name: "email", Type: "email", Selector: "#email", Candidates: candidates},
+		},
+		FormType: FormTypeContact,
+	}
+
+	template, err := detector.GenerateFormTemplate(detectedForm, "https://example.com/contact")
+	if err != nil {
+		t.Fatalf("Unexpected error generating template: %v", err)
+	}
+
+	if len(template.Fields[0].Candidates) != 2 {
+		t.Fatalf("Expected 2 selector candidates, got %d", len(template.Fields[0].Candidates))
+	}
+
+	if template.Fields[0].Candidates[1].Strategy != "Name" {
+		t.Errorf("Expected second candidate strategy to be 'Name', got %s", template.Fields[0].Candidates[1].Strategy)
+	}
+}
+
 func TestGenerateFormTemplateInvalidURL(t *testing.T) {
 	detector := &FormDetector{}
 
@@ -2,7 +2,9 @@ package automation
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/playwright-community/playwright-go"
@@ -12,6 +14,7 @@ import (
 type FormFiller struct {
 	browserManager *BrowserManager
 	config         *FormFillerConfig
+	artifacts      *ArtifactManager
 }
 
 // FormFillerConfig holds configuration for form filling operations
@@ -38,6 +41,7 @@ func DefaultFormFillerConfig() *FormFillerConfig {
 type FormTemplate struct {
 	ID              string                 `json:"id"`
 	URL             string                 `json:"url"`
+	URLPatterns     []string               `json:"url_patterns,omitempty"` // Other URLs the template applies to
 	Domain          string                 `json:"domain"`
 	FormType        string                 `json:"form_type"`
 	Fields          []FormField            `json:"fields"`
@@ -46,6 +50,89 @@ type FormTemplate struct {
 	SuccessRate     float64                `json:"success_rate"`
 	LastUpdated     time.Time              `json:"last_updated"`
 	Version         int                    `json:"version"`
+	RetryPolicy     *RetryPolicy           `json:"retry_policy,omitempty"` // Overrides the engine policy
+	HostLimits      *HostLimits            `json:"host_limits,omitempty"`  // Tightens the engine's per-host limits
+	Stale           bool                   `json:"stale,omitempty"`        // Set by drift checks; stale templates aren't picked automatically
+	Stats           *TemplateStats         `json:"stats,omitempty"`        // Outcomes of past runs, see RecordTemplateOutcome
+	Steps           []TemplateStep         `json:"steps,omitempty"`        // Actions run in order before the remaining fields are filled
+	Submit          *SubmitAction          `json:"submit,omitempty"`
+	Success         []SuccessRule          `json:"success,omitempty"`      // Criteria for a successful submission
+}
+
+// Clone returns a deep copy of the template
+func (t *FormTemplate) Clone() *FormTemplate {
+	clone := *t
+
+	clone.URLPatterns = append([]string(nil), t.URLPatterns...)
+	clone.Fields = make([]FormField, len(t.Fields))
+	for i, field := range t.Fields {
+		field.Candidates = append([]SelectorCandidate(nil), field.Candidates...)
+		field.Fallbacks = append([]string(nil), field.Fallbacks...)
+		field.Transforms = append([]string(nil), field.Transforms...)
+		clone.Fields[i] = field
+	}
+
+	if t.Selectors != nil {
+		clone.Selectors = make(map[string]string, len(t.Selectors))
+		for name, selector := range t.Selectors {
+			clone.Selectors[name] = selector
+		}
+	}
+
+	clone.ValidationRules = append([]ValidationRule(nil), t.ValidationRules...)
+	clone.Steps = append([]TemplateStep(nil), t.Steps...)
+	clone.Success = append([]SuccessRule(nil), t.Success...)
+
+	if t.RetryPolicy != nil {
+		clone.RetryPolicy = t.RetryPolicy.Merge(nil)
+	}
+	if t.HostLimits != nil {
+		limits := *t.HostLimits
+		clone.HostLimits = &limits
+	}
+	if t.Stats != nil {
+		stats := *t.Stats
+		if t.Stats.FieldFailures != nil {
+			stats.FieldFailures = make(map[string]int, len(t.Stats.FieldFailures))
+			for field, failures := range t.Stats.FieldFailures {
+				stats.FieldFailures[field] = failures
+			}
+		}
+		clone.Stats = &stats
+	}
+	if t.Submit != nil {
+		submit := *t.Submit
+		clone.Submit = &submit
+	}
+
+	return &clone
+}
+
+// Field returns the field with the given name
+func (t *FormTemplate) Field(name string) (*FormField, bool) {
+	for i := range t.Fields {
+		if t.Fields[i].Name == name {
+			return &t.Fields[i], true
+		}
+	}
+	return nil, false
+}
+
+// RecordWorkingSelectors updates each field with the selector that filled
+// it and reports whether anything changed
+func (t *FormTemplate) RecordWorkingSelectors(selectors map[string]string) bool {
+	changed := false
+	for i := range t.Fields {
+		field := &t.Fields[i]
+		selector, ok := selectors[field.Name]
+		if !ok || selector == field.WorkingSelector {
+			continue
+		}
+
+		field.WorkingSelector = selector
+		changed = true
+	}
+	return changed
 }
 
 // FormField represents a form input field
@@ -58,14 +145,64 @@ type FormField struct {
 	Required         bool   `json:"required"`
 	ValidationPattern string `json:"validation_pattern,omitempty"`
 	DefaultValue     string `json:"default_value,omitempty"`
+	Candidates       []SelectorCandidate `json:"candidates,omitempty"`       // Ranked alternatives, best first
+	WorkingSelector  string `json:"working_selector,omitempty"` // Selector that last filled the field
+	Fallbacks        []string `json:"fallbacks,omitempty"`      // Hand-written selectors tried after the others
+	Binding          string `json:"binding,omitempty"`          // Profile value to fill, e.g. "address.city"
+	Value            string `json:"value,omitempty"`            // Constant to fill instead of a profile value
+	Transforms       []string `json:"transforms,omitempty"`     // Applied to the value in order, e.g. "digits"
+}
+
+// Template step actions
+const (
+	StepClick  = "click"
+	StepWait   = "wait"
+	StepFill   = "fill"
+	StepCheck  = "check"
+	StepSelect = "select"
+	StepPress  = "press"
+)
+
+// TemplateStep is an action performed on the page while filling, such as
+// dismissing a banner or opening the next section of a form
+type TemplateStep struct {
+	Action   string        `json:"action" yaml:"action"`
+	Selector string        `json:"selector,omitempty" yaml:"selector,omitempty"`
+	Field    string        `json:"field,omitempty" yaml:"field,omitempty"` // Field to fill for fill steps
+	Value    string        `json:"value,omitempty" yaml:"value,omitempty"` // Option for select steps, key for press steps
+	Timeout  time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// SubmitAction describes how to submit the form
+type SubmitAction struct {
+	Selector string        `json:"selector" yaml:"selector"`
+	WaitFor  string        `json:"wait_for,omitempty" yaml:"wait_for,omitempty"` // Selector that appears once the submission is handled
+	Timeout  time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// Success rule types. More can be added with RegisterSuccessRule.
+const (
+	SuccessURLMatches    = "url_matches"
+	SuccessElementText   = "element_text"
+	SuccessElementAbsent = "element_absent"
+	SuccessResponseOK    = "response_ok" // A 2xx response for the form action
+	SuccessIndicators    = "indicators"  // Common success and error elements; the default
+)
+
+// SuccessRule is one criterion for a successful submission
+type SuccessRule struct {
+	Type     string `json:"type" yaml:"type"`
+	Pattern  string `json:"pattern,omitempty" yaml:"pattern,omitempty"` // Regular expression for url_matches, or the response URL for response_ok
+	Selector string `json:"selector,omitempty" yaml:"selector,omitempty"`
+	Text     string `json:"text,omitempty" yaml:"text,omitempty"` // Text the element must contain for element_text
 }
 
 // ValidationRule represents a form validation rule
 type ValidationRule struct {
-	Field   string `json:"field"`
-	Type    string `json:"type"`
-	Pattern string `json:"pattern"`
-	Message string `json:"message"`
+	Field   string `json:"field" yaml:"field"`
+	Type    string `json:"type" yaml:"type"`
+	Pattern string `json:"pattern" yaml:"pattern,omitempty"`
+	Message string `json:"message" yaml:"message,omitempty"`
 }
 
 // FillResult represents the result of a form filling operation
@@ -77,6 +214,11 @@ type FillResult struct {
 	ExecutionTime time.Duration     `json:"execution_time"`
 	Errors        []string          `json:"errors"`
 	Screenshots   []string          `json:"screenshots"`
+	Artifacts     *URLArtifacts     `json:"artifacts,omitempty"`
+	WorkingSelectors map[string]string `json:"working_selectors,omitempty"` // Field name to the selector that filled it
+	MissingFields []string          `json:"missing_fields,omitempty"` // Fields none of whose selectors matched
+	FailedFields  []string          `json:"failed_fields,omitempty"`  // Fields that could not be filled for any reason
+	SubmissionAttempted bool        `json:"submission_attempted"` // Set once a submit was triggered
 	URL           string            `json:"url"`
 	Timestamp     time.Time         `json:"timestamp"`
 }
@@ -111,62 +253,110 @@ func NewFormFiller(browserManager *BrowserManager, config *FormFillerConfig) *Fo
 	}
 }
 
+// SetArtifactManager enables storing screenshots, traces and HAR files in
+// the artifact manager's per-session directories
+func (ff *FormFiller) SetArtifactManager(artifacts *ArtifactManager) {
+	ff.artifacts = artifacts
+}
+
 // FillForm fills a form using the provided template and profile data
 func (ff *FormFiller) FillForm(ctx context.Context, template *FormTemplate, profileData *ProfileData) (*FillResult, error) {
-	startTime := time.Now()
-	result := &FillResult{
+	return ff.FillFormForSession(ctx, "", template.URL, template, profileData)
+}
+
+// FillFormForSession fills the form at pageURL and stores its artifacts
+// under the given execution session. The template may have been learned on
+// another URL that its patterns or domain match. An empty session ID groups
+// artifacts by start time.
+func (ff *FormFiller) FillFormForSession(ctx context.Context, sessionID, pageURL string, template *FormTemplate, profileData *ProfileData) (*FillResult, error) {
+	session, err := ff.OpenPageSession(ctx, sessionID, pageURL)
+	if err != nil {
+		result := newFillResult(template, time.Now())
+		result.URL = pageURL
+		result.Errors = append(result.Errors, err.Error())
+		return result, err
+	}
+	defer session.Close()
+
+	return session.Fill(ctx, template, profileData)
+}
+
+// newFillResult creates an empty result for filling a template
+func newFillResult(template *FormTemplate, startTime time.Time) *FillResult {
+	return &FillResult{
 		URL:         template.URL,
 		Timestamp:   startTime,
 		TotalFields: len(template.Fields),
 		Screenshots: []string{},
 		Errors:      []string{},
+		WorkingSelectors: make(map[string]string),
 	}
+}
 
-	// Create a new page
-	page, err := ff.browserManager.CreatePage(DefaultBrowserConfig())
-	if err != nil {
-		result.Errors = append(result.Errors, fmt.Sprintf("Failed to create page: %v", err))
-		return result, err
-	}
-	defer (*page).Close()
-
-	// Navigate to the form URL
-	_, err = (*page).Goto(template.URL, playwright.PageGotoOptions{
-		WaitUntil: playwright.WaitUntilStateNetworkidle,
-		Timeout:   playwright.Float(30000),
-	})
-	if err != nil {
-		result.Errors = append(result.Errors, fmt.Sprintf("Failed to navigate to %s: %v", template.URL, err))
-		return result, err
+// fillPage fills the template's fields and runs its steps on an open page
+func (ff *FormFiller) fillPage(ctx context.Context, page *playwright.Page, template *FormTemplate, profileData *ProfileData, result *FillResult) error {
+	// Take initial screenshot if enabled
+	if ff.config.TakeScreenshots {
+		ff.takeScreenshot(page, result, ArtifactStageBeforeFill)
 	}
 
-	// Wait for page to load
-	time.Sleep(ff.config.WaitForLoad)
+	// Fill each field, interleaved with the template's steps
+	var firstFieldErr error
+	filled := make(map[string]bool, len(template.Fields))
+	fill := func(field FormField) {
+		filled[field.Name] = true
+		selector, err := ff.fillField(ctx, page, &field, profileData)
 
-	// Take initial screenshot if enabled
-	if ff.config.TakeScreenshots {
-		screenshotPath := fmt.Sprintf("screenshot_%s_initial.png", time.Now().Format("20060102_150405"))
-		_, err = (*page).Screenshot(playwright.PageScreenshotOptions{
-			Path: &screenshotPath,
-		})
-		if err == nil {
-			result.Screenshots = append(result.Screenshots, screenshotPath)
+		event := FillEvent{Kind: FillEventField, Field: field.Name, Selector: selector, Success: err == nil, Completed: len(filled), Total: len(template.Fields)}
+		if err != nil {
+			event.Error = err.Error()
 		}
-	}
+		reportFillProgress(ctx, event)
 
-	// Fill each field
-	for _, field := range template.Fields {
-		err := ff.fillField(ctx, page, &field, profileData)
 		if err != nil {
 			result.Errors = append(result.Errors, fmt.Sprintf("Failed to fill field %s: %v", field.Name, err))
-			continue
+			result.FailedFields = append(result.FailedFields, field.Name)
+			if firstFieldErr == nil {
+				firstFieldErr = err
+			}
+			if errors.Is(err, ErrSelectorMissing) {
+				result.MissingFields = append(result.MissingFields, field.Name)
+			}
+			return
 		}
 		result.FilledFields++
+		result.WorkingSelectors[field.Name] = selector
 
 		// Add delay between field fills
 		time.Sleep(ff.config.FillDelay)
 	}
 
+	for i, step := range template.Steps {
+		if step.Action == StepFill {
+			if field, ok := template.Field(step.Field); ok && !filled[field.Name] {
+				fill(*field)
+			}
+			continue
+		}
+
+		err := ff.runStep(page, step)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("Failed step %d (%s): %v", i+1, step.Action, err))
+		}
+
+		event := FillEvent{Kind: FillEventStep, Step: i + 1, Action: step.Action, Selector: step.Selector, Success: err == nil, Completed: len(filled), Total: len(template.Fields)}
+		if err != nil {
+			event.Error = err.Error()
+		}
+		reportFillProgress(ctx, event)
+	}
+
+	for _, field := range template.Fields {
+		if !filled[field.Name] {
+			fill(field)
+		}
+	}
+
 	// Calculate success rate
 	if result.TotalFields > 0 {
 		result.SuccessRate = float64(result.FilledFields) / float64(result.TotalFields) * 100
@@ -174,76 +364,254 @@ func (ff *FormFiller) FillForm(ctx context.Context, template *FormTemplate, prof
 
 	// Take final screenshot if enabled
 	if ff.config.TakeScreenshots {
-		screenshotPath := fmt.Sprintf("screenshot_%s_final.png", time.Now().Format("20060102_150405"))
-		_, err = (*page).Screenshot(playwright.PageScreenshotOptions{
-			Path: &screenshotPath,
-		})
-		if err == nil {
-			result.Screenshots = append(result.Screenshots, screenshotPath)
-		}
+		ff.takeScreenshot(page, result, ArtifactStageAfterFill)
 	}
 
-	result.ExecutionTime = time.Since(startTime)
 	result.Success = result.FilledFields > 0 && len(result.Errors) == 0
 
-	return result, nil
+	// A form where no field could be filled is a failure; report why
+	if result.FilledFields == 0 && firstFieldErr != nil {
+		return firstFieldErr
+	}
+
+	return nil
 }
 
-// fillField fills a single form field with appropriate data
-func (ff *FormFiller) fillField(ctx context.Context, page *playwright.Page, field *FormField, profileData *ProfileData) error {
+// runStep performs a non-fill template step on the page
+func (ff *FormFiller) runStep(page *playwright.Page, step TemplateStep) error {
+	timeout := step.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	milliseconds := playwright.Float(float64(timeout.Milliseconds()))
+
+	var err error
+	switch step.Action {
+	case StepClick:
+		err = (*page).Click(step.Selector, playwright.PageClickOptions{Timeout: milliseconds})
+	case StepWait:
+		_, err = (*page).WaitForSelector(step.Selector, playwright.PageWaitForSelectorOptions{
+			State:   playwright.WaitForSelectorStateVisible,
+			Timeout: milliseconds,
+		})
+	case StepCheck:
+		err = (*page).Check(step.Selector, playwright.PageCheckOptions{Timeout: milliseconds})
+	case StepSelect:
+		_, err = (*page).SelectOption(step.Selector, playwright.SelectOptionValues{
+			Values: &[]string{step.Value},
+		}, playwright.PageSelectOptionOptions{Timeout: milliseconds})
+	case StepPress:
+		err = (*page).Press(step.Selector, step.Value, playwright.PagePressOptions{Timeout: milliseconds})
+	default:
+		return fmt.Errorf("unknown step action: %s", step.Action)
+	}
+
+	if err != nil {
+		stepErr := newAutomationError(ErrSelectorMissing, step.Action, err)
+		stepErr.Selector = step.Selector
+		return stepErr
+	}
+
+	return nil
+}
+
+// takeScreenshot captures the page for the given stage and records its path in the result
+func (ff *FormFiller) takeScreenshot(page *playwright.Page, result *FillResult, stage string) {
+	screenshotPath := fmt.Sprintf("screenshot_%s_%s.png", time.Now().Format("20060102_150405"), stage)
+	if result.Artifacts != nil {
+		screenshotPath = ff.artifacts.ScreenshotPath(result.Artifacts, stage)
+	}
+
+	_, err := (*page).Screenshot(playwright.PageScreenshotOptions{
+		Path: &screenshotPath,
+	})
+	if err != nil {
+		return
+	}
+
+	result.Screenshots = append(result.Screenshots, screenshotPath)
+	if result.Artifacts != nil {
+		switch stage {
+		case ArtifactStageBeforeFill:
+			result.Artifacts.BeforeScreenshot = screenshotPath
+		case ArtifactStageAfterFill:
+			result.Artifacts.AfterScreenshot = screenshotPath
+		}
+	}
+}
+
+// fillField fills a single form field with appropriate data and returns the
+// selector that located it
+func (ff *FormFiller) fillField(ctx context.Context, page *playwright.Page, field *FormField, profileData *ProfileData) (string, error) {
 	// Get the value to fill based on field type and name
 	value := ff.getFieldValue(field, profileData)
 	if value == "" {
-		return fmt.Errorf("no value found for field %s", field.Name)
+		return "", fmt.Errorf("no value found for field %s", field.Name)
 	}
 
-	// Wait for the element to be visible
-	_, err := (*page).WaitForSelector(field.Selector, playwright.PageWaitForSelectorOptions{
-		State:   playwright.WaitForSelectorStateVisible,
-		Timeout: playwright.Float(10000),
-	})
+	// Find the first candidate selector that matches a visible element
+	selector, err := ff.resolveFieldSelector(page, field)
 	if err != nil {
-		return fmt.Errorf("element not found or not visible: %s", field.Selector)
+		return "", err
 	}
 
 	// Handle different field types
 	switch field.Type {
 	case "text", "email", "password", "tel", "url":
-		err = (*page).Fill(field.Selector, value)
+		err = (*page).Fill(selector, value)
 	case "textarea":
-		err = (*page).Fill(field.Selector, value)
+		err = (*page).Fill(selector, value)
 	case "select":
-		_, err = (*page).SelectOption(field.Selector, playwright.SelectOptionValues{
+		_, err = (*page).SelectOption(selector, playwright.SelectOptionValues{
 			Values: &[]string{value},
 		})
 	case "checkbox":
 		if value == "true" || value == "1" || value == "yes" {
-			err = (*page).Check(field.Selector)
+			err = (*page).Check(selector)
 		}
 	case "radio":
-		err = (*page).Check(field.Selector)
+		err = (*page).Check(selector)
 	default:
-		err = (*page).Fill(field.Selector, value)
+		err = (*page).Fill(selector, value)
+	}
+
+	if err != nil {
+		return "", fillFieldError(selector, err)
+	}
+
+	// Trigger change events; dispatching through Playwright works for XPath candidates too
+	for _, event := range []string{"input", "change"} {
+		if err := (*page).DispatchEvent(selector, event, map[string]interface{}{"bubbles": true}); err != nil {
+			return "", err
+		}
+	}
+
+	return selector, nil
+}
+
+// Playwright messages about a field's element that went away, is hidden or
+// is not the kind of element the field expects
+var missingElementMessages = []string{
+	"not attached to the dom",
+	"element is detached",
+	"element is not visible",
+	"not an <input>",
+	"not a <select>",
+	"not a checkbox or radio",
+}
+
+// Playwright messages about a value the page did not accept
+var rejectedValueMessages = []string{
+	"malformed value",
+	"cannot type text into",
+	"did not find some options",
+	"element is not editable",
+	"element is not enabled",
+}
+
+// fillFieldError classifies why Playwright could not fill a field. Causes
+// that are neither a timeout, a missing element nor a refused value are
+// returned unclassified.
+func fillFieldError(selector string, cause error) error {
+	kind := classifyCause(cause, nil)
+	if kind == nil {
+		message := strings.ToLower(cause.Error())
+		if containsAny(message, missingElementMessages) {
+			kind = ErrSelectorMissing
+		} else if containsAny(message, rejectedValueMessages) {
+			kind = ErrValidationRejected
+		}
+	}
+	if kind == nil {
+		return fmt.Errorf("fill field (selector %s): %w", selector, cause)
+	}
+
+	return &AutomationError{Kind: kind, Op: "fill field", Selector: selector, Err: cause}
+}
+
+// containsAny reports whether s contains any of the substrings
+func containsAny(s string, substrings []string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveFieldSelector returns the first selector for the field that matches
+// a visible element. Alternatives are checked without waiting; if none match
+// yet, the preferred selector gets the full wait for late-rendered fields.
+func (ff *FormFiller) resolveFieldSelector(page *playwright.Page, field *FormField) (string, error) {
+	selectors := fieldSelectors(field)
+	if len(selectors) == 0 {
+		return "", &AutomationError{Kind: ErrSelectorMissing, Op: "wait for field", Err: fmt.Errorf("field %s has no selector", field.Name)}
+	}
+
+	for _, selector := range selectors {
+		if visible, err := (*page).IsVisible(selector); err == nil && visible {
+			return selector, nil
+		}
 	}
 
+	_, err := (*page).WaitForSelector(selectors[0], playwright.PageWaitForSelectorOptions{
+		State:   playwright.WaitForSelectorStateVisible,
+		Timeout: playwright.Float(10000),
+	})
 	if err != nil {
-		return fmt.Errorf("failed to fill field: %w", err)
+		missingErr := newAutomationError(ErrSelectorMissing, "wait for field", err)
+		missingErr.Selector = strings.Join(selectors, ", ")
+		return "", missingErr
 	}
 
-	// Trigger change event
-	_, err = (*page).Evaluate(fmt.Sprintf(`
-		const element = document.querySelector('%s');
-		if (element) {
-			element.dispatchEvent(new Event('input', { bubbles: true }));
-			element.dispatchEvent(new Event('change', { bubbles: true }));
+	return selectors[0], nil
+}
+
+// fieldSelectors lists the selectors to try for a field in order: the one
+// that worked last time, the ranked candidates, the primary selector, then
+// any hand-written fallbacks
+func fieldSelectors(field *FormField) []string {
+	var selectors []string
+	seen := make(map[string]bool)
+
+	add := func(selector string) {
+		if selector != "" && !seen[selector] {
+			seen[selector] = true
+			selectors = append(selectors, selector)
 		}
-	`, field.Selector))
+	}
 
-	return err
+	add(field.WorkingSelector)
+	for _, candidate := range field.Candidates {
+		add(candidate.Selector)
+	}
+	add(field.Selector)
+	for _, fallback := range field.Fallbacks {
+		add(fallback)
+	}
+
+	return selectors
 }
 
-// getFieldValue maps form fields to profile data
+// getFieldValue returns the value to fill into a field with its transforms applied
 func (ff *FormFiller) getFieldValue(field *FormField, profileData *ProfileData) string {
+	return applyTransforms(ff.rawFieldValue(field, profileData), field.Transforms)
+}
+
+// rawFieldValue maps form fields to profile data. Constants and explicit
+// bindings take precedence over matching on the field name.
+func (ff *FormFiller) rawFieldValue(field *FormField, profileData *ProfileData) string {
+	if field.Value != "" {
+		return field.Value
+	}
+
+	if field.Binding != "" {
+		if resolve, ok := profileBindings[field.Binding]; ok {
+			return resolve(profileData)
+		}
+		return ""
+	}
+
 	// Create field mappings based on common field names and types
 	fieldMappings := map[string]string{
 		"email":      profileData.Email,
@@ -293,6 +661,45 @@ func (ff *FormFiller) getFieldValue(field *FormField, profileData *ProfileData)
 	return ""
 }
 
+// profileBindings resolves the profile values a template field can be bound to
+var profileBindings = map[string]func(*ProfileData) string{
+	"first_name":       func(p *ProfileData) string { return p.FirstName },
+	"last_name":        func(p *ProfileData) string { return p.LastName },
+	"full_name":        func(p *ProfileData) string { return strings.TrimSpace(p.FirstName + " " + p.LastName) },
+	"email":            func(p *ProfileData) string { return p.Email },
+	"phone":            func(p *ProfileData) string { return p.Phone },
+	"address.street":   func(p *ProfileData) string { return p.Address.Street },
+	"address.city":     func(p *ProfileData) string { return p.Address.City },
+	"address.state":    func(p *ProfileData) string { return p.Address.State },
+	"address.zip_code": func(p *ProfileData) string { return p.Address.ZipCode },
+	"address.country":  func(p *ProfileData) string { return p.Address.Country },
+}
+
+// valueTransforms are the transforms a template field can apply to its value
+var valueTransforms = map[string]func(string) string{
+	"trim":      strings.TrimSpace,
+	"lowercase": strings.ToLower,
+	"uppercase": strings.ToUpper,
+	"digits": func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, s)
+	},
+}
+
+// applyTransforms applies the named transforms in order, skipping unknown names
+func applyTransforms(value string, transforms []string) string {
+	for _, name := range transforms {
+		if transform, ok := valueTransforms[name]; ok {
+			value = transform(value)
+		}
+	}
+	return value
+}
+
 // contains checks if a string contains a substring (case-insensitive)
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && 
@@ -318,6 +725,11 @@ func (ff *FormFiller) SubmitForm(ctx context.Context, page *playwright.Page, tem
 	// Wait before submitting
 	time.Sleep(ff.config.SubmitDelay)
 
+	// Use the template's submit action when it declares one
+	if template.Submit != nil && template.Submit.Selector != "" {
+		return ff.runSubmitAction(page, template)
+	}
+
 	// Look for submit button
 	submitSelectors := []string{
 		"input[type='submit']",
@@ -343,7 +755,34 @@ func (ff *FormFiller) SubmitForm(ctx context.Context, page *playwright.Page, tem
 		}
 	}
 
-	return fmt.Errorf("no submit button found")
+	return &AutomationError{Kind: ErrSubmissionFailed, Op: "submit form", URL: template.URL, Err: fmt.Errorf("no submit button found")}
+}
+
+// runSubmitAction clicks the template's submit selector and waits for the
+// declared confirmation element
+func (ff *FormFiller) runSubmitAction(page *playwright.Page, template *FormTemplate) error {
+	submit := template.Submit
+	timeout := submit.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	milliseconds := playwright.Float(float64(timeout.Milliseconds()))
+
+	if err := (*page).Click(submit.Selector, playwright.PageClickOptions{Timeout: milliseconds}); err != nil {
+		return &AutomationError{Kind: ErrSubmissionFailed, Op: "submit form", URL: template.URL, Selector: submit.Selector, Err: err}
+	}
+
+	if submit.WaitFor != "" {
+		_, err := (*page).WaitForSelector(submit.WaitFor, playwright.PageWaitForSelectorOptions{
+			State:   playwright.WaitForSelectorStateVisible,
+			Timeout: milliseconds,
+		})
+		if err != nil {
+			return &AutomationError{Kind: ErrSubmissionFailed, Op: "wait for submission", URL: template.URL, Selector: submit.WaitFor, Err: err}
+		}
+	}
+
+	return nil
 }
 
 // ValidateForm validates form fields before submission
@@ -0,0 +1,103 @@
+package automation
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+func TestFieldSelectorsOrder(t *testing.T) {
+	field := &FormField{
+		Name:            "email",
+		Selector:        "#email",
+		WorkingSelector: "[name=\"email\"]",
+		Candidates: []SelectorCandidate{
+			{Selector: "#email", Strategy: "ID", Stability: 100},
+			{Selector: "[name=\"email\"]", Strategy: "Name", Stability: 90},
+			{Selector: "//form/input[2]", Strategy: "XPath", Stability: 60},
+		},
+	}
+
+	selectors := fieldSelectors(field)
+
+	expected := []string{"[name=\"email\"]", "#email", "//form/input[2]"}
+	if len(selectors) != len(expected) {
+		t.Fatalf("Expected %d selectors, got %v", len(expected), selectors)
+	}
+	for i := range expected {
+		if selectors[i] != expected[i] {
+			t.Errorf("Position %d: expected %s, got %s", i, expected[i], selectors[i])
+		}
+	}
+}
+
+func TestFieldSelectorsLegacyTemplate(t *testing.T) {
+	selectors := fieldSelectors(&FormField{Name: "email", Selector: "#email"})
+
+	if len(selectors) != 1 || selectors[0] != "#email" {
+		t.Errorf("Expected only the primary selector, got %v", selectors)
+	}
+}
+
+func TestRecordWorkingSelectors(t *testing.T) {
+	template := &FormTemplate{
+		Fields: []FormField{
+			{Name: "email", Selector: "#email"},
+			{Name: "phone", Selector: "#phone", WorkingSelector: "#phone"},
+		},
+	}
+
+	changed := template.RecordWorkingSelectors(map[string]string{
+		"email": "[name=\"email\"]",
+		"phone": "#phone",
+	})
+
+	if !changed {
+		t.Error("Expected template to change")
+	}
+
+	if template.Fields[0].WorkingSelector != "[name=\"email\"]" {
+		t.Errorf("Expected working selector to be recorded, got %s", template.Fields[0].WorkingSelector)
+	}
+
+	if template.RecordWorkingSelectors(map[string]string{"phone": "#phone"}) {
+		t.Error("Expected no change when selectors are already recorded")
+	}
+}
+
+func TestFillFieldError(t *testing.T) {
+	tests := []struct {
+		name     string
+		cause    error
+		expected error
+	}{
+		{"timeout", fmt.Errorf("%w: waiting for element to be visible, element is not visible", playwright.ErrTimeout), ErrTimeout},
+		{"detached", errors.New("Element is not attached to the DOM"), ErrSelectorMissing},
+		{"hidden", errors.New("element is not visible"), ErrSelectorMissing},
+		{"wrong element", errors.New("Error: Element is not an <input>, <textarea> or <select> element"), ErrSelectorMissing},
+		{"malformed value", errors.New("Error: Malformed value"), ErrValidationRejected},
+		{"unknown option", errors.New("did not find some options"), ErrValidationRejected},
+		{"browser closed", playwright.ErrTargetClosed, ErrBrowserCrashed},
+	}
+
+	for _, tt := range tests {
+		err := fillFieldError("#email", tt.cause)
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, err)
+		}
+		var fillErr *AutomationError
+		if errors.As(err, &fillErr) && fillErr.Selector != "#email" {
+			t.Errorf("%s: expected the selector to be kept, got %q", tt.name, fillErr.Selector)
+		}
+	}
+
+	err := fillFieldError("#email", errors.New("boom"))
+	if errors.Is(err, ErrValidationRejected) || errors.Is(err, ErrSelectorMissing) || errors.Is(err, ErrTimeout) {
+		t.Errorf("Expected an unknown cause to stay unclassified, got %v", err)
+	}
+	if errorType, _ := ClassifyError(err); errorType != ErrorTypeExecution {
+		t.Errorf("Expected an unknown cause to be an execution error, got %s", errorType)
+	}
+}
@@ -0,0 +1,143 @@
+package automation
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HostLimits bounds how hard a single site is hit during a session
+type HostLimits struct {
+	MaxConcurrency int           `json:"maxConcurrency" mapstructure:"max_concurrency" yaml:"max_concurrency,omitempty"` // Parallel browsers per host, 0 = unlimited
+	MinInterval    time.Duration `json:"minInterval" mapstructure:"min_interval" yaml:"min_interval,omitempty"`          // Minimum time between task starts on a host
+}
+
+// DefaultHostLimits returns sensible defaults
+func DefaultHostLimits() HostLimits {
+	return HostLimits{
+		MaxConcurrency: 2,
+		MinInterval:    1 * time.Second,
+	}
+}
+
+// Restrict returns the stricter of the two limits for each setting. Zero
+// values in other are ignored so templates only need to set what they change.
+func (hl HostLimits) Restrict(other *HostLimits) HostLimits {
+	if other == nil {
+		return hl
+	}
+
+	if other.MaxConcurrency > 0 && (hl.MaxConcurrency == 0 || other.MaxConcurrency < hl.MaxConcurrency) {
+		hl.MaxConcurrency = other.MaxConcurrency
+	}
+	if other.MinInterval > hl.MinInterval {
+		hl.MinInterval = other.MinInterval
+	}
+
+	return hl
+}
+
+// hostScheduler hands out URL tasks round-robin across hosts while keeping
+// each host within its concurrency cap and minimum start interval
+type hostScheduler struct {
+	taskHosts []string
+	queues    map[string][]int
+	hosts     []string // Hosts in first-seen order
+	cursor    int
+	limits    map[string]HostLimits
+	active    map[string]int
+	lastStart map[string]time.Time
+	remaining int
+}
+
+// newHostScheduler groups tasks by host. Template limits tighten the global
+// limits for every task on the same host.
+func newHostScheduler(tasks []URLTask, global HostLimits) *hostScheduler {
+	hs := &hostScheduler{
+		taskHosts: make([]string, len(tasks)),
+		queues:    make(map[string][]int),
+		limits:    make(map[string]HostLimits),
+		active:    make(map[string]int),
+		lastStart: make(map[string]time.Time),
+		remaining: len(tasks),
+	}
+
+	for i, task := range tasks {
+		host := taskHost(task.URL)
+		hs.taskHosts[i] = host
+
+		if _, exists := hs.queues[host]; !exists {
+			hs.hosts = append(hs.hosts, host)
+			hs.limits[host] = global
+		}
+		hs.queues[host] = append(hs.queues[host], i)
+
+		if task.Template != nil {
+			hs.limits[host] = hs.limits[host].Restrict(task.Template.HostLimits)
+		}
+	}
+
+	return hs
+}
+
+// next returns the index of the next task that may start. When no task is
+// ready, wait is how long until a host's interval elapses, or zero if every
+// pending host is at its concurrency cap and a release must happen first.
+func (hs *hostScheduler) next(now time.Time) (index int, wait time.Duration, ok bool) {
+	for i := 0; i < len(hs.hosts); i++ {
+		position := (hs.cursor + i) % len(hs.hosts)
+		host := hs.hosts[position]
+		queue := hs.queues[host]
+		if len(queue) == 0 {
+			continue
+		}
+
+		limits := hs.limits[host]
+		if limits.MaxConcurrency > 0 && hs.active[host] >= limits.MaxConcurrency {
+			continue
+		}
+
+		if last, started := hs.lastStart[host]; started {
+			if remaining := last.Add(limits.MinInterval).Sub(now); remaining > 0 {
+				if wait == 0 || remaining < wait {
+					wait = remaining
+				}
+				continue
+			}
+		}
+
+		hs.queues[host] = queue[1:]
+		hs.active[host]++
+		hs.lastStart[host] = now
+		hs.remaining--
+		hs.cursor = position + 1
+		return queue[0], 0, true
+	}
+
+	return -1, wait, false
+}
+
+// release marks a task as finished, freeing a slot on its host
+func (hs *hostScheduler) release(index int) {
+	hs.active[hs.taskHosts[index]]--
+}
+
+// drain removes all pending tasks and returns their indexes
+func (hs *hostScheduler) drain() []int {
+	var pending []int
+	for _, host := range hs.hosts {
+		pending = append(pending, hs.queues[host]...)
+		hs.queues[host] = nil
+	}
+	hs.remaining = 0
+	return pending
+}
+
+// taskHost returns the normalized host used to group a task URL
+func taskHost(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Hostname() == "" {
+		return strings.ToLower(rawURL)
+	}
+	return strings.ToLower(parsed.Hostname())
+}
@@ -0,0 +1,93 @@
+package automation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHostSchedulerInterleavesHosts(t *testing.T) {
+	tasks := []URLTask{
+		{URL: "https://a.example.com/1"},
+		{URL: "https://a.example.com/2"},
+		{URL: "https://a.example.com/3"},
+		{URL: "https://b.example.com/1"},
+		{URL: "https://c.example.com/1"},
+	}
+
+	scheduler := newHostScheduler(tasks, HostLimits{MaxConcurrency: 1})
+	now := time.Now()
+
+	var order []int
+	for {
+		index, _, ok := scheduler.next(now)
+		if !ok {
+			break
+		}
+		order = append(order, index)
+	}
+
+	expected := []int{0, 3, 4}
+	if len(order) != len(expected) {
+		t.Fatalf("Expected %d tasks to start, got %v", len(expected), order)
+	}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Errorf("Expected start order %v, got %v", expected, order)
+			break
+		}
+	}
+
+	scheduler.release(0)
+	if index, _, ok := scheduler.next(now); !ok || index != 1 {
+		t.Errorf("Expected task 1 after releasing host a, got %d (ok=%v)", index, ok)
+	}
+}
+
+func TestHostSchedulerMinInterval(t *testing.T) {
+	tasks := []URLTask{
+		{URL: "https://example.com/1"},
+		{URL: "https://example.com/2"},
+	}
+
+	scheduler := newHostScheduler(tasks, HostLimits{MinInterval: 2 * time.Second})
+	now := time.Now()
+
+	if _, _, ok := scheduler.next(now); !ok {
+		t.Fatal("Expected first task to start immediately")
+	}
+
+	_, wait, ok := scheduler.next(now.Add(500 * time.Millisecond))
+	if ok {
+		t.Fatal("Expected second task to wait for the host interval")
+	}
+	if wait != 1500*time.Millisecond {
+		t.Errorf("Expected wait of 1.5s, got %v", wait)
+	}
+
+	if index, _, ok := scheduler.next(now.Add(2 * time.Second)); !ok || index != 1 {
+		t.Errorf("Expected task 1 once the interval elapsed, got %d (ok=%v)", index, ok)
+	}
+}
+
+func TestHostSchedulerTemplateLimits(t *testing.T) {
+	tasks := []URLTask{
+		{URL: "https://example.com/1", Template: &FormTemplate{HostLimits: &HostLimits{MaxConcurrency: 1, MinInterval: 5 * time.Second}}},
+		{URL: "https://EXAMPLE.com/2"},
+	}
+
+	scheduler := newHostScheduler(tasks, HostLimits{MaxConcurrency: 4, MinInterval: time.Second})
+
+	limits := scheduler.limits["example.com"]
+	if limits.MaxConcurrency != 1 || limits.MinInterval != 5*time.Second {
+		t.Errorf("Expected template limits to tighten host limits, got %+v", limits)
+	}
+
+	if len(scheduler.queues) != 1 {
+		t.Errorf("Expected hosts to be grouped case-insensitively, got %d queues", len(scheduler.queues))
+	}
+
+	pending := scheduler.drain()
+	if len(pending) != 2 || scheduler.remaining != 0 {
+		t.Errorf("Expected drain to return all pending tasks, got %v", pending)
+	}
+}
@@ -0,0 +1,163 @@
+package automation
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// PageSession threads one browser page through detecting, filling,
+// verifying, submitting and confirming a form, so the data that is
+// submitted is the data that was filled
+type PageSession struct {
+	filler      *FormFiller
+	page        *playwright.Page
+	recording   *PageRecording
+	url         string
+	artifacts   *URLArtifacts
+	setupErrors []string // Problems preparing the session that fills should report
+
+	submission  *pageSubmissionState
+	submittedAt time.Time
+}
+
+// formValidityScript lists the form controls the browser considers invalid
+const formValidityScript = `() => Array.from(document.querySelectorAll('input, select, textarea'))
+	.filter(el => el.willValidate && !el.checkValidity())
+	.map(el => (el.name || el.id || el.tagName.toLowerCase()) + ': ' + el.validationMessage)`
+
+// OpenPageSession creates a page, prepares its artifacts under the given
+// execution session and navigates to the URL. An empty session ID groups
+// artifacts by start time.
+func (ff *FormFiller) OpenPageSession(ctx context.Context, sessionID, pageURL string) (*PageSession, error) {
+	session := &PageSession{filler: ff, url: pageURL}
+
+	// Prepare artifact locations for this URL
+	if ff.artifacts != nil {
+		if sessionID == "" {
+			sessionID = "adhoc_" + time.Now().Format("20060102_150405")
+		}
+
+		artifacts, err := ff.artifacts.PrepareURL(sessionID, pageURL)
+		if err != nil {
+			session.setupErrors = append(session.setupErrors, fmt.Sprintf("Failed to prepare artifacts: %v", err))
+		} else {
+			session.artifacts = artifacts
+			session.recording = &PageRecording{
+				TracePath: artifacts.Trace,
+				HARPath:   artifacts.HAR,
+			}
+		}
+	}
+
+	page, err := ff.browserManager.CreateRecordedPage(DefaultBrowserConfig(), session.recording)
+	if err != nil {
+		return nil, newAutomationError(ErrBrowserCrashed, "create page", err)
+	}
+	session.page = page
+
+	_, err = (*page).Goto(pageURL, playwright.PageGotoOptions{
+		WaitUntil: playwright.WaitUntilStateNetworkidle,
+		Timeout:   playwright.Float(30000),
+	})
+	if err != nil {
+		session.Close()
+		navErr := newAutomationError(ErrNavigation, "navigate", err)
+		navErr.URL = pageURL
+		return nil, navErr
+	}
+
+	// Wait for page to load
+	time.Sleep(ff.config.WaitForLoad)
+
+	return session, nil
+}
+
+// URL returns the page's current URL
+func (ps *PageSession) URL() string {
+	return (*ps.page).URL()
+}
+
+// Detect analyzes the forms on the page
+func (ps *PageSession) Detect(ctx context.Context, detector *FormDetector) (*FormAnalysisResult, error) {
+	return detector.AnalyzeOpenPage(ctx, ps.page, ps.url)
+}
+
+// Fill fills the template's fields on the page. Filling again overwrites
+// the values of the previous fill.
+func (ps *PageSession) Fill(ctx context.Context, template *FormTemplate, profileData *ProfileData) (*FillResult, error) {
+	startTime := time.Now()
+	result := newFillResult(template, startTime)
+	result.URL = ps.url
+	result.Artifacts = ps.artifacts
+	result.Errors = append(result.Errors, ps.setupErrors...)
+
+	err := ps.filler.fillPage(ctx, ps.page, template, profileData, result)
+	result.ExecutionTime = time.Since(startTime)
+	return result, err
+}
+
+// Verify checks the filled form before it is submitted and returns the
+// problems found: required fields that were not filled and controls the
+// browser reports as invalid
+func (ps *PageSession) Verify(ctx context.Context, template *FormTemplate, fill *FillResult) []string {
+	var problems []string
+
+	failed := make(map[string]bool, len(fill.FailedFields))
+	for _, name := range fill.FailedFields {
+		failed[name] = true
+	}
+	for _, field := range template.Fields {
+		if field.Required && failed[field.Name] {
+			problems = append(problems, fmt.Sprintf("required field %s was not filled", field.Name))
+		}
+	}
+
+	problems = append(problems, ps.filler.ValidateForm(ctx, ps.page, template)...)
+
+	if invalid, err := (*ps.page).Evaluate(formValidityScript); err == nil {
+		if messages, ok := invalid.([]interface{}); ok {
+			for _, message := range messages {
+				problems = append(problems, fmt.Sprintf("invalid %v", message))
+			}
+		}
+	}
+
+	return problems
+}
+
+// Submit submits the filled form. Responses are recorded from just before
+// the submission so Confirm can check them.
+func (ps *PageSession) Submit(ctx context.Context, template *FormTemplate) error {
+	ps.submission = newPageSubmissionState(*ps.page, template)
+	ps.submittedAt = time.Now()
+	return ps.filler.SubmitForm(ctx, ps.page, template)
+}
+
+// Confirm waits for the template's success rules to decide whether the
+// submission succeeded
+func (ps *PageSession) Confirm(ctx context.Context, template *FormTemplate, timeout time.Duration) (*SubmissionResult, error) {
+	if ps.submission == nil {
+		return nil, fmt.Errorf("form was not submitted")
+	}
+
+	ps.submission.waitForSettle(timeout)
+	evaluation := EvaluateSuccessRules(ctx, template.Success, ps.submission, timeout)
+
+	return &SubmissionResult{
+		Success:           evaluation.Success,
+		Verified:          true,
+		SubmissionTime:    time.Since(ps.submittedAt),
+		RedirectURL:       ps.submission.URL(),
+		SuccessIndicators: evaluation.Passed,
+		ErrorMessages:     evaluation.Failed,
+		StatusCode:        ps.submission.actionStatus(),
+	}, nil
+}
+
+// Close closes the page and writes out its trace and HAR files
+func (ps *PageSession) Close() error {
+	return ps.filler.browserManager.CloseRecordedPage(ps.page, ps.recording)
+}
@@ -8,7 +8,7 @@ import (
 	"time"
 
 	"github.com/ai-form-filler/cli/internal/models"
-	"github.com/playwright-community/playwright-go"
+	"github.com/ai-form-filler/cli/internal/storage"
 )
 
 // ProfileFormFiller handles form filling using client profiles with intelligent field mapping
@@ -17,14 +17,17 @@ type ProfileFormFiller struct {
 	formDetector   *FormDetector
 	templateManager *TemplateManager
 	fieldMapper    *FieldMapper
+	healer         *TemplateHealer
 	config         *ProfileFormFillerConfig
 }
 
 // ProfileFormFillerConfig holds configuration for profile-based form filling
 type ProfileFormFillerConfig struct {
 	AutoDetectFields    bool
+	AutoHealTemplates   bool // Re-detect and patch templates whose selectors stop matching
 	UseAIMapping        bool
-	VerifySubmission    bool
+	Submit              bool // Submit the filled form; off by default so fills can be reviewed first
+	VerifySubmission    bool // Wait for the template's success rules after submitting
 	MaxRetries          int
 	RetryDelay          time.Duration
 	SubmissionTimeout   time.Duration
@@ -35,7 +38,9 @@ type ProfileFormFillerConfig struct {
 func DefaultProfileFormFillerConfig() *ProfileFormFillerConfig {
 	return &ProfileFormFillerConfig{
 		AutoDetectFields:    true,
+		AutoHealTemplates:   true,
 		UseAIMapping:        false, // Will be enabled when AI integration is added
+		Submit:              false,
 		VerifySubmission:    true,
 		MaxRetries:          3,
 		RetryDelay:          2 * time.Second,
@@ -51,6 +56,7 @@ type ProfileFillResult struct {
 	TemplateUsed    *FormTemplate          `json:"templateUsed,omitempty"`
 	FieldMappings   map[string]string      `json:"fieldMappings"`
 	UnmappedFields  []string               `json:"unmappedFields"`
+	VerificationErrors []string            `json:"verificationErrors,omitempty"` // Why a filled form was not submitted
 	SubmissionResult *SubmissionResult     `json:"submissionResult,omitempty"`
 	Confidence      float64                `json:"confidence"`
 }
@@ -58,6 +64,7 @@ type ProfileFillResult struct {
 // SubmissionResult represents the result of form submission
 type SubmissionResult struct {
 	Success         bool          `json:"success"`
+	Verified        bool          `json:"verified"` // Success was decided; unset when the submission was not checked
 	SubmissionTime  time.Duration `json:"submissionTime"`
 	RedirectURL     string        `json:"redirectUrl,omitempty"`
 	SuccessIndicators []string    `json:"successIndicators"`
@@ -81,11 +88,18 @@ func NewProfileFormFiller(
 		formDetector:    formDetector,
 		templateManager: templateManager,
 		fieldMapper:     NewFieldMapper(),
+		healer:          NewTemplateHealer(formDetector, templateManager, nil, nil),
 		config:          config,
 	}
 }
 
-// FillFormWithProfile fills a form using a client profile
+// SetLearningStore logs template heals to the given learning session store
+func (pff *ProfileFormFiller) SetLearningStore(store *storage.LearningSessionStore) {
+	pff.healer.learning = store
+}
+
+// FillFormWithProfile fills a form using a client profile. The form is
+// detected, filled, verified, submitted and confirmed on a single page.
 func (pff *ProfileFormFiller) FillFormWithProfile(
 	ctx context.Context,
 	pageURL string,
@@ -93,17 +107,23 @@ func (pff *ProfileFormFiller) FillFormWithProfile(
 ) (*ProfileFillResult, error) {
 	startTime := time.Now()
 
+	session, err := pff.formFiller.OpenPageSession(ctx, "", pageURL)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
 	// Try to find existing template first
 	template, err := pff.templateManager.FindBestTemplate(pageURL)
 	if err != nil && pff.config.AutoDetectFields {
 		// No template found, analyze the page to create one
-		analysis, err := pff.formDetector.AnalyzePage(ctx, pageURL)
+		analysis, err := session.Detect(ctx, pff.formDetector)
 		if err != nil {
 			return nil, fmt.Errorf("failed to analyze page: %w", err)
 		}
 
 		if len(analysis.Forms) == 0 {
-			return nil, fmt.Errorf("no forms detected on page: %s", pageURL)
+			return nil, &AutomationError{Kind: ErrFormNotFound, Op: "detect forms", URL: pageURL}
 		}
 
 		// Use the form with highest confidence
@@ -114,7 +134,7 @@ func (pff *ProfileFormFiller) FillFormWithProfile(
 		}
 
 		// Save the new template
-		if err := pff.templateManager.SaveTemplate(template); err != nil {
+		if err := pff.templateManager.SaveTemplateRevision(template, TemplateAuthorDetector, "detected from "+pageURL); err != nil {
 			// Log error but continue - template saving is not critical for filling
 			fmt.Printf("Warning: failed to save template: %v\n", err)
 		}
@@ -129,11 +149,31 @@ func (pff *ProfileFormFiller) FillFormWithProfile(
 	profileData := pff.convertToProfileData(profile)
 
 	// Fill the form
-	fillResult, err := pff.formFiller.FillForm(ctx, template, profileData)
+	fillResult, err := session.Fill(ctx, template, profileData)
+
+	// Selectors that no longer match usually mean the site changed; heal the
+	// template from the same page and fill again with the patched selectors
+	if pff.config.AutoHealTemplates && fillResult != nil && len(fillResult.MissingFields) > 0 && !fillResult.SubmissionAttempted {
+		healed, healResult, healErr := pff.healer.HealOnPage(ctx, template, session)
+		if healErr == nil {
+			fmt.Printf("Healed template %s: version %d -> %d\n", template.ID, healResult.OldVersion, healResult.NewVersion)
+			template = healed
+			fieldMappings, unmappedFields = pff.fieldMapper.MapProfileToFields(profile, template.Fields)
+			fillResult, err = session.Fill(ctx, template, profileData)
+		} else {
+			fmt.Printf("Warning: failed to heal template: %v\n", healErr)
+		}
+	}
+
 	if err != nil {
 		return nil, fmt.Errorf("failed to fill form: %w", err)
 	}
 
+	// Remember which selector candidates worked for the next fill
+	if err := pff.templateManager.RecordWorkingSelectors(template.ID, fillResult.WorkingSelectors); err != nil {
+		fmt.Printf("Warning: failed to record working selectors: %v\n", err)
+	}
+
 	// Calculate confidence based on mapping success
 	confidence := pff.calculateMappingConfidence(fieldMappings, template.Fields)
 
@@ -146,36 +186,69 @@ func (pff *ProfileFormFiller) FillFormWithProfile(
 		Confidence:     confidence,
 	}
 
-	// Submit form if configured to do so
-	if pff.config.VerifySubmission && fillResult.Success {
-		submissionResult, err := pff.submitAndVerify(ctx, template, pageURL)
-		if err != nil {
-			// Log error but don't fail the entire operation
-			fmt.Printf("Warning: form submission failed: %v\n", err)
-		} else {
-			result.SubmissionResult = submissionResult
+	// Submit the filled form only when asked to and it passes verification
+	if pff.config.Submit && fillResult.Success {
+		result.VerificationErrors = session.Verify(ctx, template, fillResult)
+		if len(result.VerificationErrors) == 0 {
+			result.SubmissionResult = pff.submit(ctx, session, template)
+			fillResult.SubmissionAttempted = true
+		}
+	}
+
+	// Record the outcome in the template's statistics
+	outcome := NewTemplateOutcome(fillResult, result.SubmissionResult)
+	if err := pff.templateManager.RecordTemplateOutcome(template.ID, outcome); err != nil {
+		fmt.Printf("Warning: failed to update template statistics: %v\n", err)
+	}
+
+	result.ExecutionTime = time.Since(startTime)
+	return result, nil
+}
+
+// submit submits the filled form and confirms it when VerifySubmission is set
+func (pff *ProfileFormFiller) submit(ctx context.Context, session *PageSession, template *FormTemplate) *SubmissionResult {
+	startTime := time.Now()
+	if err := session.Submit(ctx, template); err != nil {
+		return &SubmissionResult{
+			Success:        false,
+			Verified:       true,
+			SubmissionTime: time.Since(startTime),
+			ErrorMessages:  []string{err.Error()},
 		}
 	}
 
-	// Update template success rate
-	successRate := 0.0
-	if fillResult.Success {
-		successRate = fillResult.SuccessRate
-		if result.SubmissionResult != nil && result.SubmissionResult.Success {
-			successRate = 100.0
+	if !pff.config.VerifySubmission {
+		// Submitted but unconfirmed
+		return &SubmissionResult{
+			SubmissionTime: time.Since(startTime),
+			RedirectURL:    session.URL(),
 		}
 	}
 
-	if err := pff.templateManager.UpdateTemplateSuccess(template.ID, successRate); err != nil {
-		fmt.Printf("Warning: failed to update template success rate: %v\n", err)
+	timeout := pff.config.SubmissionTimeout
+	if template.Submit != nil && template.Submit.Timeout > 0 {
+		timeout = template.Submit.Timeout
 	}
 
-	result.ExecutionTime = time.Since(startTime)
-	return result, nil
+	submission, err := session.Confirm(ctx, template, timeout)
+	if err != nil {
+		return &SubmissionResult{
+			Success:        false,
+			Verified:       true,
+			SubmissionTime: time.Since(startTime),
+			ErrorMessages:  []string{err.Error()},
+		}
+	}
+	return submission
 }
 
 // convertToProfileData converts a ClientProfile to ProfileData for the form filler
 func (pff *ProfileFormFiller) convertToProfileData(profile *models.ClientProfile) *ProfileData {
+	return newProfileData(profile)
+}
+
+// newProfileData converts a ClientProfile to the profile data fields are filled from
+func newProfileData(profile *models.ClientProfile) *ProfileData {
 	return &ProfileData{
 		FirstName: profile.PersonalData.FirstName,
 		LastName:  profile.PersonalData.LastName,
@@ -207,126 +280,6 @@ func (pff *ProfileFormFiller) calculateMappingConfidence(mappings map[string]str
 	return float64(mappedCount) / float64(len(fields)) * 100.0
 }
 
-// submitAndVerify submits the form and verifies successful submission
-func (pff *ProfileFormFiller) submitAndVerify(ctx context.Context, template *FormTemplate, pageURL string) (*SubmissionResult, error) {
-	// Create a new page for submission
-	page, err := pff.formFiller.browserManager.CreatePage(DefaultBrowserConfig())
-	if err != nil {
-		return nil, fmt.Errorf("failed to create page for submission: %w", err)
-	}
-	defer (*page).Close()
-
-	// Navigate to the page
-	_, err = (*page).Goto(pageURL, playwright.PageGotoOptions{
-		WaitUntil: playwright.WaitUntilStateNetworkidle,
-		Timeout:   playwright.Float(float64(pff.config.SubmissionTimeout.Milliseconds())),
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to navigate to page: %w", err)
-	}
-
-	startTime := time.Now()
-
-	// Submit the form
-	err = pff.formFiller.SubmitForm(ctx, page, template)
-	if err != nil {
-		return &SubmissionResult{
-			Success:        false,
-			SubmissionTime: time.Since(startTime),
-			ErrorMessages:  []string{err.Error()},
-		}, nil
-	}
-
-	// Wait for navigation or response
-	time.Sleep(2 * time.Second)
-
-	// Get current URL to check for redirect
-	currentURL := (*page).URL()
-
-	// Check for success indicators
-	successIndicators, errorMessages := pff.checkSubmissionResult(ctx, page)
-
-	success := len(errorMessages) == 0 && (currentURL != pageURL || len(successIndicators) > 0)
-
-	return &SubmissionResult{
-		Success:           success,
-		SubmissionTime:    time.Since(startTime),
-		RedirectURL:       currentURL,
-		SuccessIndicators: successIndicators,
-		ErrorMessages:     errorMessages,
-	}, nil
-}
-
-// checkSubmissionResult checks the page for success or error indicators
-func (pff *ProfileFormFiller) checkSubmissionResult(ctx context.Context, page *playwright.Page) ([]string, []string) {
-	var successIndicators []string
-	var errorMessages []string
-
-	// Common success indicators
-	successSelectors := []string{
-		".success", ".alert-success", ".message-success",
-		"[class*='success']", "[id*='success']",
-		".confirmation", ".thank-you", ".complete",
-	}
-
-	// Common error indicators
-	errorSelectors := []string{
-		".error", ".alert-error", ".message-error",
-		"[class*='error']", "[id*='error']",
-		".warning", ".alert-warning", ".invalid",
-	}
-
-	// Check for success indicators
-	for _, selector := range successSelectors {
-		elements, err := (*page).QuerySelectorAll(selector)
-		if err != nil {
-			continue
-		}
-
-		for _, element := range elements {
-			text, err := element.TextContent()
-			if err != nil {
-				continue
-			}
-			if text != "" && strings.TrimSpace(text) != "" {
-				successIndicators = append(successIndicators, strings.TrimSpace(text))
-			}
-		}
-	}
-
-	// Check for error indicators
-	for _, selector := range errorSelectors {
-		elements, err := (*page).QuerySelectorAll(selector)
-		if err != nil {
-			continue
-		}
-
-		for _, element := range elements {
-			text, err := element.TextContent()
-			if err != nil {
-				continue
-			}
-			if text != "" && strings.TrimSpace(text) != "" {
-				errorMessages = append(errorMessages, strings.TrimSpace(text))
-			}
-		}
-	}
-
-	// Check page title for success/error keywords
-	title, err := (*page).Title()
-	if err == nil {
-		lowerTitle := strings.ToLower(title)
-		if strings.Contains(lowerTitle, "success") || strings.Contains(lowerTitle, "thank") || strings.Contains(lowerTitle, "complete") {
-			successIndicators = append(successIndicators, fmt.Sprintf("Title: %s", title))
-		}
-		if strings.Contains(lowerTitle, "error") || strings.Contains(lowerTitle, "failed") {
-			errorMessages = append(errorMessages, fmt.Sprintf("Title: %s", title))
-		}
-	}
-
-	return successIndicators, errorMessages
-}
-
 // FieldMapper handles mapping between profile data and form fields
 type FieldMapper struct {
 	fieldPatterns map[string][]*regexp.Regexp
@@ -412,11 +365,19 @@ func (fm *FieldMapper) MapProfileToFields(profile *models.ClientProfile, fields
 		"country":   profile.PersonalData.Address.Country,
 	}
 
+	// Check specific fields before generic ones, so that e.g. an
+	// "Email Address" label maps to email rather than address
+	profileFieldOrder := []string{
+		"firstName", "lastName", "email", "phone",
+		"zipCode", "city", "state", "country", "address",
+	}
+
 	for _, field := range fields {
 		mapped := false
 
 		// Try to map based on field name and label
-		for profileField, value := range profileData {
+		for _, profileField := range profileFieldOrder {
+			value := profileData[profileField]
 			if value == "" {
 				continue // Skip empty profile values
 			}
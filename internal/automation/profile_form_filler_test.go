@@ -22,6 +22,10 @@ func TestProfileFormFillerConfig(t *testing.T) {
 		t.Error("Expected VerifySubmission to be true")
 	}
 
+	if config.Submit {
+		t.Error("Expected Submit to be false by default")
+	}
+
 	if config.MaxRetries != 3 {
 		t.Errorf("Expected MaxRetries to be 3, got %d", config.MaxRetries)
 	}
@@ -263,6 +267,31 @@ func TestFieldMapperMapProfileToFields(t *testing.T) {
 	}
 }
 
+func TestFieldMapperPrefersSpecificFields(t *testing.T) {
+	fm := NewFieldMapper()
+	profile := &models.ClientProfile{
+		PersonalData: models.PersonalData{
+			Email: "john.doe@example.com",
+			Address: models.Address{
+				Street1:    "123 Main St",
+				PostalCode: "12345",
+			},
+		},
+	}
+	fields := []FormField{
+		{Name: "contact", Type: "text", Label: "Email Address"},
+		{Name: "zip", Type: "text", Label: "Address postal code"},
+	}
+
+	// Every label also matches address; the mapping must not depend on map order
+	for i := 0; i < 20; i++ {
+		mappings, _ := fm.MapProfileToFields(profile, fields)
+		if mappings["contact"] != "john.doe@example.com" || mappings["zip"] != "12345" {
+			t.Fatalf("Expected the email and postal code, got %v", mappings)
+		}
+	}
+}
+
 func TestFieldMapperHandleSpecialField(t *testing.T) {
 	fm := NewFieldMapper()
 
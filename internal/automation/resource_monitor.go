@@ -4,11 +4,14 @@ import (
 	"runtime"
 	"sync"
 	"time"
+
+	"github.com/ai-form-filler/cli/internal/sysmetrics"
 )
 
 // ResourceMonitor monitors system resources and provides optimization recommendations
 type ResourceMonitor struct {
 	currentResources *SystemResourceUsage
+	sampler          *sysmetrics.Sampler
 	history          []ResourceSnapshot
 	mutex            sync.RWMutex
 	lastUpdate       time.Time
@@ -25,6 +28,12 @@ type SystemResourceUsage struct {
 	HeapSize      int64     `json:"heapSize"`
 	HeapUsed      int64     `json:"heapUsed"`
 	GCPauses      int64     `json:"gcPauses"`
+	LoadAverage   [3]float64 `json:"loadAverage"`
+	CPUCores      float64   `json:"cpuCores"`      // Fractional when limited by a CPU quota
+	BrowserMemory int64     `json:"browserMemory"` // Resident memory of the Playwright driver and browsers
+	BrowserProcesses int    `json:"browserProcesses"`
+	Containerized bool      `json:"containerized"` // Figures are relative to cgroup limits
+	Measured      bool      `json:"measured"`      // False when falling back to estimates
 	Timestamp     time.Time `json:"timestamp"`
 }
 
@@ -48,6 +57,7 @@ type ResourceRecommendation struct {
 func NewResourceMonitor() *ResourceMonitor {
 	monitor := &ResourceMonitor{
 		currentResources: &SystemResourceUsage{},
+		sampler:          sysmetrics.NewSampler(),
 		history:          make([]ResourceSnapshot, 0, 100), // Keep last 100 snapshots
 	}
 	
@@ -82,28 +92,39 @@ func (rm *ResourceMonitor) updateResources() {
 	var memStats runtime.MemStats
 	runtime.ReadMemStats(&memStats)
 	
-	// Calculate memory usage
-	totalMemory := int64(16 * 1024 * 1024 * 1024) // Default to 16GB, should be detected from system
-	usedMemory := int64(memStats.Sys)
-	freeMemory := totalMemory - usedMemory
-	memoryUsagePercent := float64(usedMemory) / float64(totalMemory) * 100
-	
-	// Estimate CPU usage (simplified approach)
-	cpuUsage := rm.estimateCPUUsage()
-	
 	rm.currentResources = &SystemResourceUsage{
-		CPUUsage:       cpuUsage,
-		MemoryUsage:    memoryUsagePercent,
-		MemoryTotal:    totalMemory,
-		MemoryUsed:     usedMemory,
-		MemoryFree:     freeMemory,
 		GoroutineCount: runtime.NumGoroutine(),
 		HeapSize:       int64(memStats.HeapSys),
 		HeapUsed:       int64(memStats.HeapInuse),
 		GCPauses:       int64(memStats.PauseNs[(memStats.NumGC+255)%256]),
+		CPUCores:       float64(runtime.NumCPU()),
 		Timestamp:      time.Now(),
 	}
 	
+	// Read real host or container metrics where available
+	if snapshot, err := rm.sampler.Sample(); err == nil {
+		rm.currentResources.CPUUsage = snapshot.CPUPercent
+		rm.currentResources.MemoryUsage = snapshot.MemoryPercent
+		rm.currentResources.MemoryTotal = snapshot.MemoryTotal
+		rm.currentResources.MemoryUsed = snapshot.MemoryUsed
+		rm.currentResources.MemoryFree = snapshot.MemoryAvailable
+		rm.currentResources.LoadAverage = snapshot.LoadAverage
+		rm.currentResources.CPUCores = snapshot.CPUCores
+		rm.currentResources.BrowserMemory = snapshot.ChildRSS
+		rm.currentResources.BrowserProcesses = snapshot.ChildProcesses
+		rm.currentResources.Containerized = snapshot.CPULimited || snapshot.MemoryLimited
+		rm.currentResources.Measured = true
+	} else {
+		// Fall back to estimates on platforms without /proc
+		totalMemory := int64(16 * 1024 * 1024 * 1024)
+		usedMemory := int64(memStats.Sys)
+		rm.currentResources.CPUUsage = rm.estimateCPUUsage()
+		rm.currentResources.MemoryUsage = float64(usedMemory) / float64(totalMemory) * 100
+		rm.currentResources.MemoryTotal = totalMemory
+		rm.currentResources.MemoryUsed = usedMemory
+		rm.currentResources.MemoryFree = totalMemory - usedMemory
+	}
+	
 	rm.lastUpdate = time.Now()
 	
 	// Add to history
@@ -120,11 +141,10 @@ func (rm *ResourceMonitor) updateResources() {
 	}
 }
 
-// estimateCPUUsage provides a simple CPU usage estimation
+// estimateCPUUsage provides a simple CPU usage estimation for platforms
+// where real metrics cannot be read
 func (rm *ResourceMonitor) estimateCPUUsage() float64 {
-	// This is a simplified approach. In a production system, you'd use
-	// platform-specific APIs or libraries like gopsutil
-	
+
 	numCPU := runtime.NumCPU()
 	numGoroutines := runtime.NumGoroutine()
 	
@@ -178,7 +198,7 @@ func (rm *ResourceMonitor) GetOptimalConcurrency(currentConcurrency int) int {
 	
 	// Apply bounds
 	minConcurrency := 1
-	maxConcurrency := runtime.NumCPU() * 4 // Conservative maximum
+	maxConcurrency := max(1, int(resources.CPUCores*4)) // Conservative maximum
 	
 	if newConcurrency < minConcurrency {
 		newConcurrency = minConcurrency
@@ -205,7 +225,7 @@ func (rm *ResourceMonitor) GetRecommendations(currentConcurrency int) []Resource
 			Reason:      "High CPU usage detected",
 			Confidence:  0.8,
 		})
-	} else if resources.CPUUsage < 30 && currentConcurrency < runtime.NumCPU()*2 {
+	} else if resources.CPUUsage < 30 && float64(currentConcurrency) < resources.CPUCores*2 {
 		recommendations = append(recommendations, ResourceRecommendation{
 			Type:        "increase",
 			Component:   "concurrency",
@@ -0,0 +1,134 @@
+package automation
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy controls how failed URL tasks are retried
+type RetryPolicy struct {
+	MaxRetries      int           `json:"maxRetries" mapstructure:"max_retries" yaml:"max_retries,omitempty"`
+	InitialInterval time.Duration `json:"initialInterval" mapstructure:"initial_interval" yaml:"initial_interval,omitempty"`
+	MaxInterval     time.Duration `json:"maxInterval" mapstructure:"max_interval" yaml:"max_interval,omitempty"`
+	Multiplier      float64       `json:"multiplier" mapstructure:"multiplier" yaml:"multiplier,omitempty"`
+	Jitter          float64       `json:"jitter" mapstructure:"jitter" yaml:"jitter,omitempty"` // Fraction of the interval to randomize, 0-1
+	MaxElapsedTime  time.Duration `json:"maxElapsedTime" mapstructure:"max_elapsed_time" yaml:"max_elapsed_time,omitempty"`
+	RetryableErrors []string      `json:"retryableErrors" mapstructure:"retryable_errors" yaml:"retryable_errors,omitempty"` // ErrorType values
+}
+
+// DefaultRetryPolicy returns sensible defaults
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxRetries:      3,
+		InitialInterval: 1 * time.Second,
+		MaxInterval:     30 * time.Second,
+		Multiplier:      2.0,
+		Jitter:          0.2,
+		MaxElapsedTime:  2 * time.Minute,
+		RetryableErrors: []string{
+			ErrorTypeNetwork,
+			ErrorTypeTimeout,
+			ErrorTypeBrowserCrashed,
+		},
+	}
+}
+
+// Merge returns a copy of the policy with the non-zero fields of override
+// applied, so templates only need to specify what they change
+func (rp *RetryPolicy) Merge(override *RetryPolicy) *RetryPolicy {
+	merged := *rp
+	merged.RetryableErrors = append([]string(nil), rp.RetryableErrors...)
+
+	if override == nil {
+		return &merged
+	}
+
+	if override.MaxRetries != 0 {
+		merged.MaxRetries = override.MaxRetries
+	}
+	if override.InitialInterval != 0 {
+		merged.InitialInterval = override.InitialInterval
+	}
+	if override.MaxInterval != 0 {
+		merged.MaxInterval = override.MaxInterval
+	}
+	if override.Multiplier != 0 {
+		merged.Multiplier = override.Multiplier
+	}
+	if override.Jitter != 0 {
+		merged.Jitter = override.Jitter
+	}
+	if override.MaxElapsedTime != 0 {
+		merged.MaxElapsedTime = override.MaxElapsedTime
+	}
+	if override.RetryableErrors != nil {
+		merged.RetryableErrors = append([]string(nil), override.RetryableErrors...)
+	}
+
+	// A negative retry count in a template disables retries for it
+	if merged.MaxRetries < 0 {
+		merged.MaxRetries = 0
+	}
+
+	return &merged
+}
+
+// Backoff returns the wait before the given retry (1 for the first retry)
+func (rp *RetryPolicy) Backoff(retry int) time.Duration {
+	if retry < 1 {
+		return 0
+	}
+
+	multiplier := rp.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	interval := float64(rp.InitialInterval) * math.Pow(multiplier, float64(retry-1))
+	if rp.MaxInterval > 0 && interval > float64(rp.MaxInterval) {
+		interval = float64(rp.MaxInterval)
+	}
+
+	if rp.Jitter > 0 {
+		jitter := math.Min(rp.Jitter, 1)
+		interval += interval * jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(interval)
+}
+
+// IsRetryable reports whether the error belongs to one of the retryable classes.
+// Failed submissions are never retried regardless of configuration.
+func (rp *RetryPolicy) IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, ErrSubmissionFailed) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	errorType, _ := ClassifyError(err)
+	for _, retryable := range rp.RetryableErrors {
+		if retryable == errorType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NextBackoff decides whether another attempt should be made after a failure.
+// It returns false once the retry budget or the elapsed time budget is spent,
+// or when a submission was attempted, since a retry could submit twice.
+func (rp *RetryPolicy) NextBackoff(retry int, elapsed time.Duration, err error, submissionAttempted bool) (time.Duration, bool) {
+	if submissionAttempted || retry > rp.MaxRetries || !rp.IsRetryable(err) {
+		return 0, false
+	}
+
+	wait := rp.Backoff(retry)
+	if rp.MaxElapsedTime > 0 && elapsed+wait > rp.MaxElapsedTime {
+		return 0, false
+	}
+
+	return wait, true
+}
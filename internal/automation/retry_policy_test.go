@@ -0,0 +1,125 @@
+package automation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := &RetryPolicy{
+		InitialInterval: 1 * time.Second,
+		MaxInterval:     5 * time.Second,
+		Multiplier:      2.0,
+	}
+
+	expected := []time.Duration{1 * time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}
+	for i, want := range expected {
+		if got := policy.Backoff(i + 1); got != want {
+			t.Errorf("Retry %d: expected %v, got %v", i+1, want, got)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		wait := policy.Backoff(2)
+		if wait < 1*time.Second || wait > 3*time.Second {
+			t.Fatalf("Expected jittered backoff within 1s-3s, got %v", wait)
+		}
+	}
+}
+
+func TestRetryPolicyIsRetryable(t *testing.T) {
+	policy := DefaultRetryPolicy()
+
+	retryable := []error{
+		&AutomationError{Kind: ErrNavigation, Op: "navigate"},
+		&AutomationError{Kind: ErrTimeout, Op: "navigate"},
+		&AutomationError{Kind: ErrBrowserCrashed, Op: "create page"},
+		context.DeadlineExceeded,
+	}
+	for _, err := range retryable {
+		if !policy.IsRetryable(err) {
+			t.Errorf("Expected %v to be retryable", err)
+		}
+	}
+
+	// Missing selectors, rejected values and failed submissions fail the same way again
+	permanent := []error{
+		&AutomationError{Kind: ErrSelectorMissing, Op: "wait for field"},
+		&AutomationError{Kind: ErrValidationRejected, Op: "fill field"},
+		&AutomationError{Kind: ErrSubmissionFailed, Op: "submit form"},
+		context.Canceled,
+		nil,
+	}
+	for _, err := range permanent {
+		if policy.IsRetryable(err) {
+			t.Errorf("Expected %v not to be retryable", err)
+		}
+	}
+}
+
+func TestRetryPolicyNextBackoff(t *testing.T) {
+	policy := DefaultRetryPolicy()
+	policy.Jitter = 0
+	transient := &AutomationError{Kind: ErrNavigation, Op: "navigate"}
+
+	if _, ok := policy.NextBackoff(1, 0, transient, false); !ok {
+		t.Error("Expected navigation failure to be retried")
+	}
+
+	if _, ok := policy.NextBackoff(1, 0, transient, true); ok {
+		t.Error("Expected no retry after a submission was attempted")
+	}
+
+	if _, ok := policy.NextBackoff(policy.MaxRetries+1, 0, transient, false); ok {
+		t.Error("Expected no retry once MaxRetries is exhausted")
+	}
+
+	if _, ok := policy.NextBackoff(1, policy.MaxElapsedTime, transient, false); ok {
+		t.Error("Expected no retry once MaxElapsedTime is exhausted")
+	}
+
+	if _, ok := policy.NextBackoff(1, 0, &AutomationError{Kind: ErrSelectorMissing, Op: "wait for field"}, false); ok {
+		t.Error("Expected missing selector not to be retried")
+	}
+
+	policy.RetryableErrors = append(policy.RetryableErrors, ErrorTypeSubmissionFailed)
+	if _, ok := policy.NextBackoff(1, 0, &AutomationError{Kind: ErrSubmissionFailed, Op: "submit form"}, false); ok {
+		t.Error("Expected failed submission never to be retried")
+	}
+
+	if _, ok := policy.NextBackoff(1, 0, errors.New("unknown"), false); ok {
+		t.Error("Expected unclassified error not to be retried")
+	}
+}
+
+func TestRetryPolicyMerge(t *testing.T) {
+	base := DefaultRetryPolicy()
+
+	merged := base.Merge(&RetryPolicy{
+		MaxRetries:      5,
+		RetryableErrors: []string{ErrorTypeTimeout},
+	})
+
+	if merged.MaxRetries != 5 {
+		t.Errorf("Expected MaxRetries 5, got %d", merged.MaxRetries)
+	}
+
+	if merged.InitialInterval != base.InitialInterval {
+		t.Errorf("Expected InitialInterval to be inherited, got %v", merged.InitialInterval)
+	}
+
+	if len(merged.RetryableErrors) != 1 || merged.RetryableErrors[0] != ErrorTypeTimeout {
+		t.Errorf("Expected retryable errors to be overridden, got %v", merged.RetryableErrors)
+	}
+
+	if len(base.RetryableErrors) != 3 {
+		t.Errorf("Expected base policy to be unchanged, got %v", base.RetryableErrors)
+	}
+
+	if disabled := base.Merge(&RetryPolicy{MaxRetries: -1}); disabled.MaxRetries != 0 {
+		t.Errorf("Expected negative MaxRetries to disable retries, got %d", disabled.MaxRetries)
+	}
+}
@@ -3,6 +3,7 @@ package automation
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/playwright-community/playwright-go"
@@ -60,16 +61,23 @@ func (sg *SelectorGenerator) GenerateSelectors(ctx context.Context, page *playwr
 	return selectors, nil
 }
 
-// ValidateSelector validates if a selector uniquely identifies an element
+// ValidateSelector validates if a selector uniquely identifies an element.
+// XPath selectors ("//..." or "xpath=...") are evaluated as XPath.
 func (sg *SelectorGenerator) ValidateSelector(ctx context.Context, page *playwright.Page, selector string) (*SelectorValidationResult, error) {
-	script := fmt.Sprintf(`
-		(function() {
+	script := `
+		(selector) => {
 			try {
-				const elements = document.querySelectorAll('%s');
+				let count = 0;
+				if (selector.startsWith('//') || selector.startsWith('xpath=')) {
+					const xpath = selector.replace(/^xpath=/, '');
+					count = document.evaluate(xpath, document, null, XPathResult.ORDERED_NODE_SNAPSHOT_TYPE, null).snapshotLength;
+				} else {
+					count = document.querySelectorAll(selector).length;
+				}
 				return {
-					elementCount: elements.length,
-					isValid: elements.length > 0,
-					isUnique: elements.length === 1
+					elementCount: count,
+					isValid: count > 0,
+					isUnique: count === 1
 				};
 			} catch (e) {
 				return {
@@ -78,10 +86,10 @@ func (sg *SelectorGenerator) ValidateSelector(ctx context.Context, page *playwri
 					isUnique: false
 				};
 			}
-		})();
-	`, strings.ReplaceAll(selector, "'", "\\'"))
+		}
+	`
 
-	result, err := (*page).Evaluate(script)
+	result, err := (*page).Evaluate(script, selector)
 	if err != nil {
 		return &SelectorValidationResult{
 			Selector: selector,
@@ -99,9 +107,16 @@ func (sg *SelectorGenerator) ValidateSelector(ctx context.Context, page *playwri
 		}, fmt.Errorf("unexpected result format")
 	}
 
-	elementCount := int(resultMap["elementCount"].(float64))
-	isValid := resultMap["isValid"].(bool)
-	isUnique := resultMap["isUnique"].(bool)
+	// Playwright returns whole numbers as int
+	elementCount := 0
+	switch count := resultMap["elementCount"].(type) {
+	case int:
+		elementCount = count
+	case float64:
+		elementCount = int(count)
+	}
+	isValid, _ := resultMap["isValid"].(bool)
+	isUnique, _ := resultMap["isUnique"].(bool)
 
 	// Calculate confidence based on uniqueness and validity
 	confidence := 0.0
@@ -148,6 +163,95 @@ func (sg *SelectorGenerator) FindBestSelector(ctx context.Context, page *playwri
 	return bestSelector, nil
 }
 
+// SelectorCandidate is one of several ranked selectors for a form field
+type SelectorCandidate struct {
+	Selector  string  `json:"selector"`
+	Strategy  string  `json:"strategy"`
+	Stability float64 `json:"stability"` // 0-100 score from uniqueness, strategy priority and reload checks
+}
+
+// GenerateCandidates generates selectors for an element with every strategy
+// and ranks them by stability score. Selectors that match nothing are dropped.
+func (sg *SelectorGenerator) GenerateCandidates(ctx context.Context, page *playwright.Page, element playwright.ElementHandle) ([]SelectorCandidate, error) {
+	var candidates []SelectorCandidate
+	seen := make(map[string]bool)
+
+	for _, strategy := range sg.strategies {
+		selector, err := strategy.GenerateSelector(ctx, page, element)
+		if err != nil || selector == "" || seen[selector] {
+			continue
+		}
+		seen[selector] = true
+
+		result, err := sg.ValidateSelector(ctx, page, selector)
+		if err != nil || !result.IsValid {
+			continue
+		}
+
+		candidates = append(candidates, SelectorCandidate{
+			Selector:  selector,
+			Strategy:  strategy.GetName(),
+			Stability: result.Confidence * float64(strategy.GetPriority()) / 100,
+		})
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no valid selector found")
+	}
+
+	sortSelectorCandidates(candidates)
+	return candidates, nil
+}
+
+// MeasureStability reloads the page the given number of times and scales
+// each candidate's score by how often it still matches exactly one element.
+// Candidates are grouped per field and each group is re-ranked afterwards.
+func (sg *SelectorGenerator) MeasureStability(ctx context.Context, page *playwright.Page, fields [][]SelectorCandidate, iterations int) error {
+	if iterations <= 0 {
+		return nil
+	}
+
+	hits := make([][]int, len(fields))
+	for i := range fields {
+		hits[i] = make([]int, len(fields[i]))
+	}
+
+	for i := 0; i < iterations; i++ {
+		_, err := (*page).Reload(playwright.PageReloadOptions{
+			WaitUntil: playwright.WaitUntilStateNetworkidle,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to reload page: %w", err)
+		}
+
+		for f, candidates := range fields {
+			for c, candidate := range candidates {
+				result, err := sg.ValidateSelector(ctx, page, candidate.Selector)
+				if err == nil && result.IsUnique {
+					hits[f][c]++
+				}
+			}
+		}
+	}
+
+	for f, candidates := range fields {
+		for c := range candidates {
+			candidates[c].Stability *= float64(hits[f][c]) / float64(iterations)
+		}
+		sortSelectorCandidates(candidates)
+	}
+
+	return nil
+}
+
+// sortSelectorCandidates orders candidates by stability, keeping strategy
+// priority order for ties
+func sortSelectorCandidates(candidates []SelectorCandidate) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Stability > candidates[j].Stability
+	})
+}
+
 // IDSelectorStrategy generates selectors based on element ID
 type IDSelectorStrategy struct{}
 
@@ -323,12 +427,13 @@ func (s *CSSPathSelectorStrategy) GenerateSelector(ctx context.Context, page *pl
 						selector += '.' + classes.join('.');
 					}
 					
-					// Add nth-child if needed for uniqueness
+					// Add nth-of-type if needed for uniqueness; the index
+					// counts same-tag siblings only
 					const siblings = Array.from(current.parentElement?.children || [])
 						.filter(sibling => sibling.tagName === current.tagName);
 					if (siblings.length > 1) {
 						const index = siblings.indexOf(current) + 1;
-						selector += ':nth-child(' + index + ')';
+						selector += ':nth-of-type(' + index + ')';
 					}
 					
 					path.unshift(selector);
@@ -0,0 +1,32 @@
+package automation
+
+import "testing"
+
+func TestSortSelectorCandidates(t *testing.T) {
+	candidates := []SelectorCandidate{
+		{Selector: ".email", Strategy: "Class", Stability: 35},
+		{Selector: "#email", Strategy: "ID", Stability: 100},
+		{Selector: "[name=\"email\"]", Strategy: "Name", Stability: 90},
+		{Selector: "[data-testid=\"email\"]", Strategy: "DataAttribute", Stability: 90},
+	}
+
+	sortSelectorCandidates(candidates)
+
+	expected := []string{"ID", "Name", "DataAttribute", "Class"}
+	for i, strategy := range expected {
+		if candidates[i].Strategy != strategy {
+			t.Errorf("Position %d: expected %s, got %s", i, strategy, candidates[i].Strategy)
+		}
+	}
+}
+
+func TestNewSelectorGeneratorStrategies(t *testing.T) {
+	sg := NewSelectorGenerator()
+
+	for i := 1; i < len(sg.strategies); i++ {
+		if sg.strategies[i].GetPriority() > sg.strategies[i-1].GetPriority() {
+			t.Errorf("Expected strategies in priority order, %s before %s",
+				sg.strategies[i-1].GetName(), sg.strategies[i].GetName())
+		}
+	}
+}
@@ -0,0 +1,416 @@
+package automation
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// successPollInterval is how often success rules are evaluated while waiting
+const successPollInterval = 100 * time.Millisecond
+
+// defaultSuccessRules are used for templates that declare no success criteria
+var defaultSuccessRules = []SuccessRule{{Type: SuccessIndicators}}
+
+// SubmissionResponse is a network response received after a form was submitted
+type SubmissionResponse struct {
+	URL    string
+	Method string
+	Status int
+}
+
+// SubmissionState is the page a form was submitted on, as seen by success rules
+type SubmissionState interface {
+	URL() string       // Current page URL
+	FormURL() string   // URL of the page the form was submitted from
+	ActionURL() string // URL the form submits to
+	Title() (string, error)
+	ElementTexts(selector string) ([]string, error) // Trimmed texts of the visible matching elements
+	Responses() []SubmissionResponse                // Responses received since the submission started
+	Settled() bool                                  // The page has stopped loading after the submission
+}
+
+// SuccessCheck is the result of evaluating one success rule
+type SuccessCheck struct {
+	Passed bool
+	Failed bool   // The rule can no longer pass, e.g. the server rejected the form
+	Detail string // What was seen, for the submission result
+}
+
+// SuccessCheckerFunc evaluates one type of success rule against the page
+type SuccessCheckerFunc func(rule SuccessRule, state SubmissionState) SuccessCheck
+
+var (
+	successCheckers = map[string]SuccessCheckerFunc{
+		SuccessURLMatches:    checkURLMatches,
+		SuccessElementText:   checkElementText,
+		SuccessElementAbsent: checkElementAbsent,
+		SuccessResponseOK:    checkResponseOK,
+		SuccessIndicators:    checkSuccessIndicators,
+	}
+	successCheckersMutex sync.RWMutex
+)
+
+// RegisterSuccessRule adds or replaces the checker for a success rule type
+func RegisterSuccessRule(ruleType string, checker SuccessCheckerFunc) {
+	successCheckersMutex.Lock()
+	defer successCheckersMutex.Unlock()
+	successCheckers[ruleType] = checker
+}
+
+// SuccessRuleTypes returns the registered success rule types
+func SuccessRuleTypes() []string {
+	successCheckersMutex.RLock()
+	defer successCheckersMutex.RUnlock()
+
+	types := make([]string, 0, len(successCheckers))
+	for ruleType := range successCheckers {
+		types = append(types, ruleType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// successChecker returns the checker for a rule type
+func successChecker(ruleType string) (SuccessCheckerFunc, bool) {
+	successCheckersMutex.RLock()
+	defer successCheckersMutex.RUnlock()
+	checker, ok := successCheckers[ruleType]
+	return checker, ok
+}
+
+// SuccessEvaluation is the outcome of evaluating a template's success rules
+type SuccessEvaluation struct {
+	Success bool
+	Passed  []string // Details of the rules that passed
+	Failed  []string // Details of the rules that failed or never passed
+}
+
+// EvaluateSuccessRules waits until every rule passes, a rule fails for good
+// or the timeout elapses. Rules are evaluated together, so a submission only
+// succeeds when all criteria hold at the same time.
+func EvaluateSuccessRules(ctx context.Context, rules []SuccessRule, state SubmissionState, timeout time.Duration) *SuccessEvaluation {
+	if len(rules) == 0 {
+		rules = defaultSuccessRules
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(successPollInterval)
+	defer ticker.Stop()
+
+	for {
+		evaluation, done := evaluateSuccessRulesOnce(rules, state)
+		if done {
+			return evaluation
+		}
+
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			evaluation.Failed = append(evaluation.Failed, fmt.Sprintf("timed out after %s", timeout))
+			return evaluation
+		case <-ctx.Done():
+			evaluation.Failed = append(evaluation.Failed, ctx.Err().Error())
+			return evaluation
+		}
+	}
+}
+
+// evaluateSuccessRulesOnce checks every rule and reports whether the
+// evaluation is finished
+func evaluateSuccessRulesOnce(rules []SuccessRule, state SubmissionState) (*SuccessEvaluation, bool) {
+	evaluation := &SuccessEvaluation{}
+	failed := false
+	for _, rule := range rules {
+		checker, ok := successChecker(rule.Type)
+		if !ok {
+			evaluation.Failed = append(evaluation.Failed, fmt.Sprintf("unknown success rule %q", rule.Type))
+			failed = true
+			continue
+		}
+
+		check := checker(rule, state)
+		switch {
+		case check.Passed:
+			evaluation.Passed = append(evaluation.Passed, check.Detail)
+		case check.Failed:
+			evaluation.Failed = append(evaluation.Failed, check.Detail)
+			failed = true
+		default:
+			evaluation.Failed = append(evaluation.Failed, check.Detail)
+		}
+	}
+
+	evaluation.Success = len(evaluation.Passed) == len(rules)
+	return evaluation, evaluation.Success || failed
+}
+
+// checkURLMatches passes once the page URL matches the rule's pattern
+func checkURLMatches(rule SuccessRule, state SubmissionState) SuccessCheck {
+	pattern, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return SuccessCheck{Failed: true, Detail: fmt.Sprintf("invalid URL pattern %q: %v", rule.Pattern, err)}
+	}
+
+	current := state.URL()
+	if pattern.MatchString(current) {
+		return SuccessCheck{Passed: true, Detail: fmt.Sprintf("URL %s matches %s", current, rule.Pattern)}
+	}
+	return SuccessCheck{Detail: fmt.Sprintf("URL %s does not match %s", current, rule.Pattern)}
+}
+
+// checkElementText passes once a visible element contains the rule's text
+func checkElementText(rule SuccessRule, state SubmissionState) SuccessCheck {
+	texts, _ := state.ElementTexts(rule.Selector)
+	for _, text := range texts {
+		if strings.Contains(strings.ToLower(text), strings.ToLower(rule.Text)) {
+			return SuccessCheck{Passed: true, Detail: fmt.Sprintf("%s: %s", rule.Selector, text)}
+		}
+	}
+	return SuccessCheck{Detail: fmt.Sprintf("no %s element containing %q", rule.Selector, rule.Text)}
+}
+
+// checkElementAbsent passes once the page has settled without showing the
+// rule's element, and fails if the element is shown
+func checkElementAbsent(rule SuccessRule, state SubmissionState) SuccessCheck {
+	texts, _ := state.ElementTexts(rule.Selector)
+	settled := state.Settled()
+
+	if len(texts) > 0 {
+		detail := fmt.Sprintf("%s shown: %s", rule.Selector, strings.Join(texts, "; "))
+		return SuccessCheck{Failed: settled, Detail: detail}
+	}
+	if !settled {
+		return SuccessCheck{Detail: fmt.Sprintf("waiting for the page to settle before checking %s", rule.Selector)}
+	}
+	return SuccessCheck{Passed: true, Detail: fmt.Sprintf("no %s element", rule.Selector)}
+}
+
+// checkResponseOK passes once a response for the form action, or for URLs
+// matching the rule's pattern, has a 2xx status. Client and server errors
+// fail the rule; redirects are followed by waiting for the next response.
+func checkResponseOK(rule SuccessRule, state SubmissionState) SuccessCheck {
+	matches := func(responseURL string) bool { return sameResourceURL(responseURL, state.ActionURL()) }
+	target := state.ActionURL()
+	if rule.Pattern != "" {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return SuccessCheck{Failed: true, Detail: fmt.Sprintf("invalid response pattern %q: %v", rule.Pattern, err)}
+		}
+		matches = pattern.MatchString
+		target = rule.Pattern
+	}
+
+	for _, response := range state.Responses() {
+		if !matches(response.URL) {
+			continue
+		}
+		detail := fmt.Sprintf("%s %s returned %d", response.Method, response.URL, response.Status)
+		switch {
+		case response.Status >= 200 && response.Status < 300:
+			return SuccessCheck{Passed: true, Detail: detail}
+		case response.Status >= 400:
+			return SuccessCheck{Failed: true, Detail: detail}
+		}
+	}
+	return SuccessCheck{Detail: fmt.Sprintf("no successful response for %s", target)}
+}
+
+// Selectors and title keywords used when a template declares no success rules
+var (
+	successIndicatorSelectors = []string{
+		".success", ".alert-success", ".message-success",
+		"[class*='success']", "[id*='success']",
+		".confirmation", ".thank-you", ".complete",
+	}
+	errorIndicatorSelectors = []string{
+		".error", ".alert-error", ".message-error",
+		"[class*='error']", "[id*='error']",
+		".warning", ".alert-warning", ".invalid",
+	}
+)
+
+// checkSuccessIndicators guesses the outcome from common success and error
+// elements, the page title and whether the page navigated away from the form
+func checkSuccessIndicators(rule SuccessRule, state SubmissionState) SuccessCheck {
+	var successes, errors []string
+	for _, selector := range successIndicatorSelectors {
+		texts, _ := state.ElementTexts(selector)
+		successes = append(successes, texts...)
+	}
+	for _, selector := range errorIndicatorSelectors {
+		texts, _ := state.ElementTexts(selector)
+		errors = append(errors, texts...)
+	}
+
+	if title, err := state.Title(); err == nil {
+		lowerTitle := strings.ToLower(title)
+		if strings.Contains(lowerTitle, "success") || strings.Contains(lowerTitle, "thank") || strings.Contains(lowerTitle, "complete") {
+			successes = append(successes, fmt.Sprintf("Title: %s", title))
+		}
+		if strings.Contains(lowerTitle, "error") || strings.Contains(lowerTitle, "failed") {
+			errors = append(errors, fmt.Sprintf("Title: %s", title))
+		}
+	}
+
+	if len(errors) > 0 {
+		return SuccessCheck{Failed: state.Settled(), Detail: strings.Join(errors, "; ")}
+	}
+	if len(successes) > 0 {
+		return SuccessCheck{Passed: true, Detail: strings.Join(successes, "; ")}
+	}
+	if current := state.URL(); !sameResourceURL(current, state.FormURL()) {
+		return SuccessCheck{Passed: true, Detail: fmt.Sprintf("redirected to %s", current)}
+	}
+	return SuccessCheck{Detail: "no success indicators found"}
+}
+
+// sameResourceURL reports whether two URLs address the same resource,
+// ignoring their query and fragment
+func sameResourceURL(a, b string) bool {
+	parsedA, errA := url.Parse(a)
+	parsedB, errB := url.Parse(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return strings.EqualFold(parsedA.Host, parsedB.Host) &&
+		strings.TrimSuffix(parsedA.Path, "/") == strings.TrimSuffix(parsedB.Path, "/")
+}
+
+// pageSubmissionState records a page's responses from before a form is
+// submitted until the success rules have been evaluated
+type pageSubmissionState struct {
+	page      playwright.Page
+	formURL   string
+	actionURL string
+	responses []SubmissionResponse
+	settled   bool
+	mutex     sync.Mutex
+}
+
+// formActionScript returns the action of the form containing the submit
+// element, or of the first form on the page
+const formActionScript = `(selector) => {
+	const submit = selector ? document.querySelector(selector) : null;
+	const form = (submit && (submit.form || submit.closest('form'))) || document.querySelector('form');
+	return form ? form.action : location.href;
+}`
+
+// newPageSubmissionState starts recording responses on a page. It must be
+// created before the form is submitted.
+func newPageSubmissionState(page playwright.Page, template *FormTemplate) *pageSubmissionState {
+	state := &pageSubmissionState{page: page, formURL: page.URL(), actionURL: page.URL()}
+
+	submitSelector := ""
+	if template.Submit != nil {
+		submitSelector = template.Submit.Selector
+	}
+	if action, err := page.Evaluate(formActionScript, submitSelector); err == nil {
+		if actionURL, ok := action.(string); ok && actionURL != "" {
+			state.actionURL = actionURL
+		}
+	}
+
+	page.OnResponse(func(response playwright.Response) {
+		state.mutex.Lock()
+		defer state.mutex.Unlock()
+		state.responses = append(state.responses, SubmissionResponse{
+			URL:    response.URL(),
+			Method: response.Request().Method(),
+			Status: response.Status(),
+		})
+	})
+
+	return state
+}
+
+// waitForSettle marks the page settled once its network is idle or the
+// timeout elapses, whichever comes first
+func (s *pageSubmissionState) waitForSettle(timeout time.Duration) {
+	go func() {
+		s.page.WaitForLoadState(playwright.PageWaitForLoadStateOptions{
+			State:   playwright.LoadStateNetworkidle,
+			Timeout: playwright.Float(float64(timeout.Milliseconds())),
+		})
+		s.mutex.Lock()
+		s.settled = true
+		s.mutex.Unlock()
+	}()
+}
+
+// URL returns the page's current URL
+func (s *pageSubmissionState) URL() string {
+	return s.page.URL()
+}
+
+// FormURL returns the URL the form was submitted from
+func (s *pageSubmissionState) FormURL() string {
+	return s.formURL
+}
+
+// ActionURL returns the URL the form submits to
+func (s *pageSubmissionState) ActionURL() string {
+	return s.actionURL
+}
+
+// Title returns the page title
+func (s *pageSubmissionState) Title() (string, error) {
+	return s.page.Title()
+}
+
+// ElementTexts returns the trimmed, non-empty texts of the visible elements
+// matching a selector
+func (s *pageSubmissionState) ElementTexts(selector string) ([]string, error) {
+	elements, err := s.page.QuerySelectorAll(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var texts []string
+	for _, element := range elements {
+		if visible, err := element.IsVisible(); err != nil || !visible {
+			continue
+		}
+		text, err := element.TextContent()
+		if err != nil {
+			continue
+		}
+		if text = strings.TrimSpace(text); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return texts, nil
+}
+
+// Responses returns the responses received so far
+func (s *pageSubmissionState) Responses() []SubmissionResponse {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]SubmissionResponse(nil), s.responses...)
+}
+
+// Settled reports whether the page has stopped loading
+func (s *pageSubmissionState) Settled() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.settled
+}
+
+// actionStatus returns the status of the last response for the form action
+func (s *pageSubmissionState) actionStatus() int {
+	status := 0
+	for _, response := range s.Responses() {
+		if sameResourceURL(response.URL, s.actionURL) {
+			status = response.Status
+		}
+	}
+	return status
+}
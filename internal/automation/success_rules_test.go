@@ -0,0 +1,211 @@
+package automation
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSubmissionState is a page whose state changes after a delay
+type fakeSubmissionState struct {
+	mutex     sync.Mutex
+	url       string
+	formURL   string
+	actionURL string
+	title     string
+	elements  map[string][]string
+	responses []SubmissionResponse
+	settled   bool
+}
+
+func newFakeSubmissionState() *fakeSubmissionState {
+	return &fakeSubmissionState{
+		url:       "https://example.com/signup",
+		formURL:   "https://example.com/signup",
+		actionURL: "https://example.com/api/signup",
+		elements:  make(map[string][]string),
+	}
+}
+
+func (s *fakeSubmissionState) update(change func(s *fakeSubmissionState)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	change(s)
+}
+
+func (s *fakeSubmissionState) URL() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.url
+}
+
+func (s *fakeSubmissionState) FormURL() string   { return s.formURL }
+func (s *fakeSubmissionState) ActionURL() string { return s.actionURL }
+
+func (s *fakeSubmissionState) Title() (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.title, nil
+}
+
+func (s *fakeSubmissionState) ElementTexts(selector string) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.elements[selector], nil
+}
+
+func (s *fakeSubmissionState) Responses() []SubmissionResponse {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]SubmissionResponse(nil), s.responses...)
+}
+
+func (s *fakeSubmissionState) Settled() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.settled
+}
+
+func TestEvaluateSuccessRulesWaitsForRules(t *testing.T) {
+	state := newFakeSubmissionState()
+	rules := []SuccessRule{
+		{Type: SuccessURLMatches, Pattern: "/welcome$"},
+		{Type: SuccessElementText, Selector: "h1", Text: "thanks"},
+		{Type: SuccessResponseOK},
+		{Type: SuccessElementAbsent, Selector: ".error"},
+	}
+
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		state.update(func(s *fakeSubmissionState) {
+			s.responses = append(s.responses, SubmissionResponse{URL: "https://example.com/api/signup?step=1", Method: "POST", Status: 201})
+			s.url = "https://example.com/welcome"
+			s.elements["h1"] = []string{"Thanks for signing up"}
+			s.settled = true
+		})
+	}()
+
+	start := time.Now()
+	evaluation := EvaluateSuccessRules(context.Background(), rules, state, 5*time.Second)
+	if !evaluation.Success {
+		t.Fatalf("Expected success, got %+v", evaluation)
+	}
+	if len(evaluation.Passed) != len(rules) || len(evaluation.Failed) != 0 {
+		t.Errorf("Expected every rule to pass, got %+v", evaluation)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected evaluation to finish once the rules passed, took %s", elapsed)
+	}
+}
+
+func TestEvaluateSuccessRulesFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		rules  []SuccessRule
+		change func(s *fakeSubmissionState)
+		detail string
+	}{
+		{
+			name:  "rejected response",
+			rules: []SuccessRule{{Type: SuccessResponseOK}},
+			change: func(s *fakeSubmissionState) {
+				s.responses = []SubmissionResponse{{URL: s.actionURL, Method: "POST", Status: 422}}
+			},
+			detail: "returned 422",
+		},
+		{
+			name:   "error element shown",
+			rules:  []SuccessRule{{Type: SuccessElementText, Selector: "h1", Text: "thanks"}, {Type: SuccessElementAbsent, Selector: ".error"}},
+			change: func(s *fakeSubmissionState) { s.elements[".error"] = []string{"Email already taken"}; s.settled = true },
+			detail: "Email already taken",
+		},
+		{
+			name:   "timeout",
+			rules:  []SuccessRule{{Type: SuccessURLMatches, Pattern: "/welcome"}},
+			change: func(s *fakeSubmissionState) {},
+			detail: "timed out",
+		},
+		{
+			name:   "unknown rule",
+			rules:  []SuccessRule{{Type: "captcha_solved"}},
+			change: func(s *fakeSubmissionState) {},
+			detail: "unknown success rule",
+		},
+	}
+
+	for _, test := range tests {
+		state := newFakeSubmissionState()
+		state.update(test.change)
+
+		start := time.Now()
+		evaluation := EvaluateSuccessRules(context.Background(), test.rules, state, 300*time.Millisecond)
+		if evaluation.Success {
+			t.Errorf("%s: expected failure", test.name)
+		}
+		if !strings.Contains(strings.Join(evaluation.Failed, "\n"), test.detail) {
+			t.Errorf("%s: expected %q in %v", test.name, test.detail, evaluation.Failed)
+		}
+		if test.name != "timeout" && time.Since(start) > 200*time.Millisecond {
+			t.Errorf("%s: expected a definite failure not to wait for the timeout", test.name)
+		}
+	}
+}
+
+func TestElementAbsentWaitsForSettle(t *testing.T) {
+	state := newFakeSubmissionState()
+	rule := SuccessRule{Type: SuccessElementAbsent, Selector: ".error"}
+
+	if check := checkElementAbsent(rule, state); check.Passed || check.Failed {
+		t.Errorf("Expected the rule to wait until the page settles, got %+v", check)
+	}
+
+	state.update(func(s *fakeSubmissionState) { s.settled = true })
+	if check := checkElementAbsent(rule, state); !check.Passed {
+		t.Errorf("Expected the rule to pass on a settled page, got %+v", check)
+	}
+}
+
+func TestDefaultSuccessIndicators(t *testing.T) {
+	state := newFakeSubmissionState()
+	state.update(func(s *fakeSubmissionState) {
+		s.elements[".alert-success"] = []string{"Message sent"}
+	})
+
+	evaluation := EvaluateSuccessRules(context.Background(), nil, state, time.Second)
+	if !evaluation.Success || evaluation.Passed[0] != "Message sent" {
+		t.Errorf("Expected the default indicators to find the success message, got %+v", evaluation)
+	}
+
+	state = newFakeSubmissionState()
+	state.update(func(s *fakeSubmissionState) { s.url = "https://example.com/thanks" })
+	if evaluation := EvaluateSuccessRules(context.Background(), nil, state, time.Second); !evaluation.Success {
+		t.Errorf("Expected a redirect away from the form to count as success, got %+v", evaluation)
+	}
+}
+
+func TestRegisterSuccessRule(t *testing.T) {
+	RegisterSuccessRule("title_contains", func(rule SuccessRule, state SubmissionState) SuccessCheck {
+		title, _ := state.Title()
+		return SuccessCheck{Passed: strings.Contains(title, rule.Text), Detail: title}
+	})
+	defer func() {
+		successCheckersMutex.Lock()
+		delete(successCheckers, "title_contains")
+		successCheckersMutex.Unlock()
+	}()
+
+	state := newFakeSubmissionState()
+	state.update(func(s *fakeSubmissionState) { s.title = "Order confirmed" })
+
+	rules := []SuccessRule{{Type: "title_contains", Text: "confirmed"}}
+	if evaluation := EvaluateSuccessRules(context.Background(), rules, state, time.Second); !evaluation.Success {
+		t.Errorf("Expected the registered rule to pass, got %+v", evaluation)
+	}
+
+	source := "id: x\nurl: https://example.com\nfields:\n  - name: a\n    selectors: [\"#a\"]\nsuccess:\n  - type: title_contains\n    text: confirmed\n"
+	if errs := ValidateTemplateYAML([]byte(source)); len(errs) != 0 {
+		t.Errorf("Expected registered rule types to validate, got %v", errs)
+	}
+}
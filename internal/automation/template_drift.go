@@ -0,0 +1,202 @@
+package automation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// DriftSeverity ranks how likely a drifted template is to fail
+type DriftSeverity string
+
+const (
+	DriftSeverityNone   DriftSeverity = "none"
+	DriftSeverityLow    DriftSeverity = "low"
+	DriftSeverityMedium DriftSeverity = "medium"
+	DriftSeverityHigh   DriftSeverity = "high"
+)
+
+// Drift issue kinds
+const (
+	DriftFormMissing      = "form_missing"
+	DriftMissingField     = "missing_field"
+	DriftNewRequiredField = "new_required_field"
+	DriftTypeChanged      = "type_changed"
+	DriftBrokenSelector   = "broken_selector"
+)
+
+// DriftIssue is a single difference between a template and the live form
+type DriftIssue struct {
+	Field    string        `json:"field,omitempty"`
+	Kind     string        `json:"kind"`
+	Severity DriftSeverity `json:"severity"`
+	Detail   string        `json:"detail"`
+}
+
+// DriftReport compares a stored template with the form currently on its page
+type DriftReport struct {
+	TemplateID string        `json:"templateId"`
+	URL        string        `json:"url"`
+	Version    int           `json:"version"`
+	Severity   DriftSeverity `json:"severity"`
+	Issues     []DriftIssue  `json:"issues"`
+	CheckedAt  time.Time     `json:"checkedAt"`
+}
+
+// CheckTemplateDrift loads the template's page, re-runs detection and reports
+// fields that went missing, new required fields, changed types and selectors
+// that no longer match
+func (fd *FormDetector) CheckTemplateDrift(ctx context.Context, template *FormTemplate) (*DriftReport, error) {
+	page, err := fd.openPage(template.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer (*page).Close()
+
+	forms, err := fd.detectForms(ctx, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect forms: %w", err)
+	}
+
+	resolvable := make(map[string]bool, len(template.Fields))
+	for i := range template.Fields {
+		field := &template.Fields[i]
+		resolvable[field.Name] = selectorResolves(page, fieldSelectors(field))
+	}
+
+	return compareTemplateDrift(template, forms, resolvable), nil
+}
+
+// selectorResolves reports whether any of the selectors matches an element
+func selectorResolves(page *playwright.Page, selectors []string) bool {
+	for _, selector := range selectors {
+		element, err := (*page).QuerySelector(selector)
+		if err == nil && element != nil {
+			element.Dispose()
+			return true
+		}
+	}
+	return false
+}
+
+// compareTemplateDrift builds a drift report from the detected forms and
+// whether each template field's selectors still resolve on the page
+func compareTemplateDrift(template *FormTemplate, forms []DetectedForm, resolvable map[string]bool) *DriftReport {
+	report := &DriftReport{
+		TemplateID: template.ID,
+		URL:        template.URL,
+		Version:    template.Version,
+		Severity:   DriftSeverityNone,
+		Issues:     []DriftIssue{},
+		CheckedAt:  time.Now(),
+	}
+
+	if len(forms) == 0 {
+		report.addIssue(DriftIssue{
+			Kind:     DriftFormMissing,
+			Severity: DriftSeverityHigh,
+			Detail:   "no form detected on the page",
+		})
+		return report
+	}
+
+	form, pairings := bestMatchingForm(template.Fields, forms, DefaultHealerConfig().MinMatchScore)
+
+	detectedFor := make(map[int]int, len(pairings))
+	matchedDetected := make(map[int]bool, len(pairings))
+	for _, pairing := range pairings {
+		detectedFor[pairing.field] = pairing.detected
+		matchedDetected[pairing.detected] = true
+	}
+
+	for i, field := range template.Fields {
+		severity := DriftSeverityMedium
+		if field.Required {
+			severity = DriftSeverityHigh
+		}
+
+		index, matched := detectedFor[i]
+		if !matched {
+			if !resolvable[field.Name] {
+				report.addIssue(DriftIssue{
+					Field:    field.Name,
+					Kind:     DriftMissingField,
+					Severity: severity,
+					Detail:   "field no longer found on the page",
+				})
+			}
+			continue
+		}
+
+		detected := form.Fields[index]
+		if !strings.EqualFold(field.Type, detected.Type) {
+			// Fill values may no longer suit the input, e.g. text to select
+			typeSeverity := DriftSeverityLow
+			if field.Required {
+				typeSeverity = DriftSeverityMedium
+			}
+
+			report.addIssue(DriftIssue{
+				Field:    field.Name,
+				Kind:     DriftTypeChanged,
+				Severity: typeSeverity,
+				Detail:   fmt.Sprintf("type changed from %s to %s", field.Type, detected.Type),
+			})
+		}
+
+		if !resolvable[field.Name] {
+			report.addIssue(DriftIssue{
+				Field:    field.Name,
+				Kind:     DriftBrokenSelector,
+				Severity: severity,
+				Detail:   fmt.Sprintf("%s no longer matches; field now at %s", field.Selector, detected.Selector),
+			})
+		}
+	}
+
+	for i, detected := range form.Fields {
+		if matchedDetected[i] || !detected.Required {
+			continue
+		}
+
+		report.addIssue(DriftIssue{
+			Field:    detected.Name,
+			Kind:     DriftNewRequiredField,
+			Severity: DriftSeverityHigh,
+			Detail:   fmt.Sprintf("new required %s field at %s", detected.Type, detected.Selector),
+		})
+	}
+
+	return report
+}
+
+// addIssue appends an issue and raises the report severity to match
+func (r *DriftReport) addIssue(issue DriftIssue) {
+	r.Issues = append(r.Issues, issue)
+	if driftSeverityRank(issue.Severity) > driftSeverityRank(r.Severity) {
+		r.Severity = issue.Severity
+	}
+}
+
+// driftSeverityRank orders severities for comparison
+func driftSeverityRank(severity DriftSeverity) int {
+	switch severity {
+	case DriftSeverityLow:
+		return 1
+	case DriftSeverityMedium:
+		return 2
+	case DriftSeverityHigh:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Breaking reports whether the drift is severe enough that filling the
+// template is expected to fail
+func (r *DriftReport) Breaking() bool {
+	return r.Severity == DriftSeverityHigh
+}
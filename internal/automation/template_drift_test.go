@@ -0,0 +1,107 @@
+package automation
+
+import (
+	"testing"
+)
+
+func newDriftTestTemplate() *FormTemplate {
+	return &FormTemplate{
+		ID:       "template_drift",
+		URL:      "https://example.com/contact",
+		Domain:   "example.com",
+		FormType: string(FormTypeContact),
+		Fields: []FormField{
+			{Name: "email", Type: "email", Label: "Email", Selector: "#email", Required: true},
+			{Name: "phone", Type: "tel", Label: "Phone", Selector: "#phone"},
+			{Name: "fax", Type: "tel", Label: "Fax", Selector: "#fax"},
+		},
+		Version: 4,
+	}
+}
+
+func TestCompareTemplateDrift(t *testing.T) {
+	template := newDriftTestTemplate()
+	forms := []DetectedForm{{
+		Fields: []DetectedField{
+			{Name: "email", Type: "email", Label: "Email", Selector: "#contact-email", Required: true},
+			{Name: "phone", Type: "text", Label: "Phone", Selector: "#phone"},
+			{Name: "company", Type: "text", Label: "Company", Selector: "#company", Required: true},
+		},
+	}}
+	resolvable := map[string]bool{"email": false, "phone": true, "fax": false}
+
+	report := compareTemplateDrift(template, forms, resolvable)
+
+	expected := map[string]DriftSeverity{
+		DriftBrokenSelector + ":email":     DriftSeverityHigh,
+		DriftTypeChanged + ":phone":        DriftSeverityLow,
+		DriftMissingField + ":fax":         DriftSeverityMedium,
+		DriftNewRequiredField + ":company": DriftSeverityHigh,
+	}
+
+	if len(report.Issues) != len(expected) {
+		t.Fatalf("Expected %d issues, got %d: %+v", len(expected), len(report.Issues), report.Issues)
+	}
+
+	for _, issue := range report.Issues {
+		key := issue.Kind + ":" + issue.Field
+		if severity, ok := expected[key]; !ok || severity != issue.Severity {
+			t.Errorf("Unexpected issue %s with severity %s", key, issue.Severity)
+		}
+	}
+
+	if report.Severity != DriftSeverityHigh || !report.Breaking() {
+		t.Errorf("Expected a breaking high severity report, got %s", report.Severity)
+	}
+}
+
+func TestCompareTemplateDriftUnchanged(t *testing.T) {
+	template := newDriftTestTemplate()
+	forms := []DetectedForm{{
+		Fields: []DetectedField{
+			{Name: "email", Type: "email", Label: "Email", Selector: "#email", Required: true},
+			{Name: "phone", Type: "tel", Label: "Phone", Selector: "#phone"},
+			{Name: "fax", Type: "tel", Label: "Fax", Selector: "#fax"},
+		},
+	}}
+	resolvable := map[string]bool{"email": true, "phone": true, "fax": true}
+
+	report := compareTemplateDrift(template, forms, resolvable)
+	if report.Severity != DriftSeverityNone || len(report.Issues) != 0 {
+		t.Errorf("Expected no drift, got %s: %+v", report.Severity, report.Issues)
+	}
+
+	report = compareTemplateDrift(template, nil, resolvable)
+	if len(report.Issues) != 1 || report.Issues[0].Kind != DriftFormMissing {
+		t.Errorf("Expected a missing form issue, got %+v", report.Issues)
+	}
+}
+
+func TestFindBestTemplateSkipsStale(t *testing.T) {
+	tm, err := NewTemplateManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create template manager: %v", err)
+	}
+
+	template := newDriftTestTemplate()
+	if err := tm.SaveTemplate(template); err != nil {
+		t.Fatalf("Failed to save template: %v", err)
+	}
+
+	if _, err := tm.FindBestTemplate(template.URL); err != nil {
+		t.Fatalf("Expected template to be found: %v", err)
+	}
+
+	if err := tm.MarkTemplateStale(template.ID, true); err != nil {
+		t.Fatalf("Failed to mark template stale: %v", err)
+	}
+
+	if _, err := tm.FindBestTemplate(template.URL); err == nil {
+		t.Error("Expected stale template to be skipped")
+	}
+
+	history, _ := tm.TemplateHistory(template.ID)
+	if len(history) != 1 {
+		t.Errorf("Expected marking stale not to create a revision, got %d revisions", len(history))
+	}
+}
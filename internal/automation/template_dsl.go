@@ -0,0 +1,658 @@
+package automation
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// templateDocument is the hand-editable YAML form of a template. Runtime
+// state such as success rates, working selectors and ranked candidates is
+// not part of it; the stored JSON keeps those.
+type templateDocument struct {
+	ID         string           `yaml:"id"`
+	URL        string           `yaml:"url"`
+	Match      []string         `yaml:"match,omitempty"`
+	Domain     string           `yaml:"domain,omitempty"`
+	FormType   string           `yaml:"form_type,omitempty"`
+	Version    int              `yaml:"version,omitempty"`
+	Fields     []fieldDocument  `yaml:"fields"`
+	Steps      []TemplateStep   `yaml:"steps,omitempty"`
+	Submit     *SubmitAction    `yaml:"submit,omitempty"`
+	Success    []SuccessRule    `yaml:"success,omitempty"`
+	Validation []ValidationRule `yaml:"validation,omitempty"`
+	Retry      *RetryPolicy     `yaml:"retry,omitempty"`
+	HostLimits *HostLimits      `yaml:"host_limits,omitempty"`
+}
+
+// fieldDocument is a template field in YAML. The first selector is the
+// primary one and the rest are fallbacks.
+type fieldDocument struct {
+	ID         string   `yaml:"id,omitempty"`
+	Name       string   `yaml:"name"`
+	Type       string   `yaml:"type,omitempty"`
+	Label      string   `yaml:"label,omitempty"`
+	Required   bool     `yaml:"required,omitempty"`
+	Selectors  []string `yaml:"selectors"`
+	Bind       string   `yaml:"bind,omitempty"`
+	Value      string   `yaml:"value,omitempty"`
+	Transforms []string `yaml:"transforms,omitempty"`
+	Pattern    string   `yaml:"pattern,omitempty"`
+	Default    string   `yaml:"default,omitempty"`
+}
+
+// Keys accepted in each section of a YAML template
+var (
+	templateDocumentKeys = []string{"id", "url", "match", "domain", "form_type", "version", "fields", "steps", "submit", "success", "validation", "retry", "host_limits"}
+	fieldDocumentKeys    = []string{"id", "name", "type", "label", "required", "selectors", "bind", "value", "transforms", "pattern", "default"}
+	stepDocumentKeys     = []string{"action", "selector", "field", "value", "timeout"}
+	submitDocumentKeys   = []string{"selector", "wait_for", "timeout"}
+	successDocumentKeys  = []string{"type", "pattern", "selector", "text"}
+	validationKeys       = []string{"field", "type", "pattern", "message"}
+	retryDocumentKeys    = []string{"max_retries", "initial_interval", "max_interval", "multiplier", "jitter", "max_elapsed_time", "retryable_errors"}
+	hostLimitsKeys       = []string{"max_concurrency", "min_interval"}
+)
+
+// templateFieldTypes are the input types a template field can declare
+var templateFieldTypes = []string{"text", "email", "password", "tel", "url", "number", "date", "textarea", "select", "checkbox", "radio", "hidden"}
+
+// TemplateValidationError is a problem found in a YAML template
+type TemplateValidationError struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e TemplateValidationError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("line %d, column %d: %s: %s", e.Line, e.Column, e.Path, e.Message)
+}
+
+// TemplateValidationErrors lists every problem found in a YAML template
+type TemplateValidationErrors []TemplateValidationError
+
+func (e TemplateValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// ParseTemplateYAML parses and validates a YAML template. Validation
+// problems are returned together as TemplateValidationErrors.
+func ParseTemplateYAML(data []byte) (*FormTemplate, error) {
+	document, errs := decodeTemplateDocument(data)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return document.template(), nil
+}
+
+// ValidateTemplateYAML reports every problem in a YAML template, in line order
+func ValidateTemplateYAML(data []byte) TemplateValidationErrors {
+	_, errs := decodeTemplateDocument(data)
+	return errs
+}
+
+// MarshalTemplateYAML renders a template in the YAML template format
+func MarshalTemplateYAML(template *FormTemplate) ([]byte, error) {
+	document := newTemplateDocument(template)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(document); err != nil {
+		return nil, fmt.Errorf("failed to marshal template: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to marshal template: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// newTemplateDocument converts a template to its YAML form
+func newTemplateDocument(template *FormTemplate) *templateDocument {
+	document := &templateDocument{
+		ID:         template.ID,
+		URL:        template.URL,
+		Match:      template.URLPatterns,
+		FormType:   template.FormType,
+		Version:    template.Version,
+		Steps:      template.Steps,
+		Submit:     template.Submit,
+		Success:    template.Success,
+		Validation: template.ValidationRules,
+		Retry:      template.RetryPolicy,
+		HostLimits: template.HostLimits,
+	}
+
+	// The domain is only written when it differs from the URL's host
+	if parsed, err := url.Parse(template.URL); err != nil || parsed.Hostname() != template.Domain {
+		document.Domain = template.Domain
+	}
+
+	for _, field := range template.Fields {
+		selectors := []string{}
+		if field.Selector != "" {
+			selectors = append(selectors, field.Selector)
+		}
+		selectors = append(selectors, field.Fallbacks...)
+
+		document.Fields = append(document.Fields, fieldDocument{
+			ID:         field.ID,
+			Name:       field.Name,
+			Type:       field.Type,
+			Label:      field.Label,
+			Required:   field.Required,
+			Selectors:  selectors,
+			Bind:       field.Binding,
+			Value:      field.Value,
+			Transforms: field.Transforms,
+			Pattern:    field.ValidationPattern,
+			Default:    field.DefaultValue,
+		})
+	}
+
+	return document
+}
+
+// template converts a validated document to a template
+func (d *templateDocument) template() *FormTemplate {
+	template := &FormTemplate{
+		ID:              d.ID,
+		URL:             d.URL,
+		URLPatterns:     d.Match,
+		Domain:          d.Domain,
+		FormType:        d.FormType,
+		Version:         d.Version,
+		Fields:          make([]FormField, 0, len(d.Fields)),
+		Selectors:       make(map[string]string, len(d.Fields)),
+		ValidationRules: d.Validation,
+		Steps:           d.Steps,
+		Submit:          d.Submit,
+		Success:         d.Success,
+		RetryPolicy:     d.Retry,
+		HostLimits:      d.HostLimits,
+	}
+
+	if template.Domain == "" {
+		if parsed, err := url.Parse(d.URL); err == nil {
+			template.Domain = parsed.Hostname()
+		}
+	}
+	if template.ValidationRules == nil {
+		template.ValidationRules = []ValidationRule{}
+	}
+
+	for _, field := range d.Fields {
+		formField := FormField{
+			ID:                field.ID,
+			Name:              field.Name,
+			Type:              field.Type,
+			Label:             field.Label,
+			Required:          field.Required,
+			ValidationPattern: field.Pattern,
+			DefaultValue:      field.Default,
+			Binding:           field.Bind,
+			Value:             field.Value,
+			Transforms:        field.Transforms,
+		}
+		if len(field.Selectors) > 0 {
+			formField.Selector = field.Selectors[0]
+			if len(field.Selectors) > 1 {
+				formField.Fallbacks = append([]string(nil), field.Selectors[1:]...)
+			}
+		}
+		if formField.ID == "" {
+			formField.ID = fmt.Sprintf("field_%s", field.Name)
+		}
+
+		template.Fields = append(template.Fields, formField)
+		template.Selectors[formField.Name] = formField.Selector
+	}
+
+	return template
+}
+
+// decodeTemplateDocument parses a YAML template and collects validation errors
+func decodeTemplateDocument(data []byte) (*templateDocument, TemplateValidationErrors) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, TemplateValidationErrors{yamlSyntaxError(err)}
+	}
+
+	if len(root.Content) == 0 {
+		return nil, TemplateValidationErrors{{Line: 1, Column: 1, Message: "template is empty"}}
+	}
+
+	v := &templateValidator{}
+	node := root.Content[0]
+	if node.Kind != yaml.MappingNode {
+		v.errorf(node, "", "template must be a mapping")
+		return nil, v.errors
+	}
+
+	v.checkStructure(node)
+	if len(v.errors) > 0 {
+		return nil, v.sorted()
+	}
+
+	var document templateDocument
+	if err := node.Decode(&document); err != nil {
+		v.addDecodeError(err)
+		return nil, v.sorted()
+	}
+
+	v.checkDocument(node, &document)
+	if len(v.errors) > 0 {
+		return nil, v.sorted()
+	}
+
+	return &document, nil
+}
+
+// templateValidator collects validation errors with their source positions
+type templateValidator struct {
+	errors TemplateValidationErrors
+}
+
+// errorf records an error at the position of a node
+func (v *templateValidator) errorf(node *yaml.Node, path string, format string, args ...interface{}) {
+	v.errors = append(v.errors, TemplateValidationError{
+		Line:    node.Line,
+		Column:  node.Column,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// sorted returns the errors in line order
+func (v *templateValidator) sorted() TemplateValidationErrors {
+	sort.SliceStable(v.errors, func(i, j int) bool {
+		if v.errors[i].Line != v.errors[j].Line {
+			return v.errors[i].Line < v.errors[j].Line
+		}
+		return v.errors[i].Column < v.errors[j].Column
+	})
+	return v.errors
+}
+
+// checkStructure rejects unknown keys and wrongly shaped sections before decoding
+func (v *templateValidator) checkStructure(node *yaml.Node) {
+	v.checkKeys(node, "", templateDocumentKeys)
+
+	sections := map[string][]string{
+		"fields":     fieldDocumentKeys,
+		"steps":      stepDocumentKeys,
+		"success":    successDocumentKeys,
+		"validation": validationKeys,
+	}
+	for section, keys := range sections {
+		list := mappingValue(node, section)
+		if list == nil || list.Tag == "!!null" {
+			continue
+		}
+		if list.Kind != yaml.SequenceNode {
+			v.errorf(list, section, "must be a list")
+			continue
+		}
+		for i, item := range list.Content {
+			path := fmt.Sprintf("%s[%d]", section, i)
+			if item.Kind != yaml.MappingNode {
+				v.errorf(item, path, "must be a mapping")
+				continue
+			}
+			v.checkKeys(item, path, keys)
+		}
+	}
+
+	mappings := map[string][]string{
+		"submit":      submitDocumentKeys,
+		"retry":       retryDocumentKeys,
+		"host_limits": hostLimitsKeys,
+	}
+	for section, keys := range mappings {
+		mapping := mappingValue(node, section)
+		if mapping == nil || mapping.Tag == "!!null" {
+			continue
+		}
+		if mapping.Kind != yaml.MappingNode {
+			v.errorf(mapping, section, "must be a mapping")
+			continue
+		}
+		v.checkKeys(mapping, section, keys)
+	}
+}
+
+// checkKeys reports keys that are not allowed or appear twice in a mapping
+func (v *templateValidator) checkKeys(node *yaml.Node, path string, allowed []string) {
+	seen := make(map[string]int)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		keyPath := joinPath(path, key.Value)
+
+		if line, duplicate := seen[key.Value]; duplicate {
+			v.errorf(key, keyPath, "duplicate key, first defined on line %d", line)
+			continue
+		}
+		seen[key.Value] = key.Line
+
+		if !containsString(allowed, key.Value) {
+			v.errorf(key, keyPath, "unknown key, expected one of: %s", strings.Join(allowed, ", "))
+		}
+	}
+}
+
+// addDecodeError converts a YAML decoding error into validation errors
+func (v *templateValidator) addDecodeError(err error) {
+	typeErr, ok := err.(*yaml.TypeError)
+	if !ok {
+		v.errors = append(v.errors, yamlSyntaxError(err))
+		return
+	}
+
+	for _, message := range typeErr.Errors {
+		v.errors = append(v.errors, yamlSyntaxError(fmt.Errorf("%s", message)))
+	}
+}
+
+// checkDocument validates the meaning of a decoded template
+func (v *templateValidator) checkDocument(node *yaml.Node, d *templateDocument) {
+	if d.ID == "" {
+		v.errorf(keyOrNode(node, "id"), "id", "is required")
+	} else if !validTemplateID(d.ID) {
+		v.errorf(mappingValue(node, "id"), "id", "may only contain letters, digits, '_', '-' and '.', got %q", d.ID)
+	}
+
+	if d.URL == "" {
+		v.errorf(keyOrNode(node, "url"), "url", "is required")
+	} else if parsed, err := url.Parse(d.URL); err != nil || parsed.Scheme == "" || (parsed.Host == "" && parsed.Scheme != "file") {
+		v.errorf(mappingValue(node, "url"), "url", "must be an absolute URL, got %q", d.URL)
+	}
+
+	matchNode := mappingValue(node, "match")
+	for i, raw := range d.Match {
+		if _, err := ParseURLPattern(raw); err != nil {
+			v.errorf(matchNode.Content[i], fmt.Sprintf("match[%d]", i), "%v", err)
+		}
+	}
+
+	if d.FormType != "" && !isKnownFormType(d.FormType) {
+		v.errorf(mappingValue(node, "form_type"), "form_type", "unknown form type %q", d.FormType)
+	}
+
+	fieldLines := make(map[string]int)
+	fieldsNode := mappingValue(node, "fields")
+	if len(d.Fields) == 0 {
+		v.errorf(keyOrNode(node, "fields"), "fields", "at least one field is required")
+	}
+	for i, field := range d.Fields {
+		v.checkField(fieldsNode.Content[i], fmt.Sprintf("fields[%d]", i), field, fieldLines)
+	}
+
+	stepsNode := mappingValue(node, "steps")
+	for i, step := range d.Steps {
+		v.checkStep(stepsNode.Content[i], fmt.Sprintf("steps[%d]", i), step, fieldLines)
+	}
+
+	if d.Submit != nil {
+		submitNode := mappingValue(node, "submit")
+		if d.Submit.Selector == "" {
+			v.errorf(submitNode, "submit.selector", "is required")
+		}
+		if d.Submit.Timeout < 0 {
+			v.errorf(mappingValue(submitNode, "timeout"), "submit.timeout", "must not be negative")
+		}
+	}
+
+	successNode := mappingValue(node, "success")
+	for i, rule := range d.Success {
+		v.checkSuccessRule(successNode.Content[i], fmt.Sprintf("success[%d]", i), rule)
+	}
+
+	validationNode := mappingValue(node, "validation")
+	for i, rule := range d.Validation {
+		path := fmt.Sprintf("validation[%d]", i)
+		ruleNode := validationNode.Content[i]
+		if _, exists := fieldLines[rule.Field]; !exists {
+			v.errorf(keyOrNode(ruleNode, "field"), path+".field", "unknown field %q", rule.Field)
+		}
+		if rule.Pattern != "" {
+			if _, err := regexp.Compile(rule.Pattern); err != nil {
+				v.errorf(mappingValue(ruleNode, "pattern"), path+".pattern", "invalid regular expression: %v", err)
+			}
+		}
+	}
+}
+
+// checkField validates a single field and records its name
+func (v *templateValidator) checkField(node *yaml.Node, path string, field fieldDocument, fieldLines map[string]int) {
+	if field.Name == "" {
+		v.errorf(keyOrNode(node, "name"), path+".name", "is required")
+	} else if line, duplicate := fieldLines[field.Name]; duplicate {
+		v.errorf(mappingValue(node, "name"), path+".name", "duplicate field %q, first defined on line %d", field.Name, line)
+	} else {
+		fieldLines[field.Name] = node.Line
+	}
+
+	if field.Type != "" && !containsString(templateFieldTypes, field.Type) {
+		v.errorf(mappingValue(node, "type"), path+".type", "unknown field type %q, expected one of: %s", field.Type, strings.Join(templateFieldTypes, ", "))
+	}
+
+	if len(field.Selectors) == 0 {
+		v.errorf(keyOrNode(node, "selectors"), path+".selectors", "at least one selector is required")
+	}
+	selectorsNode := mappingValue(node, "selectors")
+	for i, selector := range field.Selectors {
+		if strings.TrimSpace(selector) == "" {
+			v.errorf(selectorsNode.Content[i], fmt.Sprintf("%s.selectors[%d]", path, i), "selector is empty")
+		}
+	}
+
+	if field.Bind != "" {
+		if _, ok := profileBindings[field.Bind]; !ok {
+			v.errorf(mappingValue(node, "bind"), path+".bind", "unknown profile binding %q, expected one of: %s", field.Bind, strings.Join(sortedKeys(profileBindings), ", "))
+		}
+		if field.Value != "" {
+			v.errorf(mappingValue(node, "value"), path+".value", "a field cannot have both bind and value")
+		}
+	}
+
+	transformsNode := mappingValue(node, "transforms")
+	for i, transform := range field.Transforms {
+		if _, ok := valueTransforms[transform]; !ok {
+			v.errorf(transformsNode.Content[i], fmt.Sprintf("%s.transforms[%d]", path, i), "unknown transform %q, expected one of: %s", transform, strings.Join(sortedKeys(valueTransforms), ", "))
+		}
+	}
+
+	if field.Pattern != "" {
+		if _, err := regexp.Compile(field.Pattern); err != nil {
+			v.errorf(mappingValue(node, "pattern"), path+".pattern", "invalid regular expression: %v", err)
+		}
+	}
+}
+
+// checkStep validates a single step
+func (v *templateValidator) checkStep(node *yaml.Node, path string, step TemplateStep, fieldLines map[string]int) {
+	switch step.Action {
+	case "":
+		v.errorf(keyOrNode(node, "action"), path+".action", "is required")
+		return
+	case StepFill:
+		if step.Field == "" {
+			v.errorf(keyOrNode(node, "field"), path+".field", "is required for fill steps")
+		} else if _, exists := fieldLines[step.Field]; !exists {
+			v.errorf(mappingValue(node, "field"), path+".field", "unknown field %q", step.Field)
+		}
+	case StepClick, StepWait, StepCheck, StepSelect, StepPress:
+		if step.Selector == "" {
+			v.errorf(keyOrNode(node, "selector"), path+".selector", "is required for %s steps", step.Action)
+		}
+		if (step.Action == StepSelect || step.Action == StepPress) && step.Value == "" {
+			v.errorf(keyOrNode(node, "value"), path+".value", "is required for %s steps", step.Action)
+		}
+	default:
+		v.errorf(mappingValue(node, "action"), path+".action", "unknown action %q, expected one of: %s", step.Action,
+			strings.Join([]string{StepClick, StepWait, StepFill, StepCheck, StepSelect, StepPress}, ", "))
+	}
+
+	if step.Timeout < 0 {
+		v.errorf(mappingValue(node, "timeout"), path+".timeout", "must not be negative")
+	}
+}
+
+// checkSuccessRule validates a single success rule
+func (v *templateValidator) checkSuccessRule(node *yaml.Node, path string, rule SuccessRule) {
+	switch rule.Type {
+	case "":
+		v.errorf(keyOrNode(node, "type"), path+".type", "is required")
+	case SuccessURLMatches:
+		if rule.Pattern == "" {
+			v.errorf(keyOrNode(node, "pattern"), path+".pattern", "is required for url_matches rules")
+		} else if _, err := regexp.Compile(rule.Pattern); err != nil {
+			v.errorf(mappingValue(node, "pattern"), path+".pattern", "invalid regular expression: %v", err)
+		}
+	case SuccessElementText:
+		if rule.Selector == "" {
+			v.errorf(keyOrNode(node, "selector"), path+".selector", "is required for element_text rules")
+		}
+		if rule.Text == "" {
+			v.errorf(keyOrNode(node, "text"), path+".text", "is required for element_text rules")
+		}
+	case SuccessElementAbsent:
+		if rule.Selector == "" {
+			v.errorf(keyOrNode(node, "selector"), path+".selector", "is required for element_absent rules")
+		}
+	case SuccessResponseOK:
+		if rule.Pattern != "" {
+			if _, err := regexp.Compile(rule.Pattern); err != nil {
+				v.errorf(mappingValue(node, "pattern"), path+".pattern", "invalid regular expression: %v", err)
+			}
+		}
+	default:
+		if _, ok := successChecker(rule.Type); !ok {
+			v.errorf(mappingValue(node, "type"), path+".type", "unknown success rule %q, expected one of: %s", rule.Type,
+				strings.Join(SuccessRuleTypes(), ", "))
+		}
+	}
+}
+
+// yamlLinePattern extracts the line number from YAML parser messages
+var yamlLinePattern = regexp.MustCompile(`line (\d+): `)
+
+// yamlSyntaxError converts a YAML parser error into a validation error
+func yamlSyntaxError(err error) TemplateValidationError {
+	message := strings.TrimPrefix(err.Error(), "yaml: ")
+
+	line := 1
+	if match := yamlLinePattern.FindStringSubmatchIndex(message); match != nil {
+		line, _ = strconv.Atoi(message[match[2]:match[3]])
+		message = message[:match[0]] + message[match[1]:]
+	}
+
+	return TemplateValidationError{Line: line, Column: 1, Message: message}
+}
+
+// mappingValue returns the value node for a key, or nil if it is absent
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// keyOrNode returns the value node for a key, or the mapping itself when the
+// key is absent, so missing keys are reported where they belong
+func keyOrNode(node *yaml.Node, key string) *yaml.Node {
+	if value := mappingValue(node, key); value != nil {
+		return value
+	}
+	return node
+}
+
+// joinPath appends a key to a dotted document path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// isKnownFormType reports whether a form type is one the detector produces
+func isKnownFormType(formType string) bool {
+	switch FormType(formType) {
+	case FormTypeRegistration, FormTypeLogin, FormTypeContact, FormTypeCheckout, FormTypeProfile, FormTypeSurvey, FormTypeUnknown:
+		return true
+	}
+	return false
+}
+
+// containsString reports whether a list contains a value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of a map in order, for error messages
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ImportTemplateYAML parses a YAML template and saves it as an imported
+// revision. Runtime state of an existing template with the same ID, such as
+// its success rate and the candidates of unchanged fields, is kept.
+func (tm *TemplateManager) ImportTemplateYAML(data []byte, source string) (*FormTemplate, error) {
+	template, err := ParseTemplateYAML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	if existing, err := tm.getTemplate(template.ID); err == nil {
+		template.Version = existing.Version
+		template.SuccessRate = existing.SuccessRate
+		for i := range template.Fields {
+			field := &template.Fields[i]
+			previous, ok := existing.Field(field.Name)
+			if !ok || previous.Selector != field.Selector {
+				continue
+			}
+			field.Candidates = append([]SelectorCandidate(nil), previous.Candidates...)
+			field.WorkingSelector = previous.WorkingSelector
+		}
+	}
+
+	if err := tm.saveRevision(template, TemplateAuthorImport, "imported from "+source, false); err != nil {
+		return nil, err
+	}
+
+	return template, nil
+}
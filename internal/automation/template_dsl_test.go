@@ -0,0 +1,239 @@
+package automation
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleTemplateYAML = `id: example_signup
+url: https://example.com/signup
+match: ["*.example.com/signup/**"]
+form_type: registration
+version: 2
+fields:
+  - name: email
+    type: email
+    label: Email address
+    required: true
+    selectors: ["#email", "input[name=email]"]
+    bind: email
+    transforms: [trim, lowercase]
+  - name: phone
+    type: tel
+    selectors: ["#phone"]
+    bind: phone
+    transforms: [digits]
+  - name: source
+    type: select
+    selectors: ["#source"]
+    value: search
+steps:
+  - action: click
+    selector: "#accept-cookies"
+    timeout: 5s
+  - action: fill
+    field: email
+  - action: click
+    selector: "#next"
+  - action: wait
+    selector: "#phone"
+submit:
+  selector: button[type=submit]
+  wait_for: .confirmation
+success:
+  - type: url_matches
+    pattern: /welcome
+  - type: element_absent
+    selector: .error
+host_limits:
+  max_concurrency: 1
+  min_interval: 3s
+`
+
+func TestParseTemplateYAML(t *testing.T) {
+	template, err := ParseTemplateYAML([]byte(sampleTemplateYAML))
+	if err != nil {
+		t.Fatalf("Failed to parse template: %v", err)
+	}
+
+	if template.Domain != "example.com" {
+		t.Errorf("Expected domain to be derived from the URL, got %q", template.Domain)
+	}
+
+	if !reflect.DeepEqual(template.URLPatterns, []string{"*.example.com/signup/**"}) {
+		t.Errorf("Expected URL patterns, got %v", template.URLPatterns)
+	}
+
+	email, ok := template.Field("email")
+	if !ok {
+		t.Fatal("Expected email field")
+	}
+	if email.Selector != "#email" || !reflect.DeepEqual(email.Fallbacks, []string{"input[name=email]"}) {
+		t.Errorf("Expected primary selector and fallback, got %q %v", email.Selector, email.Fallbacks)
+	}
+	if email.Binding != "email" || len(email.Transforms) != 2 {
+		t.Errorf("Expected binding and transforms, got %q %v", email.Binding, email.Transforms)
+	}
+
+	if len(template.Steps) != 4 || template.Steps[0].Timeout != 5*time.Second {
+		t.Errorf("Expected 4 steps with a 5s timeout on the first, got %+v", template.Steps)
+	}
+
+	if template.Submit == nil || template.Submit.WaitFor != ".confirmation" {
+		t.Errorf("Expected submit action, got %+v", template.Submit)
+	}
+
+	if template.HostLimits == nil || template.HostLimits.MinInterval != 3*time.Second {
+		t.Errorf("Expected host limits, got %+v", template.HostLimits)
+	}
+}
+
+func TestTemplateYAMLRoundTrip(t *testing.T) {
+	template, err := ParseTemplateYAML([]byte(sampleTemplateYAML))
+	if err != nil {
+		t.Fatalf("Failed to parse template: %v", err)
+	}
+
+	// Through the stored JSON and back to YAML
+	data, err := json.Marshal(template)
+	if err != nil {
+		t.Fatalf("Failed to marshal JSON: %v", err)
+	}
+	var stored FormTemplate
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v", err)
+	}
+
+	rendered, err := MarshalTemplateYAML(&stored)
+	if err != nil {
+		t.Fatalf("Failed to marshal YAML: %v", err)
+	}
+
+	reparsed, err := ParseTemplateYAML(rendered)
+	if err != nil {
+		t.Fatalf("Failed to parse rendered YAML: %v\n%s", err, rendered)
+	}
+
+	if !reflect.DeepEqual(template, reparsed) {
+		t.Errorf("Expected template to survive the round trip\noriginal: %+v\nreparsed: %+v", template, reparsed)
+	}
+
+	again, err := MarshalTemplateYAML(reparsed)
+	if err != nil {
+		t.Fatalf("Failed to marshal YAML: %v", err)
+	}
+	if string(again) != string(rendered) {
+		t.Errorf("Expected stable YAML output\nfirst:\n%s\nsecond:\n%s", rendered, again)
+	}
+}
+
+func TestValidateTemplateYAML(t *testing.T) {
+	source := `id: broken
+url: example.com/signup
+fields:
+  - name: email
+    selectors: ["#email"]
+    bind: emial
+  - name: email
+    selectors: []
+    colour: red
+steps:
+  - action: fill
+    field: phone
+  - action: hover
+    selector: "#x"
+success:
+  - type: url_matches
+    pattern: "("
+`
+
+	errs := ValidateTemplateYAML([]byte(source))
+
+	// Unknown keys are reported before the rest of the template is checked
+	if len(errs) != 1 || errs[0].Line != 9 || errs[0].Path != "fields[1].colour" {
+		t.Fatalf("Expected unknown key on line 9, got %v", errs)
+	}
+
+	errs = ValidateTemplateYAML([]byte(strings.Replace(source, "    colour: red\n", "", 1)))
+
+	expected := []struct {
+		line int
+		path string
+	}{
+		{2, "url"},
+		{6, "fields[0].bind"},
+		{7, "fields[1].name"},
+		{8, "fields[1].selectors"},
+		{11, "steps[0].field"},
+		{12, "steps[1].action"},
+		{16, "success[0].pattern"},
+	}
+
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d:\n%v", len(expected), len(errs), errs)
+	}
+
+	for i, want := range expected {
+		if errs[i].Line != want.line || errs[i].Path != want.path {
+			t.Errorf("Error %d: expected line %d %s, got %v", i, want.line, want.path, errs[i])
+		}
+	}
+}
+
+func TestValidateTemplateYAMLSyntaxError(t *testing.T) {
+	errs := ValidateTemplateYAML([]byte("id: x\nurl: https://example.com\nfields:\n  - name: [a\n"))
+	// The parser reports where the unterminated list's context starts
+	if len(errs) != 1 || errs[0].Line < 3 || errs[0].Line > 4 {
+		t.Fatalf("Expected a syntax error near line 4, got %v", errs)
+	}
+
+	errs = ValidateTemplateYAML([]byte("id: x\nurl: https://example.com\nversion: two\nfields:\n  - name: a\n    selectors: [\"#a\"]\n"))
+	if len(errs) != 1 || errs[0].Line != 3 {
+		t.Fatalf("Expected a type error on line 3, got %v", errs)
+	}
+}
+
+func TestValidateTemplateYAMLUnsafeID(t *testing.T) {
+	for _, id := range []string{"../../x", "a/b", "..", ".", "sign up"} {
+		source := fmt.Sprintf("id: %q\nurl: https://example.com\nfields:\n  - name: a\n    selectors: [\"#a\"]\n", id)
+		errs := ValidateTemplateYAML([]byte(source))
+		if len(errs) != 1 || errs[0].Line != 1 || errs[0].Path != "id" {
+			t.Errorf("Expected id %q to be refused on line 1, got %v", id, errs)
+		}
+	}
+
+	if errs := ValidateTemplateYAML([]byte("id: signup_v2.example-com\nurl: https://example.com\nfields:\n  - name: a\n    selectors: [\"#a\"]\n")); len(errs) != 0 {
+		t.Errorf("Expected a safe id to be valid, got %v", errs)
+	}
+}
+
+func TestFieldValueBindingAndTransforms(t *testing.T) {
+	filler := &FormFiller{}
+	profile := &ProfileData{
+		Email: "  Jane@Example.COM ",
+		Phone: "(555) 123-4567",
+		Address: Address{
+			City: "Springfield",
+		},
+	}
+
+	tests := []struct {
+		field    FormField
+		expected string
+	}{
+		{FormField{Name: "contact", Binding: "email", Transforms: []string{"trim", "lowercase"}}, "jane@example.com"},
+		{FormField{Name: "mobile", Binding: "phone", Transforms: []string{"digits"}}, "5551234567"},
+		{FormField{Name: "town", Binding: "address.city", Transforms: []string{"uppercase"}}, "SPRINGFIELD"},
+		{FormField{Name: "email", Value: "fixed@example.com"}, "fixed@example.com"},
+	}
+
+	for _, test := range tests {
+		if got := filler.getFieldValue(&test.field, profile); got != test.expected {
+			t.Errorf("Field %s: expected %q, got %q", test.field.Name, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,371 @@
+package automation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/ai-form-filler/cli/internal/storage"
+)
+
+// TemplateHealer repairs templates whose selectors no longer match the page
+// by re-detecting the form and mapping the old fields onto the new elements
+type TemplateHealer struct {
+	detector  *FormDetector
+	templates *TemplateManager
+	learning  *storage.LearningSessionStore
+	config    *HealerConfig
+	mutex     sync.Mutex // Serializes heals so concurrent tasks don't race on the template files
+}
+
+// HealerConfig holds configuration for template healing
+type HealerConfig struct {
+	MinMatchScore  float64 `json:"minMatchScore"`  // Minimum similarity for a field match, 0-1
+	MinHealedRatio float64 `json:"minHealedRatio"` // Fraction of fields that must match to accept a heal
+}
+
+// HealResult describes the outcome of a heal attempt
+type HealResult struct {
+	TemplateID string       `json:"templateId"`
+	URL        string       `json:"url"`
+	OldVersion int          `json:"oldVersion"`
+	NewVersion int          `json:"newVersion"`
+	Matches    []FieldMatch `json:"matches"`
+	Unmatched  []string     `json:"unmatched"`
+	Healed     bool         `json:"healed"`
+}
+
+// FieldMatch pairs a template field with the element detected in its place
+type FieldMatch struct {
+	Field       string  `json:"field"`
+	OldSelector string  `json:"oldSelector"`
+	NewSelector string  `json:"newSelector"`
+	Score       float64 `json:"score"`
+}
+
+// DefaultHealerConfig returns sensible defaults
+func DefaultHealerConfig() *HealerConfig {
+	return &HealerConfig{
+		MinMatchScore:  0.5,
+		MinHealedRatio: 0.5,
+	}
+}
+
+// NewTemplateHealer creates a new template healer. The template manager and
+// learning store are optional; without them healed templates are not persisted
+// or logged.
+func NewTemplateHealer(detector *FormDetector, templates *TemplateManager, learning *storage.LearningSessionStore, config *HealerConfig) *TemplateHealer {
+	if config == nil {
+		config = DefaultHealerConfig()
+	}
+
+	return &TemplateHealer{
+		detector:  detector,
+		templates: templates,
+		learning:  learning,
+		config:    config,
+	}
+}
+
+// Heal re-detects the template's form on the page at pageURL, which may be
+// another page its patterns or domain match, and returns a patched copy
+// with the next version number. The previous version stays in the template
+// history.
+func (th *TemplateHealer) Heal(ctx context.Context, template *FormTemplate, pageURL string) (*FormTemplate, *HealResult, error) {
+	return th.heal(template, pageURL, func() (*FormAnalysisResult, error) {
+		return th.detector.AnalyzePage(ctx, pageURL)
+	})
+}
+
+// HealOnPage heals the template from the form on a page session's page
+// instead of loading the template URL again
+func (th *TemplateHealer) HealOnPage(ctx context.Context, template *FormTemplate, session *PageSession) (*FormTemplate, *HealResult, error) {
+	return th.heal(template, session.url, func() (*FormAnalysisResult, error) {
+		return session.Detect(ctx, th.detector)
+	})
+}
+
+// heal patches the template from the forms analyze found on the page at pageURL
+func (th *TemplateHealer) heal(template *FormTemplate, pageURL string, analyze func() (*FormAnalysisResult, error)) (*FormTemplate, *HealResult, error) {
+	th.mutex.Lock()
+	defer th.mutex.Unlock()
+
+	result := &HealResult{
+		TemplateID: template.ID,
+		URL:        pageURL,
+		OldVersion: template.Version,
+		NewVersion: template.Version,
+	}
+
+	analysis, err := analyze()
+	if err != nil {
+		return nil, result, fmt.Errorf("failed to analyze page: %w", err)
+	}
+
+	if len(analysis.Forms) == 0 {
+		return nil, result, &AutomationError{Kind: ErrFormNotFound, Op: "heal template", URL: pageURL}
+	}
+
+	// Use the form whose fields best cover the template
+	bestForm, bestMatches := bestMatchingForm(template.Fields, analysis.Forms, th.config.MinMatchScore)
+
+	healed := template.Clone()
+	matched := make(map[int]bool, len(bestMatches))
+	for _, pairing := range bestMatches {
+		field := &healed.Fields[pairing.field]
+		detected := bestForm.Fields[pairing.detected]
+		matched[pairing.field] = true
+
+		result.Matches = append(result.Matches, FieldMatch{
+			Field:       field.Name,
+			OldSelector: field.Selector,
+			NewSelector: detected.Selector,
+			Score:       pairing.score,
+		})
+
+		field.Selector = detected.Selector
+		field.Candidates = append([]SelectorCandidate(nil), detected.Candidates...)
+		field.WorkingSelector = ""
+		if healed.Selectors == nil {
+			healed.Selectors = make(map[string]string)
+		}
+		healed.Selectors[field.Name] = detected.Selector
+	}
+
+	for i, field := range template.Fields {
+		if !matched[i] {
+			result.Unmatched = append(result.Unmatched, field.Name)
+		}
+	}
+
+	ratio := 0.0
+	if len(template.Fields) > 0 {
+		ratio = float64(len(bestMatches)) / float64(len(template.Fields))
+	}
+	if len(bestMatches) == 0 || ratio < th.config.MinHealedRatio {
+		th.recordHeal(template, bestForm, result, ratio)
+		return nil, result, fmt.Errorf("only %d of %d fields could be matched", len(bestMatches), len(template.Fields))
+	}
+
+	healed.Version = template.Version + 1
+	healed.LastUpdated = time.Now()
+	healed.Stale = false
+
+	if th.templates != nil {
+		reason := fmt.Sprintf("healed %d of %d fields", len(bestMatches), len(template.Fields))
+		if err := th.templates.SaveTemplateRevision(healed, TemplateAuthorHeal, reason); err != nil {
+			return nil, result, fmt.Errorf("failed to save healed template: %w", err)
+		}
+	}
+
+	result.NewVersion = healed.Version
+	result.Healed = true
+
+	th.recordHeal(template, bestForm, result, ratio)
+
+	return healed, result, nil
+}
+
+// recordHeal logs a heal attempt as a learning session
+func (th *TemplateHealer) recordHeal(template *FormTemplate, form *DetectedForm, result *HealResult, ratio float64) {
+	if th.learning == nil {
+		return
+	}
+
+	analysis, err := json.Marshal(map[string]interface{}{
+		"formIndex":      form.Index,
+		"formType":       form.FormType,
+		"formConfidence": form.Confidence,
+		"detectedFields": len(form.Fields),
+		"templateFields": len(template.Fields),
+	})
+	if err != nil {
+		fmt.Printf("Warning: failed to encode heal analysis: %v\n", err)
+		return
+	}
+
+	improvements, err := json.Marshal(result)
+	if err != nil {
+		fmt.Printf("Warning: failed to encode heal result: %v\n", err)
+		return
+	}
+
+	session := &storage.LearningSession{
+		ID:           fmt.Sprintf("heal_%s_%d", template.ID, time.Now().UnixNano()),
+		URL:          result.URL,
+		TemplateID:   template.ID,
+		AnalysisData: string(analysis),
+		Improvements: string(improvements),
+		SuccessRate:  ratio,
+	}
+	if err := th.learning.RecordLearningSession(session); err != nil {
+		fmt.Printf("Warning: failed to record heal: %v\n", err)
+	}
+}
+
+// fieldPairing is a scored match between a template field and a detected field
+type fieldPairing struct {
+	field    int
+	detected int
+	score    float64
+}
+
+// bestMatchingForm returns the detected form whose fields best cover the
+// template fields, along with the field pairings
+func bestMatchingForm(fields []FormField, forms []DetectedForm, minScore float64) (*DetectedForm, []fieldPairing) {
+	var bestForm *DetectedForm
+	var bestMatches []fieldPairing
+	bestScore := -1.0
+
+	for i := range forms {
+		pairings := matchTemplateFields(fields, forms[i].Fields, minScore)
+		score := 0.0
+		for _, pairing := range pairings {
+			score += pairing.score
+		}
+		if score > bestScore {
+			bestScore = score
+			bestForm = &forms[i]
+			bestMatches = pairings
+		}
+	}
+
+	return bestForm, bestMatches
+}
+
+// matchTemplateFields greedily pairs template fields with detected fields,
+// best scores first, so each element is used at most once
+func matchTemplateFields(fields []FormField, detected []DetectedField, minScore float64) []fieldPairing {
+	var candidates []fieldPairing
+	for i, field := range fields {
+		for j, element := range detected {
+			score := fieldSimilarity(field, element)
+			if score >= minScore {
+				candidates = append(candidates, fieldPairing{field: i, detected: j, score: score})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return candidates[a].score > candidates[b].score
+	})
+
+	usedFields := make(map[int]bool)
+	usedDetected := make(map[int]bool)
+	var pairings []fieldPairing
+	for _, candidate := range candidates {
+		if usedFields[candidate.field] || usedDetected[candidate.detected] {
+			continue
+		}
+		usedFields[candidate.field] = true
+		usedDetected[candidate.detected] = true
+		pairings = append(pairings, candidate)
+	}
+
+	sort.Slice(pairings, func(a, b int) bool {
+		return pairings[a].field < pairings[b].field
+	})
+
+	return pairings
+}
+
+// fieldSimilarity scores how likely a detected field is the template field,
+// weighing name, label and type. Missing labels don't count against a match.
+func fieldSimilarity(field FormField, detected DetectedField) float64 {
+	nameScore := nameSimilarity(field.Name, detected.Name)
+
+	typeScore := 0.0
+	if strings.EqualFold(field.Type, detected.Type) {
+		typeScore = 1.0
+	}
+
+	if field.Label == "" || detected.Label == "" {
+		return (0.45*nameScore + 0.2*typeScore) / 0.65
+	}
+
+	labelScore := tokenSimilarity(similarityTokens(field.Label), similarityTokens(detected.Label))
+	return 0.45*nameScore + 0.35*labelScore + 0.2*typeScore
+}
+
+// nameSimilarity compares field names ignoring case and separators
+func nameSimilarity(a, b string) float64 {
+	normalizedA := strings.Join(similarityTokens(a), "")
+	normalizedB := strings.Join(similarityTokens(b), "")
+	if normalizedA == "" || normalizedB == "" {
+		return 0
+	}
+	if normalizedA == normalizedB {
+		return 1.0
+	}
+	if strings.Contains(normalizedA, normalizedB) || strings.Contains(normalizedB, normalizedA) {
+		return 0.7
+	}
+	return tokenSimilarity(similarityTokens(a), similarityTokens(b))
+}
+
+// tokenSimilarity returns the Jaccard similarity of two token sets
+func tokenSimilarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	set := make(map[string]bool, len(a))
+	for _, token := range a {
+		set[token] = true
+	}
+
+	intersection := 0
+	union := len(set)
+	seen := make(map[string]bool, len(b))
+	for _, token := range b {
+		if seen[token] {
+			continue
+		}
+		seen[token] = true
+		if set[token] {
+			intersection++
+		} else {
+			union++
+		}
+	}
+
+	return float64(intersection) / float64(union)
+}
+
+// similarityTokens splits a name or label into lowercase words, breaking on
+// separators and camelCase boundaries
+func similarityTokens(s string) []string {
+	var tokens []string
+	var current []rune
+	var previous rune
+
+	flush := func() {
+		if len(current) > 0 {
+			tokens = append(tokens, string(current))
+			current = nil
+		}
+	}
+
+	for _, r := range s {
+		switch {
+		case unicode.IsUpper(r):
+			if unicode.IsLower(previous) {
+				flush()
+			}
+			current = append(current, unicode.ToLower(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			current = append(current, r)
+		default:
+			flush()
+		}
+		previous = r
+	}
+	flush()
+
+	return tokens
+}
@@ -0,0 +1,124 @@
+package automation
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSimilarityTokens(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"firstName", []string{"first", "name"}},
+		{"first_name", []string{"first", "name"}},
+		{"E-mail Address:", []string{"e", "mail", "address"}},
+		{"zip2", []string{"zip2"}},
+	}
+
+	for _, test := range tests {
+		if got := similarityTokens(test.input); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("similarityTokens(%q): expected %v, got %v", test.input, test.expected, got)
+		}
+	}
+}
+
+func TestFieldSimilarity(t *testing.T) {
+	field := FormField{Name: "firstName", Type: "text", Label: "First Name"}
+
+	renamed := fieldSimilarity(field, DetectedField{Name: "first_name", Type: "text", Label: "First name"})
+	if renamed < 0.99 {
+		t.Errorf("Expected renamed field to match closely, got %.2f", renamed)
+	}
+
+	unrelated := fieldSimilarity(field, DetectedField{Name: "email", Type: "email", Label: "Email Address"})
+	if unrelated >= DefaultHealerConfig().MinMatchScore {
+		t.Errorf("Expected unrelated field below the match threshold, got %.2f", unrelated)
+	}
+
+	unlabeled := fieldSimilarity(field, DetectedField{Name: "firstName", Type: "text"})
+	if unlabeled < 0.99 {
+		t.Errorf("Expected a missing label not to lower the score, got %.2f", unlabeled)
+	}
+}
+
+func TestMatchTemplateFields(t *testing.T) {
+	fields := []FormField{
+		{Name: "firstName", Type: "text", Label: "First Name"},
+		{Name: "lastName", Type: "text", Label: "Last Name"},
+		{Name: "email", Type: "email", Label: "Email"},
+		{Name: "fax", Type: "tel", Label: "Fax"},
+	}
+	detected := []DetectedField{
+		{Name: "user_email", Type: "email", Label: "Your email", Selector: "#user_email"},
+		{Name: "surname", Type: "text", Label: "Last name", Selector: "#surname"},
+		{Name: "given_name", Type: "text", Label: "First name", Selector: "#given_name"},
+	}
+
+	pairings := matchTemplateFields(fields, detected, DefaultHealerConfig().MinMatchScore)
+
+	expected := map[int]int{0: 2, 1: 1, 2: 0}
+	if len(pairings) != len(expected) {
+		t.Fatalf("Expected %d matches, got %d: %+v", len(expected), len(pairings), pairings)
+	}
+
+	for _, pairing := range pairings {
+		if want, ok := expected[pairing.field]; !ok || pairing.detected != want {
+			t.Errorf("Field %s matched detected field %d", fields[pairing.field].Name, pairing.detected)
+		}
+	}
+}
+
+func TestFormTemplateClone(t *testing.T) {
+	original := &FormTemplate{
+		ID:        "template_1",
+		Fields:    []FormField{{Name: "email", Selector: "#email", Candidates: []SelectorCandidate{{Selector: "#email"}}}},
+		Selectors: map[string]string{"email": "#email"},
+	}
+
+	clone := original.Clone()
+	clone.Fields[0].Selector = "#new"
+	clone.Fields[0].Candidates[0].Selector = "#new"
+	clone.Selectors["email"] = "#new"
+
+	if original.Fields[0].Selector != "#email" || original.Fields[0].Candidates[0].Selector != "#email" {
+		t.Error("Expected clone fields to be independent of the original")
+	}
+
+	if original.Selectors["email"] != "#email" {
+		t.Error("Expected clone selectors to be independent of the original")
+	}
+}
+
+func TestHealOnMatchedURL(t *testing.T) {
+	healer := NewTemplateHealer(nil, nil, nil, nil)
+	template := &FormTemplate{
+		ID:          "signup",
+		URL:         "https://example.com/signup",
+		URLPatterns: []string{"https://example.com/*"},
+		Version:     1,
+		Fields:      []FormField{{Name: "email", Type: "email", Label: "Email", Selector: "#email"}},
+	}
+	analysis := &FormAnalysisResult{Forms: []DetectedForm{{
+		Fields: []DetectedField{{Name: "email", Type: "email", Label: "Email", Selector: "#user_email"}},
+	}}}
+
+	pageURL := "https://example.com/join"
+	healed, result, err := healer.heal(template, pageURL, func() (*FormAnalysisResult, error) { return analysis, nil })
+	if err != nil {
+		t.Fatalf("Expected the template to heal, got %v", err)
+	}
+	if result.URL != pageURL {
+		t.Errorf("Expected the heal of %s, got %s", pageURL, result.URL)
+	}
+	if healed.URL != template.URL || healed.Fields[0].Selector != "#user_email" {
+		t.Errorf("Expected the template's URL to be kept and its selector healed, got %s, %s", healed.URL, healed.Fields[0].Selector)
+	}
+
+	_, _, err = healer.heal(template, pageURL, func() (*FormAnalysisResult, error) { return &FormAnalysisResult{}, nil })
+	var automationErr *AutomationError
+	if !errors.As(err, &automationErr) || automationErr.Kind != ErrFormNotFound || automationErr.URL != pageURL {
+		t.Errorf("Expected no form on %s, got %v", pageURL, err)
+	}
+}
@@ -0,0 +1,389 @@
+package automation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TemplateAuthor identifies what produced a template revision
+type TemplateAuthor string
+
+const (
+	TemplateAuthorDetector  TemplateAuthor = "detector"
+	TemplateAuthorHeal      TemplateAuthor = "heal"
+	TemplateAuthorManual    TemplateAuthor = "manual"
+	TemplateAuthorImport    TemplateAuthor = "import"
+	TemplateAuthorExtension TemplateAuthor = "extension" // Trained from fields captured by the browser extension
+)
+
+// TemplateRevision is a saved version of a template
+type TemplateRevision struct {
+	Version  int            `json:"version"`
+	Author   TemplateAuthor `json:"author"`
+	Reason   string         `json:"reason,omitempty"`
+	SavedAt  time.Time      `json:"savedAt"`
+	Template *FormTemplate  `json:"template"`
+}
+
+// TemplateDiff lists the changes between two versions of a template
+type TemplateDiff struct {
+	TemplateID  string           `json:"templateId"`
+	FromVersion int              `json:"fromVersion"`
+	ToVersion   int              `json:"toVersion"`
+	Changes     []TemplateChange `json:"changes"`
+}
+
+// TemplateChange is a single difference between two template versions
+type TemplateChange struct {
+	Field    string `json:"field,omitempty"` // Empty for template-level properties
+	Kind     string `json:"kind"`            // added, removed or changed
+	Property string `json:"property,omitempty"`
+	Old      string `json:"old,omitempty"`
+	New      string `json:"new,omitempty"`
+}
+
+// Template change kinds
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// SaveTemplateRevision saves a template and records it in the template's
+// history. A template whose fields or selectors differ from the latest
+// revision gets the next version number; other updates, such as success
+// rates, overwrite the current version without a new revision.
+func (tm *TemplateManager) SaveTemplateRevision(template *FormTemplate, author TemplateAuthor, reason string) error {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	return tm.saveRevision(template, author, reason, false)
+}
+
+// saveRevision saves a template, always starting a new version when force is set
+func (tm *TemplateManager) saveRevision(template *FormTemplate, author TemplateAuthor, reason string, force bool) error {
+	if template.ID == "" {
+		return fmt.Errorf("template ID cannot be empty")
+	}
+
+	history, err := tm.templateHistory(template.ID)
+	if err != nil {
+		return err
+	}
+
+	// Templates saved before history was kept start it with their current version
+	if len(history) == 0 {
+		if previous, err := tm.getTemplate(template.ID); err == nil && previous != template && previous.Version < template.Version {
+			if err := tm.writeRevision(previous, TemplateAuthorImport, "recorded from existing template"); err != nil {
+				return err
+			}
+			history = []*TemplateRevision{{Version: previous.Version, Template: previous}}
+		}
+	}
+
+	if template.Version < 1 {
+		template.Version = 1
+	}
+
+	newRevision := len(history) == 0
+	if len(history) > 0 {
+		latest := history[len(history)-1]
+		switch {
+		case template.Version > latest.Version:
+			newRevision = true
+		case force || len(DiffTemplates(latest.Template, template).Changes) > 0:
+			template.Version = latest.Version + 1
+			newRevision = true
+		}
+	}
+
+	if err := tm.writeTemplate(template); err != nil {
+		return err
+	}
+
+	if !newRevision {
+		return nil
+	}
+
+	return tm.writeRevision(template, author, reason)
+}
+
+// writeRevision stores a snapshot of the template under its version
+func (tm *TemplateManager) writeRevision(template *FormTemplate, author TemplateAuthor, reason string) error {
+	dir, err := tm.historyDir(template.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create template history directory: %w", err)
+	}
+
+	revision := &TemplateRevision{
+		Version:  template.Version,
+		Author:   author,
+		Reason:   reason,
+		SavedAt:  time.Now(),
+		Template: template,
+	}
+
+	data, err := json.MarshalIndent(revision, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal template revision: %w", err)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("v%d.json", template.Version))
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write template revision: %w", err)
+	}
+
+	return nil
+}
+
+// TemplateHistory returns the saved revisions of a template, oldest first
+func (tm *TemplateManager) TemplateHistory(templateID string) ([]*TemplateRevision, error) {
+	tm.mutex.RLock()
+	defer tm.mutex.RUnlock()
+
+	return tm.templateHistory(templateID)
+}
+
+// templateHistory reads a template's revisions from its history directory
+func (tm *TemplateManager) templateHistory(templateID string) ([]*TemplateRevision, error) {
+	dir, err := tm.historyDir(templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template history: %w", err)
+	}
+
+	var history []*TemplateRevision
+	for _, file := range files {
+		if _, ok := parseRevisionFilename(file.Name()); !ok {
+			continue
+		}
+
+		revision, err := tm.loadRevisionFromFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			continue // Skip invalid files
+		}
+		history = append(history, revision)
+	}
+
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Version < history[j].Version
+	})
+
+	return history, nil
+}
+
+// LoadTemplateRevision loads a saved revision of a template
+func (tm *TemplateManager) LoadTemplateRevision(templateID string, version int) (*TemplateRevision, error) {
+	dir, err := tm.historyDir(templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("v%d.json", version))
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("template %s has no version %d", templateID, version)
+	}
+
+	return tm.loadRevisionFromFile(path)
+}
+
+// RollbackTemplate restores the fields and selectors of an earlier version.
+// The restored template is saved as a new version so the history is kept.
+func (tm *TemplateManager) RollbackTemplate(templateID string, version int) (*FormTemplate, error) {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	current, err := tm.getTemplate(templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := tm.LoadTemplateRevision(templateID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	restored := revision.Template.Clone()
+	restored.Version = current.Version
+	restored.SuccessRate = current.SuccessRate
+	restored.Stats = current.Clone().Stats
+	restored.Stale = false
+
+	if err := tm.saveRevision(restored, TemplateAuthorManual, fmt.Sprintf("rollback to v%d", version), true); err != nil {
+		return nil, err
+	}
+
+	return restored, nil
+}
+
+// loadRevisionFromFile loads a template revision from a JSON file
+func (tm *TemplateManager) loadRevisionFromFile(path string) (*TemplateRevision, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template revision: %w", err)
+	}
+
+	var revision TemplateRevision
+	if err := json.Unmarshal(data, &revision); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal template revision: %w", err)
+	}
+
+	if revision.Template == nil {
+		return nil, fmt.Errorf("template revision %s has no template", path)
+	}
+
+	return &revision, nil
+}
+
+// historyDir returns the directory holding a template's revisions
+func (tm *TemplateManager) historyDir(templateID string) (string, error) {
+	if !validTemplateID(templateID) {
+		return "", fmt.Errorf("template ID %q cannot name a history directory", templateID)
+	}
+
+	return filepath.Join(tm.templatesDir, "history", templateID), nil
+}
+
+// templateIDPattern matches the characters allowed in template IDs
+var templateIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// validTemplateID reports whether a template ID is safe as a directory name
+func validTemplateID(id string) bool {
+	return templateIDPattern.MatchString(id) && id != "." && !strings.Contains(id, "..")
+}
+
+// parseRevisionFilename extracts the version from a "v<N>.json" filename
+func parseRevisionFilename(name string) (int, bool) {
+	if !strings.HasPrefix(name, "v") || !strings.HasSuffix(name, ".json") {
+		return 0, false
+	}
+
+	version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "v"), ".json"))
+	if err != nil {
+		return 0, false
+	}
+
+	return version, true
+}
+
+// ParseTemplateVersion parses a version written as "3" or "v3"
+func ParseTemplateVersion(s string) (int, error) {
+	version, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(s), "v"))
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid template version: %s", s)
+	}
+
+	return version, nil
+}
+
+// DiffTemplates compares the fields and selectors of two template versions.
+// Working selectors and statistics are ignored since they change on every run.
+func DiffTemplates(from, to *FormTemplate) *TemplateDiff {
+	diff := &TemplateDiff{
+		TemplateID:  to.ID,
+		FromVersion: from.Version,
+		ToVersion:   to.Version,
+		Changes:     []TemplateChange{},
+	}
+
+	diff.compare("", "url", from.URL, to.URL)
+	diff.compare("", "url_patterns", strings.Join(from.URLPatterns, ", "), strings.Join(to.URLPatterns, ", "))
+	diff.compare("", "form_type", from.FormType, to.FormType)
+	diff.compare("", "steps", jsonString(from.Steps), jsonString(to.Steps))
+	diff.compare("", "submit", jsonString(from.Submit), jsonString(to.Submit))
+	diff.compare("", "success", jsonString(from.Success), jsonString(to.Success))
+
+	fromFields := make(map[string]FormField, len(from.Fields))
+	for _, field := range from.Fields {
+		fromFields[field.Name] = field
+	}
+	toFields := make(map[string]bool, len(to.Fields))
+
+	for _, field := range to.Fields {
+		toFields[field.Name] = true
+
+		previous, exists := fromFields[field.Name]
+		if !exists {
+			diff.Changes = append(diff.Changes, TemplateChange{
+				Field: field.Name,
+				Kind:  ChangeAdded,
+				New:   fmt.Sprintf("%s %s", field.Type, field.Selector),
+			})
+			continue
+		}
+
+		diff.compare(field.Name, "type", previous.Type, field.Type)
+		diff.compare(field.Name, "selector", previous.Selector, field.Selector)
+		diff.compare(field.Name, "label", previous.Label, field.Label)
+		diff.compare(field.Name, "required", strconv.FormatBool(previous.Required), strconv.FormatBool(field.Required))
+		diff.compare(field.Name, "validation_pattern", previous.ValidationPattern, field.ValidationPattern)
+		diff.compare(field.Name, "candidates", candidateSelectors(previous.Candidates), candidateSelectors(field.Candidates))
+		diff.compare(field.Name, "fallbacks", strings.Join(previous.Fallbacks, ", "), strings.Join(field.Fallbacks, ", "))
+		diff.compare(field.Name, "binding", previous.Binding, field.Binding)
+		diff.compare(field.Name, "value", previous.Value, field.Value)
+		diff.compare(field.Name, "transforms", strings.Join(previous.Transforms, ", "), strings.Join(field.Transforms, ", "))
+	}
+
+	for _, field := range from.Fields {
+		if !toFields[field.Name] {
+			diff.Changes = append(diff.Changes, TemplateChange{
+				Field: field.Name,
+				Kind:  ChangeRemoved,
+				Old:   fmt.Sprintf("%s %s", field.Type, field.Selector),
+			})
+		}
+	}
+
+	return diff
+}
+
+// compare records a change when a property differs between versions
+func (d *TemplateDiff) compare(field, property, old, new string) {
+	if old == new {
+		return
+	}
+
+	d.Changes = append(d.Changes, TemplateChange{
+		Field:    field,
+		Kind:     ChangeChanged,
+		Property: property,
+		Old:      old,
+		New:      new,
+	})
+}
+
+// candidateSelectors joins candidate selectors for comparison and display
+func candidateSelectors(candidates []SelectorCandidate) string {
+	selectors := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		selectors[i] = candidate.Selector
+	}
+	return strings.Join(selectors, ", ")
+}
+
+// jsonString renders a value as compact JSON for comparison and display
+func jsonString(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil || string(data) == "null" {
+		return ""
+	}
+	return string(data)
+}
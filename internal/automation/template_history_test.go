@@ -0,0 +1,166 @@
+package automation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newHistoryTestTemplate() *FormTemplate {
+	return &FormTemplate{
+		ID:       "template_example",
+		URL:      "https://example.com/signup",
+		Domain:   "example.com",
+		FormType: string(FormTypeRegistration),
+		Fields: []FormField{
+			{Name: "email", Type: "email", Selector: "#email"},
+			{Name: "firstName", Type: "text", Selector: "#first"},
+		},
+		Selectors: map[string]string{"email": "#email", "firstName": "#first"},
+		Version:   1,
+	}
+}
+
+func TestTemplateHistoryRevisions(t *testing.T) {
+	tm, err := NewTemplateManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create template manager: %v", err)
+	}
+
+	template := newHistoryTestTemplate()
+	if err := tm.SaveTemplateRevision(template, TemplateAuthorDetector, "detected"); err != nil {
+		t.Fatalf("Failed to save template: %v", err)
+	}
+
+	// Statistics updates don't create revisions
+	if err := tm.UpdateTemplateSuccess(template.ID, 100); err != nil {
+		t.Fatalf("Failed to update success: %v", err)
+	}
+
+	changed := template.Clone()
+	changed.Fields[0].Selector = "#user_email"
+	if err := tm.SaveTemplate(changed); err != nil {
+		t.Fatalf("Failed to save changed template: %v", err)
+	}
+
+	if changed.Version != 2 {
+		t.Errorf("Expected changed template to be version 2, got %d", changed.Version)
+	}
+
+	history, err := tm.TemplateHistory(template.ID)
+	if err != nil {
+		t.Fatalf("Failed to load history: %v", err)
+	}
+
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 revisions, got %d", len(history))
+	}
+
+	if history[0].Author != TemplateAuthorDetector || history[0].Reason != "detected" {
+		t.Errorf("Expected first revision by detector, got %s (%s)", history[0].Author, history[0].Reason)
+	}
+
+	if history[1].Author != TemplateAuthorManual || history[1].Template.Fields[0].Selector != "#user_email" {
+		t.Errorf("Expected second revision to hold the manual change, got %+v", history[1])
+	}
+}
+
+func TestTemplateHistoryRefusesUnsafeID(t *testing.T) {
+	dir := t.TempDir()
+	tm, err := NewTemplateManager(filepath.Join(dir, "templates"))
+	if err != nil {
+		t.Fatalf("Failed to create template manager: %v", err)
+	}
+
+	template := newHistoryTestTemplate()
+	template.ID = "../../escaped"
+	if err := tm.SaveTemplateRevision(template, TemplateAuthorImport, "imported"); err == nil {
+		t.Error("Expected a template ID outside the history directory to be refused")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escaped")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be written outside the templates directory, got %v", err)
+	}
+	if _, err := tm.TemplateHistory(template.ID); err == nil {
+		t.Error("Expected the history of an unsafe template ID to be refused")
+	}
+}
+
+func TestTemplateRollback(t *testing.T) {
+	tm, err := NewTemplateManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create template manager: %v", err)
+	}
+
+	template := newHistoryTestTemplate()
+	if err := tm.SaveTemplate(template); err != nil {
+		t.Fatalf("Failed to save template: %v", err)
+	}
+
+	changed := template.Clone()
+	changed.Fields = changed.Fields[:1]
+	if err := tm.SaveTemplate(changed); err != nil {
+		t.Fatalf("Failed to save changed template: %v", err)
+	}
+
+	restored, err := tm.RollbackTemplate(template.ID, 1)
+	if err != nil {
+		t.Fatalf("Failed to roll back: %v", err)
+	}
+
+	if restored.Version != 3 {
+		t.Errorf("Expected rollback to create version 3, got %d", restored.Version)
+	}
+
+	if len(restored.Fields) != 2 {
+		t.Errorf("Expected rollback to restore 2 fields, got %d", len(restored.Fields))
+	}
+
+	current, err := tm.LoadTemplate(template.ID)
+	if err != nil || current.Version != 3 {
+		t.Errorf("Expected current template to be version 3, got %+v (%v)", current, err)
+	}
+
+	history, _ := tm.TemplateHistory(template.ID)
+	if len(history) != 3 || history[2].Reason != "rollback to v1" {
+		t.Errorf("Expected rollback to be recorded in history, got %d revisions", len(history))
+	}
+}
+
+func TestDiffTemplates(t *testing.T) {
+	from := newHistoryTestTemplate()
+	to := from.Clone()
+	to.Version = 2
+	to.Fields[0].Selector = "#user_email"
+	to.Fields[0].WorkingSelector = "#user_email"
+	to.Fields = append(to.Fields[:1], FormField{Name: "phone", Type: "tel", Selector: "#phone"})
+
+	diff := DiffTemplates(from, to)
+
+	expected := []TemplateChange{
+		{Field: "email", Kind: ChangeChanged, Property: "selector", Old: "#email", New: "#user_email"},
+		{Field: "phone", Kind: ChangeAdded, New: "tel #phone"},
+		{Field: "firstName", Kind: ChangeRemoved, Old: "text #first"},
+	}
+
+	if len(diff.Changes) != len(expected) {
+		t.Fatalf("Expected %d changes, got %d: %+v", len(expected), len(diff.Changes), diff.Changes)
+	}
+
+	for i, change := range expected {
+		if diff.Changes[i] != change {
+			t.Errorf("Change %d: expected %+v, got %+v", i, change, diff.Changes[i])
+		}
+	}
+}
+
+func TestParseTemplateVersion(t *testing.T) {
+	for _, input := range []string{"3", "v3", "V3"} {
+		if version, err := ParseTemplateVersion(input); err != nil || version != 3 {
+			t.Errorf("ParseTemplateVersion(%q): expected 3, got %d (%v)", input, version, err)
+		}
+	}
+
+	if _, err := ParseTemplateVersion("latest"); err == nil {
+		t.Error("Expected error for invalid version")
+	}
+}
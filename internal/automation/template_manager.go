@@ -2,20 +2,27 @@ package automation
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/ai-form-filler/cli/internal/storage"
 )
 
-// TemplateManager manages form templates storage and retrieval
+// TemplateManager manages form templates storage and retrieval. It is safe
+// for concurrent use; templates it returns are copies, so changes only take
+// effect when saved.
 type TemplateManager struct {
 	templatesDir string
-	templates    map[string]*FormTemplate
+	templates    map[string]*FormTemplate   // File-backed templates by ID
+	store        *storage.FormTemplateStore // Set when templates live in the database
+	mutex        sync.RWMutex
 }
 
 // TemplateSearchCriteria defines criteria for searching templates
@@ -56,8 +63,85 @@ func NewTemplateManager(templatesDir string) (*TemplateManager, error) {
 	return tm, nil
 }
 
-// SaveTemplate saves a form template to disk
+// NewStoredTemplateManager creates a template manager backed by the
+// form_templates table. Template history is still kept under templatesDir,
+// and JSON templates found there are imported into the database once.
+func NewStoredTemplateManager(templatesDir string, db *storage.DatabaseManager) (*TemplateManager, error) {
+	if err := os.MkdirAll(templatesDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create templates directory: %w", err)
+	}
+
+	tm := &TemplateManager{
+		templatesDir: templatesDir,
+		templates:    make(map[string]*FormTemplate),
+		store:        storage.NewFormTemplateStore(db),
+	}
+
+	if err := tm.importTemplateFiles(); err != nil {
+		return nil, fmt.Errorf("failed to import templates: %w", err)
+	}
+
+	return tm, nil
+}
+
+// importTemplateFiles copies the JSON templates in the templates directory
+// into the database the first time it is opened with that directory
+func (tm *TemplateManager) importTemplateFiles() error {
+	dir, err := filepath.Abs(tm.templatesDir)
+	if err != nil {
+		dir = tm.templatesDir
+	}
+	name := "templates_dir:" + dir
+
+	done, err := tm.store.IsImportDone(name)
+	if err != nil || done {
+		return err
+	}
+
+	files, err := ioutil.ReadDir(tm.templatesDir)
+	if err != nil {
+		return fmt.Errorf("failed to read templates directory: %w", err)
+	}
+
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	count := 0
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+
+		template, err := tm.loadTemplateFromFile(filepath.Join(tm.templatesDir, file.Name()))
+		if err != nil || template.ID == "" {
+			continue // Skip invalid files
+		}
+
+		// Keep the timestamps and statistics the file recorded
+		lastUpdated := template.LastUpdated
+		if err := tm.saveRevision(template, TemplateAuthorImport, "imported from "+file.Name(), false); err != nil {
+			return fmt.Errorf("failed to import %s: %w", file.Name(), err)
+		}
+		if !lastUpdated.IsZero() {
+			template.LastUpdated = lastUpdated
+			if err := tm.putTemplate(template); err != nil {
+				return err
+			}
+		}
+		count++
+	}
+
+	return tm.store.MarkImportDone(name, count)
+}
+
+// SaveTemplate saves a form template. Changes to its fields or selectors
+// are recorded as a new manual revision.
 func (tm *TemplateManager) SaveTemplate(template *FormTemplate) error {
+	return tm.SaveTemplateRevision(template, TemplateAuthorManual, "")
+}
+
+// writeTemplate stores the current version of a template
+func (tm *TemplateManager) writeTemplate(template *FormTemplate) error {
 	if template.ID == "" {
 		return fmt.Errorf("template ID cannot be empty")
 	}
@@ -65,8 +149,29 @@ func (tm *TemplateManager) SaveTemplate(template *FormTemplate) error {
 	// Update timestamp
 	template.LastUpdated = time.Now()
 
+	return tm.putTemplate(template)
+}
+
+// putTemplate stores a template as is, in the database or on disk
+func (tm *TemplateManager) putTemplate(template *FormTemplate) error {
+	if tm.store != nil {
+		record, err := newTemplateRecord(template)
+		if err != nil {
+			return err
+		}
+		return tm.store.SaveFormTemplate(record)
+	}
+
 	// Create filename based on domain and template ID
 	filename := tm.getTemplateFilename(template)
+
+	// Remove the previous file if the domain or form type changed
+	if previous, exists := tm.templates[template.ID]; exists {
+		if previousFilename := tm.getTemplateFilename(previous); previousFilename != filename {
+			os.Remove(filepath.Join(tm.templatesDir, previousFilename))
+		}
+	}
+
 	filepath := filepath.Join(tm.templatesDir, filename)
 
 	// Marshal template to JSON
@@ -81,13 +186,37 @@ func (tm *TemplateManager) SaveTemplate(template *FormTemplate) error {
 	}
 
 	// Update in-memory cache
-	tm.templates[template.ID] = template
+	tm.templates[template.ID] = template.Clone()
 
 	return nil
 }
 
 // LoadTemplate loads a specific template by ID
 func (tm *TemplateManager) LoadTemplate(templateID string) (*FormTemplate, error) {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	template, err := tm.getTemplate(templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	return template.Clone(), nil
+}
+
+// getTemplate returns the stored template without copying it
+func (tm *TemplateManager) getTemplate(templateID string) (*FormTemplate, error) {
+	if tm.store != nil {
+		record, err := tm.store.GetFormTemplate(templateID)
+		if errors.Is(err, storage.ErrFormTemplateNotFound) {
+			return nil, fmt.Errorf("template not found: %s", templateID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return templateFromRecord(record)
+	}
+
 	// Check in-memory cache first
 	if template, exists := tm.templates[templateID]; exists {
 		return template, nil
@@ -116,8 +245,16 @@ func (tm *TemplateManager) LoadTemplate(templateID string) (*FormTemplate, error
 	return nil, fmt.Errorf("template not found: %s", templateID)
 }
 
-// LoadTemplates loads all templates from disk
+// LoadTemplates loads all templates from disk. Database-backed managers
+// read templates as they are needed instead.
 func (tm *TemplateManager) LoadTemplates() error {
+	if tm.store != nil {
+		return nil
+	}
+
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
 	files, err := ioutil.ReadDir(tm.templatesDir)
 	if err != nil {
 		return fmt.Errorf("failed to read templates directory: %w", err)
@@ -152,13 +289,37 @@ func (tm *TemplateManager) loadTemplateFromFile(filepath string) (*FormTemplate,
 	return &template, nil
 }
 
+// allTemplates returns every stored template without copying them
+func (tm *TemplateManager) allTemplates() ([]*FormTemplate, error) {
+	if tm.store != nil {
+		records, err := tm.store.ListFormTemplates()
+		if err != nil {
+			return nil, err
+		}
+		return templatesFromRecords(records)
+	}
+
+	templates := make([]*FormTemplate, 0, len(tm.templates))
+	for _, template := range tm.templates {
+		templates = append(templates, template)
+	}
+	return templates, nil
+}
+
 // FindTemplates finds templates matching the given criteria
 func (tm *TemplateManager) FindTemplates(criteria TemplateSearchCriteria) ([]*FormTemplate, error) {
-	var matches []*FormTemplate
+	tm.mutex.RLock()
+	defer tm.mutex.RUnlock()
 
-	for _, template := range tm.templates {
+	templates, err := tm.allTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*FormTemplate
+	for _, template := range templates {
 		if tm.matchesCriteria(template, criteria) {
-			matches = append(matches, template)
+			matches = append(matches, template.Clone())
 		}
 	}
 
@@ -170,32 +331,50 @@ func (tm *TemplateManager) FindTemplates(criteria TemplateSearchCriteria) ([]*Fo
 	return matches, nil
 }
 
-// FindBestTemplate finds the best template for a given URL
+// FindBestTemplate finds the best template for a given URL. See
+// MatchTemplates for how candidates are ranked.
 func (tm *TemplateManager) FindBestTemplate(targetURL string) (*FormTemplate, error) {
-	parsedURL, err := url.Parse(targetURL)
+	matches, err := tm.MatchTemplates(targetURL)
 	if err != nil {
-		return nil, fmt.Errorf("invalid URL: %w", err)
+		return nil, err
 	}
 
-	// First, try exact URL match
-	criteria := TemplateSearchCriteria{
-		URL: targetURL,
+	for _, match := range matches {
+		if match.Chosen {
+			return match.Template, nil
+		}
 	}
-	matches, err := tm.FindTemplates(criteria)
-	if err == nil && len(matches) > 0 {
-		return matches[0], nil
+
+	return nil, fmt.Errorf("no suitable template found for URL: %s", targetURL)
+}
+
+// MarkTemplateStale sets or clears a template's stale flag
+func (tm *TemplateManager) MarkTemplateStale(templateID string, stale bool) error {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	if tm.store != nil {
+		if err := tm.store.SetFormTemplateStale(templateID, stale); err != nil {
+			if errors.Is(err, storage.ErrFormTemplateNotFound) {
+				return fmt.Errorf("template not found: %s", templateID)
+			}
+			return err
+		}
+		return nil
 	}
 
-	// Then, try domain match
-	criteria = TemplateSearchCriteria{
-		Domain: parsedURL.Hostname(),
+	template, err := tm.getTemplate(templateID)
+	if err != nil {
+		return err
 	}
-	matches, err = tm.FindTemplates(criteria)
-	if err == nil && len(matches) > 0 {
-		return matches[0], nil
+
+	if template.Stale == stale {
+		return nil
 	}
 
-	return nil, fmt.Errorf("no suitable template found for URL: %s", targetURL)
+	template = template.Clone()
+	template.Stale = stale
+	return tm.saveRevision(template, TemplateAuthorManual, "", false)
 }
 
 // matchesCriteria checks if a template matches the search criteria
@@ -231,27 +410,89 @@ func (tm *TemplateManager) matchesCriteria(template *FormTemplate, criteria Temp
 	return true
 }
 
-// UpdateTemplateSuccess updates the success rate of a template
+// UpdateTemplateSuccess records a run that filled the given percentage of fields
 func (tm *TemplateManager) UpdateTemplateSuccess(templateID string, successRate float64) error {
-	template, exists := tm.templates[templateID]
-	if !exists {
-		return fmt.Errorf("template not found: %s", templateID)
+	return tm.RecordTemplateOutcome(templateID, TemplateOutcome{FillRatio: successRate / 100})
+}
+
+// RecordTemplateOutcome adds the outcome of a run to a template's statistics
+// and updates its success rate to the new score
+func (tm *TemplateManager) RecordTemplateOutcome(templateID string, outcome TemplateOutcome) error {
+	if outcome.At.IsZero() {
+		outcome.At = time.Now()
+	}
+
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	if tm.store != nil {
+		// Read and written in one transaction, so runs from other processes aren't lost either
+		err := tm.store.UpdateFormTemplateStats(templateID, func(record *storage.FormTemplateStats) float64 {
+			stats := templateStatsFromRecord(record)
+			stats.Record(outcome)
+			*record = *newTemplateStatsRecord(stats)
+			return stats.Score(outcome.At) * 100
+		})
+		if errors.Is(err, storage.ErrFormTemplateNotFound) {
+			return fmt.Errorf("template not found: %s", templateID)
+		}
+		return err
 	}
 
-	// Update success rate using weighted average
-	// Give more weight to recent results
-	currentWeight := 0.7
-	newWeight := 0.3
-	
-	template.SuccessRate = template.SuccessRate*currentWeight + successRate*newWeight
-	template.LastUpdated = time.Now()
+	template, err := tm.getTemplate(templateID)
+	if err != nil {
+		return err
+	}
+
+	template = template.Clone()
+	if template.Stats == nil {
+		template.Stats = &TemplateStats{}
+	}
+	template.Stats.Record(outcome)
+	template.SuccessRate = template.Stats.Score(outcome.At) * 100
+
+	return tm.saveRevision(template, TemplateAuthorManual, "", false)
+}
+
+// RecordWorkingSelectors stores which selector candidate filled each field,
+// so the next fill tries it first. The template is only saved if it changed.
+func (tm *TemplateManager) RecordWorkingSelectors(templateID string, selectors map[string]string) error {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	template, err := tm.getTemplate(templateID)
+	if err != nil {
+		return err
+	}
+
+	template = template.Clone()
+	if !template.RecordWorkingSelectors(selectors) {
+		return nil
+	}
 
-	// Save updated template
-	return tm.SaveTemplate(template)
+	return tm.saveRevision(template, TemplateAuthorManual, "", false)
 }
 
 // DeleteTemplate deletes a template
 func (tm *TemplateManager) DeleteTemplate(templateID string) error {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	return tm.deleteTemplate(templateID)
+}
+
+// deleteTemplate removes a template from the database or disk
+func (tm *TemplateManager) deleteTemplate(templateID string) error {
+	if tm.store != nil {
+		if err := tm.store.DeleteFormTemplate(templateID); err != nil {
+			if errors.Is(err, storage.ErrFormTemplateNotFound) {
+				return fmt.Errorf("template not found: %s", templateID)
+			}
+			return err
+		}
+		return nil
+	}
+
 	template, exists := tm.templates[templateID]
 	if !exists {
 		return fmt.Errorf("template not found: %s", templateID)
@@ -273,15 +514,20 @@ func (tm *TemplateManager) DeleteTemplate(templateID string) error {
 
 // GetTemplateMetrics returns metrics about stored templates
 func (tm *TemplateManager) GetTemplateMetrics() *TemplateMetrics {
+	tm.mutex.RLock()
+	defer tm.mutex.RUnlock()
+
+	templates, _ := tm.allTemplates()
+
 	metrics := &TemplateMetrics{
-		TotalTemplates:    len(tm.templates),
+		TotalTemplates:    len(templates),
 		TemplatesByDomain: make(map[string]int),
 		TemplatesByType:   make(map[FormType]int),
 		LastUpdated:       time.Now(),
 	}
 
 	totalSuccess := 0.0
-	for _, template := range tm.templates {
+	for _, template := range templates {
 		// Count by domain
 		metrics.TemplatesByDomain[template.Domain]++
 
@@ -293,8 +539,8 @@ func (tm *TemplateManager) GetTemplateMetrics() *TemplateMetrics {
 	}
 
 	// Calculate average success rate
-	if len(tm.templates) > 0 {
-		metrics.AverageSuccess = totalSuccess / float64(len(tm.templates))
+	if len(templates) > 0 {
+		metrics.AverageSuccess = totalSuccess / float64(len(templates))
 	}
 
 	return metrics
@@ -302,10 +548,12 @@ func (tm *TemplateManager) GetTemplateMetrics() *TemplateMetrics {
 
 // ListTemplates returns all templates with optional filtering
 func (tm *TemplateManager) ListTemplates(limit int, offset int) ([]*FormTemplate, error) {
-	templates := make([]*FormTemplate, 0, len(tm.templates))
-	
-	for _, template := range tm.templates {
-		templates = append(templates, template)
+	tm.mutex.RLock()
+	defer tm.mutex.RUnlock()
+
+	templates, err := tm.allTemplates()
+	if err != nil {
+		return nil, err
 	}
 
 	// Sort by last updated (newest first)
@@ -324,14 +572,21 @@ func (tm *TemplateManager) ListTemplates(limit int, offset int) ([]*FormTemplate
 		end = len(templates)
 	}
 
-	return templates[start:end], nil
+	page := make([]*FormTemplate, 0, end-start)
+	for _, template := range templates[start:end] {
+		page = append(page, template.Clone())
+	}
+
+	return page, nil
 }
 
 // ExportTemplates exports templates to a JSON file
 func (tm *TemplateManager) ExportTemplates(exportPath string) error {
-	templates := make([]*FormTemplate, 0, len(tm.templates))
-	for _, template := range tm.templates {
-		templates = append(templates, template)
+	tm.mutex.RLock()
+	templates, err := tm.allTemplates()
+	tm.mutex.RUnlock()
+	if err != nil {
+		return err
 	}
 
 	data, err := json.MarshalIndent(templates, "", "  ")
@@ -358,9 +613,12 @@ func (tm *TemplateManager) ImportTemplates(importPath string) error {
 		return fmt.Errorf("failed to unmarshal templates: %w", err)
 	}
 
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
 	// Save each template
 	for _, template := range templates {
-		if err := tm.SaveTemplate(template); err != nil {
+		if err := tm.saveRevision(template, TemplateAuthorImport, "imported from "+importPath, false); err != nil {
 			return fmt.Errorf("failed to save imported template %s: %w", template.ID, err)
 		}
 	}
@@ -370,17 +628,25 @@ func (tm *TemplateManager) ImportTemplates(importPath string) error {
 
 // CleanupOldTemplates removes templates older than the specified duration
 func (tm *TemplateManager) CleanupOldTemplates(maxAge time.Duration) error {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	templates, err := tm.allTemplates()
+	if err != nil {
+		return err
+	}
+
 	cutoff := time.Now().Add(-maxAge)
 	var toDelete []string
 
-	for id, template := range tm.templates {
+	for _, template := range templates {
 		if template.LastUpdated.Before(cutoff) {
-			toDelete = append(toDelete, id)
+			toDelete = append(toDelete, template.ID)
 		}
 	}
 
 	for _, id := range toDelete {
-		if err := tm.DeleteTemplate(id); err != nil {
+		if err := tm.deleteTemplate(id); err != nil {
 			return fmt.Errorf("failed to delete old template %s: %w", id, err)
 		}
 	}
@@ -427,14 +693,37 @@ func (tm *TemplateManager) ValidateTemplate(template *FormTemplate) error {
 		}
 	}
 
+	for _, pattern := range template.URLPatterns {
+		if _, err := ParseURLPattern(pattern); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 // GetTemplateByURL finds a template by exact URL match
 func (tm *TemplateManager) GetTemplateByURL(url string) (*FormTemplate, error) {
-	for _, template := range tm.templates {
+	tm.mutex.RLock()
+	defer tm.mutex.RUnlock()
+
+	var templates []*FormTemplate
+	var err error
+	if tm.store != nil {
+		var records []*storage.FormTemplateRecord
+		if records, err = tm.store.FindFormTemplateCandidates(url, "", nil); err == nil {
+			templates, err = templatesFromRecords(records)
+		}
+	} else {
+		templates, err = tm.allTemplates()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, template := range templates {
 		if template.URL == url {
-			return template, nil
+			return template.Clone(), nil
 		}
 	}
 
@@ -443,11 +732,22 @@ func (tm *TemplateManager) GetTemplateByURL(url string) (*FormTemplate, error) {
 
 // GetTemplatesByDomain finds all templates for a specific domain
 func (tm *TemplateManager) GetTemplatesByDomain(domain string) ([]*FormTemplate, error) {
+	tm.mutex.RLock()
+	defer tm.mutex.RUnlock()
+
+	if tm.store != nil {
+		records, err := tm.store.ListFormTemplatesByDomain(domain)
+		if err != nil {
+			return nil, err
+		}
+		return templatesFromRecords(records)
+	}
+
 	var templates []*FormTemplate
 
 	for _, template := range tm.templates {
 		if template.Domain == domain {
-			templates = append(templates, template)
+			templates = append(templates, template.Clone())
 		}
 	}
 
@@ -457,4 +757,140 @@ func (tm *TemplateManager) GetTemplatesByDomain(domain string) ([]*FormTemplate,
 	})
 
 	return templates, nil
-}
\ No newline at end of file
+}
+// newTemplateRecord converts a template to a form_templates row
+func newTemplateRecord(template *FormTemplate) (*storage.FormTemplateRecord, error) {
+	data, err := json.Marshal(template)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal template: %w", err)
+	}
+	fields, err := json.Marshal(template.Fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal template fields: %w", err)
+	}
+	selectors, err := json.Marshal(template.Selectors)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal template selectors: %w", err)
+	}
+	validationRules, err := json.Marshal(template.ValidationRules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal template validation rules: %w", err)
+	}
+
+	record := &storage.FormTemplateRecord{
+		ID:              template.ID,
+		URL:             template.URL,
+		Domain:          template.Domain,
+		FormType:        template.FormType,
+		Fields:          string(fields),
+		Selectors:       string(selectors),
+		ValidationRules: string(validationRules),
+		Data:            string(data),
+		SuccessRate:     template.SuccessRate,
+		Stale:           template.Stale,
+		Version:         template.Version,
+		LastUpdated:     template.LastUpdated,
+	}
+	if template.Stats != nil && template.Stats.Attempts > 0 {
+		record.Stats = newTemplateStatsRecord(template.Stats)
+	}
+
+	for _, raw := range template.URLPatterns {
+		pattern, err := ParseURLPattern(raw)
+		if err != nil {
+			return nil, err
+		}
+		record.Patterns = append(record.Patterns, storage.TemplatePattern{Host: pattern.indexHost(), Pattern: raw})
+	}
+
+	return record, nil
+}
+
+// templateFromRecord converts a form_templates row to a template. Rows
+// written before the whole template was stored are rebuilt from their columns.
+func templateFromRecord(record *storage.FormTemplateRecord) (*FormTemplate, error) {
+	template := &FormTemplate{}
+	if record.Data != "" {
+		if err := json.Unmarshal([]byte(record.Data), template); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal template %s: %w", record.ID, err)
+		}
+	} else {
+		template.URL = record.URL
+		template.Domain = record.Domain
+		template.FormType = record.FormType
+		if err := json.Unmarshal([]byte(record.Fields), &template.Fields); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal fields of template %s: %w", record.ID, err)
+		}
+		if err := json.Unmarshal([]byte(record.Selectors), &template.Selectors); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal selectors of template %s: %w", record.ID, err)
+		}
+		if record.ValidationRules != "" {
+			if err := json.Unmarshal([]byte(record.ValidationRules), &template.ValidationRules); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal validation rules of template %s: %w", record.ID, err)
+			}
+		}
+	}
+
+	// These columns are updated in place and take precedence over the JSON
+	template.ID = record.ID
+	template.SuccessRate = record.SuccessRate
+	template.Stale = record.Stale
+	template.Version = record.Version
+	template.LastUpdated = record.LastUpdated
+	if record.Stats != nil {
+		template.Stats = templateStatsFromRecord(record.Stats)
+	}
+
+	return template, nil
+}
+
+// newTemplateStatsRecord converts template statistics to their columns
+func newTemplateStatsRecord(stats *TemplateStats) *storage.FormTemplateStats {
+	record := &storage.FormTemplateStats{
+		Attempts:            stats.Attempts,
+		Fills:               stats.Fills,
+		Submissions:         stats.Submissions,
+		VerifiedSubmissions: stats.VerifiedSubmissions,
+		ScoreSum:            stats.ScoreSum,
+		ScoreWeight:         stats.ScoreWeight,
+		LastOutcomeAt:       stats.LastOutcome,
+		FieldFailures:       make(map[string]int, len(stats.FieldFailures)),
+	}
+	for field, failures := range stats.FieldFailures {
+		record.FieldFailures[field] = failures
+	}
+	return record
+}
+
+// templateStatsFromRecord converts stored statistics to template statistics
+func templateStatsFromRecord(record *storage.FormTemplateStats) *TemplateStats {
+	stats := &TemplateStats{
+		Attempts:            record.Attempts,
+		Fills:               record.Fills,
+		Submissions:         record.Submissions,
+		VerifiedSubmissions: record.VerifiedSubmissions,
+		ScoreSum:            record.ScoreSum,
+		ScoreWeight:         record.ScoreWeight,
+		LastOutcome:         record.LastOutcomeAt,
+	}
+	if len(record.FieldFailures) > 0 {
+		stats.FieldFailures = make(map[string]int, len(record.FieldFailures))
+		for field, failures := range record.FieldFailures {
+			stats.FieldFailures[field] = failures
+		}
+	}
+	return stats
+}
+
+// templatesFromRecords converts form_templates rows, skipping unreadable ones
+func templatesFromRecords(records []*storage.FormTemplateRecord) ([]*FormTemplate, error) {
+	templates := make([]*FormTemplate, 0, len(records))
+	for _, record := range records {
+		template, err := templateFromRecord(record)
+		if err != nil {
+			continue // Skip invalid rows
+		}
+		templates = append(templates, template)
+	}
+	return templates, nil
+}
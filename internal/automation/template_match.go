@@ -0,0 +1,492 @@
+package automation
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Template match kinds, from strongest to weakest
+const (
+	MatchExactURL = "exact_url" // The template was learned on this exact URL
+	MatchPattern  = "pattern"   // One of the template's URL patterns matched
+	MatchDomain   = "domain"    // A template without patterns on the same host
+)
+
+// URLPattern is a compiled template URL pattern.
+//
+// Glob patterns have the form [*.]host[/path][?key=value&key], where "*"
+// matches within a path segment and "**" across segments. A pattern without
+// a path matches any path; query parameters must be present but others may
+// be added. Patterns starting with "re:" are regular expressions matched
+// against the whole host, path and query, e.g. re:example\.com/orders/\d+.
+type URLPattern struct {
+	raw         string
+	host        string
+	wildcard    bool
+	path        *regexp.Regexp
+	query       map[string]*regexp.Regexp
+	regex       *regexp.Regexp
+	specificity PatternSpecificity
+}
+
+// PatternSpecificity ranks how narrowly a pattern describes a URL
+type PatternSpecificity struct {
+	Literal     int  `json:"literal"`     // Characters of the path and query matched literally
+	Wildcards   int  `json:"wildcards"`   // Wildcards in the path and query, fewer is more specific
+	QueryParams int  `json:"queryParams"` // Query parameters the pattern requires
+	HostExact   bool `json:"hostExact"`   // The host is named exactly rather than by subdomain wildcard
+	HostLabels  int  `json:"hostLabels"`  // Labels in the host, e.g. *.shop.example.com over *.example.com
+}
+
+// Compare returns 1 if s is more specific than other, -1 if less and 0 if equal
+func (s PatternSpecificity) Compare(other PatternSpecificity) int {
+	switch {
+	case s.Literal != other.Literal:
+		return compareInts(s.Literal, other.Literal)
+	case s.Wildcards != other.Wildcards:
+		return compareInts(other.Wildcards, s.Wildcards)
+	case s.QueryParams != other.QueryParams:
+		return compareInts(s.QueryParams, other.QueryParams)
+	case s.HostExact != other.HostExact:
+		if s.HostExact {
+			return 1
+		}
+		return -1
+	default:
+		return compareInts(s.HostLabels, other.HostLabels)
+	}
+}
+
+func (s PatternSpecificity) String() string {
+	host := "wildcard host"
+	if s.HostExact {
+		host = "exact host"
+	}
+	return fmt.Sprintf("%d literal, %d wildcards, %d query params, %s", s.Literal, s.Wildcards, s.QueryParams, host)
+}
+
+// compareInts returns the sign of a - b
+func compareInts(a, b int) int {
+	switch {
+	case a > b:
+		return 1
+	case a < b:
+		return -1
+	default:
+		return 0
+	}
+}
+
+// ParseURLPattern compiles a template URL pattern
+func ParseURLPattern(pattern string) (*URLPattern, error) {
+	raw := strings.TrimSpace(pattern)
+	if raw == "" {
+		return nil, fmt.Errorf("URL pattern is empty")
+	}
+
+	if strings.HasPrefix(raw, "re:") {
+		expression := strings.TrimPrefix(raw, "re:")
+		regex, err := regexp.Compile("^(?:" + expression + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %w", err)
+		}
+
+		return &URLPattern{
+			raw:         raw,
+			regex:       regex,
+			specificity: PatternSpecificity{Literal: regexLiteralLength(expression), Wildcards: 1},
+		}, nil
+	}
+
+	rest := raw
+	if index := strings.Index(rest, "://"); index >= 0 {
+		rest = rest[index+3:]
+	}
+
+	var query string
+	if index := strings.Index(rest, "?"); index >= 0 {
+		rest, query = rest[:index], rest[index+1:]
+	}
+
+	host, path := rest, ""
+	if index := strings.Index(rest, "/"); index >= 0 {
+		host, path = rest[:index], rest[index:]
+	}
+
+	p := &URLPattern{raw: raw, host: strings.ToLower(host)}
+	if strings.HasPrefix(p.host, "*.") {
+		p.wildcard = true
+		p.host = strings.TrimPrefix(p.host, "*.")
+	}
+	if p.host == "" {
+		return nil, fmt.Errorf("URL pattern %q has no host", raw)
+	}
+	if strings.Contains(p.host, "*") {
+		return nil, fmt.Errorf("URL pattern %q: only a leading \"*.\" wildcard is supported in the host", raw)
+	}
+
+	p.specificity.HostExact = !p.wildcard
+	p.specificity.HostLabels = strings.Count(p.host, ".") + 1
+
+	if path != "" {
+		path = normalizePatternPath(path)
+		p.path = globRegexp(path)
+		p.addGlobSpecificity(path)
+	}
+
+	if query != "" {
+		p.query = make(map[string]*regexp.Regexp)
+		for _, pair := range strings.Split(query, "&") {
+			key, value, hasValue := strings.Cut(pair, "=")
+			if key == "" {
+				return nil, fmt.Errorf("URL pattern %q has a query parameter without a name", raw)
+			}
+
+			p.query[key] = nil
+			if hasValue {
+				p.query[key] = globRegexp(value)
+				p.addGlobSpecificity(value)
+			}
+			p.specificity.Literal += len(key)
+			p.specificity.QueryParams++
+		}
+	}
+
+	return p, nil
+}
+
+// String returns the pattern as written
+func (p *URLPattern) String() string {
+	return p.raw
+}
+
+// Specificity returns how narrowly the pattern describes a URL
+func (p *URLPattern) Specificity() PatternSpecificity {
+	return p.specificity
+}
+
+// Match reports whether the URL matches the pattern
+func (p *URLPattern) Match(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	path := normalizePatternPath(u.Path)
+
+	if p.regex != nil {
+		target := host + path
+		if u.RawQuery != "" {
+			target += "?" + u.RawQuery
+		}
+		return p.regex.MatchString(target)
+	}
+
+	if p.wildcard {
+		if !strings.HasSuffix(host, "."+p.host) {
+			return false
+		}
+	} else if host != p.host {
+		return false
+	}
+
+	if p.path != nil && !p.path.MatchString(path) {
+		return false
+	}
+
+	if len(p.query) > 0 {
+		values := u.Query()
+		for key, value := range p.query {
+			if _, present := values[key]; !present {
+				return false
+			}
+			if value != nil && !value.MatchString(values.Get(key)) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// addGlobSpecificity counts the literal characters and wildcards of a glob.
+// "**" counts twice since it matches more than "*".
+func (p *URLPattern) addGlobSpecificity(glob string) {
+	p.specificity.Wildcards += strings.Count(glob, "*")
+	p.specificity.Literal += len(strings.ReplaceAll(glob, "*", ""))
+}
+
+// normalizePatternPath drops trailing slashes so "/contact/" and "/contact" match
+func normalizePatternPath(path string) string {
+	path = strings.TrimRight(path, "/")
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// globRegexp compiles a glob where "*" stays within a path segment and "**" does not
+func globRegexp(glob string) *regexp.Regexp {
+	var expression strings.Builder
+	expression.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**"):
+			expression.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			expression.WriteString("[^/]*")
+		default:
+			expression.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	expression.WriteString("$")
+	return regexp.MustCompile(expression.String())
+}
+
+// regexLiteralLength approximates how many characters of the path and query a
+// regular expression matches literally, counting letters, digits, path
+// punctuation and escaped metacharacters from the first "/" on
+func regexLiteralLength(expression string) int {
+	start := strings.Index(expression, "/")
+	if start < 0 {
+		return 0
+	}
+
+	length := 0
+	for i := start; i < len(expression); i++ {
+		c := rune(expression[i])
+		if c == '\\' && i+1 < len(expression) {
+			if next := rune(expression[i+1]); !unicode.IsLetter(next) && !unicode.IsDigit(next) {
+				length++
+			}
+			i++
+			continue
+		}
+		if unicode.IsLetter(c) || unicode.IsDigit(c) || strings.ContainsRune("/-_=&%", c) {
+			length++
+		}
+	}
+	return length
+}
+
+// TemplateMatch is a template that could be used for a URL, and why
+type TemplateMatch struct {
+	Template    *FormTemplate      `json:"-"`
+	TemplateID  string             `json:"templateId"`
+	Kind        string             `json:"kind"`
+	Pattern     string             `json:"pattern,omitempty"`
+	Specificity PatternSpecificity `json:"specificity"`
+	Score       float64            `json:"score"`             // Decayed success score from 0 to 1
+	Failing     bool               `json:"failing,omitempty"` // Recent outcomes were poor
+	Chosen      bool               `json:"chosen"`
+	Reason      string             `json:"reason"`
+}
+
+// matchKindRank orders match kinds from weakest to strongest
+func matchKindRank(kind string) int {
+	switch kind {
+	case MatchExactURL:
+		return 2
+	case MatchPattern:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// MatchTemplates returns every template that matches the URL, best first.
+// Exact URL matches rank first, then the most specific URL pattern, then
+// templates without patterns on the same host. Templates whose recent
+// outcomes are poor rank after healthy ones of the same kind. Ties go to the
+// higher score and then the most recently updated template. Stale templates
+// are listed but never chosen.
+func (tm *TemplateManager) MatchTemplates(targetURL string) ([]*TemplateMatch, error) {
+	parsedURL, err := url.Parse(targetURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	tm.mutex.RLock()
+	templates, err := tm.candidateTemplates(targetURL, parsedURL)
+	tm.mutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var matches []*TemplateMatch
+	for _, template := range templates {
+		if match := matchTemplate(template.Clone(), targetURL, parsedURL); match != nil {
+			match.Score = match.Template.Score(now)
+			match.Failing = match.Template.Failing(now)
+			matches = append(matches, match)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return compareTemplateMatches(matches[i], matches[j]) > 0
+	})
+
+	var chosen *TemplateMatch
+	for _, match := range matches {
+		switch {
+		case match.Template.Stale:
+			match.Reason = "stale: marked by a drift check"
+		case chosen == nil:
+			match.Chosen = true
+			match.Reason = "chosen: " + describeMatch(match)
+			chosen = match
+		default:
+			match.Reason = explainMatchLoss(match, chosen)
+		}
+	}
+
+	return matches, nil
+}
+
+// candidateTemplates returns the templates that may match a URL. The
+// database is searched through its URL, domain and pattern host indices.
+func (tm *TemplateManager) candidateTemplates(targetURL string, parsedURL *url.URL) ([]*FormTemplate, error) {
+	if tm.store == nil {
+		return tm.allTemplates()
+	}
+
+	host := strings.ToLower(parsedURL.Hostname())
+	records, err := tm.store.FindFormTemplateCandidates(targetURL, host, parentHosts(host))
+	if err != nil {
+		return nil, err
+	}
+	return templatesFromRecords(records)
+}
+
+// parentHosts returns a host and each domain above it, which is where
+// exact and subdomain wildcard patterns for the host are indexed
+func parentHosts(host string) []string {
+	var hosts []string
+	for host != "" {
+		hosts = append(hosts, host)
+		index := strings.Index(host, ".")
+		if index < 0 {
+			break
+		}
+		host = host[index+1:]
+	}
+	return hosts
+}
+
+// indexHost returns the host the pattern is indexed under, or "" for
+// regular expressions that may match any host
+func (p *URLPattern) indexHost() string {
+	if p.regex != nil {
+		return ""
+	}
+	return p.host
+}
+
+// matchTemplate returns the strongest way a template matches the URL, or nil
+func matchTemplate(template *FormTemplate, targetURL string, parsedURL *url.URL) *TemplateMatch {
+	if template.URL == targetURL {
+		match := &TemplateMatch{Template: template, TemplateID: template.ID, Kind: MatchExactURL, Pattern: template.URL}
+		if pattern, err := templateURLPattern(template.URL); err == nil {
+			match.Specificity = pattern.Specificity()
+		}
+		return match
+	}
+
+	patterns := template.URLPatterns
+	if len(patterns) == 0 {
+		// Templates without patterns match the path they were learned on
+		if pattern, err := templateURLPattern(template.URL); err == nil {
+			patterns = []string{pattern.String()}
+		}
+	}
+
+	var best *TemplateMatch
+	for _, raw := range patterns {
+		pattern, err := ParseURLPattern(raw)
+		if err != nil || !pattern.Match(parsedURL) {
+			continue
+		}
+		if best == nil || pattern.Specificity().Compare(best.Specificity) > 0 {
+			best = &TemplateMatch{Template: template, TemplateID: template.ID, Kind: MatchPattern, Pattern: raw, Specificity: pattern.Specificity()}
+		}
+	}
+	if best != nil {
+		return best
+	}
+
+	if len(template.URLPatterns) == 0 && strings.EqualFold(template.Domain, parsedURL.Hostname()) {
+		return &TemplateMatch{Template: template, TemplateID: template.ID, Kind: MatchDomain}
+	}
+
+	return nil
+}
+
+// templateURLPattern builds the implicit host and path pattern of a template URL
+func templateURLPattern(templateURL string) (*URLPattern, error) {
+	parsed, err := url.Parse(templateURL)
+	if err != nil || parsed.Hostname() == "" {
+		return nil, fmt.Errorf("template URL %q has no host", templateURL)
+	}
+
+	return ParseURLPattern(parsed.Hostname() + parsed.Path)
+}
+
+// compareTemplateMatches returns 1 if a should be preferred over b
+func compareTemplateMatches(a, b *TemplateMatch) int {
+	if rank := compareInts(matchKindRank(a.Kind), matchKindRank(b.Kind)); rank != 0 {
+		return rank
+	}
+	if a.Failing != b.Failing {
+		if b.Failing {
+			return 1
+		}
+		return -1
+	}
+	if specificity := a.Specificity.Compare(b.Specificity); specificity != 0 {
+		return specificity
+	}
+	if a.Score != b.Score {
+		if a.Score > b.Score {
+			return 1
+		}
+		return -1
+	}
+	switch {
+	case a.Template.LastUpdated.After(b.Template.LastUpdated):
+		return 1
+	case a.Template.LastUpdated.Before(b.Template.LastUpdated):
+		return -1
+	}
+	return 0
+}
+
+// describeMatch says how a match was made
+func describeMatch(match *TemplateMatch) string {
+	switch match.Kind {
+	case MatchExactURL:
+		return "learned on this exact URL"
+	case MatchPattern:
+		return fmt.Sprintf("pattern %s (%s)", match.Pattern, match.Specificity)
+	default:
+		return "same host, template has no URL patterns"
+	}
+}
+
+// explainMatchLoss says why a match lost to the chosen one
+func explainMatchLoss(match, chosen *TemplateMatch) string {
+	switch {
+	case matchKindRank(match.Kind) != matchKindRank(chosen.Kind):
+		return fmt.Sprintf("weaker match: %s", describeMatch(match))
+	case match.Failing && !chosen.Failing:
+		return fmt.Sprintf("recent outcomes poor (score %.0f%%)", match.Score*100)
+	case match.Specificity.Compare(chosen.Specificity) != 0:
+		return fmt.Sprintf("less specific: %s", describeMatch(match))
+	case match.Score != chosen.Score:
+		return fmt.Sprintf("equally specific, lower score (%.0f%% vs %.0f%%)", match.Score*100, chosen.Score*100)
+	default:
+		return "equally specific and successful, updated less recently"
+	}
+}
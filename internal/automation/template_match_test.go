@@ -0,0 +1,127 @@
+package automation
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestURLPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		url     string
+		matches bool
+	}{
+		{"example.com/contact", "https://example.com/contact/", true},
+		{"example.com/contact", "https://example.com/contact/us", false},
+		{"example.com/account/*", "https://example.com/account/billing", true},
+		{"example.com/account/*", "https://example.com/account/billing/edit", false},
+		{"example.com/account/**", "https://example.com/account/billing/edit", true},
+		{"*.example.com/signup", "https://eu.shop.example.com/signup", true},
+		{"*.example.com/signup", "https://example.com/signup", false},
+		{"example.com", "https://EXAMPLE.com/anything", true},
+		{"example.com/search?type=jobs", "https://example.com/search?q=go&type=jobs", true},
+		{"example.com/search?type=jobs", "https://example.com/search?type=news", false},
+		{"example.com/search?page", "https://example.com/search?page=2", true},
+		{"https://example.com/checkout?step=*", "https://example.com/checkout?step=2", true},
+		{`re:example\.com/orders/\d+/edit`, "https://example.com/orders/42/edit", true},
+		{`re:example\.com/orders/\d+/edit`, "https://example.com/orders/new/edit", false},
+	}
+
+	for _, test := range tests {
+		pattern, err := ParseURLPattern(test.pattern)
+		if err != nil {
+			t.Fatalf("Failed to parse pattern %s: %v", test.pattern, err)
+		}
+
+		parsed, _ := url.Parse(test.url)
+		if got := pattern.Match(parsed); got != test.matches {
+			t.Errorf("Pattern %s on %s: expected %v, got %v", test.pattern, test.url, test.matches, got)
+		}
+	}
+}
+
+func TestParseURLPatternErrors(t *testing.T) {
+	for _, pattern := range []string{"", "/contact", "exa*mple.com/x", "example.com?=x", "re:("} {
+		if _, err := ParseURLPattern(pattern); err == nil {
+			t.Errorf("Expected pattern %q to be rejected", pattern)
+		}
+	}
+}
+
+func TestPatternSpecificity(t *testing.T) {
+	ordered := []string{
+		"example.com/account/billing",
+		"example.com/account/*",
+		"example.com/account/**",
+		"example.com",
+	}
+
+	for i := 0; i+1 < len(ordered); i++ {
+		more, _ := ParseURLPattern(ordered[i])
+		less, _ := ParseURLPattern(ordered[i+1])
+		if more.Specificity().Compare(less.Specificity()) <= 0 {
+			t.Errorf("Expected %s to be more specific than %s", ordered[i], ordered[i+1])
+		}
+	}
+
+	exact, _ := ParseURLPattern("shop.example.com/cart")
+	wildcard, _ := ParseURLPattern("*.example.com/cart")
+	if exact.Specificity().Compare(wildcard.Specificity()) <= 0 {
+		t.Error("Expected an exact host to be more specific than a subdomain wildcard")
+	}
+}
+
+func TestMatchTemplates(t *testing.T) {
+	tm, err := NewTemplateManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create template manager: %v", err)
+	}
+
+	now := time.Now()
+	tm.templates = map[string]*FormTemplate{
+		"contact": {ID: "contact", URL: "https://example.com/contact", Domain: "example.com"},
+		"signup":  {ID: "signup", URL: "https://example.com/signup", Domain: "example.com"},
+		"account": {ID: "account", URL: "https://example.com/account/profile", Domain: "example.com",
+			URLPatterns: []string{"example.com/account/**"}, SuccessRate: 90, LastUpdated: now},
+		"account_newer": {ID: "account_newer", URL: "https://example.com/account/settings", Domain: "example.com",
+			URLPatterns: []string{"example.com/account/**"}, SuccessRate: 90, LastUpdated: now.Add(time.Hour)},
+		"billing": {ID: "billing", URL: "https://example.com/account/billing", Domain: "example.com",
+			URLPatterns: []string{"example.com/account/billing"}, Stale: true},
+	}
+
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"https://example.com/contact?ref=footer", "contact"},
+		{"https://example.com/signup", "signup"},
+		{"https://example.com/account/orders", "account_newer"},  // Tie broken by recency
+		{"https://example.com/account/billing", "account_newer"}, // More specific template is stale
+		{"https://example.com/about", "contact"},                 // Domain fallback, higher success rate
+	}
+
+	tm.templates["contact"].SuccessRate = 80
+
+	for _, test := range tests {
+		template, err := tm.FindBestTemplate(test.url)
+		if err != nil {
+			t.Fatalf("Expected a template for %s: %v", test.url, err)
+		}
+		if template.ID != test.expected {
+			t.Errorf("URL %s: expected template %s, got %s", test.url, test.expected, template.ID)
+		}
+	}
+
+	matches, _ := tm.MatchTemplates("https://example.com/account/billing")
+	if len(matches) != 5 || matches[0].TemplateID != "billing" || matches[0].Chosen {
+		t.Fatalf("Expected the stale billing template ranked first but not chosen, got %+v", matches[0])
+	}
+	if matches[2].TemplateID != "account" || matches[2].Reason == "" {
+		t.Errorf("Expected the older account template third with a reason, got %+v", matches[2])
+	}
+
+	if _, err := tm.FindBestTemplate("https://other.example/contact"); err == nil {
+		t.Error("Expected no template for another host")
+	}
+}
@@ -0,0 +1,169 @@
+package automation
+
+import (
+	"math"
+	"time"
+)
+
+// Template score settings. Outcomes lose half their weight every half-life,
+// and the score is pulled towards the prior until there is enough evidence.
+const (
+	templateScoreHalfLife    = 14 * 24 * time.Hour
+	templateScorePrior       = 0.5
+	templateScorePriorWeight = 1.0
+
+	// Templates with at least this much recent weight and a score below the
+	// threshold are ranked after healthy templates
+	templateFailingMinWeight = 3.0
+	templateFailingScore     = 0.3
+)
+
+// TemplateStats counts how a template has performed across runs
+type TemplateStats struct {
+	Attempts            int            `json:"attempts"`
+	Fills               int            `json:"fills"` // Runs that filled every field
+	Submissions         int            `json:"submissions"`
+	VerifiedSubmissions int            `json:"verified_submissions"`
+	FieldFailures       map[string]int `json:"field_failures,omitempty"`
+	ScoreSum            float64        `json:"score_sum"`    // Decayed sum of outcome values
+	ScoreWeight         float64        `json:"score_weight"` // Decayed number of outcomes
+	LastOutcome         time.Time      `json:"last_outcome,omitempty"`
+}
+
+// SubmissionVerification is what checking a submission found
+type SubmissionVerification int
+
+const (
+	SubmissionUnverified SubmissionVerification = iota // Not submitted, or submitted without checking
+	SubmissionConfirmed                                // The success rules passed
+	SubmissionRejected                                 // The submission failed or its success rules did not pass
+)
+
+// TemplateOutcome is the result of one run of a template
+type TemplateOutcome struct {
+	FillRatio    float64                // Share of fields filled, from 0 to 1
+	FailedFields []string               // Fields that could not be filled
+	Submitted    bool                   // The form was submitted
+	Verification SubmissionVerification // What checking the submission found
+	At           time.Time              // When the run finished; defaults to now
+}
+
+// NewTemplateOutcome builds an outcome from a fill result and an optional
+// submission result
+func NewTemplateOutcome(fill *FillResult, submission *SubmissionResult) TemplateOutcome {
+	outcome := TemplateOutcome{At: time.Now()}
+	if fill != nil {
+		if fill.TotalFields > 0 {
+			outcome.FillRatio = float64(fill.FilledFields) / float64(fill.TotalFields)
+		}
+		outcome.FailedFields = fill.FailedFields
+		outcome.Submitted = fill.SubmissionAttempted
+	}
+	if submission != nil {
+		outcome.Submitted = true
+		switch {
+		case !submission.Verified:
+			outcome.Verification = SubmissionUnverified
+		case submission.Success:
+			outcome.Verification = SubmissionConfirmed
+		default:
+			outcome.Verification = SubmissionRejected
+		}
+	}
+	return outcome
+}
+
+// value scores a single outcome from 0 to 1. A confirmed submission is a full
+// success and a rejected one a failure; otherwise the fill ratio counts,
+// whether or not the form was submitted.
+func (o TemplateOutcome) value() float64 {
+	switch o.Verification {
+	case SubmissionConfirmed:
+		return 1
+	case SubmissionRejected:
+		return 0
+	default:
+		return o.FillRatio
+	}
+}
+
+// Record adds an outcome to the statistics
+func (s *TemplateStats) Record(outcome TemplateOutcome) {
+	if outcome.At.IsZero() {
+		outcome.At = time.Now()
+	}
+
+	decay := s.decay(outcome.At)
+	s.ScoreSum = s.ScoreSum*decay + outcome.value()
+	s.ScoreWeight = s.ScoreWeight*decay + 1
+	if outcome.At.After(s.LastOutcome) {
+		s.LastOutcome = outcome.At
+	}
+
+	s.Attempts++
+	if outcome.FillRatio >= 1 {
+		s.Fills++
+	}
+	if outcome.Submitted {
+		s.Submissions++
+	}
+	if outcome.Verification == SubmissionConfirmed {
+		s.VerifiedSubmissions++
+	}
+
+	for _, field := range outcome.FailedFields {
+		if s.FieldFailures == nil {
+			s.FieldFailures = make(map[string]int)
+		}
+		s.FieldFailures[field]++
+	}
+}
+
+// Score returns the decayed success score at the given time, from 0 to 1.
+// Old outcomes count for less and the score drifts back towards neutral.
+func (s *TemplateStats) Score(now time.Time) float64 {
+	decay := s.decay(now)
+	return (s.ScoreSum*decay + templateScorePrior*templateScorePriorWeight) /
+		(s.ScoreWeight*decay + templateScorePriorWeight)
+}
+
+// RecentWeight returns how many outcomes the score rests on after decay
+func (s *TemplateStats) RecentWeight(now time.Time) float64 {
+	return s.ScoreWeight * s.decay(now)
+}
+
+// FieldFailureRate returns the share of attempts in which a field failed
+func (s *TemplateStats) FieldFailureRate(field string) float64 {
+	if s.Attempts == 0 {
+		return 0
+	}
+	return float64(s.FieldFailures[field]) / float64(s.Attempts)
+}
+
+// decay returns the weight left to earlier outcomes at the given time
+func (s *TemplateStats) decay(now time.Time) float64 {
+	if s.LastOutcome.IsZero() || !now.After(s.LastOutcome) {
+		return 1
+	}
+	return math.Pow(0.5, float64(now.Sub(s.LastOutcome))/float64(templateScoreHalfLife))
+}
+
+// Score returns the template's success score at the given time, from 0 to 1.
+// Templates without statistics fall back to their stored success rate.
+func (t *FormTemplate) Score(now time.Time) float64 {
+	if t.Stats != nil && t.Stats.Attempts > 0 {
+		return t.Stats.Score(now)
+	}
+	if t.SuccessRate > 0 {
+		return math.Min(t.SuccessRate/100, 1)
+	}
+	return templateScorePrior
+}
+
+// Failing reports whether enough recent outcomes were poor that the
+// template should only be used when nothing healthier matches
+func (t *FormTemplate) Failing(now time.Time) bool {
+	return t.Stats != nil &&
+		t.Stats.RecentWeight(now) >= templateFailingMinWeight &&
+		t.Stats.Score(now) < templateFailingScore
+}
@@ -0,0 +1,149 @@
+package automation
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTemplateStatsRecord(t *testing.T) {
+	start := time.Now()
+	stats := &TemplateStats{}
+
+	stats.Record(TemplateOutcome{FillRatio: 1, Submitted: true, Verification: SubmissionConfirmed, At: start})
+	stats.Record(TemplateOutcome{FillRatio: 0.5, FailedFields: []string{"phone"}, At: start})
+
+	if stats.Attempts != 2 || stats.Fills != 1 || stats.Submissions != 1 || stats.VerifiedSubmissions != 1 {
+		t.Errorf("Expected 2 attempts, 1 fill, 1 submission and 1 verified, got %+v", stats)
+	}
+	if stats.FieldFailures["phone"] != 1 || stats.FieldFailureRate("phone") != 0.5 {
+		t.Errorf("Expected phone to fail in half the attempts, got %v", stats.FieldFailures)
+	}
+
+	// (1 + 0.5 + prior 0.5) / (2 + 1)
+	if score := stats.Score(start); math.Abs(score-2.0/3) > 1e-9 {
+		t.Errorf("Expected score 0.667, got %.3f", score)
+	}
+
+	// After many half-lives the evidence is gone and the score is neutral again
+	if score := stats.Score(start.Add(20 * templateScoreHalfLife)); math.Abs(score-templateScorePrior) > 1e-3 {
+		t.Errorf("Expected score to decay to %.1f, got %.3f", templateScorePrior, score)
+	}
+
+	// A recent failure outweighs an old success
+	old := &TemplateStats{}
+	old.Record(TemplateOutcome{FillRatio: 1, Submitted: true, Verification: SubmissionConfirmed, At: start})
+	old.Record(TemplateOutcome{FillRatio: 0, At: start.Add(2 * templateScoreHalfLife)})
+	if score := old.Score(start.Add(2 * templateScoreHalfLife)); score >= 0.5 {
+		t.Errorf("Expected recent failure to pull the score below 0.5, got %.3f", score)
+	}
+}
+
+func TestNewTemplateOutcome(t *testing.T) {
+	fill := &FillResult{TotalFields: 4, FilledFields: 3, FailedFields: []string{"phone"}, SubmissionAttempted: true}
+	unsubmitted := NewTemplateOutcome(&FillResult{TotalFields: 4, FilledFields: 3}, nil)
+
+	outcome := NewTemplateOutcome(fill, nil)
+	if outcome.FillRatio != 0.75 || !outcome.Submitted || outcome.Verification != SubmissionUnverified {
+		t.Errorf("Expected an unverified submission of 75%% of the fields, got %+v", outcome)
+	}
+	if outcome.value() != 0.75 || outcome.value() < unsubmitted.value() {
+		t.Errorf("Expected an unverified submission to count as the fill, got %.3f", outcome.value())
+	}
+
+	outcome = NewTemplateOutcome(fill, &SubmissionResult{})
+	if outcome.Verification != SubmissionUnverified || outcome.value() != 0.75 {
+		t.Errorf("Expected a submission without checks to count as the fill, got %+v", outcome)
+	}
+
+	outcome = NewTemplateOutcome(fill, &SubmissionResult{Success: true, Verified: true})
+	if outcome.Verification != SubmissionConfirmed || outcome.value() != 1 {
+		t.Errorf("Expected a confirmed submission to count fully, got %+v", outcome)
+	}
+
+	outcome = NewTemplateOutcome(fill, &SubmissionResult{Verified: true})
+	if outcome.Verification != SubmissionRejected || outcome.value() != 0 {
+		t.Errorf("Expected a rejected submission to count as a failure, got %+v", outcome)
+	}
+}
+
+func TestFindBestTemplatePrefersHealthyTemplates(t *testing.T) {
+	tm, err := NewTemplateManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create template manager: %v", err)
+	}
+
+	// The specific template has failed every recent run
+	specific := &FormTemplate{ID: "specific", URL: "https://example.com/signup/step1?ref=home", Domain: "example.com",
+		URLPatterns: []string{"example.com/signup/step1"}, Fields: []FormField{{Name: "email", Selector: "#email"}}}
+	general := &FormTemplate{ID: "general", URL: "https://example.com/signup", Domain: "example.com",
+		URLPatterns: []string{"example.com/signup/*"}, Fields: []FormField{{Name: "email", Selector: "#email"}}}
+	for _, template := range []*FormTemplate{specific, general} {
+		if err := tm.SaveTemplate(template); err != nil {
+			t.Fatalf("Failed to save template: %v", err)
+		}
+	}
+
+	if best, _ := tm.FindBestTemplate("https://example.com/signup/step1"); best == nil || best.ID != "specific" {
+		t.Fatalf("Expected the more specific template without statistics, got %v", best)
+	}
+
+	for i := 0; i < 5; i++ {
+		outcome := TemplateOutcome{FillRatio: 0, FailedFields: []string{"email"}}
+		if err := tm.RecordTemplateOutcome("specific", outcome); err != nil {
+			t.Fatalf("Failed to record outcome: %v", err)
+		}
+	}
+
+	matches, _ := tm.MatchTemplates("https://example.com/signup/step1")
+	if len(matches) != 2 || matches[0].TemplateID != "general" || !matches[1].Failing {
+		t.Fatalf("Expected the failing template to rank last, got %+v", matches)
+	}
+	if matches[1].Reason != "recent outcomes poor (score 8%)" {
+		t.Errorf("Unexpected reason: %s", matches[1].Reason)
+	}
+
+	loaded, _ := tm.LoadTemplate("specific")
+	if loaded.Stats.FieldFailures["email"] != 5 || loaded.SuccessRate >= 10 {
+		t.Errorf("Expected 5 email failures and a low success rate, got %+v %.1f", loaded.Stats, loaded.SuccessRate)
+	}
+}
+
+func TestStoredTemplateManagerRecordsOutcomes(t *testing.T) {
+	tm, _ := newStoredTestTemplateManager(t, t.TempDir())
+
+	template := &FormTemplate{ID: "signup", URL: "https://example.com/signup", Domain: "example.com",
+		Fields: []FormField{{Name: "email", Selector: "#email"}, {Name: "phone", Selector: "#phone"}}}
+	if err := tm.SaveTemplate(template); err != nil {
+		t.Fatalf("Failed to save template: %v", err)
+	}
+
+	outcomes := []TemplateOutcome{
+		{FillRatio: 1, Submitted: true, Verification: SubmissionConfirmed},
+		{FillRatio: 0.5, FailedFields: []string{"phone"}},
+	}
+	for _, outcome := range outcomes {
+		if err := tm.RecordTemplateOutcome("signup", outcome); err != nil {
+			t.Fatalf("Failed to record outcome: %v", err)
+		}
+	}
+
+	// Saving the template again must not reset its statistics
+	loaded, _ := tm.LoadTemplate("signup")
+	loaded.Fields[1].Selector = "#tel"
+	if err := tm.SaveTemplate(loaded); err != nil {
+		t.Fatalf("Failed to save template: %v", err)
+	}
+
+	loaded, _ = tm.LoadTemplate("signup")
+	if loaded.Stats == nil || loaded.Stats.Attempts != 2 || loaded.Stats.VerifiedSubmissions != 1 || loaded.Stats.FieldFailures["phone"] != 1 {
+		t.Fatalf("Expected statistics to be stored, got %+v", loaded.Stats)
+	}
+	if math.Abs(loaded.SuccessRate-loaded.Score(loaded.Stats.LastOutcome)*100) > 1e-6 {
+		t.Errorf("Expected success rate to follow the score, got %.2f", loaded.SuccessRate)
+	}
+
+	if err := tm.RecordTemplateOutcome("missing", TemplateOutcome{}); err == nil {
+		t.Error("Expected an error for a missing template")
+	}
+}
@@ -0,0 +1,160 @@
+package automation
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ai-form-filler/cli/internal/storage"
+)
+
+func newStoredTestTemplateManager(t *testing.T, dir string) (*TemplateManager, *storage.DatabaseManager) {
+	db, err := storage.NewDatabaseManager(&storage.DatabaseConfig{
+		DatabasePath: filepath.Join(t.TempDir(), "test.db"),
+		CreateTables: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	tm, err := NewStoredTemplateManager(dir, db)
+	if err != nil {
+		t.Fatalf("Failed to create template manager: %v", err)
+	}
+	return tm, db
+}
+
+func TestStoredTemplateManagerImportsFilesOnce(t *testing.T) {
+	dir := t.TempDir()
+	legacy := &FormTemplate{
+		ID:          "legacy",
+		URL:         "https://example.com/contact",
+		Domain:      "example.com",
+		FormType:    string(FormTypeContact),
+		Fields:      []FormField{{Name: "email", Type: "email", Selector: "#email"}},
+		SuccessRate: 0.8,
+		Version:     3,
+		LastUpdated: time.Now().Add(-24 * time.Hour).Round(time.Second),
+	}
+	data, _ := json.Marshal(legacy)
+	if err := ioutil.WriteFile(filepath.Join(dir, "example_com_contact_legacy.json"), data, 0644); err != nil {
+		t.Fatalf("Failed to write template file: %v", err)
+	}
+
+	tm, db := newStoredTestTemplateManager(t, dir)
+
+	imported, err := tm.LoadTemplate("legacy")
+	if err != nil {
+		t.Fatalf("Expected template to be imported: %v", err)
+	}
+	if imported.Version != 3 || imported.SuccessRate != 0.8 || !imported.LastUpdated.Equal(legacy.LastUpdated) {
+		t.Errorf("Expected version, success rate and timestamp to be kept, got %+v", imported)
+	}
+
+	// Deleted templates are not imported again when the manager is reopened
+	if err := tm.DeleteTemplate("legacy"); err != nil {
+		t.Fatalf("Failed to delete template: %v", err)
+	}
+	reopened, err := NewStoredTemplateManager(dir, db)
+	if err != nil {
+		t.Fatalf("Failed to reopen template manager: %v", err)
+	}
+	if _, err := reopened.LoadTemplate("legacy"); err == nil {
+		t.Error("Expected JSON templates to be imported only once")
+	}
+}
+
+func TestStoredTemplateManagerMatching(t *testing.T) {
+	tm, _ := newStoredTestTemplateManager(t, t.TempDir())
+
+	templates := []*FormTemplate{
+		{ID: "contact", URL: "https://example.com/contact", Domain: "example.com"},
+		{ID: "billing", URL: "https://shop.example.com/account/billing", Domain: "shop.example.com",
+			URLPatterns: []string{"*.example.com/account/billing"}},
+		{ID: "orders", URL: "https://example.com/orders/1/edit", Domain: "example.com",
+			URLPatterns: []string{`re:example\.com/orders/\d+/edit`}},
+	}
+	for _, template := range templates {
+		if err := tm.SaveTemplate(template); err != nil {
+			t.Fatalf("Failed to save template %s: %v", template.ID, err)
+		}
+	}
+
+	tests := map[string]string{
+		"https://example.com/contact?ref=nav":         "contact",
+		"https://eu.example.com/account/billing":      "billing",
+		"https://example.com/orders/42/edit":          "orders",
+		"https://example.com/somewhere-else":          "contact",
+		"https://eu.shop.example.com/account/billing": "billing",
+	}
+	for url, expected := range tests {
+		template, err := tm.FindBestTemplate(url)
+		if err != nil {
+			t.Errorf("Expected a template for %s: %v", url, err)
+			continue
+		}
+		if template.ID != expected {
+			t.Errorf("URL %s: expected %s, got %s", url, expected, template.ID)
+		}
+	}
+
+	byDomain, _ := tm.GetTemplatesByDomain("example.com")
+	if len(byDomain) != 2 {
+		t.Errorf("Expected 2 templates on example.com, got %d", len(byDomain))
+	}
+
+	if err := tm.MarkTemplateStale("contact", true); err != nil {
+		t.Fatalf("Failed to mark template stale: %v", err)
+	}
+	if _, err := tm.FindBestTemplate("https://example.com/contact"); err == nil {
+		t.Error("Expected stale template to be skipped")
+	}
+}
+
+func TestTemplateManagerConcurrentUpdates(t *testing.T) {
+	stored, _ := newStoredTestTemplateManager(t, t.TempDir())
+	files, err := NewTemplateManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create template manager: %v", err)
+	}
+
+	for name, tm := range map[string]*TemplateManager{"stored": stored, "files": files} {
+		template := &FormTemplate{ID: "signup", URL: "https://example.com/signup", Domain: "example.com",
+			Fields: []FormField{{Name: "email", Selector: "#email"}}}
+		if err := tm.SaveTemplate(template); err != nil {
+			t.Fatalf("%s: failed to save template: %v", name, err)
+		}
+
+		const updates = 20
+		var wg sync.WaitGroup
+		for i := 0; i < updates; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				if err := tm.UpdateTemplateSuccess("signup", 100); err != nil {
+					t.Errorf("%s: failed to update success rate: %v", name, err)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				if _, err := tm.FindBestTemplate("https://example.com/signup"); err != nil {
+					t.Errorf("%s: failed to find template: %v", name, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		// Every update is counted; none may be lost
+		loaded, _ := tm.LoadTemplate("signup")
+		if loaded.Stats == nil || loaded.Stats.Attempts != updates || loaded.Stats.Fills != updates {
+			t.Errorf("%s: expected %d attempts and fills, got %+v", name, updates, loaded.Stats)
+		}
+		if loaded.SuccessRate <= 90 {
+			t.Errorf("%s: expected success rate above 90%%, got %.2f", name, loaded.SuccessRate)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package messaging
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Bridge endpoint defaults
+const (
+	DefaultBridgeAddress = "127.0.0.1:47615"
+	BridgePath           = "/native-messaging"
+)
+
+// bridgeWriteTimeout is how long a connection may take to accept a message
+const bridgeWriteTimeout = 10 * time.Second
+
+// BridgeConfig holds configuration for the WebSocket bridge
+type BridgeConfig struct {
+	Address        string                                   // Loopback address to listen on
+	AllowedOrigins []string                                 // Extensions allowed to connect, as origins or Chromium extension IDs
+	MaxConnections int                                      // Connections served at the same time
+	Host           *NativeHostConfig                        // Configuration of each connection's host; Origin is set per connection
+	Logf           func(format string, args ...interface{}) // Reports connections and refusals when set
+}
+
+// DefaultBridgeConfig returns default bridge configuration
+func DefaultBridgeConfig() *BridgeConfig {
+	return &BridgeConfig{
+		Address:        DefaultBridgeAddress,
+		MaxConnections: 4,
+		Host:           DefaultNativeHostConfig(),
+	}
+}
+
+// Bridge serves the native messaging protocol over a localhost WebSocket,
+// for browsers where native messaging is disabled by policy. Each text
+// message is one message of the protocol. Every connection gets its own
+// host and session, so handshakes, pairing and the session token work as
+// they do over native messaging.
+type Bridge struct {
+	config   *BridgeConfig
+	register func(host *NativeHost)
+	origins  map[string]bool
+	upgrader websocket.Upgrader
+	slots    chan struct{}
+
+	mu     sync.Mutex
+	server *http.Server
+	conns  map[*NativeHost]*websocket.Conn
+	closed bool
+}
+
+// NewBridge creates a bridge. register registers the handlers of the host
+// of each connection.
+func NewBridge(config *BridgeConfig, register func(host *NativeHost)) *Bridge {
+	if config == nil {
+		config = DefaultBridgeConfig()
+	}
+	if config.Host == nil {
+		config.Host = DefaultNativeHostConfig()
+	}
+
+	b := &Bridge{
+		config:   config,
+		register: register,
+		origins:  make(map[string]bool),
+		slots:    make(chan struct{}, max(config.MaxConnections, 1)),
+		conns:    make(map[*NativeHost]*websocket.Conn),
+	}
+	for _, origin := range config.AllowedOrigins {
+		b.origins[NormalizeOrigin(origin)] = true
+	}
+	// Origins are checked before upgrading, with a reason in the log
+	b.upgrader.CheckOrigin = func(r *http.Request) bool { return true }
+	return b
+}
+
+// NormalizeOrigin returns the origin of an extension given by origin or,
+// for Chromium browsers, by ID
+func NormalizeOrigin(origin string) string {
+	origin = strings.TrimSuffix(strings.TrimSpace(origin), "/")
+	if chromeExtensionID.MatchString(origin) {
+		return "chrome-extension://" + origin
+	}
+	return origin
+}
+
+// ListenAndServe listens on the configured loopback address and serves
+// connections until Shutdown
+func (b *Bridge) ListenAndServe() error {
+	if len(b.origins) == 0 {
+		return fmt.Errorf("no extension origins are allowed to connect")
+	}
+	host, _, err := net.SplitHostPort(b.config.Address)
+	if err != nil {
+		return fmt.Errorf("invalid bridge address %q: %w", b.config.Address, err)
+	}
+	if !isLoopback(host) {
+		return fmt.Errorf("the bridge only listens on loopback addresses, not %s", host)
+	}
+
+	listener, err := net.Listen("tcp", b.config.Address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", b.config.Address, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(BridgePath, b)
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		listener.Close()
+		return http.ErrServerClosed
+	}
+	b.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	b.mu.Unlock()
+
+	return b.server.Serve(listener)
+}
+
+// Shutdown stops listening and closes every connection. Requests in
+// flight are cancelled.
+func (b *Bridge) Shutdown(ctx context.Context) error {
+	b.mu.Lock()
+	b.closed = true
+	server := b.server
+	for host, conn := range b.conns {
+		host.Stop()
+		conn.Close()
+	}
+	b.mu.Unlock()
+
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
+}
+
+// ServeHTTP upgrades a request from an allowed extension to a WebSocket
+// and serves the protocol on it until either side closes it
+func (b *Bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Pages on other sites can't reach the bridge through a name resolving to 127.0.0.1
+	if !isLoopback(r.Host) {
+		b.logf("refused connection for host %s", r.Host)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	origin := NormalizeOrigin(r.Header.Get("Origin"))
+	if !b.origins[origin] {
+		b.logf("refused connection from origin %q", origin)
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+		defer func() { <-b.slots }()
+	default:
+		b.logf("refused connection from %s: too many connections", origin)
+		http.Error(w, "Too many connections", http.StatusServiceUnavailable)
+		return
+	}
+
+	conn, err := b.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		b.logf("failed to upgrade connection from %s: %v", origin, err)
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(maxMessageSize)
+
+	config := *b.config.Host
+	config.Origin = origin
+	if config.Logger != nil {
+		config.Logger = config.Logger.With("origin", origin)
+	}
+	host := NewNativeHostWithIO(&config, &webSocketReader{conn: conn}, &webSocketWriter{conn: conn})
+	if b.register != nil {
+		b.register(host)
+	}
+	if !b.track(host, conn) {
+		return
+	}
+	defer b.untrack(host)
+
+	b.logf("connected %s", origin)
+	if err := host.Start(); err != nil {
+		b.logf("connection from %s ended: %v", origin, err)
+	} else {
+		b.logf("disconnected %s", origin)
+	}
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+}
+
+// track registers a connection to close on Shutdown, unless the bridge is
+// shutting down already
+func (b *Bridge) track(host *NativeHost, conn *websocket.Conn) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return false
+	}
+	b.conns[host] = conn
+	return true
+}
+
+func (b *Bridge) untrack(host *NativeHost) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.conns, host)
+}
+
+func (b *Bridge) logf(format string, args ...interface{}) {
+	if b.config.Logf != nil {
+		b.config.Logf(format, args...)
+	}
+}
+
+// isLoopback reports whether a host, with or without a port, names this
+// machine
+func isLoopback(host string) bool {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// webSocketReader presents the text messages of a connection as the
+// frames a host reads
+type webSocketReader struct {
+	conn    *websocket.Conn
+	pending bytes.Buffer
+}
+
+func (r *webSocketReader) Read(p []byte) (int, error) {
+	for r.pending.Len() == 0 {
+		_, data, err := r.conn.ReadMessage()
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
+			return 0, io.EOF
+		}
+		if err != nil {
+			return 0, err
+		}
+		if len(data) > 0 {
+			WriteFrame(&r.pending, data)
+		}
+	}
+	return r.pending.Read(p)
+}
+
+// webSocketWriter sends the frames a host writes as text messages. The
+// host writes one frame at a time.
+type webSocketWriter struct {
+	conn    *websocket.Conn
+	pending []byte
+}
+
+func (w *webSocketWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for len(w.pending) >= 4 {
+		length := int(binary.LittleEndian.Uint32(w.pending))
+		if len(w.pending) < 4+length {
+			break
+		}
+
+		w.conn.SetWriteDeadline(time.Now().Add(bridgeWriteTimeout))
+		if err := w.conn.WriteMessage(websocket.TextMessage, w.pending[4:4+length]); err != nil {
+			return 0, err
+		}
+		w.pending = w.pending[4+length:]
+	}
+	return len(p), nil
+}
@@ -0,0 +1,157 @@
+package messaging
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const bridgeTestExtension = "abcdefghijklmnopabcdefghijklmnop"
+
+func startBridge(t *testing.T, pairings *PairingStore) (*Bridge, string) {
+	config := DefaultBridgeConfig()
+	config.AllowedOrigins = []string{bridgeTestExtension}
+	config.Host.Pairings = pairings
+
+	profiles := newMemoryProfiles("bridge")
+	bridge := NewBridge(config, func(host *NativeHost) {
+		host.RegisterHandler("HANDSHAKE", NewHandshakeHandler("test"))
+		handler := NewProfileHandler(profiles)
+		host.RegisterHandler("GET_PROFILES", handler)
+		host.RegisterHandler("CREATE_PROFILE", handler)
+		if pairings != nil {
+			host.RegisterHandler("PAIR", NewPairingHandler(pairings))
+		}
+	})
+	server := httptest.NewServer(bridge)
+	t.Cleanup(server.Close)
+	return bridge, "ws" + strings.TrimPrefix(server.URL, "http") + BridgePath
+}
+
+func dialBridge(url, origin string) (*websocket.Conn, *http.Response, error) {
+	header := http.Header{}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+	return websocket.DefaultDialer.Dial(url, header)
+}
+
+func TestBridgeServesAllowedOrigins(t *testing.T) {
+	_, url := startBridge(t, nil)
+
+	conn, _, err := dialBridge(url, "chrome-extension://"+bridgeTestExtension)
+	if err != nil {
+		t.Fatalf("Expected the extension to connect, got %v", err)
+	}
+	defer conn.Close()
+
+	requests := []struct{ request, response string }{
+		{`{"id":"1","type":"HANDSHAKE","data":{"protocolVersion":4}}`, "HANDSHAKE_RESPONSE"},
+		{`{"id":"2","type":"CREATE_PROFILE","data":{"name":"Jo"}}`, "PROFILE_CREATED"},
+		{`{"id":"3","type":"GET_PROFILES"}`, "PROFILES_RESPONSE"},
+		{`{"id":"4","type":"FILL_FORM","data":{}}`, "ERROR"},
+	}
+	for _, step := range requests {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(step.request)); err != nil {
+			t.Fatalf("Expected to send %s, got %v", step.request, err)
+		}
+		var response NativeMessage
+		if err := conn.ReadJSON(&response); err != nil {
+			t.Fatalf("Expected a response to %s, got %v", step.request, err)
+		}
+		if response.Type != step.response {
+			t.Errorf("Expected %s, got %+v", step.response, response)
+		}
+	}
+
+	// Other sites and unlisted extensions are refused before upgrading
+	for _, origin := range []string{"", "https://example.com", "chrome-extension://ponmlkjihgfedcbaponmlkjihgfedcba"} {
+		_, response, err := dialBridge(url, origin)
+		if err == nil || response == nil || response.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected origin %q to be refused, got %v", origin, err)
+		}
+	}
+}
+
+func TestBridgeRefusesOtherHosts(t *testing.T) {
+	bridge, _ := startBridge(t, nil)
+
+	request := httptest.NewRequest(http.MethodGet, "http://attacker.example:47615"+BridgePath, nil)
+	request.Header.Set("Origin", "chrome-extension://"+bridgeTestExtension)
+	recorder := httptest.NewRecorder()
+	bridge.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected a rebound host name to be refused, got %d", recorder.Code)
+	}
+}
+
+// exchange sends a request on a connection and reads the next message
+func exchange(t *testing.T, conn *websocket.Conn, request string) *NativeMessage {
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(request)); err != nil {
+		t.Fatalf("Expected to send %s, got %v", request, err)
+	}
+	var response NativeMessage
+	if err := conn.ReadJSON(&response); err != nil {
+		t.Fatalf("Expected a response to %s, got %v", request, err)
+	}
+	return &response
+}
+
+func TestBridgeCountsPairingFailuresPerConnection(t *testing.T) {
+	pairings := NewPairingStore(t.TempDir())
+	_, url := startBridge(t, pairings)
+	origin := "chrome-extension://" + bridgeTestExtension
+
+	conn, _, err := dialBridge(url, origin)
+	if err != nil {
+		t.Fatalf("Expected the extension to connect, got %v", err)
+	}
+	for i := 0; i < maxPairingFailures; i++ {
+		if response := exchange(t, conn, `{"id":"1","type":"PAIR","data":{"code":"WRONG-CODE"}}`); response.Code != ErrUnauthorized {
+			t.Fatalf("Expected a wrong code to be refused, got %+v", response)
+		}
+	}
+	code, _, err := pairings.CreateCode("browser", time.Minute)
+	if err != nil {
+		t.Fatalf("Expected a pairing code, got %v", err)
+	}
+	if response := exchange(t, conn, `{"id":"2","type":"PAIR","data":{"code":"`+code+`"}}`); response.Type != "ERROR" {
+		t.Errorf("Expected PAIR to be refused after %d wrong codes, got %+v", maxPairingFailures, response)
+	}
+	conn.Close()
+
+	// Another connection starts over
+	conn, _, err = dialBridge(url, origin)
+	if err != nil {
+		t.Fatalf("Expected the extension to reconnect, got %v", err)
+	}
+	defer conn.Close()
+	if response := exchange(t, conn, `{"id":"3","type":"PAIR","data":{"code":"`+code+`"}}`); response.Type != "PAIRED" {
+		t.Errorf("Expected a new connection to pair, got %+v", response)
+	}
+}
+
+func TestBridgeNeedsPairing(t *testing.T) {
+	_, url := startBridge(t, NewPairingStore(t.TempDir()))
+
+	conn, _, err := dialBridge(url, "chrome-extension://"+bridgeTestExtension)
+	if err != nil {
+		t.Fatalf("Expected the extension to connect, got %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"id":"1","type":"GET_PROFILES"}`)); err != nil {
+		t.Fatalf("Expected to send the request, got %v", err)
+	}
+	var response NativeMessage
+	if err := conn.ReadJSON(&response); err != nil {
+		t.Fatalf("Expected a response, got %v", err)
+	}
+	if response.Code != ErrUnauthorized {
+		t.Errorf("Expected UNAUTHORIZED without a session token, got %+v", response)
+	}
+}
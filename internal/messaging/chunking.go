@@ -0,0 +1,161 @@
+package messaging
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+// ChunkMessageType carries part of a message too large for one frame
+const ChunkMessageType = "CHUNK"
+
+// Chunking limits
+const (
+	chunkOverhead   = 512         // Room in a frame for a CHUNK's envelope and fields
+	maxTransfers    = 8           // Transfers from the extension joined at the same time
+	transferTimeout = time.Minute // Time allowed between the parts of a transfer
+)
+
+// splitMessage splits a message's JSON into CHUNKs with its ID whose
+// frames are at most frameSize bytes
+func splitMessage(id string, data []byte, frameSize int) []*NativeMessage {
+	// Base64 takes 4 bytes for every 3
+	partSize := max((frameSize-chunkOverhead)/4*3, 3)
+	total := (len(data) + partSize - 1) / partSize
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	transferID := randomToken(9)
+
+	chunks := make([]*NativeMessage, 0, total)
+	for index := 0; index < total; index++ {
+		part := data[index*partSize : min((index+1)*partSize, len(data))]
+		chunks = append(chunks, &NativeMessage{
+			ID:   id,
+			Type: ChunkMessageType,
+			Data: Chunk{
+				TransferID: transferID,
+				Index:      index,
+				Total:      total,
+				Size:       len(data),
+				SHA256:     digest,
+				Data:       base64.StdEncoding.EncodeToString(part),
+			},
+			Success: true,
+		})
+	}
+	return chunks
+}
+
+// transfer is a message whose CHUNKs are arriving
+type transfer struct {
+	chunk    Chunk          // The first part received, for its total, size and digest
+	parts    map[int][]byte // By index; not sized by the total, which the peer claims
+	received int            // Parts received
+	bytes    int            // Decoded bytes received
+	lastPart time.Time
+}
+
+// chunkAssembler joins the CHUNKs of messages sent in several frames. It
+// is used by the goroutine reading frames only.
+type chunkAssembler struct {
+	maxSize   int // Largest message joined
+	transfers map[string]*transfer
+	now       func() time.Time
+}
+
+func newChunkAssembler(maxSize int) *chunkAssembler {
+	if maxSize <= 0 {
+		maxSize = defaultMaxTransferSize
+	}
+	return &chunkAssembler{
+		maxSize:   maxSize,
+		transfers: make(map[string]*transfer),
+		now:       time.Now,
+	}
+}
+
+// add stores a CHUNK and returns the joined message once every part of its
+// transfer arrived, or nil until then. A transfer with an invalid part is
+// dropped.
+func (a *chunkAssembler) add(msg *NativeMessage) ([]byte, error) {
+	var chunk Chunk
+	if err := decodeData(msg, &chunk); err != nil {
+		return nil, err
+	}
+	a.expire()
+
+	current, ok := a.transfers[chunk.TransferID]
+	if !ok {
+		switch {
+		case chunk.Size > a.maxSize:
+			return nil, NewProtocolError(ErrTooLarge, "transfer %s of %d bytes exceeds the limit of %d", chunk.TransferID, chunk.Size, a.maxSize)
+		case chunk.Total > chunk.Size:
+			return nil, NewProtocolError(ErrInvalidRequest, "transfer %s has more parts than bytes", chunk.TransferID)
+		case len(a.transfers) >= maxTransfers:
+			return nil, NewProtocolError(ErrUnavailable, "too many transfers in progress")
+		}
+		current = &transfer{chunk: chunk, parts: make(map[int][]byte)}
+		a.transfers[chunk.TransferID] = current
+	}
+
+	if err := current.add(chunk); err != nil {
+		delete(a.transfers, chunk.TransferID)
+		return nil, err
+	}
+	current.lastPart = a.now()
+	if current.received < current.chunk.Total {
+		return nil, nil
+	}
+
+	delete(a.transfers, chunk.TransferID)
+	data := make([]byte, 0, current.bytes)
+	for index := 0; index < current.chunk.Total; index++ {
+		data = append(data, current.parts[index]...)
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != current.chunk.SHA256 {
+		return nil, NewProtocolError(ErrInvalidMessage, "transfer %s does not match its SHA-256 digest", chunk.TransferID)
+	}
+	return data, nil
+}
+
+// add decodes and stores a part of the transfer
+func (t *transfer) add(chunk Chunk) error {
+	first := t.chunk
+	if chunk.Total != first.Total || chunk.Size != first.Size || chunk.SHA256 != first.SHA256 {
+		return NewProtocolError(ErrInvalidRequest, "part %d of transfer %s disagrees with part %d on total, size or digest", chunk.Index, chunk.TransferID, first.Index)
+	}
+	if chunk.Index < 0 || chunk.Index >= chunk.Total {
+		return NewProtocolError(ErrInvalidRequest, "part %d of transfer %s is past its %d parts", chunk.Index, chunk.TransferID, chunk.Total)
+	}
+	if t.parts[chunk.Index] != nil {
+		return NewProtocolError(ErrInvalidRequest, "part %d of transfer %s was sent twice", chunk.Index, chunk.TransferID)
+	}
+
+	part, err := base64.StdEncoding.DecodeString(chunk.Data)
+	if err != nil || len(part) == 0 {
+		return NewProtocolError(ErrInvalidRequest, "part %d of transfer %s is not base64", chunk.Index, chunk.TransferID)
+	}
+	if t.bytes+len(part) > first.Size {
+		return NewProtocolError(ErrInvalidMessage, "transfer %s is longer than its size of %d bytes", chunk.TransferID, first.Size)
+	}
+
+	t.parts[chunk.Index] = part
+	t.received++
+	t.bytes += len(part)
+	if t.received == first.Total && t.bytes != first.Size {
+		return NewProtocolError(ErrInvalidMessage, "transfer %s is shorter than its size of %d bytes", chunk.TransferID, first.Size)
+	}
+	return nil
+}
+
+// expire drops transfers whose next part is overdue
+func (a *chunkAssembler) expire() {
+	now := a.now()
+	for id, current := range a.transfers {
+		if now.Sub(current.lastPart) > transferTimeout {
+			delete(a.transfers, id)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package messaging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSendMessageSplitsLargeMessages(t *testing.T) {
+	var output bytes.Buffer
+	config := DefaultNativeHostConfig()
+	config.MaxFrameSize = 1024
+	host := NewNativeHostWithIO(config, strings.NewReader(""), &output)
+
+	// Multi-byte characters may be split between parts
+	msg := &NativeMessage{ID: "7", Type: "PROFILES_RESPONSE", Data: strings.Repeat("héllo ", 1000), Success: true}
+	if err := host.SendMessage(msg); err != nil {
+		t.Fatalf("Expected the message to be sent, got %v", err)
+	}
+
+	chunks := newChunkAssembler(0)
+	var joined []byte
+	frames := 0
+	for {
+		data, err := ReadFrame(&output)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Expected frames, got %v", err)
+		}
+		frames++
+		if len(data) > 1024 {
+			t.Errorf("Expected frames of at most 1024 bytes, got %d", len(data))
+		}
+
+		var chunk NativeMessage
+		json.Unmarshal(data, &chunk)
+		if chunk.Type != ChunkMessageType || chunk.ID != "7" {
+			t.Fatalf("Expected CHUNKs with the message's ID, got %s %q", chunk.Type, chunk.ID)
+		}
+		if joined, err = chunks.add(&chunk); err != nil {
+			t.Fatalf("Expected the parts to join, got %v", err)
+		}
+	}
+
+	expected, _ := json.Marshal(msg)
+	if frames < 2 || !bytes.Equal(joined, expected) {
+		t.Errorf("Expected %d frames to join to the message, got %q", frames, joined)
+	}
+
+	// Without chunking the message can't be sent
+	host.session.Negotiate(ProtocolVersion, []string{CapabilityProfileManagement})
+	if err := host.SendMessage(msg); ErrorCode(err) != ErrTooLarge {
+		t.Errorf("Expected TOO_LARGE without chunking, got %v", err)
+	}
+}
+
+// chunkFrames splits a request into the frames of a transfer
+func chunkFrames(t *testing.T, request string, frameSize int) [][]byte {
+	var msg NativeMessage
+	json.Unmarshal([]byte(request), &msg)
+
+	var frames [][]byte
+	for _, chunk := range splitMessage(msg.ID, []byte(request), frameSize) {
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			t.Fatalf("Expected the chunk to encode, got %v", err)
+		}
+		frames = append(frames, data)
+	}
+	return frames
+}
+
+func TestHostJoinsChunks(t *testing.T) {
+	config := DefaultNativeHostConfig()
+	config.MaxTransferSize = 64 * 1024
+	inputReader, inputWriter := io.Pipe()
+	outputReader, outputWriter := io.Pipe()
+	host := newProfileHost(newMemoryProfiles("chunked"), config)(inputReader, outputWriter)
+	go host.Start()
+	defer inputWriter.Close()
+
+	send := func(frames [][]byte) *NativeMessage {
+		for _, frame := range frames {
+			if err := WriteFrame(inputWriter, frame); err != nil {
+				t.Fatalf("Expected to send a frame, got %v", err)
+			}
+		}
+		data, err := ReadFrame(outputReader)
+		if err != nil {
+			t.Fatalf("Expected a response, got %v", err)
+		}
+		var response NativeMessage
+		json.Unmarshal(data, &response)
+		return &response
+	}
+
+	name := strings.Repeat("n", 5000)
+	request := `{"id":"1","type":"CREATE_PROFILE","data":{"name":"` + name + `"}}`
+	frames := chunkFrames(t, request, 1024)
+	if response := send(frames); response.ID != "1" || response.Type != "PROFILE_CREATED" {
+		t.Errorf("Expected the joined request to create a profile, got %+v", response)
+	}
+
+	// A part sent twice drops the transfer
+	frames = chunkFrames(t, strings.Replace(request, `"id":"1"`, `"id":"2"`, 1), 1024)
+	if response := send([][]byte{frames[0], frames[0]}); response.Code != ErrInvalidRequest {
+		t.Errorf("Expected INVALID_REQUEST for a repeated part, got %+v", response)
+	}
+
+	// Parts that don't match the digest
+	frames = chunkFrames(t, strings.Replace(request, `"id":"1"`, `"id":"3"`, 1), 1024)
+	frames[1] = bytes.Replace(frames[1], []byte(`"data":"bm5u`), []byte(`"data":"eHh4`), 1)
+	if response := send(frames); response.ID != "3" || response.Code != ErrInvalidMessage {
+		t.Errorf("Expected INVALID_MESSAGE for a corrupted transfer, got %+v", response)
+	}
+
+	// The joined request is validated like any other
+	frames = chunkFrames(t, `{"id":"4","type":"UPDATE_PROFILE","data":{"name":"`+name+`"}}`, 1024)
+	if response := send(frames); response.ID != "4" || response.Code != ErrInvalidRequest {
+		t.Errorf("Expected INVALID_REQUEST for the joined request, got %+v", response)
+	}
+
+	large := `{"id":"5","type":"CREATE_PROFILE","data":{"name":"` + strings.Repeat("n", 70*1024) + `"}}`
+	if response := send(chunkFrames(t, large, 100*1024)[:1]); response.Code != ErrTooLarge {
+		t.Errorf("Expected TOO_LARGE over the transfer limit, got %+v", response)
+	}
+}
+
+func TestChunkAssemblerIgnoresClaimedTotals(t *testing.T) {
+	chunks := newChunkAssembler(0)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	for i := 0; i < maxTransfers; i++ {
+		msg := &NativeMessage{ID: "1", Type: ChunkMessageType, Data: map[string]interface{}{
+			"transferId": fmt.Sprintf("transfer-%d", i),
+			"index":      0,
+			"total":      60000000,
+			"size":       60000000,
+			"sha256":     strings.Repeat("0", 64),
+			"data":       "eHh4",
+		}}
+		if joined, err := chunks.add(msg); err != nil || joined != nil {
+			t.Fatalf("Expected the first part to be kept, got %q, %v", joined, err)
+		}
+	}
+	runtime.ReadMemStats(&after)
+
+	// Parts are kept as they arrive, not allocated for the total a peer claims
+	if grown := int64(after.HeapAlloc) - int64(before.HeapAlloc); grown > 16*1024*1024 {
+		t.Errorf("Expected tiny transfers to take little memory, heap grew by %d bytes", grown)
+	}
+	runtime.KeepAlive(chunks)
+}
@@ -1,9 +1,13 @@
 package messaging
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
+
+	"github.com/ai-form-filler/cli/internal/automation"
 )
 
 // HandshakeHandler handles initial connection handshake
@@ -15,25 +19,33 @@ func NewHandshakeHandler(version string) *HandshakeHandler {
 	return &HandshakeHandler{version: version}
 }
 
-func (h *HandshakeHandler) HandleMessage(msg *NativeMessage) (*NativeMessage, error) {
-	var handshakeData struct {
-		ExtensionID string `json:"extensionId"`
-		Version     string `json:"version"`
+func (h *HandshakeHandler) HandleMessage(ctx context.Context, msg *NativeMessage) (*NativeMessage, error) {
+	var request HandshakeRequest
+	if err := decodeData(msg, &request); err != nil {
+		return nil, err
 	}
 
-	if msg.Data != nil {
-		data, _ := json.Marshal(msg.Data)
-		json.Unmarshal(data, &handshakeData)
+	session := SessionFromContext(ctx)
+	if session == nil {
+		session = newSession("")
+	}
+	version, capabilities, err := session.Negotiate(request.ProtocolVersion, request.Capabilities)
+	if err != nil {
+		return nil, err
 	}
 
 	response := &NativeMessage{
 		ID:   msg.ID,
 		Type: "HANDSHAKE_RESPONSE",
-		Data: map[string]interface{}{
-			"cliVersion":    h.version,
-			"status":        "connected",
-			"capabilities":  []string{"form_filling", "training", "profile_management"},
-			"timestamp":     time.Now().Unix(),
+		Data: HandshakeResponse{
+			CLIVersion:         h.version,
+			ProtocolVersion:    version,
+			MinProtocolVersion: MinProtocolVersion,
+			MaxProtocolVersion: ProtocolVersion,
+			Status:             "connected",
+			Capabilities:       capabilities,
+			Challenge:          session.newChallenge(),
+			Timestamp:          time.Now().Unix(),
 		},
 		Success: true,
 	}
@@ -41,11 +53,98 @@ func (h *HandshakeHandler) HandleMessage(msg *NativeMessage) (*NativeMessage, er
 	return response, nil
 }
 
+// maxPairingFailures is how many wrong codes a connection may send before
+// PAIR is refused until the extension reconnects
+const maxPairingFailures = 5
+
+// PairingHandler pairs extensions with the one-time codes of the pair
+// command and authenticates paired extensions
+type PairingHandler struct {
+	store    *PairingStore
+	failures int
+	mu       sync.Mutex
+}
+
+func NewPairingHandler(store *PairingStore) *PairingHandler {
+	return &PairingHandler{store: store}
+}
+
+func (h *PairingHandler) HandleMessage(ctx context.Context, msg *NativeMessage) (*NativeMessage, error) {
+	session := SessionFromContext(ctx)
+	if session == nil {
+		return nil, fmt.Errorf("pairing needs a host session")
+	}
+
+	switch msg.Type {
+	case "PAIR":
+		var request PairRequest
+		if err := decodeData(msg, &request); err != nil {
+			return nil, err
+		}
+
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.failures >= maxPairingFailures {
+			return nil, NewProtocolError(ErrUnauthorized, "too many wrong pairing codes; reconnect to try again")
+		}
+
+		pairing, err := h.store.CompletePairing(request.Code, session.Origin(), request.Name)
+		if err != nil {
+			if ErrorCode(err) == ErrUnauthorized {
+				h.failures++
+			}
+			return nil, err
+		}
+
+		return &NativeMessage{
+			ID:   msg.ID,
+			Type: "PAIRED",
+			Data: PairResponse{
+				PairingID: pairing.ID,
+				Secret:    pairing.Secret,
+				Token:     session.signIn(pairing.ID),
+			},
+			Success: true,
+		}, nil
+
+	case "AUTHENTICATE":
+		var request AuthenticateRequest
+		if err := decodeData(msg, &request); err != nil {
+			return nil, err
+		}
+
+		challenge := session.takeChallenge()
+		if challenge == "" {
+			return nil, NewProtocolError(ErrUnauthorized, "no challenge to sign; send HANDSHAKE first")
+		}
+
+		pairing, err := h.store.Verify(request.PairingID, session.Origin(), challenge, request.Signature)
+		if err != nil {
+			return nil, err
+		}
+
+		return &NativeMessage{
+			ID:   msg.ID,
+			Type: "AUTHENTICATED",
+			Data: AuthenticateResponse{
+				PairingID: pairing.ID,
+				Token:     session.signIn(pairing.ID),
+			},
+			Success: true,
+		}, nil
+
+	default:
+		return nil, NewProtocolError(ErrUnknownType, "unknown pairing operation: %s", msg.Type)
+	}
+}
+
 // ProfileHandler handles profile-related operations
 type ProfileHandler struct {
 	profileService ProfileService
 }
 
+// ProfileService takes profile data as the sparse map the profile form
+// also uses: fields left out are unchanged
 type ProfileService interface {
 	GetProfiles() ([]interface{}, error)
 	CreateProfile(data map[string]interface{}) (interface{}, error)
@@ -58,7 +157,7 @@ func NewProfileHandler(service ProfileService) *ProfileHandler {
 	return &ProfileHandler{profileService: service}
 }
 
-func (h *ProfileHandler) HandleMessage(msg *NativeMessage) (*NativeMessage, error) {
+func (h *ProfileHandler) HandleMessage(ctx context.Context, msg *NativeMessage) (*NativeMessage, error) {
 	switch msg.Type {
 	case "GET_PROFILES":
 		profiles, err := h.profileService.GetProfiles()
@@ -73,9 +172,10 @@ func (h *ProfileHandler) HandleMessage(msg *NativeMessage) (*NativeMessage, erro
 		}, nil
 
 	case "CREATE_PROFILE":
-		data, ok := msg.Data.(map[string]interface{})
-		if !ok {
-			return nil, fmt.Errorf("invalid profile data")
+		var request CreateProfileRequest
+		data, err := decodeProfileData(msg, &request)
+		if err != nil {
+			return nil, err
 		}
 		
 		profile, err := h.profileService.CreateProfile(data)
@@ -91,9 +191,10 @@ func (h *ProfileHandler) HandleMessage(msg *NativeMessage) (*NativeMessage, erro
 		}, nil
 
 	case "UPDATE_PROFILE":
-		data, ok := msg.Data.(map[string]interface{})
-		if !ok {
-			return nil, fmt.Errorf("invalid profile data")
+		var request UpdateProfileRequest
+		data, err := decodeProfileData(msg, &request)
+		if err != nil {
+			return nil, err
 		}
 		
 		profile, err := h.profileService.UpdateProfile(data)
@@ -109,17 +210,12 @@ func (h *ProfileHandler) HandleMessage(msg *NativeMessage) (*NativeMessage, erro
 		}, nil
 
 	case "DELETE_PROFILE":
-		data, ok := msg.Data.(map[string]interface{})
-		if !ok {
-			return nil, fmt.Errorf("invalid request data")
-		}
-		
-		profileID, ok := data["profileId"].(string)
-		if !ok {
-			return nil, fmt.Errorf("missing profile ID")
+		var request DeleteProfileRequest
+		if err := decodeData(msg, &request); err != nil {
+			return nil, err
 		}
 		
-		err := h.profileService.DeleteProfile(profileID)
+		err := h.profileService.DeleteProfile(request.ProfileID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to delete profile: %w", err)
 		}
@@ -127,13 +223,31 @@ func (h *ProfileHandler) HandleMessage(msg *NativeMessage) (*NativeMessage, erro
 		return &NativeMessage{
 			ID:      msg.ID,
 			Type:    "PROFILE_DELETED",
-			Data:    map[string]string{"profileId": profileID},
+			Data:    ProfileDeletedResponse{ProfileID: request.ProfileID},
 			Success: true,
 		}, nil
 
 	default:
-		return nil, fmt.Errorf("unknown profile operation: %s", msg.Type)
+		return nil, NewProtocolError(ErrUnknownType, "unknown profile operation: %s", msg.Type)
+	}
+}
+
+// decodeProfileData decodes a profile request, then encodes it back as the
+// service's map. Fields the request left out stay out of the map.
+func decodeProfileData(msg *NativeMessage, request interface{}) (map[string]interface{}, error) {
+	if err := decodeData(msg, request); err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode profile data: %w", err)
 	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(encoded, &data); err != nil {
+		return nil, fmt.Errorf("failed to encode profile data: %w", err)
+	}
+	return data, nil
 }
 
 // FormHandler handles form-related operations
@@ -142,25 +256,25 @@ type FormHandler struct {
 }
 
 type FormService interface {
-	FillForm(data map[string]interface{}) (interface{}, error)
-	TrainForm(data map[string]interface{}) (interface{}, error)
-	AnalyzeForms(data map[string]interface{}) (interface{}, error)
-	ProcessTrainingData(data map[string]interface{}) error
+	FillForm(ctx context.Context, request FillFormRequest) (*FillFormResponse, error)
+	TrainForm(ctx context.Context, request TrainFormRequest) (*TrainFormResponse, error)
+	AnalyzeForms(ctx context.Context, request FormsDetectedRequest) (*FormsAnalyzedResponse, error)
+	ProcessTrainingData(ctx context.Context, request TrainFormRequest) error
 }
 
 func NewFormHandler(service FormService) *FormHandler {
 	return &FormHandler{formService: service}
 }
 
-func (h *FormHandler) HandleMessage(msg *NativeMessage) (*NativeMessage, error) {
-	data, ok := msg.Data.(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid request data")
-	}
-
+func (h *FormHandler) HandleMessage(ctx context.Context, msg *NativeMessage) (*NativeMessage, error) {
 	switch msg.Type {
 	case "FILL_FORM":
-		result, err := h.formService.FillForm(data)
+		var request FillFormRequest
+		if err := decodeData(msg, &request); err != nil {
+			return nil, err
+		}
+
+		result, err := h.formService.FillForm(ctx, request)
 		if err != nil {
 			return nil, fmt.Errorf("form filling failed: %w", err)
 		}
@@ -173,7 +287,12 @@ func (h *FormHandler) HandleMessage(msg *NativeMessage) (*NativeMessage, error)
 		}, nil
 
 	case "TRAIN_FORM":
-		result, err := h.formService.TrainForm(data)
+		var request TrainFormRequest
+		if err := decodeData(msg, &request); err != nil {
+			return nil, err
+		}
+
+		result, err := h.formService.TrainForm(ctx, request)
 		if err != nil {
 			return nil, fmt.Errorf("form training failed: %w", err)
 		}
@@ -186,7 +305,12 @@ func (h *FormHandler) HandleMessage(msg *NativeMessage) (*NativeMessage, error)
 		}, nil
 
 	case "FORMS_DETECTED":
-		result, err := h.formService.AnalyzeForms(data)
+		var request FormsDetectedRequest
+		if err := decodeData(msg, &request); err != nil {
+			return nil, err
+		}
+
+		result, err := h.formService.AnalyzeForms(ctx, request)
 		if err != nil {
 			return nil, fmt.Errorf("form analysis failed: %w", err)
 		}
@@ -199,7 +323,12 @@ func (h *FormHandler) HandleMessage(msg *NativeMessage) (*NativeMessage, error)
 		}, nil
 
 	case "TRAINING_DATA":
-		err := h.formService.ProcessTrainingData(data)
+		var request TrainFormRequest
+		if err := decodeData(msg, &request); err != nil {
+			return nil, err
+		}
+
+		err := h.formService.ProcessTrainingData(ctx, request)
 		if err != nil {
 			return nil, fmt.Errorf("training data processing failed: %w", err)
 		}
@@ -211,7 +340,7 @@ func (h *FormHandler) HandleMessage(msg *NativeMessage) (*NativeMessage, error)
 		}, nil
 
 	default:
-		return nil, fmt.Errorf("unknown form operation: %s", msg.Type)
+		return nil, NewProtocolError(ErrUnknownType, "unknown form operation: %s", msg.Type)
 	}
 }
 
@@ -221,7 +350,7 @@ type StatusHandler struct {
 }
 
 type StatusService interface {
-	GetStatus() (interface{}, error)
+	GetStatus() (*StatusResponse, error)
 	OpenDashboard() error
 }
 
@@ -229,7 +358,7 @@ func NewStatusHandler(service StatusService) *StatusHandler {
 	return &StatusHandler{statusService: service}
 }
 
-func (h *StatusHandler) HandleMessage(msg *NativeMessage) (*NativeMessage, error) {
+func (h *StatusHandler) HandleMessage(ctx context.Context, msg *NativeMessage) (*NativeMessage, error) {
 	switch msg.Type {
 	case "GET_STATUS":
 		status, err := h.statusService.GetStatus()
@@ -257,6 +386,93 @@ func (h *StatusHandler) HandleMessage(msg *NativeMessage) (*NativeMessage, error
 		}, nil
 
 	default:
-		return nil, fmt.Errorf("unknown status operation: %s", msg.Type)
+		return nil, NewProtocolError(ErrUnknownType, "unknown status operation: %s", msg.Type)
 	}
-}
\ No newline at end of file
+}
+// ExecutionHandler pushes the progress of execution sessions run from the
+// CLI to subscribed extensions
+type ExecutionHandler struct {
+	executionService ExecutionService
+	subscriptions    map[string]context.CancelFunc
+	mu               sync.Mutex
+}
+
+type ExecutionService interface {
+	WatchExecutions(ctx context.Context, send func(update automation.ExecutionUpdate) error) error
+}
+
+func NewExecutionHandler(service ExecutionService) *ExecutionHandler {
+	return &ExecutionHandler{
+		executionService: service,
+		subscriptions:    make(map[string]context.CancelFunc),
+	}
+}
+
+func (h *ExecutionHandler) HandleMessage(ctx context.Context, msg *NativeMessage) (*NativeMessage, error) {
+	switch msg.Type {
+	case "SUBSCRIBE_EXECUTIONS":
+		stream := StreamFromContext(ctx)
+		if stream == nil || msg.ID == "" {
+			return nil, NewProtocolError(ErrInvalidRequest, "subscriptions need a request ID")
+		}
+
+		// Updates carry the subscribing request's ID and continue after this response
+		stream = stream.Detached()
+		watchCtx, cancel := context.WithCancel(stream.Context())
+
+		h.mu.Lock()
+		if _, exists := h.subscriptions[msg.ID]; exists {
+			h.mu.Unlock()
+			cancel()
+			return nil, NewProtocolError(ErrDuplicateRequest, "subscription %s already exists", msg.ID)
+		}
+		h.subscriptions[msg.ID] = cancel
+		h.mu.Unlock()
+
+		go func() {
+			defer h.unsubscribe(msg.ID)
+			err := h.executionService.WatchExecutions(watchCtx, func(update automation.ExecutionUpdate) error {
+				return stream.Send("EXECUTION_UPDATE", update)
+			})
+			if err != nil && watchCtx.Err() == nil {
+				stream.Send("EXECUTION_WATCH_FAILED", WatchFailedEvent{Error: err.Error()})
+			}
+		}()
+
+		return &NativeMessage{
+			ID:      msg.ID,
+			Type:    "EXECUTIONS_SUBSCRIBED",
+			Data:    SubscriptionResponse{SubscriptionID: msg.ID},
+			Success: true,
+		}, nil
+
+	case "UNSUBSCRIBE_EXECUTIONS":
+		var request UnsubscribeRequest
+		if err := decodeData(msg, &request); err != nil {
+			return nil, err
+		}
+
+		return &NativeMessage{
+			ID:      msg.ID,
+			Type:    "EXECUTIONS_UNSUBSCRIBED",
+			Data:    UnsubscribeResponse{SubscriptionID: request.SubscriptionID, Found: h.unsubscribe(request.SubscriptionID)},
+			Success: true,
+		}, nil
+
+	default:
+		return nil, NewProtocolError(ErrUnknownType, "unknown execution operation: %s", msg.Type)
+	}
+}
+
+// unsubscribe stops a subscription and reports whether it existed
+func (h *ExecutionHandler) unsubscribe(subscriptionID string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	cancel, exists := h.subscriptions[subscriptionID]
+	if exists {
+		cancel()
+		delete(h.subscriptions, subscriptionID)
+	}
+	return exists
+}
@@ -0,0 +1,222 @@
+package messaging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Log file defaults
+const (
+	DefaultLogMaxSize  = 5 * 1024 * 1024 // Bytes written before the file is rotated
+	DefaultLogMaxFiles = 3               // Rotated files kept
+)
+
+// discardLogger is the logger of hosts configured without one
+var discardLogger = slog.New(slog.NewJSONHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+
+// ParseLogLevel parses debug, info, warn or error
+func ParseLogLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q: expected debug, info, warn or error", name)
+}
+
+// RotatingFile is an append-only log file. Once it reaches its size limit
+// it is renamed to path.1, older files move up to path.2 and so on, and
+// the oldest is removed. Several processes may append to the same file;
+// one that finds the file was rotated by another reopens it.
+type RotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+// OpenRotatingFile opens a log file for appending, creating it and its
+// directory if needed. maxSize and maxFiles fall back to the defaults when
+// not positive.
+func OpenRotatingFile(path string, maxSize int64, maxFiles int) (*RotatingFile, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultLogMaxSize
+	}
+	if maxFiles <= 0 {
+		maxFiles = DefaultLogMaxFiles
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	f := &RotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write appends p, rotating the file first if p would take it over its
+// size limit. Each call is written whole, so log records don't interleave.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close closes the file
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// rotate moves the full file aside and opens a new one
+func (f *RotatingFile) rotate() error {
+	current, err := f.file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	f.file.Close()
+
+	// Another process may have rotated the file already; then only its size was stale
+	if info, err := os.Stat(f.path); err == nil && os.SameFile(info, current) {
+		os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxFiles))
+		for i := f.maxFiles - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+		}
+		if err := os.Rename(f.path, f.path+".1"); err != nil {
+			return fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	}
+	return f.open()
+}
+
+// loggedFields are the fields of message data logged as they are. Every
+// other value, such as profile values and pairing secrets, is redacted;
+// URLs are cut to their origin.
+var loggedFields = map[string]bool{
+	"id":                 true,
+	"requestId":          true,
+	"profileId":          true,
+	"templateId":         true,
+	"subscriptionId":     true,
+	"pairingId":          true,
+	"transferId":         true,
+	"extensionId":        true,
+	"status":             true,
+	"state":              true,
+	"mode":               true,
+	"formType":           true,
+	"version":            true,
+	"templateVersion":    true,
+	"protocolVersion":    true,
+	"minProtocolVersion": true,
+	"maxProtocolVersion": true,
+	"cliVersion":         true,
+	"capabilities":       true,
+	"index":              true,
+	"total":              true,
+	"size":               true,
+	"totalFields":        true,
+	"error":              true,
+	"errors":             true,
+}
+
+// logData is message data as it is logged. It is only encoded for records
+// the log level lets through.
+type logData struct {
+	data interface{}
+}
+
+// LogValue implements slog.LogValuer
+func (d logData) LogValue() slog.Value {
+	if d.data == nil {
+		return slog.AnyValue(nil)
+	}
+
+	var generic interface{}
+	encoded, err := json.Marshal(d.data)
+	if err != nil || json.Unmarshal(encoded, &generic) != nil {
+		return slog.StringValue(redactedValue)
+	}
+	return slog.AnyValue(redactValues("", generic))
+}
+
+// redactValues redacts the values of a decoded JSON value that are not
+// named in loggedFields. Keys, booleans and nulls are kept.
+func redactValues(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for name, item := range v {
+			v[name] = redactValues(name, item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValues(key, item)
+		}
+		return v
+	case nil, bool:
+		return v
+	}
+
+	if key == "url" {
+		if s, ok := value.(string); ok {
+			if u, err := url.Parse(s); err == nil && u.Host != "" {
+				return u.Scheme + "://" + u.Host
+			}
+		}
+	}
+	if loggedFields[key] {
+		return value
+	}
+	return redactedValue
+}
@@ -0,0 +1,97 @@
+package messaging
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFileRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "host.log")
+	file, err := OpenRotatingFile(path, 100, 2)
+	if err != nil {
+		t.Fatalf("Expected the log to open, got %v", err)
+	}
+	defer file.Close()
+
+	line := strings.Repeat("x", 39) + "\n"
+	for i := 0; i < 10; i++ {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatalf("Expected to write, got %v", err)
+		}
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("Expected %s to exist, got %v", filepath.Base(name), err)
+		}
+		if info.Size() > 100 {
+			t.Errorf("Expected %s to hold at most 100 bytes, got %d", filepath.Base(name), info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected only 2 rotated files, got %v", err)
+	}
+
+	// A file rotated by another process is reopened, not rotated again
+	other, err := OpenRotatingFile(path, 100, 2)
+	if err != nil {
+		t.Fatalf("Expected the log to open, got %v", err)
+	}
+	defer other.Close()
+	other.Write([]byte(line + line))
+	first, _ := os.ReadFile(path + ".1")
+	file.Write([]byte(line + line))
+	if second, _ := os.ReadFile(path + ".1"); !bytes.Equal(first, second) {
+		t.Errorf("Expected the second writer to reopen the log")
+	}
+}
+
+func TestHostLogsRedactProfileValues(t *testing.T) {
+	var output bytes.Buffer
+	config := DefaultNativeHostConfig()
+	config.Logger = slog.New(slog.NewJSONHandler(&output, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	var input bytes.Buffer
+	WriteFrame(&input, []byte(`{"id":"1","type":"CREATE_PROFILE","data":{"name":"Jo Smith","personalData":{"email":"jo@example.com","phone":"555-1234"}}}`))
+	WriteFrame(&input, []byte(`{"id":"2","type":"FORMS_DETECTED","data":{"url":"https://shop.example.com/checkout?email=jo@example.com","forms":[]}}`))
+	host := newProfileHost(newMemoryProfiles("logged"), config)(&input, io.Discard)
+	if err := host.Start(); err != nil {
+		t.Fatalf("Expected the host to run, got %v", err)
+	}
+
+	logged := output.String()
+	for _, secret := range []string{"Jo Smith", "jo@example.com", "555-1234", "checkout"} {
+		if strings.Contains(logged, secret) {
+			t.Errorf("Expected %q to be redacted, got %s", secret, logged)
+		}
+	}
+
+	var handled, received int
+	for _, line := range strings.Split(strings.TrimSpace(logged), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Expected JSON records, got %q", line)
+		}
+		switch record["msg"] {
+		case "request received":
+			received++
+			if record["id"] == "2" && !strings.Contains(line, `"url":"https://shop.example.com"`) {
+				t.Errorf("Expected the URL's site to be logged, got %s", line)
+			}
+		case "request handled":
+			handled++
+			if _, ok := record["durationMs"]; !ok || record["id"] == nil {
+				t.Errorf("Expected the request ID and duration, got %s", line)
+			}
+		}
+	}
+	if received != 2 || handled != 2 {
+		t.Errorf("Expected 2 requests received and handled, got %d and %d", received, handled)
+	}
+}
@@ -0,0 +1,131 @@
+package messaging
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// HostName is the name the browser extension connects to
+const HostName = "com.ai_form_filler.cli"
+
+// HostManifest is the JSON file that tells a browser how to start the host
+type HostManifest struct {
+	Name              string   `json:"name"`
+	Description       string   `json:"description"`
+	Path              string   `json:"path"`
+	Type              string   `json:"type"`
+	AllowedOrigins    []string `json:"allowed_origins,omitempty"`    // Chromium-based browsers
+	AllowedExtensions []string `json:"allowed_extensions,omitempty"` // Firefox
+}
+
+// Browser is a browser that can start the native messaging host
+type Browser struct {
+	Name        string `json:"name"`
+	ManifestDir string `json:"manifestDir"` // Per-user NativeMessagingHosts directory
+	Firefox     bool   `json:"firefox"`     // Uses allowed_extensions instead of allowed_origins
+}
+
+// chromeExtensionID matches Chromium extension IDs: 32 letters from a to p
+var chromeExtensionID = regexp.MustCompile(`^[a-p]{32}$`)
+
+// firefoxExtensionID matches Firefox add-on IDs: an email-like ID or a GUID
+var firefoxExtensionID = regexp.MustCompile(`^([A-Za-z0-9._+-]*@[A-Za-z0-9._-]+|\{[0-9A-Fa-f-]{36}\})$`)
+
+// LinuxBrowsers returns the browsers that read per-user manifests on Linux
+func LinuxBrowsers(homeDir string) []Browser {
+	config := filepath.Join(homeDir, ".config")
+	return []Browser{
+		{Name: "chrome", ManifestDir: filepath.Join(config, "google-chrome", "NativeMessagingHosts")},
+		{Name: "chromium", ManifestDir: filepath.Join(config, "chromium", "NativeMessagingHosts")},
+		{Name: "brave", ManifestDir: filepath.Join(config, "BraveSoftware", "Brave-Browser", "NativeMessagingHosts")},
+		{Name: "edge", ManifestDir: filepath.Join(config, "microsoft-edge", "NativeMessagingHosts")},
+		{Name: "firefox", ManifestDir: filepath.Join(homeDir, ".mozilla", "native-messaging-hosts"), Firefox: true},
+	}
+}
+
+// ManifestPath returns where the browser looks for the host's manifest
+func (b Browser) ManifestPath() string {
+	return filepath.Join(b.ManifestDir, HostName+".json")
+}
+
+// SplitExtensionIDs sorts extension IDs into Chromium and Firefox IDs
+func SplitExtensionIDs(ids []string) (chromium, firefox []string, err error) {
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		switch {
+		case chromeExtensionID.MatchString(id):
+			chromium = append(chromium, id)
+		case firefoxExtensionID.MatchString(id):
+			firefox = append(firefox, id)
+		default:
+			return nil, nil, fmt.Errorf("invalid extension ID %q: expected 32 letters a-p (Chromium) or an add-on ID like name@example.com (Firefox)", id)
+		}
+	}
+	return chromium, firefox, nil
+}
+
+// NewHostManifest creates the manifest for a browser. It returns nil when
+// none of the extension IDs are for that kind of browser.
+func NewHostManifest(browser Browser, hostPath string, chromiumIDs, firefoxIDs []string) *HostManifest {
+	manifest := &HostManifest{
+		Name:        HostName,
+		Description: "AI Form Filler CLI Native Messaging Host",
+		Path:        hostPath,
+		Type:        "stdio",
+	}
+
+	if browser.Firefox {
+		if len(firefoxIDs) == 0 {
+			return nil
+		}
+		manifest.AllowedExtensions = append([]string(nil), firefoxIDs...)
+		return manifest
+	}
+
+	if len(chromiumIDs) == 0 {
+		return nil
+	}
+	for _, id := range chromiumIDs {
+		manifest.AllowedOrigins = append(manifest.AllowedOrigins, "chrome-extension://"+id+"/")
+	}
+	return manifest
+}
+
+// Marshal returns the manifest as indented JSON
+func (m *HostManifest) Marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// ReadHostManifest reads an installed manifest
+func ReadHostManifest(path string) (*HostManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest HostManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+	return &manifest, nil
+}
+
+// HostLauncher returns a shell script that starts the host with the given
+// binary. Browsers run the manifest's path without arguments of their own
+// choosing, so the script adds the native-messaging command.
+func HostLauncher(binary string) string {
+	return fmt.Sprintf("#!/bin/sh\n# Started by the browser to talk to the AI Form Filler extension\nexec %s native-messaging \"$@\"\n", shellQuote(binary))
+}
+
+// shellQuote quotes a string for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,118 @@
+package messaging
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const chromiumID = "abcdefghijklmnopabcdefghijklmnop"
+
+func TestSplitExtensionIDs(t *testing.T) {
+	chromium, firefox, err := SplitExtensionIDs([]string{
+		chromiumID,
+		" filler@example.com ",
+		"{12345678-abcd-ef01-2345-6789abcdef01}",
+	})
+	if err != nil {
+		t.Fatalf("Expected the IDs to be valid, got %v", err)
+	}
+	if !reflect.DeepEqual(chromium, []string{chromiumID}) {
+		t.Errorf("Expected the Chromium ID, got %v", chromium)
+	}
+	if !reflect.DeepEqual(firefox, []string{"filler@example.com", "{12345678-abcd-ef01-2345-6789abcdef01}"}) {
+		t.Errorf("Expected the Firefox email ID and GUID, trimmed, got %v", firefox)
+	}
+
+	for _, id := range []string{
+		"abcdefghijklmnopabcdefghijklmnoq", // q is not a Chromium ID letter
+		"abcdefghijklmnop",
+		"{12345678-abcd}",
+		"filler",
+		"",
+	} {
+		if _, _, err := SplitExtensionIDs([]string{chromiumID, id}); err == nil {
+			t.Errorf("Expected %q to be refused", id)
+		}
+	}
+}
+
+func TestNewHostManifest(t *testing.T) {
+	browsers := LinuxBrowsers("/home/jo")
+	chrome, firefox := browsers[0], browsers[len(browsers)-1]
+	if chrome.ManifestPath() != "/home/jo/.config/google-chrome/NativeMessagingHosts/"+HostName+".json" {
+		t.Errorf("Expected Chrome's per-user manifest path, got %s", chrome.ManifestPath())
+	}
+	if !firefox.Firefox {
+		t.Fatalf("Expected the last browser to be Firefox, got %+v", firefox)
+	}
+
+	manifest := NewHostManifest(chrome, "/opt/host", []string{chromiumID}, []string{"filler@example.com"})
+	if manifest == nil || manifest.Name != HostName || manifest.Path != "/opt/host" || manifest.Type != "stdio" {
+		t.Fatalf("Expected a stdio manifest for the host, got %+v", manifest)
+	}
+	if !reflect.DeepEqual(manifest.AllowedOrigins, []string{"chrome-extension://" + chromiumID + "/"}) || manifest.AllowedExtensions != nil {
+		t.Errorf("Expected Chrome to allow the extension's origin only, got %+v", manifest)
+	}
+
+	manifest = NewHostManifest(firefox, "/opt/host", []string{chromiumID}, []string{"filler@example.com"})
+	if manifest == nil || !reflect.DeepEqual(manifest.AllowedExtensions, []string{"filler@example.com"}) || manifest.AllowedOrigins != nil {
+		t.Errorf("Expected Firefox to allow the add-on ID only, got %+v", manifest)
+	}
+
+	if manifest := NewHostManifest(firefox, "/opt/host", []string{chromiumID}, nil); manifest != nil {
+		t.Errorf("Expected no Firefox manifest without a Firefox ID, got %+v", manifest)
+	}
+	if manifest := NewHostManifest(chrome, "/opt/host", nil, []string{"filler@example.com"}); manifest != nil {
+		t.Errorf("Expected no Chrome manifest without a Chromium ID, got %+v", manifest)
+	}
+
+	// Installed manifests read back as written
+	path := filepath.Join(t.TempDir(), HostName+".json")
+	manifest = NewHostManifest(chrome, "/opt/host", []string{chromiumID}, nil)
+	data, err := manifest.Marshal()
+	if err != nil {
+		t.Fatalf("Expected the manifest to marshal, got %v", err)
+	}
+	os.WriteFile(path, data, 0644)
+	if read, err := ReadHostManifest(path); err != nil || !reflect.DeepEqual(read, manifest) {
+		t.Errorf("Expected %+v, got %+v, %v", manifest, read, err)
+	}
+}
+
+func TestHostLauncherQuotesBinary(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The launcher is a POSIX shell script")
+	}
+
+	// A binary in a directory with a quote and a space, printing its arguments
+	dir := filepath.Join(t.TempDir(), "Jo's apps")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Expected the directory to be created, got %v", err)
+	}
+	binary := filepath.Join(dir, "ai-form-filler")
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\necho \"$@\"\n"), 0755); err != nil {
+		t.Fatalf("Expected the binary to be written, got %v", err)
+	}
+
+	launcher := HostLauncher(binary)
+	if !strings.Contains(launcher, `'\''`) {
+		t.Errorf("Expected the quote to be escaped, got %s", launcher)
+	}
+	path := filepath.Join(t.TempDir(), "launcher.sh")
+	if err := os.WriteFile(path, []byte(launcher), 0755); err != nil {
+		t.Fatalf("Expected the launcher to be written, got %v", err)
+	}
+
+	output, err := exec.Command(path, "chrome-extension://"+chromiumID+"/").CombinedOutput()
+	if err != nil {
+		t.Fatalf("Expected the launcher to run, got %v: %s", err, output)
+	}
+	if got := strings.TrimSpace(string(output)); got != "native-messaging chrome-extension://"+chromiumID+"/" {
+		t.Errorf("Expected the binary to get the native-messaging command and the browser's arguments, got %q", got)
+	}
+}
@@ -0,0 +1,365 @@
+package messaging
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ai-form-filler/cli/internal/automation"
+	"github.com/ai-form-filler/cli/internal/models"
+)
+
+// Kinds of messages
+const (
+	KindRequest  = "request"  // Sent by the extension
+	KindResponse = "response" // Sent by the host once per request
+	KindEvent    = "event"    // Sent by the host with a request's ID before or after its response
+	KindFrame    = "frame"    // Sent either way in place of a message too large for one frame
+)
+
+// MessageSpec describes a message type of the protocol. Data holds a value
+// of the Go type of the message's data, or nil when it has none.
+type MessageSpec struct {
+	Type        string
+	Kind        string
+	Description string
+	Data        interface{}
+	Response    string   // For requests, the type of the success response
+	Events      []string // For requests, the events that may be sent
+	Capability  string   // For events, the capability that enables them
+	Sensitive   bool     // For requests, whether the session token is needed
+}
+
+// MessageSpecs lists every message type of the protocol
+var MessageSpecs = []MessageSpec{
+	{Type: "HANDSHAKE", Kind: KindRequest, Description: "Agrees on the protocol version and capabilities; sent first",
+		Data: HandshakeRequest{}, Response: "HANDSHAKE_RESPONSE"},
+	{Type: "PAIR", Kind: KindRequest, Description: "Pairs the extension with a code from 'ai-form-filler native-messaging pair' and authenticates the session",
+		Data: PairRequest{}, Response: "PAIRED"},
+	{Type: "AUTHENTICATE", Kind: KindRequest, Description: "Authenticates the session as a paired extension by signing the handshake's challenge: base64 HMAC-SHA256 keyed with the base64-decoded secret",
+		Data: AuthenticateRequest{}, Response: "AUTHENTICATED"},
+	{Type: "GET_PROFILES", Kind: KindRequest, Description: "Lists the profiles", Response: "PROFILES_RESPONSE", Sensitive: true},
+	{Type: "CREATE_PROFILE", Kind: KindRequest, Description: "Creates a profile",
+		Data: CreateProfileRequest{}, Response: "PROFILE_CREATED", Sensitive: true},
+	{Type: "UPDATE_PROFILE", Kind: KindRequest, Description: "Changes the fields sent of a profile",
+		Data: UpdateProfileRequest{}, Response: "PROFILE_UPDATED", Sensitive: true},
+	{Type: "DELETE_PROFILE", Kind: KindRequest, Description: "Deletes a profile",
+		Data: DeleteProfileRequest{}, Response: "PROFILE_DELETED", Sensitive: true},
+	{Type: "FILL_FORM", Kind: KindRequest, Description: "Plans the values of a form's fields, or fills it in the CLI's browser in run mode",
+		Data: FillFormRequest{}, Response: "FORM_FILL_RESPONSE", Events: []string{"FILL_PROGRESS", "FILL_STEP", "FILL_DONE"}, Sensitive: true},
+	{Type: "TRAIN_FORM", Kind: KindRequest, Description: "Saves a template learned from the fields the extension captured",
+		Data: TrainFormRequest{}, Response: "TRAINING_RESPONSE", Sensitive: true},
+	{Type: "FORMS_DETECTED", Kind: KindRequest, Description: "Reports which template covers the forms found on a page",
+		Data: FormsDetectedRequest{}, Response: "FORMS_ANALYZED", Sensitive: true},
+	{Type: "TRAINING_DATA", Kind: KindRequest, Description: "Saves training data like TRAIN_FORM without a result",
+		Data: TrainFormRequest{}, Response: "TRAINING_DATA_PROCESSED", Sensitive: true},
+	{Type: "GET_STATUS", Kind: KindRequest, Description: "Reports the host's version, uptime and storage", Response: "STATUS_RESPONSE"},
+	{Type: "OPEN_CLI_DASHBOARD", Kind: KindRequest, Description: "Opens the CLI dashboard", Response: "DASHBOARD_OPENED", Sensitive: true},
+	{Type: "SUBSCRIBE_EXECUTIONS", Kind: KindRequest, Description: "Streams the progress of execution sessions run from the CLI",
+		Response: "EXECUTIONS_SUBSCRIBED", Events: []string{"EXECUTION_UPDATE", "EXECUTION_WATCH_FAILED"}, Sensitive: true},
+	{Type: "UNSUBSCRIBE_EXECUTIONS", Kind: KindRequest, Description: "Stops a subscription",
+		Data: UnsubscribeRequest{}, Response: "EXECUTIONS_UNSUBSCRIBED", Sensitive: true},
+	{Type: CancelMessageType, Kind: KindRequest, Description: "Aborts a request in progress; answered at once",
+		Data: CancelRequest{}, Response: "CANCEL_RESPONSE"},
+
+	{Type: "HANDSHAKE_RESPONSE", Kind: KindResponse, Data: HandshakeResponse{}},
+	{Type: "PAIRED", Kind: KindResponse, Data: PairResponse{}},
+	{Type: "AUTHENTICATED", Kind: KindResponse, Data: AuthenticateResponse{}},
+	{Type: "PROFILES_RESPONSE", Kind: KindResponse, Data: []models.ClientProfile{}},
+	{Type: "PROFILE_CREATED", Kind: KindResponse, Data: models.ClientProfile{}},
+	{Type: "PROFILE_UPDATED", Kind: KindResponse, Data: models.ClientProfile{}},
+	{Type: "PROFILE_DELETED", Kind: KindResponse, Data: ProfileDeletedResponse{}},
+	{Type: "FORM_FILL_RESPONSE", Kind: KindResponse, Data: FillFormResponse{}},
+	{Type: "TRAINING_RESPONSE", Kind: KindResponse, Data: TrainFormResponse{}},
+	{Type: "FORMS_ANALYZED", Kind: KindResponse, Data: FormsAnalyzedResponse{}},
+	{Type: "TRAINING_DATA_PROCESSED", Kind: KindResponse},
+	{Type: "STATUS_RESPONSE", Kind: KindResponse, Data: StatusResponse{}},
+	{Type: "DASHBOARD_OPENED", Kind: KindResponse},
+	{Type: "EXECUTIONS_SUBSCRIBED", Kind: KindResponse, Data: SubscriptionResponse{}},
+	{Type: "EXECUTIONS_UNSUBSCRIBED", Kind: KindResponse, Data: UnsubscribeResponse{}},
+	{Type: "CANCEL_RESPONSE", Kind: KindResponse, Data: CancelResponse{}},
+	{Type: "SUCCESS", Kind: KindResponse, Description: "Answers a request whose handler returned no response"},
+	{Type: "ERROR", Kind: KindResponse, Description: "Answers a request that failed; see error, code and details"},
+
+	{Type: "FILL_PROGRESS", Kind: KindEvent, Description: "A field of a run fill was filled or failed",
+		Data: automation.FillEvent{}, Capability: CapabilityFillProgress},
+	{Type: "FILL_STEP", Kind: KindEvent, Description: "A template step other than fill ran",
+		Data: automation.FillEvent{}, Capability: CapabilityFillProgress},
+	{Type: "FILL_DONE", Kind: KindEvent, Description: "A run fill finished; sent before its response",
+		Data: FillSummary{}, Capability: CapabilityFillProgress},
+	{Type: "EXECUTION_UPDATE", Kind: KindEvent, Description: "An execution session changed",
+		Data: automation.ExecutionUpdate{}, Capability: CapabilityExecutionUpdates},
+	{Type: "EXECUTION_WATCH_FAILED", Kind: KindEvent, Description: "A subscription ended because the execution feed failed",
+		Data: WatchFailedEvent{}, Capability: CapabilityExecutionUpdates},
+
+	{Type: ChunkMessageType, Kind: KindFrame, Description: "Part of a message larger than one frame, with the message's ID; the base64-decoded parts joined in order are its JSON",
+		Data: Chunk{}, Capability: CapabilityChunking},
+}
+
+// findMessageSpec returns the spec of a message type
+func findMessageSpec(messageType string) (MessageSpec, bool) {
+	for _, spec := range MessageSpecs {
+		if spec.Type == messageType {
+			return spec, true
+		}
+	}
+	return MessageSpec{}, false
+}
+
+// decodeData converts a message's data into a request struct. The host has
+// already checked the data against the request's schema.
+func decodeData(msg *NativeMessage, request interface{}) error {
+	if msg.Data == nil {
+		return nil
+	}
+
+	encoded, err := json.Marshal(msg.Data)
+	if err != nil {
+		return NewProtocolError(ErrInvalidRequest, "invalid request data: %v", err)
+	}
+	if err := json.Unmarshal(encoded, request); err != nil {
+		return NewProtocolError(ErrInvalidRequest, "invalid request data: %v", err)
+	}
+	return nil
+}
+
+// HandshakeRequest is the data of a HANDSHAKE message
+type HandshakeRequest struct {
+	ProtocolVersion int      `json:"protocolVersion,omitempty" schema:"minimum=1"` // Highest version the extension speaks; 1 when missing
+	ExtensionID     string   `json:"extensionId,omitempty"`
+	Version         string   `json:"version,omitempty"`      // Extension version
+	Capabilities    []string `json:"capabilities,omitempty"` // Capabilities wanted; every one when missing
+}
+
+// HandshakeResponse is the data of a HANDSHAKE_RESPONSE message
+type HandshakeResponse struct {
+	CLIVersion         string   `json:"cliVersion"`
+	ProtocolVersion    int      `json:"protocolVersion"` // Agreed version
+	MinProtocolVersion int      `json:"minProtocolVersion"`
+	MaxProtocolVersion int      `json:"maxProtocolVersion"`
+	Status             string   `json:"status" schema:"enum=connected"`
+	Capabilities       []string `json:"capabilities"` // Agreed capabilities
+	Challenge          string   `json:"challenge"`    // Signed by AUTHENTICATE
+	Timestamp          int64    `json:"timestamp"`    // Unix seconds
+}
+
+// PairRequest is the data of a PAIR message
+type PairRequest struct {
+	Code string `json:"code" schema:"required,minLength=1"` // As printed by the pair command; case and dashes don't matter
+	Name string `json:"name,omitempty"`                     // Label for the pairing list, unless the pair command named it
+}
+
+// PairResponse is the data of a PAIRED message. The extension stores the
+// pairing ID and secret to authenticate later sessions.
+type PairResponse struct {
+	PairingID string `json:"pairingId"`
+	Secret    string `json:"secret"` // Base64 HMAC key
+	Token     string `json:"token"`  // Session token for this connection
+}
+
+// AuthenticateRequest is the data of an AUTHENTICATE message
+type AuthenticateRequest struct {
+	PairingID string `json:"pairingId" schema:"required,minLength=1"`
+	Signature string `json:"signature" schema:"required,minLength=1"` // Base64 HMAC-SHA256 of the challenge
+}
+
+// AuthenticateResponse is the data of an AUTHENTICATED message
+type AuthenticateResponse struct {
+	PairingID string `json:"pairingId"`
+	Token     string `json:"token"` // Session token for this connection
+}
+
+// ProfileFields are the profile fields a request sets; fields left out
+// are unchanged
+type ProfileFields struct {
+	PersonalData *PersonalDataFields `json:"personalData,omitempty"`
+	Preferences  *PreferenceFields   `json:"preferences,omitempty"`
+}
+
+// PersonalDataFields are the personal data fields a request sets
+type PersonalDataFields struct {
+	FirstName   *string        `json:"firstName,omitempty"`
+	LastName    *string        `json:"lastName,omitempty"`
+	Email       *string        `json:"email,omitempty"`
+	Phone       *string        `json:"phone,omitempty"`
+	DateOfBirth *string        `json:"dateOfBirth,omitempty"` // Format: YYYY-MM-DD
+	Address     *AddressFields `json:"address,omitempty"`
+}
+
+// AddressFields are the address fields a request sets
+type AddressFields struct {
+	Street1    *string `json:"street1,omitempty"`
+	Street2    *string `json:"street2,omitempty"`
+	City       *string `json:"city,omitempty"`
+	State      *string `json:"state,omitempty"`
+	PostalCode *string `json:"postalCode,omitempty"`
+	Country    *string `json:"country,omitempty"`
+}
+
+// PreferenceFields are the preferences a request sets
+type PreferenceFields struct {
+	AutoFill         *bool   `json:"autoFill,omitempty"`
+	SkipValidation   *bool   `json:"skipValidation,omitempty"`
+	DefaultTimeout   *int    `json:"defaultTimeout,omitempty" schema:"minimum=0"` // In seconds
+	PreferredBrowser *string `json:"preferredBrowser,omitempty"`
+}
+
+// CreateProfileRequest is the data of a CREATE_PROFILE message
+type CreateProfileRequest struct {
+	Name string `json:"name" schema:"required,minLength=1"`
+	ProfileFields
+}
+
+// UpdateProfileRequest is the data of an UPDATE_PROFILE message
+type UpdateProfileRequest struct {
+	ID   string  `json:"id" schema:"required,minLength=1"`
+	Name *string `json:"name,omitempty" schema:"minLength=1"`
+	ProfileFields
+}
+
+// DeleteProfileRequest is the data of a DELETE_PROFILE message
+type DeleteProfileRequest struct {
+	ProfileID string `json:"profileId" schema:"required,minLength=1"`
+}
+
+// ProfileDeletedResponse is the data of a PROFILE_DELETED message
+type ProfileDeletedResponse struct {
+	ProfileID string `json:"profileId"`
+}
+
+// Fill modes of a FILL_FORM request
+const (
+	FillModePlan = "plan" // Return a fill plan for the extension to apply
+	FillModeRun  = "run"  // Fill the page in the CLI's browser, streaming progress
+)
+
+// FillFormRequest is the data of a FILL_FORM message
+type FillFormRequest struct {
+	URL         string                     `json:"url" schema:"required,minLength=1"`
+	ProfileID   string                     `json:"profileId,omitempty"`   // Looked up first
+	ProfileName string                     `json:"profileName,omitempty"` // Looked up without an ID; the only profile is used without either
+	Fields      []automation.DetectedField `json:"fields,omitempty"`      // Fields found in the tab, used when no template matches
+	FormType    string                     `json:"formType,omitempty"`
+	Mode        string                     `json:"mode,omitempty" schema:"enum=plan|run"` // Plan when missing
+	Submit      bool                       `json:"submit,omitempty"`                      // Submit after a run fill
+}
+
+// FillFormResponse is the data of a FORM_FILL_RESPONSE message. A plan
+// fill returns the plan's fields; a run fill returns its result.
+type FillFormResponse struct {
+	Mode string `json:"mode" schema:"enum=plan|run"`
+	*automation.FillPlan
+	Result *FillSummary `json:"result,omitempty"`
+}
+
+// FillSummary is the outcome of a run fill, sent as FILL_DONE and in its response
+type FillSummary struct {
+	URL               string   `json:"url"`
+	Success           bool     `json:"success"`
+	FilledFields      int      `json:"filledFields"`
+	TotalFields       int      `json:"totalFields"`
+	FailedFields      []string `json:"failedFields"`
+	Errors            []string `json:"errors"`
+	Submitted         bool     `json:"submitted"`
+	SubmissionSuccess *bool    `json:"submissionSuccess,omitempty"` // Set when submitted
+	TemplateID        string   `json:"templateId,omitempty"`
+}
+
+// TrainFormRequest is the data of a TRAIN_FORM or TRAINING_DATA message
+type TrainFormRequest struct {
+	URL           string                     `json:"url" schema:"required,minLength=1"`
+	TemplateID    string                     `json:"templateId,omitempty"` // Template to update; matched by URL if empty
+	FormType      string                     `json:"formType,omitempty"`
+	Fields        []automation.DetectedField `json:"fields" schema:"required,minItems=1"`
+	SubmitButtons []automation.SubmitButton  `json:"submitButtons,omitempty"`
+	URLPatterns   []string                   `json:"urlPatterns,omitempty"`
+	Success       []automation.SuccessRule   `json:"success,omitempty"`
+}
+
+// TrainFormResponse is the data of a TRAINING_RESPONSE message
+type TrainFormResponse struct {
+	Status     string    `json:"status" schema:"enum=trained"`
+	TemplateID string    `json:"templateId"`
+	Version    int       `json:"version"`
+	Created    bool      `json:"created"` // False when an existing template was updated
+	Fields     int       `json:"fields"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// FormsDetectedRequest is the data of a FORMS_DETECTED message
+type FormsDetectedRequest struct {
+	URL   string                    `json:"url,omitempty"` // Without it the forms are only counted
+	Forms []automation.DetectedForm `json:"forms,omitempty"`
+}
+
+// FormsAnalyzedResponse is the data of a FORMS_ANALYZED message
+type FormsAnalyzedResponse struct {
+	Status            string         `json:"status" schema:"enum=analyzed"`
+	FormsDetected     int            `json:"formsDetected"`
+	TemplateID        string         `json:"templateId,omitempty"` // Empty when no template matches
+	TemplateVersion   int            `json:"templateVersion,omitempty"`
+	FormType          string         `json:"formType,omitempty"`
+	TrainingSuggested bool           `json:"trainingSuggested"`
+	Forms             []FormCoverage `json:"forms,omitempty"`
+	Timestamp         time.Time      `json:"timestamp"`
+}
+
+// FormCoverage counts the template fields a detected form still has
+type FormCoverage struct {
+	Index         int `json:"index"`
+	Fields        int `json:"fields"`
+	MatchedFields int `json:"matchedFields"`
+}
+
+// StatusResponse is the data of a STATUS_RESPONSE message
+type StatusResponse struct {
+	Status          string    `json:"status" schema:"enum=running"`
+	Version         string    `json:"version"`
+	ProtocolVersion int       `json:"protocolVersion"`
+	Uptime          float64   `json:"uptime"` // In seconds
+	Profiles        int       `json:"profiles"`
+	Templates       int       `json:"templates"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+// SubscriptionResponse is the data of an EXECUTIONS_SUBSCRIBED message
+type SubscriptionResponse struct {
+	SubscriptionID string `json:"subscriptionId"` // The subscribing request's ID, also carried by its events
+}
+
+// UnsubscribeRequest is the data of an UNSUBSCRIBE_EXECUTIONS message
+type UnsubscribeRequest struct {
+	SubscriptionID string `json:"subscriptionId" schema:"required,minLength=1"`
+}
+
+// UnsubscribeResponse is the data of an EXECUTIONS_UNSUBSCRIBED message
+type UnsubscribeResponse struct {
+	SubscriptionID string `json:"subscriptionId"`
+	Found          bool   `json:"found"`
+}
+
+// WatchFailedEvent is the data of an EXECUTION_WATCH_FAILED message
+type WatchFailedEvent struct {
+	Error string `json:"error"`
+}
+
+// CancelRequest is the data of a CANCEL message
+type CancelRequest struct {
+	RequestID string `json:"requestId" schema:"required,minLength=1"`
+}
+
+// CancelResponse is the data of a CANCEL_RESPONSE message
+type CancelResponse struct {
+	RequestID string `json:"requestId"`
+	Cancelled bool   `json:"cancelled"` // False when no such request was in progress
+}
+
+// Chunk is the data of CHUNK: one part of a message sent in several
+// frames. Every part of a transfer repeats its total, size and digest.
+type Chunk struct {
+	TransferID string `json:"transferId" schema:"required,minLength=1"`
+	Index      int    `json:"index" schema:"required,minimum=0"`     // From 0
+	Total      int    `json:"total" schema:"required,minimum=1"`     // Parts in the transfer
+	Size       int    `json:"size" schema:"required,minimum=1"`      // Bytes of the whole message
+	SHA256     string `json:"sha256" schema:"required,minLength=64"` // Hex digest of the whole message
+	Data       string `json:"data" schema:"required,minLength=1"`    // Base64 of this part
+}
@@ -2,49 +2,189 @@ package messaging
 
 import (
 	"bufio"
+	"context"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"sync"
 	"time"
 )
 
-// NativeMessage represents a message exchanged with the browser extension
+// NativeMessage represents a message exchanged with the browser extension.
+// The data of each message type is described by MessageSpecs.
 type NativeMessage struct {
-	ID      string      `json:"id,omitempty"`
-	Type    string      `json:"type"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
-	Success bool        `json:"success"`
+	ID      string       `json:"id,omitempty"`
+	Type    string       `json:"type"`
+	Data    interface{}  `json:"data,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	Code    string       `json:"code,omitempty"`    // Error code, see ErrorCodes
+	Details []FieldError `json:"details,omitempty"` // Why a request was invalid
+	Token   string       `json:"token,omitempty"`   // Session token on sensitive requests
+	Success bool         `json:"success"`
 }
 
-// MessageHandler defines the interface for handling different message types
+// MessageHandler defines the interface for handling different message types.
+// The context is cancelled when the request times out, is cancelled by the
+// extension or the host stops.
 type MessageHandler interface {
-	HandleMessage(msg *NativeMessage) (*NativeMessage, error)
+	HandleMessage(ctx context.Context, msg *NativeMessage) (*NativeMessage, error)
+}
+
+// Size limits. Chrome does not pass the extension messages over 1 MB.
+const (
+	maxMessageSize         = 1024 * 1024      // Largest frame read or, by default, written
+	defaultMaxTransferSize = 64 * 1024 * 1024 // Largest message joined from CHUNKs by default
+)
+
+// CancelMessageType asks the host to abort the request whose ID is given
+// as the message's requestId
+const CancelMessageType = "CANCEL"
+
+// Stream sends messages correlated to a request, such as progress events
+// before its response
+type Stream struct {
+	host      *NativeHost
+	requestID string
+	ctx       context.Context
+}
+
+// streamKey holds the Stream of a request's context
+type streamKey struct{}
+
+// StreamFromContext returns the stream of the request a handler is
+// answering, or nil outside a handler
+func StreamFromContext(ctx context.Context) *Stream {
+	stream, _ := ctx.Value(streamKey{}).(*Stream)
+	return stream
+}
+
+// Send sends a message with the request's ID. Nothing is sent once the
+// request has been answered with an error, so late events can't follow it,
+// nor are events whose capability the extension did not negotiate.
+func (s *Stream) Send(messageType string, data interface{}) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	if spec, ok := findMessageSpec(messageType); ok && !s.host.session.Enabled(spec.Capability) {
+		return nil
+	}
+	return s.host.SendMessage(&NativeMessage{
+		ID:      s.requestID,
+		Type:    messageType,
+		Data:    data,
+		Success: true,
+	})
+}
+
+// Context returns the context that ends sending on the stream
+func (s *Stream) Context() context.Context {
+	return s.ctx
+}
+
+// Detached returns a stream that keeps sending after the request is
+// answered, until the host stops. Subscriptions use it to push updates.
+func (s *Stream) Detached() *Stream {
+	return &Stream{host: s.host, requestID: s.requestID, ctx: s.host.ctx}
+}
+
+// NativeHostConfig holds configuration for request handling
+type NativeHostConfig struct {
+	Workers         int                      // Requests handled at the same time
+	MaxPending      int                      // Requests accepted before reading stops
+	TypeLimits      map[string]int           // Concurrent requests allowed per message type
+	RequestTimeout  time.Duration            // Time allowed from receipt to response
+	TypeTimeouts    map[string]time.Duration // Per message type overrides of RequestTimeout
+	Pairings        *PairingStore            // Paired extensions; sensitive messages are not checked without it
+	Origin          string                   // Extension that started the host, see CallerOrigin
+	Recorder        *Recorder                // Records every frame read and written when set
+	MaxFrameSize    int                      // Largest frame written; larger messages are sent as CHUNKs
+	MaxTransferSize int                      // Largest message joined from the extension's CHUNKs
+	Logger          *slog.Logger             // Logs requests by ID; message data is redacted
+}
+
+// DefaultNativeHostConfig returns default host configuration. Form
+// requests are limited so they can't take every worker from quick requests
+// like GET_STATUS and HANDSHAKE.
+func DefaultNativeHostConfig() *NativeHostConfig {
+	return &NativeHostConfig{
+		Workers:    4,
+		MaxPending: 64,
+		TypeLimits: map[string]int{
+			"FILL_FORM":     2,
+			"TRAIN_FORM":    1,
+			"TRAINING_DATA": 1,
+		},
+		RequestTimeout: 30 * time.Second,
+		TypeTimeouts: map[string]time.Duration{
+			"FILL_FORM": 2 * time.Minute,
+		},
+		MaxFrameSize:    maxMessageSize,
+		MaxTransferSize: defaultMaxTransferSize,
+	}
 }
 
 // NativeHost manages communication between the CLI and browser extension
 type NativeHost struct {
-	input       io.Reader
-	output      io.Writer
-	handlers    map[string]MessageHandler
-	mu          sync.RWMutex
-	isRunning   bool
-	stopChan    chan struct{}
-	messageChan chan *NativeMessage
+	config    *NativeHostConfig
+	input     io.Reader
+	output    io.Writer
+	handlers  map[string]MessageHandler
+	mu        sync.RWMutex
+	writeMu   sync.Mutex // Responses are written by several workers
+	isRunning bool
+	stopChan  chan struct{}
+
+	workers   chan struct{}                 // Held while a handler runs
+	pending   chan struct{}                 // Held from receipt until the response is sent
+	limits    map[string]chan struct{}      // Held while a handler of a limited type runs
+	inFlight  map[string]context.CancelFunc // Cancels requests by ID
+	session   *Session                      // Agreed in the handshake
+	chunks    *chunkAssembler               // Joins the extension's CHUNKs
+	log       *slog.Logger
+	requests  sync.WaitGroup
+	ctx       context.Context // Parent of every request, cancelled by Stop
+	cancelAll context.CancelFunc
+}
+
+// NewNativeHost creates a new native messaging host speaking over stdin
+// and stdout, as browsers start it
+func NewNativeHost(config *NativeHostConfig) *NativeHost {
+	return NewNativeHostWithIO(config, os.Stdin, os.Stdout)
 }
 
-// NewNativeHost creates a new native messaging host
-func NewNativeHost() *NativeHost {
-	return &NativeHost{
-		input:       os.Stdin,
-		output:      os.Stdout,
-		handlers:    make(map[string]MessageHandler),
-		stopChan:    make(chan struct{}),
-		messageChan: make(chan *NativeMessage, 100),
+// NewNativeHostWithIO creates a native messaging host that reads framed
+// messages from input and writes them to output, for tests and replays
+func NewNativeHostWithIO(config *NativeHostConfig, input io.Reader, output io.Writer) *NativeHost {
+	if config == nil {
+		config = DefaultNativeHostConfig()
+	}
+
+	nh := &NativeHost{
+		config:   config,
+		input:    input,
+		output:   output,
+		handlers: make(map[string]MessageHandler),
+		stopChan: make(chan struct{}),
+		workers:  make(chan struct{}, max(config.Workers, 1)),
+		pending:  make(chan struct{}, max(config.MaxPending, 1)),
+		limits:   make(map[string]chan struct{}),
+		inFlight: make(map[string]context.CancelFunc),
+		session:  newSession(config.Origin),
+		chunks:   newChunkAssembler(config.MaxTransferSize),
+		log:      config.Logger,
 	}
+	if nh.log == nil {
+		nh.log = discardLogger
+	}
+	nh.ctx, nh.cancelAll = context.WithCancel(context.Background())
+	for messageType, limit := range config.TypeLimits {
+		nh.limits[messageType] = make(chan struct{}, max(limit, 1))
+	}
+	return nh
 }
 
 // RegisterHandler registers a message handler for a specific message type
@@ -54,7 +194,9 @@ func (nh *NativeHost) RegisterHandler(messageType string, handler MessageHandler
 	nh.handlers[messageType] = handler
 }
 
-// Start begins listening for messages from the browser extension
+// Start begins listening for messages from the browser extension. It
+// returns once input ends or the host stops and every accepted request has
+// been answered.
 func (nh *NativeHost) Start() error {
 	nh.mu.Lock()
 	if nh.isRunning {
@@ -64,48 +206,113 @@ func (nh *NativeHost) Start() error {
 	nh.isRunning = true
 	nh.mu.Unlock()
 
-	// Start message processing goroutine
-	go nh.processMessages()
-
-	// Start reading messages
-	return nh.readMessages()
+	err := nh.readMessages()
+	nh.requests.Wait()
+	return err
 }
 
-// Stop stops the native messaging host
+// Stop stops the native messaging host and cancels requests in flight
 func (nh *NativeHost) Stop() {
 	nh.mu.Lock()
 	defer nh.mu.Unlock()
-	
+
 	if !nh.isRunning {
 		return
 	}
-	
+
 	nh.isRunning = false
 	close(nh.stopChan)
+	nh.cancelAll()
 }
 
-// SendMessage sends a message to the browser extension
+// SendMessage sends a message to the browser extension. A message larger
+// than a frame is sent as CHUNKs if the extension negotiated chunking.
 func (nh *NativeHost) SendMessage(msg *NativeMessage) error {
 	data, err := json.Marshal(msg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
+	nh.log.Debug("message sent", "id", msg.ID, "type", msg.Type, "bytes", len(data), "data", logData{msg.Data})
 
-	// Write message length (4 bytes, little endian)
+	frameSize := nh.config.MaxFrameSize
+	if frameSize <= 0 {
+		frameSize = maxMessageSize
+	}
+	if len(data) <= frameSize {
+		return nh.writeFrame(data, true)
+	}
+	if !nh.session.Enabled(CapabilityChunking) {
+		return NewProtocolError(ErrTooLarge, "%s of %d bytes exceeds the frame limit of %d and chunking was not negotiated", msg.Type, len(data), frameSize)
+	}
+
+	// Recordings keep the message whole. Other messages may be sent between the parts.
+	if nh.config.Recorder != nil {
+		nh.config.Recorder.record(FrameOut, data)
+	}
+	for _, chunk := range splitMessage(msg.ID, data, frameSize) {
+		part, err := json.Marshal(chunk)
+		if err != nil {
+			return fmt.Errorf("failed to marshal message: %w", err)
+		}
+		if err := nh.writeFrame(part, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFrame writes a frame and records it if asked
+func (nh *NativeHost) writeFrame(data []byte, record bool) error {
+	nh.writeMu.Lock()
+	defer nh.writeMu.Unlock()
+
+	if record && nh.config.Recorder != nil {
+		nh.config.Recorder.record(FrameOut, data)
+	}
+	return WriteFrame(nh.output, data)
+}
+
+// WriteFrame writes one message: its length as 4 bytes, little endian,
+// then the message
+func WriteFrame(w io.Writer, data []byte) error {
 	length := uint32(len(data))
-	if err := binary.Write(nh.output, binary.LittleEndian, length); err != nil {
+	if err := binary.Write(w, binary.LittleEndian, length); err != nil {
 		return fmt.Errorf("failed to write message length: %w", err)
 	}
 
-	// Write message data
-	if _, err := nh.output.Write(data); err != nil {
+	if _, err := w.Write(data); err != nil {
 		return fmt.Errorf("failed to write message data: %w", err)
 	}
 
 	return nil
 }
 
-// readMessages reads messages from the browser extension
+// ReadFrame reads one message written by WriteFrame. It returns io.EOF
+// when the input ends between messages.
+func ReadFrame(r io.Reader) ([]byte, error) {
+	var length uint32
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read message length: %w", err)
+	}
+
+	// Validate message length
+	if length == 0 || length > maxMessageSize {
+		return nil, fmt.Errorf("invalid message length: %d", length)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("failed to read message data: %w", err)
+	}
+	return data, nil
+}
+
+// readMessages reads messages from the browser extension. Reading waits
+// while MaxPending requests are unanswered, so a busy host slows the
+// extension down instead of dropping its messages.
 func (nh *NativeHost) readMessages() error {
 	reader := bufio.NewReader(nh.input)
 
@@ -116,24 +323,15 @@ func (nh *NativeHost) readMessages() error {
 		default:
 		}
 
-		// Read message length (4 bytes, little endian)
-		var length uint32
-		if err := binary.Read(reader, binary.LittleEndian, &length); err != nil {
-			if err == io.EOF {
-				return nil // Normal termination
-			}
-			return fmt.Errorf("failed to read message length: %w", err)
+		data, err := ReadFrame(reader)
+		if err == io.EOF {
+			return nil // Normal termination
 		}
-
-		// Validate message length
-		if length == 0 || length > 1024*1024 { // Max 1MB
-			return fmt.Errorf("invalid message length: %d", length)
+		if err != nil {
+			return err
 		}
-
-		// Read message data
-		data := make([]byte, length)
-		if _, err := io.ReadFull(reader, data); err != nil {
-			return fmt.Errorf("failed to read message data: %w", err)
+		if nh.config.Recorder != nil {
+			nh.config.Recorder.record(FrameIn, data)
 		}
 
 		// Parse message
@@ -144,85 +342,285 @@ func (nh *NativeHost) readMessages() error {
 				ID:      msg.ID,
 				Type:    "ERROR",
 				Error:   fmt.Sprintf("failed to parse message: %v", err),
+				Code:    ErrInvalidMessage,
 				Success: false,
 			}
+			nh.log.Warn("request failed", "code", ErrInvalidMessage, "error", err)
 			nh.SendMessage(errorMsg)
 			continue
 		}
+		nh.log.Debug("request received", "id", msg.ID, "type", msg.Type, "bytes", len(data), "data", logData{msg.Data})
+
+		if err := ValidateRequest(&msg); err != nil {
+			nh.sendResponse(&msg, nil, err)
+			continue
+		}
+		if msg.Type == ChunkMessageType {
+			joined, err := nh.joinChunk(&msg)
+			if err != nil {
+				nh.sendResponse(&msg, nil, err)
+				continue
+			}
+			if joined == nil {
+				continue // More parts to come
+			}
+			msg = *joined
+		}
+		if err := nh.authorize(&msg); err != nil {
+			nh.sendResponse(&msg, nil, err)
+			continue
+		}
+
+		// Cancellations are handled at once so they never wait behind their request
+		if msg.Type == CancelMessageType {
+			nh.sendResponse(&msg, nh.cancelRequest(&msg), nil)
+			continue
+		}
 
-		// Queue message for processing
 		select {
-		case nh.messageChan <- &msg:
+		case nh.pending <- struct{}{}:
 		case <-nh.stopChan:
 			return nil
-		default:
-			// Channel full, drop message
-			errorMsg := &NativeMessage{
-				ID:      msg.ID,
-				Type:    "ERROR",
-				Error:   "message queue full",
-				Success: false,
-			}
-			nh.SendMessage(errorMsg)
 		}
+
+		ctx, cancel, err := nh.beginRequest(&msg)
+		if err != nil {
+			<-nh.pending
+			nh.sendResponse(&msg, nil, err)
+			continue
+		}
+
+		nh.requests.Add(1)
+		go nh.handleMessage(ctx, cancel, &msg)
 	}
 }
 
-// processMessages processes queued messages
-func (nh *NativeHost) processMessages() {
-	for {
-		select {
-		case msg := <-nh.messageChan:
-			nh.handleMessage(msg)
-		case <-nh.stopChan:
-			return
-		}
+// joinChunk adds a CHUNK to its transfer and returns the message the
+// transfer carried once every part arrived
+func (nh *NativeHost) joinChunk(chunk *NativeMessage) (*NativeMessage, error) {
+	data, err := nh.chunks.add(chunk)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	var msg NativeMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, NewProtocolError(ErrInvalidMessage, "failed to parse message: %v", err)
+	}
+	if msg.Type == ChunkMessageType {
+		return nil, NewProtocolError(ErrInvalidMessage, "a CHUNK can't carry another CHUNK")
+	}
+	if err := ValidateRequest(&msg); err != nil {
+		// The error goes to the request the transfer carried
+		chunk.ID = msg.ID
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// authorize checks that a sensitive message carries the token of a session
+// authenticated as a pairing that has not been revoked since
+func (nh *NativeHost) authorize(msg *NativeMessage) error {
+	if nh.config.Pairings == nil {
+		return nil
+	}
+	if spec, ok := findMessageSpec(msg.Type); !ok || !spec.Sensitive {
+		return nil
+	}
+
+	pairingID, ok := nh.session.authorized(msg.Token)
+	if !ok {
+		return NewProtocolError(ErrUnauthorized, "%s needs a paired extension: pair with 'ai-form-filler native-messaging pair', then AUTHENTICATE and send the token", msg.Type)
+	}
+	if _, err := nh.config.Pairings.Get(pairingID); err != nil {
+		nh.session.signOut()
+		return err
+	}
+	return nil
+}
+
+// beginRequest registers a request so it can be cancelled and returns the
+// context its handler runs under
+func (nh *NativeHost) beginRequest(msg *NativeMessage) (context.Context, context.CancelFunc, error) {
+	timeout := nh.config.RequestTimeout
+	if typeTimeout, ok := nh.config.TypeTimeouts[msg.Type]; ok {
+		timeout = typeTimeout
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(nh.ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(nh.ctx)
+	}
+
+	ctx = context.WithValue(ctx, streamKey{}, &Stream{host: nh, requestID: msg.ID, ctx: ctx})
+	ctx = context.WithValue(ctx, sessionKey{}, nh.session)
+
+	// Requests without an ID can't be cancelled, only time out
+	if msg.ID == "" {
+		return ctx, cancel, nil
 	}
+
+	nh.mu.Lock()
+	defer nh.mu.Unlock()
+
+	if _, exists := nh.inFlight[msg.ID]; exists {
+		cancel()
+		return nil, nil, NewProtocolError(ErrDuplicateRequest, "request %s is already in progress", msg.ID)
+	}
+	nh.inFlight[msg.ID] = cancel
+
+	return ctx, cancel, nil
+}
+
+// endRequest forgets a finished request
+func (nh *NativeHost) endRequest(msg *NativeMessage, cancel context.CancelFunc) {
+	cancel()
+	if msg.ID == "" {
+		return
+	}
+
+	nh.mu.Lock()
+	defer nh.mu.Unlock()
+	delete(nh.inFlight, msg.ID)
 }
 
-// handleMessage handles a single message
-func (nh *NativeHost) handleMessage(msg *NativeMessage) {
+// cancelRequest aborts the request named by a CANCEL message
+func (nh *NativeHost) cancelRequest(msg *NativeMessage) *NativeMessage {
+	var request CancelRequest
+	decodeData(msg, &request)
+
 	nh.mu.RLock()
-	handler, exists := nh.handlers[msg.Type]
+	cancel, found := nh.inFlight[request.RequestID]
 	nh.mu.RUnlock()
+	if found {
+		cancel()
+	}
+
+	return &NativeMessage{
+		Type:    "CANCEL_RESPONSE",
+		Data:    CancelResponse{RequestID: request.RequestID, Cancelled: found},
+		Success: true,
+	}
+}
+
+// handleMessage runs a request's handler once a worker and the limit of its
+// type allow, and sends exactly one response. A handler still running when
+// its context ends is answered with the context's error; its late result
+// is discarded.
+func (nh *NativeHost) handleMessage(ctx context.Context, cancel context.CancelFunc, msg *NativeMessage) {
+	defer nh.requests.Done()
+	defer func() { <-nh.pending }()
+	defer nh.endRequest(msg, cancel)
+
+	start := time.Now()
+	defer func() {
+		nh.log.Info("request handled", "id", msg.ID, "type", msg.Type, "durationMs", time.Since(start).Milliseconds())
+	}()
 
-	var response *NativeMessage
-	var err error
+	nh.mu.RLock()
+	handler, exists := nh.handlers[msg.Type]
+	nh.mu.RUnlock()
 
 	if !exists {
-		response = &NativeMessage{
-			ID:      msg.ID,
-			Type:    "ERROR",
-			Error:   fmt.Sprintf("unknown message type: %s", msg.Type),
-			Success: false,
+		nh.sendResponse(msg, nil, NewProtocolError(ErrUnknownType, "unknown message type: %s", msg.Type))
+		return
+	}
+
+	// Both slots are freed when the handler returns, which may be after a
+	// timed out or cancelled request was answered
+	limit := nh.limits[msg.Type]
+	if limit != nil {
+		select {
+		case limit <- struct{}{}:
+		case <-ctx.Done():
+			nh.sendResponse(msg, nil, requestError(ctx))
+			return
 		}
-	} else {
-		response, err = handler.HandleMessage(msg)
-		if err != nil {
-			response = &NativeMessage{
-				ID:      msg.ID,
-				Type:    "ERROR",
-				Error:   err.Error(),
-				Success: false,
+	}
+
+	select {
+	case nh.workers <- struct{}{}:
+	case <-ctx.Done():
+		if limit != nil {
+			<-limit
+		}
+		nh.sendResponse(msg, nil, requestError(ctx))
+		return
+	}
+
+	type result struct {
+		response *NativeMessage
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			<-nh.workers
+			if limit != nil {
+				<-limit
 			}
-		} else if response == nil {
-			// Handler didn't return a response, create a default success response
-			response = &NativeMessage{
-				ID:      msg.ID,
-				Type:    "SUCCESS",
-				Success: true,
+		}()
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				nh.log.Error("handler panicked", "id", msg.ID, "type", msg.Type, "panic", fmt.Sprint(recovered))
+				done <- result{err: fmt.Errorf("handler panicked: %v", recovered)}
 			}
+		}()
+
+		response, err := handler.HandleMessage(ctx, msg)
+		done <- result{response: response, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil && ctx.Err() != nil {
+			r.err = requestError(ctx)
 		}
+		nh.sendResponse(msg, r.response, r.err)
+	case <-ctx.Done():
+		nh.sendResponse(msg, nil, requestError(ctx))
 	}
+}
+
+// requestError describes why a request's context ended
+func requestError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return NewProtocolError(ErrTimeout, "request timed out")
+	}
+	return NewProtocolError(ErrCancelled, "request cancelled")
+}
 
-	// Ensure response has the same ID as the request
-	if response.ID == "" {
-		response.ID = msg.ID
+// sendResponse answers a request, always with the request's ID. Errors
+// carry the code of the protocol error in their chain, or INTERNAL.
+func (nh *NativeHost) sendResponse(msg *NativeMessage, response *NativeMessage, err error) {
+	if err != nil {
+		nh.log.Warn("request failed", "id", msg.ID, "type", msg.Type, "code", ErrorCode(err), "error", err)
+		response = &NativeMessage{
+			Type:    "ERROR",
+			Error:   err.Error(),
+			Code:    ErrorCode(err),
+			Details: errorDetails(err),
+			Success: false,
+		}
+	} else if response == nil {
+		// Handler didn't return a response, create a default success response
+		response = &NativeMessage{
+			Type:    "SUCCESS",
+			Success: true,
+		}
 	}
+	response.ID = msg.ID
 
-	// Send response
 	if err := nh.SendMessage(response); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to send response: %v\n", err)
+		// A response too large to send is answered with why instead
+		if ErrorCode(err) == ErrTooLarge && response.Type != "ERROR" {
+			nh.sendResponse(msg, nil, err)
+			return
+		}
+		nh.log.Error("failed to send response", "id", msg.ID, "type", response.Type, "error", err)
 	}
 }
 
@@ -258,7 +656,7 @@ func (nh *NativeHost) SetTimeout(duration time.Duration) {
 	go func() {
 		timer := time.NewTimer(duration)
 		defer timer.Stop()
-		
+
 		select {
 		case <-timer.C:
 			nh.Stop()
@@ -266,4 +664,4 @@ func (nh *NativeHost) SetTimeout(duration time.Duration) {
 			return
 		}
 	}()
-}
\ No newline at end of file
+}
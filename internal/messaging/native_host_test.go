@@ -0,0 +1,233 @@
+package messaging
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// idleWait is how long a request that should not start is watched
+const idleWait = 50 * time.Millisecond
+
+// blockingHandler answers a request only once the test releases it,
+// whether or not the request's context ended
+type blockingHandler struct {
+	started chan string // IDs of requests whose handler runs
+	release chan struct{}
+	once    sync.Once
+}
+
+func (h *blockingHandler) HandleMessage(ctx context.Context, msg *NativeMessage) (*NativeMessage, error) {
+	h.started <- msg.ID
+	<-h.release
+	return &NativeMessage{Type: "DONE", Success: true}, nil
+}
+
+// releaseOne lets one running handler return
+func (h *blockingHandler) releaseOne() {
+	h.release <- struct{}{}
+}
+
+// releaseAll lets every handler return, now and later
+func (h *blockingHandler) releaseAll() {
+	h.once.Do(func() { close(h.release) })
+}
+
+// expectStarted waits for a handler of the request to run
+func (h *blockingHandler) expectStarted(t *testing.T, id string) {
+	t.Helper()
+	select {
+	case started := <-h.started:
+		if started != id {
+			t.Fatalf("Expected request %s to start, got %s", id, started)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected request %s to start", id)
+	}
+}
+
+// expectIdle checks that no other handler starts
+func (h *blockingHandler) expectIdle(t *testing.T) {
+	t.Helper()
+	select {
+	case started := <-h.started:
+		t.Fatalf("Expected no request to start, got %s", started)
+	case <-time.After(idleWait):
+	}
+}
+
+// quickHandler answers at once
+type quickHandler struct{}
+
+func (quickHandler) HandleMessage(ctx context.Context, msg *NativeMessage) (*NativeMessage, error) {
+	return &NativeMessage{Type: "DONE", Success: true}, nil
+}
+
+// startBlockingHost starts a host handling SLOW requests with a blocking
+// handler and QUICK requests at once
+func startBlockingHost(t *testing.T, config *NativeHostConfig) (*pipeHost, *blockingHandler) {
+	handler := &blockingHandler{started: make(chan string, 16), release: make(chan struct{})}
+	host := startPipeHost(t, func(input io.Reader, output io.Writer) *NativeHost {
+		host := NewNativeHostWithIO(config, input, output)
+		host.RegisterHandler("SLOW", handler)
+		host.RegisterHandler("QUICK", quickHandler{})
+		return host
+	})
+	// Registered after the host's cleanup, so it runs first and the host can stop
+	t.Cleanup(handler.releaseAll)
+	return host, handler
+}
+
+// readResponses reads n messages by ID
+func (p *pipeHost) readResponses(n int) map[string]*NativeMessage {
+	responses := make(map[string]*NativeMessage)
+	for i := 0; i < n; i++ {
+		response := p.read()
+		responses[response.ID] = response
+	}
+	return responses
+}
+
+func TestHostLimitsWorkers(t *testing.T) {
+	config := DefaultNativeHostConfig()
+	config.Workers = 2
+	host, handler := startBlockingHost(t, config)
+
+	host.send(`{"id":"1","type":"SLOW"}`)
+	handler.expectStarted(t, "1")
+	host.send(`{"id":"2","type":"SLOW"}`)
+	handler.expectStarted(t, "2")
+	host.send(`{"id":"3","type":"SLOW"}`)
+	handler.expectIdle(t)
+
+	handler.releaseOne()
+	handler.expectStarted(t, "3")
+	handler.releaseAll()
+	for id, response := range host.readResponses(3) {
+		if response.Type != "DONE" {
+			t.Errorf("Expected request %s to be answered, got %+v", id, response)
+		}
+	}
+}
+
+func TestHostLimitsTypes(t *testing.T) {
+	config := DefaultNativeHostConfig()
+	config.TypeLimits = map[string]int{"SLOW": 1}
+	host, handler := startBlockingHost(t, config)
+
+	host.send(`{"id":"1","type":"SLOW"}`)
+	handler.expectStarted(t, "1")
+	host.send(`{"id":"2","type":"SLOW"}`)
+	handler.expectIdle(t)
+
+	// Other types still have workers
+	if response := host.exchange(`{"id":"3","type":"QUICK"}`); response.ID != "3" || response.Type != "DONE" {
+		t.Errorf("Expected QUICK to be answered while SLOW waits, got %+v", response)
+	}
+
+	handler.releaseOne()
+	handler.expectStarted(t, "2")
+	handler.releaseAll()
+	host.readResponses(2)
+}
+
+func TestHostTimesOutRequests(t *testing.T) {
+	config := DefaultNativeHostConfig()
+	config.TypeLimits = map[string]int{"SLOW": 1}
+	config.TypeTimeouts = map[string]time.Duration{"SLOW": idleWait}
+	host, handler := startBlockingHost(t, config)
+
+	host.send(`{"id":"1","type":"SLOW"}`)
+	handler.expectStarted(t, "1")
+	if response := host.read(); response.ID != "1" || response.Code != ErrTimeout {
+		t.Fatalf("Expected the request to time out, got %+v", response)
+	}
+
+	// The timed out handler still runs, so it keeps its type's slot
+	host.send(`{"id":"2","type":"SLOW"}`)
+	if response := host.read(); response.ID != "2" || response.Code != ErrTimeout {
+		t.Errorf("Expected the next request to time out waiting, got %+v", response)
+	}
+	handler.expectIdle(t)
+
+	handler.releaseOne()
+	host.send(`{"id":"3","type":"SLOW"}`)
+	handler.expectStarted(t, "3")
+}
+
+func TestHostCancelsRequests(t *testing.T) {
+	host, handler := startBlockingHost(t, DefaultNativeHostConfig())
+
+	host.send(`{"id":"1","type":"SLOW"}`)
+	handler.expectStarted(t, "1")
+	host.send(`{"id":"2","type":"CANCEL","data":{"requestId":"1"}}`)
+
+	responses := host.readResponses(2)
+	if response := responses["1"]; response == nil || response.Code != ErrCancelled {
+		t.Errorf("Expected the request to be cancelled, got %+v", response)
+	}
+	var cancelled CancelResponse
+	if response := responses["2"]; response == nil || decodeData(response, &cancelled) != nil || !cancelled.Cancelled {
+		t.Errorf("Expected CANCEL to report the request cancelled, got %+v", response)
+	}
+
+	response := host.exchange(`{"id":"3","type":"CANCEL","data":{"requestId":"unknown"}}`)
+	if decodeData(response, &cancelled) != nil || cancelled.Cancelled {
+		t.Errorf("Expected CANCEL of an unknown request to cancel nothing, got %+v", response)
+	}
+}
+
+func TestHostRefusesDuplicateRequests(t *testing.T) {
+	host, handler := startBlockingHost(t, DefaultNativeHostConfig())
+
+	host.send(`{"id":"1","type":"SLOW"}`)
+	handler.expectStarted(t, "1")
+	if response := host.exchange(`{"id":"1","type":"QUICK"}`); response.Code != ErrDuplicateRequest {
+		t.Errorf("Expected a second request with the ID to be refused, got %+v", response)
+	}
+
+	handler.releaseOne()
+	if response := host.read(); response.ID != "1" || response.Type != "DONE" {
+		t.Errorf("Expected the first request to be answered, got %+v", response)
+	}
+}
+
+func TestHostStopsReadingWhenBusy(t *testing.T) {
+	config := DefaultNativeHostConfig()
+	config.Workers = 1
+	config.MaxPending = 1
+	host, handler := startBlockingHost(t, config)
+
+	host.send(`{"id":"1","type":"SLOW"}`)
+	handler.expectStarted(t, "1")
+
+	// The host reads the second request, then waits for the first to be answered
+	written := make(chan error, 2)
+	go func() {
+		written <- WriteFrame(host.input, []byte(`{"id":"2","type":"SLOW"}`))
+		written <- WriteFrame(host.input, []byte(`{"id":"3","type":"SLOW"}`))
+	}()
+	if err := <-written; err != nil {
+		t.Fatalf("Expected the second request to be read, got %v", err)
+	}
+	select {
+	case err := <-written:
+		t.Fatalf("Expected the third request to wait, got %v", err)
+	case <-time.After(idleWait):
+	}
+
+	handler.releaseAll()
+	if response := host.read(); response.ID != "1" {
+		t.Fatalf("Expected the first request to be answered first, got %+v", response)
+	}
+	if err := <-written; err != nil {
+		t.Fatalf("Expected the third request to be read once the first was answered, got %v", err)
+	}
+	for id, response := range host.readResponses(2) {
+		if response.Type != "DONE" {
+			t.Errorf("Expected request %s to be answered, got %+v", id, response)
+		}
+	}
+}
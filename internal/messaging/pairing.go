@@ -0,0 +1,325 @@
+package messaging
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Pairing is an extension allowed to send sensitive messages. The extension
+// keeps the secret and proves it holds it with AUTHENTICATE.
+type Pairing struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Origin    string    `json:"origin,omitempty"` // Extension that paired, as reported by the browser
+	Secret    string    `json:"secret"`           // Base64 HMAC key
+	CreatedAt time.Time `json:"createdAt"`
+	LastUsed  time.Time `json:"-"` // Last pairing or authentication, kept as the file's modification time
+}
+
+// pairingCode is a one-time code waiting for an extension
+type pairingCode struct {
+	Name      string    `json:"name"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// pairingCodeAlphabet leaves out letters and digits that are easily confused
+const pairingCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// PairingStore keeps pairing codes and paired extensions, one file each, so
+// the pair command and a running host never rewrite the same file. Codes
+// are stored by hash and used up by removing their file.
+type PairingStore struct {
+	dir string
+	now func() time.Time
+}
+
+// NewPairingStore creates a pairing store in dir
+func NewPairingStore(dir string) *PairingStore {
+	return &PairingStore{dir: dir, now: time.Now}
+}
+
+func (s *PairingStore) codesDir() string {
+	return filepath.Join(s.dir, "codes")
+}
+
+func (s *PairingStore) extensionsDir() string {
+	return filepath.Join(s.dir, "extensions")
+}
+
+// CreateCode issues a one-time pairing code valid for ttl. The name labels
+// the extension that uses it.
+func (s *PairingStore) CreateCode(name string, ttl time.Duration) (string, time.Time, error) {
+	s.pruneCodes()
+
+	// Bytes past the last whole multiple of the alphabet are skipped so every character is equally likely
+	limit := byte(256 / len(pairingCodeAlphabet) * len(pairingCodeAlphabet))
+	code := make([]byte, 0, 8)
+	raw := make([]byte, 16)
+	for len(code) < cap(code) {
+		if _, err := rand.Read(raw); err != nil {
+			return "", time.Time{}, fmt.Errorf("failed to generate code: %w", err)
+		}
+		for _, b := range raw {
+			if b < limit && len(code) < cap(code) {
+				code = append(code, pairingCodeAlphabet[int(b)%len(pairingCodeAlphabet)])
+			}
+		}
+	}
+
+	expiresAt := s.now().Add(ttl)
+	if err := writePrivateJSON(s.codePath(string(code)), pairingCode{Name: name, ExpiresAt: expiresAt}); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to save code: %w", err)
+	}
+	return string(code[:4]) + "-" + string(code[4:]), expiresAt, nil
+}
+
+// codePath returns the file of a code, named by its hash
+func (s *PairingStore) codePath(code string) string {
+	sum := sha256.Sum256([]byte(normalizePairingCode(code)))
+	return filepath.Join(s.codesDir(), hex.EncodeToString(sum[:])+".json")
+}
+
+// normalizePairingCode accepts codes typed in lower case or without the dash
+func normalizePairingCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(code))
+}
+
+// CompletePairing uses up a code and pairs the extension that sent it
+func (s *PairingStore) CompletePairing(code, origin, name string) (*Pairing, error) {
+	path := s.codePath(code)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, NewProtocolError(ErrUnauthorized, "invalid pairing code")
+	}
+	// Only one caller can remove the file, so a code pairs at most once
+	if err := os.Remove(path); err != nil {
+		return nil, NewProtocolError(ErrUnauthorized, "invalid pairing code")
+	}
+
+	var pending pairingCode
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return nil, fmt.Errorf("failed to read pairing code: %w", err)
+	}
+	if s.now().After(pending.ExpiresAt) {
+		return nil, NewProtocolError(ErrUnauthorized, "pairing code expired")
+	}
+
+	if pending.Name != "" {
+		name = pending.Name
+	}
+	pairing := &Pairing{
+		ID:        randomToken(9),
+		Name:      name,
+		Origin:    origin,
+		Secret:    base64.StdEncoding.EncodeToString(randomBytes(32)),
+		CreatedAt: s.now(),
+	}
+	if err := s.save(pairing); err != nil {
+		return nil, err
+	}
+	return pairing, nil
+}
+
+// Verify checks an AUTHENTICATE signature: the base64 HMAC-SHA256 of the
+// challenge keyed with the pairing's secret. A pairing made by another
+// extension is refused when the browser reports the caller.
+func (s *PairingStore) Verify(pairingID, origin, challenge, signature string) (*Pairing, error) {
+	pairing, err := s.Get(pairingID)
+	if err != nil {
+		return nil, err
+	}
+	if pairing.Origin != "" && origin != "" && pairing.Origin != origin {
+		return nil, NewProtocolError(ErrUnauthorized, "pairing %s belongs to another extension", pairingID)
+	}
+
+	given, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(given, SignChallenge(pairing.Secret, challenge)) {
+		return nil, NewProtocolError(ErrUnauthorized, "invalid signature")
+	}
+
+	// Touching the file instead of rewriting it can't bring back a pairing revoked meanwhile
+	now := s.now()
+	if err := os.Chtimes(s.pairingPath(pairingID), now, now); err != nil {
+		return nil, NewProtocolError(ErrUnauthorized, "unknown or revoked pairing %s", pairingID)
+	}
+	pairing.LastUsed = now
+	return pairing, nil
+}
+
+// SignChallenge returns the HMAC-SHA256 of a challenge keyed with a
+// pairing secret, as the extension computes it
+func SignChallenge(secret, challenge string) []byte {
+	key, _ := base64.StdEncoding.DecodeString(secret)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(challenge))
+	return mac.Sum(nil)
+}
+
+// Get returns a paired extension
+func (s *PairingStore) Get(pairingID string) (*Pairing, error) {
+	if pairingID == "" || strings.ContainsAny(pairingID, `/\.`) {
+		return nil, NewProtocolError(ErrUnauthorized, "unknown pairing %q", pairingID)
+	}
+
+	path := s.pairingPath(pairingID)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, NewProtocolError(ErrUnauthorized, "unknown or revoked pairing %s", pairingID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pairing: %w", err)
+	}
+
+	var pairing Pairing
+	if err := json.Unmarshal(data, &pairing); err != nil {
+		return nil, fmt.Errorf("failed to read pairing: %w", err)
+	}
+	if info, err := os.Stat(path); err == nil {
+		pairing.LastUsed = info.ModTime()
+	}
+	return &pairing, nil
+}
+
+// List returns the paired extensions, oldest first
+func (s *PairingStore) List() ([]*Pairing, error) {
+	entries, err := os.ReadDir(s.extensionsDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pairings: %w", err)
+	}
+
+	var pairings []*Pairing
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		pairing, err := s.Get(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		pairings = append(pairings, pairing)
+	}
+
+	sort.Slice(pairings, func(i, j int) bool {
+		return pairings[i].CreatedAt.Before(pairings[j].CreatedAt)
+	})
+	return pairings, nil
+}
+
+// Revoke removes a paired extension. A connected extension loses access
+// with its next sensitive message.
+func (s *PairingStore) Revoke(pairingID string) error {
+	if _, err := s.Get(pairingID); err != nil {
+		return err
+	}
+	if err := os.Remove(s.pairingPath(pairingID)); err != nil {
+		return fmt.Errorf("failed to revoke pairing: %w", err)
+	}
+	return nil
+}
+
+func (s *PairingStore) pairingPath(pairingID string) string {
+	return filepath.Join(s.extensionsDir(), pairingID+".json")
+}
+
+func (s *PairingStore) save(pairing *Pairing) error {
+	if err := writePrivateJSON(s.pairingPath(pairing.ID), pairing); err != nil {
+		return fmt.Errorf("failed to save pairing: %w", err)
+	}
+	return nil
+}
+
+// pruneCodes removes expired codes that were never used
+func (s *PairingStore) pruneCodes() {
+	entries, err := os.ReadDir(s.codesDir())
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		path := filepath.Join(s.codesDir(), entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var pending pairingCode
+		if json.Unmarshal(data, &pending) == nil && s.now().After(pending.ExpiresAt) {
+			os.Remove(path)
+		}
+	}
+}
+
+// writePrivateJSON writes a file only the user can read, atomically
+func writePrivateJSON(path string, value interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), ".pairing-*")
+	if err != nil {
+		return err
+	}
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+	temp.Close()
+
+	if err := os.Rename(temp.Name(), path); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	return nil
+}
+
+// randomToken returns n random bytes as URL-safe base64
+func randomToken(n int) string {
+	return base64.RawURLEncoding.EncodeToString(randomBytes(n))
+}
+
+// randomBytes returns n random bytes. The system's random source does not
+// fail on supported platforms.
+func randomBytes(n int) []byte {
+	raw := make([]byte, n)
+	if _, err := rand.Read(raw); err != nil {
+		panic(fmt.Sprintf("failed to read random bytes: %v", err))
+	}
+	return raw
+}
+
+// CallerOrigin returns the extension that started the host from the
+// arguments the browser passes: the origin for Chromium browsers, the
+// manifest path and the add-on ID for Firefox
+func CallerOrigin(args []string) string {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "chrome-extension://") {
+			return strings.TrimSuffix(arg, "/")
+		}
+	}
+	if len(args) >= 2 && firefoxExtensionID.MatchString(args[1]) {
+		return "moz-extension:" + args[1]
+	}
+	return ""
+}
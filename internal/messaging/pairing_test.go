@@ -0,0 +1,259 @@
+package messaging
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	pairedOrigin = "chrome-extension://abcdefghijklmnopabcdefghijklmnop"
+	otherOrigin  = "chrome-extension://ponmlkjihgfedcbaponmlkjihgfedcba"
+)
+
+// pipeHost is a running host spoken to through pipes
+type pipeHost struct {
+	t      *testing.T
+	host   *NativeHost
+	input  *io.PipeWriter
+	output *io.PipeReader
+	next   int // Numbers request IDs
+}
+
+// id returns a request ID not used before on the host. An ID can be used
+// again only once the host has finished with its request.
+func (p *pipeHost) id() string {
+	p.next++
+	return fmt.Sprintf("%d", p.next)
+}
+
+// startPipeHost starts a host created by newHost and stops it when the test ends
+func startPipeHost(t *testing.T, newHost func(io.Reader, io.Writer) *NativeHost) *pipeHost {
+	inputReader, inputWriter := io.Pipe()
+	outputReader, outputWriter := io.Pipe()
+	p := &pipeHost{t: t, host: newHost(inputReader, outputWriter), input: inputWriter, output: outputReader}
+
+	done := make(chan struct{})
+	go func() {
+		p.host.Start()
+		close(done)
+	}()
+	t.Cleanup(func() {
+		inputWriter.Close()
+		outputReader.Close()
+		<-done
+	})
+	return p
+}
+
+// send writes a request without waiting for anything
+func (p *pipeHost) send(request string) {
+	if err := WriteFrame(p.input, []byte(request)); err != nil {
+		p.t.Fatalf("Expected to send %s, got %v", request, err)
+	}
+}
+
+// read returns the next message the host writes
+func (p *pipeHost) read() *NativeMessage {
+	data, err := ReadFrame(p.output)
+	if err != nil {
+		p.t.Fatalf("Expected a message, got %v", err)
+	}
+	var msg NativeMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		p.t.Fatalf("Expected a JSON message, got %q", data)
+	}
+	return &msg
+}
+
+// exchange sends a request and returns the next message
+func (p *pipeHost) exchange(request string) *NativeMessage {
+	p.send(request)
+	return p.read()
+}
+
+// startPairingHost starts a host checking pairings in store for an
+// extension with the given origin
+func startPairingHost(t *testing.T, store *PairingStore, origin string) *pipeHost {
+	return startPipeHost(t, func(input io.Reader, output io.Writer) *NativeHost {
+		config := DefaultNativeHostConfig()
+		config.Pairings = store
+		config.Origin = origin
+		host := newProfileHost(newMemoryProfiles("paired"), config)(input, output)
+		pairingHandler := NewPairingHandler(store)
+		host.RegisterHandler("PAIR", pairingHandler)
+		host.RegisterHandler("AUTHENTICATE", pairingHandler)
+		return host
+	})
+}
+
+// challenge completes a handshake and returns the challenge to sign
+func (p *pipeHost) challenge() string {
+	response := p.exchange(`{"id":"` + p.id() + `","type":"HANDSHAKE","data":{"protocolVersion":4}}`)
+	var handshake HandshakeResponse
+	if err := decodeData(response, &handshake); err != nil || handshake.Challenge == "" {
+		p.t.Fatalf("Expected a challenge, got %+v", response)
+	}
+	return handshake.Challenge
+}
+
+// authenticate sends AUTHENTICATE with a signed challenge
+func (p *pipeHost) authenticate(pairingID, signature string) *NativeMessage {
+	return p.exchange(`{"id":"` + p.id() + `","type":"AUTHENTICATE","data":{"pairingId":"` + pairingID + `","signature":"` + signature + `"}}`)
+}
+
+// sign signs a challenge with a pairing's secret
+func sign(secret, challenge string) string {
+	return base64.StdEncoding.EncodeToString(SignChallenge(secret, challenge))
+}
+
+func TestPairingCodeWorksOnce(t *testing.T) {
+	store := NewPairingStore(t.TempDir())
+	code, _, err := store.CreateCode("laptop", time.Minute)
+	if err != nil {
+		t.Fatalf("Expected a code, got %v", err)
+	}
+
+	// Codes may be typed in lower case and without the dash
+	pairing, err := store.CompletePairing(strings.ToLower(strings.Replace(code, "-", "", 1)), pairedOrigin, "ignored")
+	if err != nil {
+		t.Fatalf("Expected the code to pair, got %v", err)
+	}
+	if pairing.Name != "laptop" || pairing.Origin != pairedOrigin || pairing.Secret == "" {
+		t.Errorf("Expected a pairing named by the code for the origin, got %+v", pairing)
+	}
+	if stored, err := store.Get(pairing.ID); err != nil || stored.Secret != pairing.Secret {
+		t.Errorf("Expected the pairing to be stored, got %+v, %v", stored, err)
+	}
+
+	if _, err := store.CompletePairing(code, pairedOrigin, ""); ErrorCode(err) != ErrUnauthorized {
+		t.Errorf("Expected a used code to be refused, got %v", err)
+	}
+}
+
+func TestPairingCodeExpires(t *testing.T) {
+	store := NewPairingStore(t.TempDir())
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	code, expiresAt, err := store.CreateCode("", 5*time.Minute)
+	if err != nil {
+		t.Fatalf("Expected a code, got %v", err)
+	}
+	if !expiresAt.Equal(now.Add(5 * time.Minute)) {
+		t.Errorf("Expected the code to expire in 5 minutes, got %v", expiresAt)
+	}
+
+	now = now.Add(6 * time.Minute)
+	_, err = store.CompletePairing(code, pairedOrigin, "")
+	if ErrorCode(err) != ErrUnauthorized || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("Expected an expired code to be refused, got %v", err)
+	}
+	if pairings, _ := store.List(); len(pairings) != 0 {
+		t.Errorf("Expected no pairing, got %d", len(pairings))
+	}
+}
+
+func TestPairingLimitsWrongCodes(t *testing.T) {
+	store := NewPairingStore(t.TempDir())
+	code, _, err := store.CreateCode("", time.Minute)
+	if err != nil {
+		t.Fatalf("Expected a code, got %v", err)
+	}
+
+	host := startPairingHost(t, store, pairedOrigin)
+	for i := 0; i < maxPairingFailures; i++ {
+		response := host.exchange(`{"id":"` + host.id() + `","type":"PAIR","data":{"code":"AAAA-AAAA"}}`)
+		if response.Code != ErrUnauthorized || !strings.Contains(response.Error, "invalid pairing code") {
+			t.Fatalf("Expected a wrong code to be refused, got %+v", response)
+		}
+	}
+
+	// Once the limit is reached, even the right code is refused on this connection
+	response := host.exchange(`{"id":"` + host.id() + `","type":"PAIR","data":{"code":"` + code + `"}}`)
+	if response.Code != ErrUnauthorized || !strings.Contains(response.Error, "too many") {
+		t.Errorf("Expected PAIR to be refused after %d wrong codes, got %+v", maxPairingFailures, response)
+	}
+
+	response = startPairingHost(t, store, pairedOrigin).exchange(`{"id":"1","type":"PAIR","data":{"code":"` + code + `"}}`)
+	var paired PairResponse
+	if response.Type != "PAIRED" || decodeData(response, &paired) != nil || paired.Token == "" {
+		t.Errorf("Expected the code to pair on a new connection, got %+v", response)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	store := NewPairingStore(t.TempDir())
+	code, _, _ := store.CreateCode("", time.Minute)
+	pairing, err := store.CompletePairing(code, pairedOrigin, "")
+	if err != nil {
+		t.Fatalf("Expected to pair, got %v", err)
+	}
+
+	host := startPairingHost(t, store, pairedOrigin)
+	if response := host.authenticate(pairing.ID, sign(pairing.Secret, "x")); response.Code != ErrUnauthorized {
+		t.Errorf("Expected AUTHENTICATE before a handshake to be refused, got %+v", response)
+	}
+
+	challenge := host.challenge()
+	if response := host.authenticate(pairing.ID, sign(base64.StdEncoding.EncodeToString([]byte("wrong secret")), challenge)); response.Code != ErrUnauthorized {
+		t.Errorf("Expected a bad signature to be refused, got %+v", response)
+	}
+
+	// A refused attempt uses up the challenge too
+	challenge = host.challenge()
+	signature := sign(pairing.Secret, challenge)
+	response := host.authenticate(pairing.ID, signature)
+	var authenticated AuthenticateResponse
+	if response.Type != "AUTHENTICATED" || decodeData(response, &authenticated) != nil || authenticated.Token == "" {
+		t.Fatalf("Expected a good signature to authenticate, got %+v", response)
+	}
+
+	if response := host.authenticate(pairing.ID, signature); response.Code != ErrUnauthorized {
+		t.Errorf("Expected a replayed signature to be refused, got %+v", response)
+	}
+
+	// Another extension can't use the pairing, even with its secret
+	other := startPairingHost(t, store, otherOrigin)
+	response = other.authenticate(pairing.ID, sign(pairing.Secret, other.challenge()))
+	if response.Code != ErrUnauthorized || !strings.Contains(response.Error, "another extension") {
+		t.Errorf("Expected a pairing from another origin to be refused, got %+v", response)
+	}
+}
+
+func TestSensitiveMessagesNeedToken(t *testing.T) {
+	store := NewPairingStore(t.TempDir())
+	code, _, _ := store.CreateCode("", time.Minute)
+	host := startPairingHost(t, store, pairedOrigin)
+
+	if response := host.exchange(`{"id":"1","type":"GET_PROFILES"}`); response.Code != ErrUnauthorized {
+		t.Errorf("Expected GET_PROFILES without a token to be refused, got %+v", response)
+	}
+	if response := host.exchange(`{"id":"2","type":"HANDSHAKE"}`); response.Type != "HANDSHAKE_RESPONSE" {
+		t.Errorf("Expected HANDSHAKE to need no token, got %+v", response)
+	}
+
+	var paired PairResponse
+	decodeData(host.exchange(`{"id":"3","type":"PAIR","data":{"code":"`+code+`"}}`), &paired)
+	if response := host.exchange(`{"id":"4","type":"GET_PROFILES","token":"not-the-token"}`); response.Code != ErrUnauthorized {
+		t.Errorf("Expected a wrong token to be refused, got %+v", response)
+	}
+	if response := host.exchange(`{"id":"5","type":"GET_PROFILES","token":"` + paired.Token + `"}`); response.Type != "PROFILES_RESPONSE" {
+		t.Errorf("Expected the session token to be accepted, got %+v", response)
+	}
+
+	// Revoking the pairing ends the session
+	if err := store.Revoke(paired.PairingID); err != nil {
+		t.Fatalf("Expected to revoke the pairing, got %v", err)
+	}
+	if response := host.exchange(`{"id":"6","type":"GET_PROFILES","token":"` + paired.Token + `"}`); response.Code != ErrUnauthorized {
+		t.Errorf("Expected the token of a revoked pairing to be refused, got %+v", response)
+	}
+	if _, err := store.Get(paired.PairingID); ErrorCode(err) != ErrUnauthorized {
+		t.Errorf("Expected the revoked pairing to be gone, got %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+package messaging
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Protocol versions. The host speaks every version from MinProtocolVersion
+// to ProtocolVersion; an extension that sends none speaks version 1.
+// Version 2 added error codes, request validation and negotiated
+// capabilities; version 1 extensions still work but ignore them. Version 3
+// added pairing: sensitive messages need a paired, authenticated extension.
+// Version 4 added CHUNK frames for messages larger than one frame.
+const (
+	ProtocolVersion    = 4
+	MinProtocolVersion = 1
+)
+
+// Capabilities the host offers in a handshake
+const (
+	CapabilityFormFilling       = "form_filling"
+	CapabilityTraining          = "training"
+	CapabilityProfileManagement = "profile_management"
+	CapabilityFillProgress      = "fill_progress"     // FILL_PROGRESS, FILL_STEP and FILL_DONE events
+	CapabilityExecutionUpdates  = "execution_updates" // EXECUTION_UPDATE events
+	CapabilityPairing           = "pairing"           // PAIR and AUTHENTICATE
+	CapabilityChunking          = "chunking"          // CHUNK frames both ways
+)
+
+// HostCapabilities lists every capability the host offers
+var HostCapabilities = []string{
+	CapabilityFormFilling,
+	CapabilityTraining,
+	CapabilityProfileManagement,
+	CapabilityFillProgress,
+	CapabilityExecutionUpdates,
+	CapabilityPairing,
+	CapabilityChunking,
+}
+
+// Error codes sent in the code of ERROR responses
+const (
+	ErrInvalidMessage     = "INVALID_MESSAGE"     // The frame is not a JSON message
+	ErrUnknownType        = "UNKNOWN_TYPE"        // No handler for the message type
+	ErrInvalidRequest     = "INVALID_REQUEST"     // The data does not match the request's schema
+	ErrUnsupportedVersion = "UNSUPPORTED_VERSION" // No protocol version both sides speak
+	ErrDuplicateRequest   = "DUPLICATE_REQUEST"   // A request with the same ID is in progress
+	ErrUnauthorized       = "UNAUTHORIZED"        // Pairing or authentication failed, or is needed first
+	ErrTooLarge           = "TOO_LARGE"           // A message is too large to send or to join from CHUNKs
+	ErrNotFound           = "NOT_FOUND"           // A profile, template or subscription does not exist
+	ErrTimeout            = "TIMEOUT"             // The request took longer than its timeout
+	ErrCancelled          = "CANCELLED"           // The request was cancelled or the host stopped
+	ErrUnavailable        = "UNAVAILABLE"         // The host can't do this here, such as start a browser
+	ErrInternal           = "INTERNAL"            // Anything else
+)
+
+// ErrorCodes lists every error code
+var ErrorCodes = []string{
+	ErrInvalidMessage,
+	ErrUnknownType,
+	ErrInvalidRequest,
+	ErrUnsupportedVersion,
+	ErrDuplicateRequest,
+	ErrUnauthorized,
+	ErrTooLarge,
+	ErrNotFound,
+	ErrTimeout,
+	ErrCancelled,
+	ErrUnavailable,
+	ErrInternal,
+}
+
+// FieldError describes one way a request's data does not match its schema
+type FieldError struct {
+	Path    string `json:"path"` // Such as data.fields[2].selector
+	Reason  string `json:"reason" schema:"enum=required|type|enum|minimum|minLength|minItems"`
+	Message string `json:"message"`
+}
+
+// ProtocolError is an error with a code the extension can act on. Handlers
+// and services return it, possibly wrapped, to choose the response's code.
+type ProtocolError struct {
+	Code    string
+	Message string
+	Details []FieldError
+}
+
+// NewProtocolError creates a protocol error with a formatted message
+func NewProtocolError(code, format string, args ...interface{}) *ProtocolError {
+	return &ProtocolError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *ProtocolError) Error() string {
+	return e.Message
+}
+
+// ErrorCode returns the code of the first protocol error in err's chain,
+// or ErrInternal
+func ErrorCode(err error) string {
+	var protocolErr *ProtocolError
+	if errors.As(err, &protocolErr) {
+		return protocolErr.Code
+	}
+	return ErrInternal
+}
+
+// errorDetails returns the field errors of the first protocol error in err's chain
+func errorDetails(err error) []FieldError {
+	var protocolErr *ProtocolError
+	if errors.As(err, &protocolErr) {
+		return protocolErr.Details
+	}
+	return nil
+}
+
+// Session holds what the host and the extension agreed in their handshake.
+// Until a handshake, version 1 and every capability are assumed, as
+// version 1 extensions never negotiate.
+type Session struct {
+	mu           sync.RWMutex
+	version      int
+	capabilities map[string]bool // Nil until negotiated
+	origin       string          // Extension that started the host, if the browser said
+
+	challenge string // Signed by AUTHENTICATE; one per handshake
+	pairingID string // Set once the extension authenticated
+	token     string // Carried by sensitive messages once authenticated
+}
+
+// newSession creates a session for an extension that has not shaken hands
+func newSession(origin string) *Session {
+	return &Session{version: MinProtocolVersion, origin: origin}
+}
+
+// sessionKey holds the Session of a request's context
+type sessionKey struct{}
+
+// SessionFromContext returns the session of the connection a handler is
+// answering, or nil outside a handler
+func SessionFromContext(ctx context.Context) *Session {
+	session, _ := ctx.Value(sessionKey{}).(*Session)
+	return session
+}
+
+// Negotiate agrees on the highest protocol version both sides speak and the
+// capabilities both want. An extension that asks for no capabilities gets
+// every one. The agreed version and capabilities are returned.
+func (s *Session) Negotiate(version int, wanted []string) (int, []string, error) {
+	if version == 0 {
+		version = MinProtocolVersion
+	}
+	if version < MinProtocolVersion {
+		return 0, nil, NewProtocolError(ErrUnsupportedVersion, "protocol version %d is not supported; the host speaks %d to %d",
+			version, MinProtocolVersion, ProtocolVersion)
+	}
+	version = min(version, ProtocolVersion)
+
+	agreed := make([]string, 0, len(HostCapabilities))
+	if len(wanted) == 0 {
+		agreed = append(agreed, HostCapabilities...)
+	} else {
+		wants := make(map[string]bool, len(wanted))
+		for _, capability := range wanted {
+			wants[capability] = true
+		}
+		for _, capability := range HostCapabilities {
+			if wants[capability] {
+				agreed = append(agreed, capability)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version = version
+	s.capabilities = make(map[string]bool, len(agreed))
+	for _, capability := range agreed {
+		s.capabilities[capability] = true
+	}
+	return version, agreed, nil
+}
+
+// Version returns the agreed protocol version
+func (s *Session) Version() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.version
+}
+
+// Enabled reports whether a capability was agreed. An empty capability is
+// always enabled.
+func (s *Session) Enabled(capability string) bool {
+	if capability == "" {
+		return true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.capabilities == nil || s.capabilities[capability]
+}
+
+// Origin returns the extension that started the host, or "" when the
+// browser did not say
+func (s *Session) Origin() string {
+	return s.origin
+}
+
+// newChallenge replaces the challenge AUTHENTICATE signs
+func (s *Session) newChallenge() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.challenge = randomToken(32)
+	return s.challenge
+}
+
+// takeChallenge returns the challenge and clears it so a signature can't be replayed
+func (s *Session) takeChallenge() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	challenge := s.challenge
+	s.challenge = ""
+	return challenge
+}
+
+// signIn authenticates the session as a pairing and returns its new token
+func (s *Session) signIn(pairingID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pairingID = pairingID
+	s.token = randomToken(32)
+	return s.token
+}
+
+// signOut forgets the session's authentication
+func (s *Session) signOut() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pairingID = ""
+	s.token = ""
+}
+
+// authorized returns the pairing a token authenticates
+func (s *Session) authorized(token string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+		return "", false
+	}
+	return s.pairingID, true
+}
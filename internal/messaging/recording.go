@@ -0,0 +1,151 @@
+package messaging
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Frame directions in recordings
+const (
+	FrameIn  = "in"  // From the extension to the host
+	FrameOut = "out" // From the host to the extension
+)
+
+// RecordedFrame is one message of a recorded session, kept as a line of
+// JSON. Frames that are not JSON, which the host answers with
+// INVALID_MESSAGE, are kept as Raw.
+type RecordedFrame struct {
+	Direction string          `json:"dir"`
+	Elapsed   int64           `json:"ms"` // Milliseconds since recording started
+	Message   json.RawMessage `json:"message,omitempty"`
+	Raw       string          `json:"raw,omitempty"`
+}
+
+// Data returns the frame as it was sent
+func (f *RecordedFrame) Data() []byte {
+	if f.Message == nil {
+		return []byte(f.Raw)
+	}
+	return f.Message
+}
+
+// redactedFields are the fields of each message type that carry pairing
+// secrets, which are not written to recordings
+var redactedFields = map[string][]string{
+	"PAIR":          {"code"},
+	"PAIRED":        {"secret", "token"},
+	"AUTHENTICATE":  {"signature"},
+	"AUTHENTICATED": {"token"},
+}
+
+// redactedValue replaces secrets in recordings
+const redactedValue = "[redacted]"
+
+// Recorder writes the frames a host reads and writes as JSON lines.
+// Messages the host splits into CHUNKs are written whole. Session tokens
+// and pairing secrets are redacted.
+type Recorder struct {
+	mu    sync.Mutex
+	w     io.Writer
+	start time.Time
+	err   error
+}
+
+// NewRecorder creates a recorder writing to w
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: w, start: time.Now()}
+}
+
+// Err returns the first error writing the recording
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// record writes a frame. A failing recording never interrupts the host.
+func (r *Recorder) record(direction string, data []byte) {
+	frame := RecordedFrame{Direction: direction}
+
+	var message map[string]json.RawMessage
+	if json.Unmarshal(data, &message) == nil && message != nil {
+		frame.Message = redact(message, data)
+	} else {
+		frame.Raw = string(data)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+
+	frame.Elapsed = time.Since(r.start).Milliseconds()
+	line, err := json.Marshal(frame)
+	if err != nil {
+		r.err = fmt.Errorf("failed to encode frame: %w", err)
+		return
+	}
+	if _, err := r.w.Write(append(line, '\n')); err != nil {
+		r.err = fmt.Errorf("failed to write recording: %w", err)
+	}
+}
+
+// redact returns a message without its session token and pairing secrets
+func redact(message map[string]json.RawMessage, data []byte) json.RawMessage {
+	changed := false
+	if _, ok := message["token"]; ok {
+		message["token"], _ = json.Marshal(redactedValue)
+		changed = true
+	}
+
+	var messageType string
+	json.Unmarshal(message["type"], &messageType)
+	if fields := redactedFields[messageType]; len(fields) > 0 {
+		var payload map[string]json.RawMessage
+		if json.Unmarshal(message["data"], &payload) == nil && payload != nil {
+			for _, field := range fields {
+				if _, ok := payload[field]; ok {
+					payload[field], _ = json.Marshal(redactedValue)
+				}
+			}
+			message["data"], _ = json.Marshal(payload)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return data
+	}
+	redacted, _ := json.Marshal(message)
+	return redacted
+}
+
+// ReadRecording reads the frames written by a Recorder
+func ReadRecording(r io.Reader) ([]RecordedFrame, error) {
+	var frames []RecordedFrame
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 2*defaultMaxTransferSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var frame RecordedFrame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			return nil, fmt.Errorf("failed to parse recording line %d: %w", line, err)
+		}
+		if frame.Direction != FrameIn && frame.Direction != FrameOut {
+			return nil, fmt.Errorf("failed to parse recording line %d: unknown direction %q", line, frame.Direction)
+		}
+		frames = append(frames, frame)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	return frames, nil
+}
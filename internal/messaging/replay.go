@@ -0,0 +1,397 @@
+package messaging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReplayIgnoredFields hold times and one-off values that differ on every
+// run. Replays compare only whether they are present.
+var ReplayIgnoredFields = []string{
+	"timestamp", "uptime", "challenge",
+	"createdAt", "updatedAt", "lastUpdated", "savedAt", "checkedAt",
+	"startTime", "endTime", "analysisTime", "executionTime", "submissionTime",
+}
+
+// ReplayConfig controls how a recording is replayed
+type ReplayConfig struct {
+	StepTimeout time.Duration // How long to wait for the messages recorded before each request
+	Ignore      []string      // Fields, at any depth, whose values are not compared
+}
+
+// DefaultReplayConfig returns default replay configuration
+func DefaultReplayConfig() *ReplayConfig {
+	return &ReplayConfig{
+		StepTimeout: 30 * time.Second,
+		Ignore:      ReplayIgnoredFields,
+	}
+}
+
+// ReplayDiff is a recorded message the replayed host did not send alike
+type ReplayDiff struct {
+	Frame    int         `json:"frame"` // Recorded frame, from 1; 0 for a message that was not recorded
+	ID       string      `json:"id,omitempty"`
+	Type     string      `json:"type"`
+	Path     string      `json:"path,omitempty"` // Field that differs; empty when the whole message does
+	Recorded interface{} `json:"recorded,omitempty"`
+	Replayed interface{} `json:"replayed,omitempty"`
+}
+
+func (d ReplayDiff) String() string {
+	message := d.Type
+	if d.ID != "" {
+		message += " " + d.ID
+	}
+
+	switch {
+	case d.Frame == 0:
+		return fmt.Sprintf("unexpected %s: %s", message, compactJSON(d.Replayed))
+	case d.Path == "" && d.Replayed == nil:
+		return fmt.Sprintf("frame %d: %s was not sent", d.Frame, message)
+	default:
+		return fmt.Sprintf("frame %d: %s: %s: recorded %s, replayed %s", d.Frame, message, d.Path, compactJSON(d.Recorded), compactJSON(d.Replayed))
+	}
+}
+
+// ReplayResult summarises a replay
+type ReplayResult struct {
+	Requests int          `json:"requests"` // Frames sent to the host
+	Messages int          `json:"messages"` // Recorded messages the host sent again
+	Skipped  int          `json:"skipped"`  // Pairing frames, whose secrets are not recorded
+	Diffs    []ReplayDiff `json:"diffs"`
+}
+
+// expectedMessage is a recorded message the replayed host has yet to send
+type expectedMessage struct {
+	frame   int
+	message map[string]interface{}
+}
+
+// replayer compares what a host sends with a recording
+type replayer struct {
+	config      *ReplayConfig
+	ignore      map[string]bool
+	ids         map[string]string // Recorded IDs the host generated again, such as profile IDs, and their replayed values
+	expected    map[string][]expectedMessage
+	outstanding int
+	skippedIDs  map[string]bool
+	result      *ReplayResult
+}
+
+// Replay sends the requests of a recording to the host newHost creates
+// over the given streams and compares what it sends back. Each request is
+// sent once the messages recorded before it arrived or StepTimeout passed.
+// Messages are matched by request ID, so concurrent responses may come in
+// any order. IDs the host generates, such as those of created profiles,
+// are learned from the first message they differ in and substituted in
+// later requests. Pairing frames are skipped: the host should be created
+// without a pairing store.
+func Replay(frames []RecordedFrame, newHost func(input io.Reader, output io.Writer) *NativeHost, config *ReplayConfig) (*ReplayResult, error) {
+	if config == nil {
+		config = DefaultReplayConfig()
+	}
+
+	r := &replayer{
+		config:     config,
+		ignore:     make(map[string]bool),
+		ids:        make(map[string]string),
+		expected:   make(map[string][]expectedMessage),
+		skippedIDs: make(map[string]bool),
+		result:     &ReplayResult{},
+	}
+	for _, field := range config.Ignore {
+		r.ignore[field] = true
+	}
+
+	inputReader, inputWriter := io.Pipe()
+	outputReader, outputWriter := io.Pipe()
+	host := newHost(inputReader, outputWriter)
+
+	hostDone := make(chan error, 1)
+	go func() {
+		err := host.Start()
+		// Requests left unsent fail instead of waiting for a host that stopped reading
+		inputReader.CloseWithError(io.ErrClosedPipe)
+		outputWriter.Close()
+		hostDone <- err
+	}()
+
+	// Messages sent as CHUNKs are recorded whole, so they are joined first
+	replies := make(chan []byte)
+	go func() {
+		defer close(replies)
+		chunks := newChunkAssembler(0)
+		for {
+			data, err := ReadFrame(outputReader)
+			if err != nil {
+				return
+			}
+
+			var msg NativeMessage
+			if json.Unmarshal(data, &msg) == nil && msg.Type == ChunkMessageType {
+				joined, err := chunks.add(&msg)
+				if err == nil && joined == nil {
+					continue
+				}
+				if err == nil {
+					data = joined
+				}
+			}
+			replies <- data
+		}
+	}()
+
+	for i, frame := range frames {
+		if frame.Direction == FrameOut {
+			r.expect(i+1, frame)
+			continue
+		}
+		if r.skip(frame) {
+			continue
+		}
+
+		r.wait(replies)
+		if err := WriteFrame(inputWriter, r.substitute(frame.Data())); err != nil {
+			break
+		}
+		r.result.Requests++
+	}
+	r.wait(replies)
+	inputWriter.Close()
+
+	// Give requests still running, such as subscriptions, one step to finish
+	timeout := time.NewTimer(config.StepTimeout)
+	defer timeout.Stop()
+	for done := false; !done; {
+		select {
+		case data, ok := <-replies:
+			if !ok {
+				done = true
+				break
+			}
+			r.match(data)
+		case <-timeout.C:
+			host.Stop()
+		}
+	}
+	err := <-hostDone
+
+	for _, queue := range r.expected {
+		for _, expected := range queue {
+			r.diff(expected, "", expected.message, nil)
+		}
+	}
+	sort.SliceStable(r.result.Diffs, func(i, j int) bool {
+		a, b := r.result.Diffs[i].Frame, r.result.Diffs[j].Frame
+		return a != 0 && (b == 0 || a < b)
+	})
+	return r.result, err
+}
+
+// skip reports whether a request is left out of the replay. Pairing
+// requests are, as their secrets are redacted, and so are their replies.
+func (r *replayer) skip(frame RecordedFrame) bool {
+	var message NativeMessage
+	if frame.Message == nil || json.Unmarshal(frame.Message, &message) != nil {
+		return false
+	}
+	if _, ok := redactedFields[message.Type]; !ok {
+		return false
+	}
+
+	if message.ID != "" {
+		r.skippedIDs[message.ID] = true
+	}
+	r.result.Skipped++
+	return true
+}
+
+// expect queues a recorded message for the host to send again
+func (r *replayer) expect(frame int, recorded RecordedFrame) {
+	var message map[string]interface{}
+	if json.Unmarshal(recorded.Data(), &message) != nil {
+		return
+	}
+
+	id, _ := message["id"].(string)
+	if id != "" && r.skippedIDs[id] {
+		r.result.Skipped++
+		return
+	}
+	r.expected[id] = append(r.expected[id], expectedMessage{frame: frame, message: message})
+	r.outstanding++
+}
+
+// wait matches what the host sends until every queued message arrived or
+// a step's time passed
+func (r *replayer) wait(replies <-chan []byte) {
+	timeout := time.NewTimer(r.config.StepTimeout)
+	defer timeout.Stop()
+
+	for r.outstanding > 0 {
+		select {
+		case data, ok := <-replies:
+			if !ok {
+				return
+			}
+			r.match(data)
+		case <-timeout.C:
+			return
+		}
+	}
+}
+
+// match compares a message from the host with the first recorded message
+// of the same request
+func (r *replayer) match(data []byte) {
+	var message map[string]interface{}
+	if err := json.Unmarshal(data, &message); err != nil {
+		r.result.Diffs = append(r.result.Diffs, ReplayDiff{Replayed: string(data)})
+		return
+	}
+
+	id, _ := message["id"].(string)
+	queue := r.expected[id]
+	if len(queue) == 0 {
+		messageType, _ := message["type"].(string)
+		r.result.Diffs = append(r.result.Diffs, ReplayDiff{ID: id, Type: messageType, Replayed: message})
+		return
+	}
+
+	expected := queue[0]
+	if len(queue) == 1 {
+		delete(r.expected, id)
+	} else {
+		r.expected[id] = queue[1:]
+	}
+	r.outstanding--
+	r.result.Messages++
+
+	// Fields of messages of another type are not worth comparing
+	if message["type"] != expected.message["type"] {
+		r.diff(expected, "type", expected.message["type"], message["type"])
+		return
+	}
+	r.compare(expected, "", "", expected.message, message)
+}
+
+// compare walks a recorded value and the replayed one and records where
+// they differ
+func (r *replayer) compare(expected expectedMessage, path, key string, recorded, replayed interface{}) {
+	if r.ignore[key] {
+		if (recorded == nil) != (replayed == nil) {
+			r.diff(expected, path, recorded, replayed)
+		}
+		return
+	}
+
+	switch recorded := recorded.(type) {
+	case map[string]interface{}:
+		replayed, ok := replayed.(map[string]interface{})
+		if !ok {
+			r.diff(expected, path, recorded, replayed)
+			return
+		}
+		keys := make([]string, 0, len(recorded)+len(replayed))
+		for name := range recorded {
+			keys = append(keys, name)
+		}
+		for name := range replayed {
+			if _, ok := recorded[name]; !ok {
+				keys = append(keys, name)
+			}
+		}
+		sort.Strings(keys)
+		for _, name := range keys {
+			r.compare(expected, joinPath(path, name), name, recorded[name], replayed[name])
+		}
+
+	case []interface{}:
+		replayed, ok := replayed.([]interface{})
+		if !ok {
+			r.diff(expected, path, recorded, replayed)
+			return
+		}
+		for i := 0; i < max(len(recorded), len(replayed)); i++ {
+			var a, b interface{}
+			if i < len(recorded) {
+				a = recorded[i]
+			}
+			if i < len(replayed) {
+				b = replayed[i]
+			}
+			r.compare(expected, fmt.Sprintf("%s[%d]", path, i), key, a, b)
+		}
+
+	case string:
+		replayed, ok := replayed.(string)
+		switch {
+		case !ok:
+			r.diff(expected, path, recorded, replayed)
+		case recorded == replayed:
+		case isIDField(key) && recorded != "":
+			if learned, ok := r.ids[recorded]; ok && learned != replayed {
+				r.diff(expected, path, learned, replayed)
+			}
+			r.ids[recorded] = replayed
+		case string(r.substitute([]byte(recorded))) != replayed:
+			r.diff(expected, path, recorded, replayed)
+		}
+
+	default:
+		if recorded != replayed {
+			r.diff(expected, path, recorded, replayed)
+		}
+	}
+}
+
+// diff records a difference in a message
+func (r *replayer) diff(expected expectedMessage, path string, recorded, replayed interface{}) {
+	id, _ := expected.message["id"].(string)
+	messageType, _ := expected.message["type"].(string)
+	r.result.Diffs = append(r.result.Diffs, ReplayDiff{
+		Frame:    expected.frame,
+		ID:       id,
+		Type:     messageType,
+		Path:     path,
+		Recorded: recorded,
+		Replayed: replayed,
+	})
+}
+
+// substitute replaces the recorded IDs learned so far with their replayed values
+func (r *replayer) substitute(data []byte) []byte {
+	for recorded, replayed := range r.ids {
+		data = bytes.ReplaceAll(data, []byte(recorded), []byte(replayed))
+	}
+	return data
+}
+
+// isIDField reports whether a field holds an ID, which a replayed host may
+// generate anew
+func isIDField(key string) bool {
+	return key == "id" || strings.HasSuffix(key, "Id") || strings.HasSuffix(key, "ID")
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func compactJSON(value interface{}) string {
+	if value == nil {
+		return "nothing"
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
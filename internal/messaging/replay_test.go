@@ -0,0 +1,175 @@
+package messaging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryProfiles is a profile service whose IDs start with a prefix, so a
+// replayed host generates other IDs than the recorded one
+type memoryProfiles struct {
+	prefix   string
+	suffix   string // Appended to names, to make replays differ
+	next     int
+	profiles map[string]map[string]interface{}
+}
+
+func newMemoryProfiles(prefix string) *memoryProfiles {
+	return &memoryProfiles{prefix: prefix, profiles: make(map[string]map[string]interface{})}
+}
+
+func (m *memoryProfiles) GetProfiles() ([]interface{}, error) {
+	var profiles []interface{}
+	for i := 1; i <= m.next; i++ {
+		if profile, ok := m.profiles[fmt.Sprintf("%s-%d", m.prefix, i)]; ok {
+			profiles = append(profiles, profile)
+		}
+	}
+	return profiles, nil
+}
+
+func (m *memoryProfiles) CreateProfile(data map[string]interface{}) (interface{}, error) {
+	m.next++
+	id := fmt.Sprintf("%s-%d", m.prefix, m.next)
+	m.profiles[id] = map[string]interface{}{"id": id, "name": fmt.Sprint(data["name"]) + m.suffix}
+	return m.profiles[id], nil
+}
+
+func (m *memoryProfiles) UpdateProfile(data map[string]interface{}) (interface{}, error) {
+	profile, ok := m.profiles[fmt.Sprint(data["id"])]
+	if !ok {
+		return nil, NewProtocolError(ErrNotFound, "profile not found")
+	}
+	profile["name"] = fmt.Sprint(data["name"]) + m.suffix
+	return profile, nil
+}
+
+func (m *memoryProfiles) DeleteProfile(profileID string) error {
+	delete(m.profiles, profileID)
+	return nil
+}
+
+func (m *memoryProfiles) GetProfile(profileID string) (interface{}, error) {
+	return m.profiles[profileID], nil
+}
+
+func newProfileHost(profiles *memoryProfiles, config *NativeHostConfig) func(io.Reader, io.Writer) *NativeHost {
+	return func(input io.Reader, output io.Writer) *NativeHost {
+		host := NewNativeHostWithIO(config, input, output)
+		host.RegisterHandler("HANDSHAKE", NewHandshakeHandler("test"))
+		handler := NewProfileHandler(profiles)
+		host.RegisterHandler("GET_PROFILES", handler)
+		host.RegisterHandler("CREATE_PROFILE", handler)
+		host.RegisterHandler("UPDATE_PROFILE", handler)
+		return host
+	}
+}
+
+// recordSession sends each request once the previous one was answered and
+// returns the recording
+func recordSession(t *testing.T, requests []string) []RecordedFrame {
+	var recording bytes.Buffer
+	config := DefaultNativeHostConfig()
+	config.Recorder = NewRecorder(&recording)
+
+	inputReader, inputWriter := io.Pipe()
+	outputReader, outputWriter := io.Pipe()
+	host := newProfileHost(newMemoryProfiles("recorded"), config)(inputReader, outputWriter)
+	done := make(chan error, 1)
+	go func() { done <- host.Start() }()
+
+	for _, request := range requests {
+		if err := WriteFrame(inputWriter, []byte(request)); err != nil {
+			t.Fatalf("Expected to send %s, got %v", request, err)
+		}
+		if _, err := ReadFrame(outputReader); err != nil {
+			t.Fatalf("Expected a response to %s, got %v", request, err)
+		}
+	}
+	inputWriter.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("Expected the host to stop cleanly, got %v", err)
+	}
+	if err := config.Recorder.Err(); err != nil {
+		t.Fatalf("Expected the recording to be written, got %v", err)
+	}
+
+	if strings.Contains(recording.String(), "s3cret") {
+		t.Errorf("Expected the session token to be redacted, got %s", recording.String())
+	}
+	frames, err := ReadRecording(&recording)
+	if err != nil {
+		t.Fatalf("Expected the recording to parse, got %v", err)
+	}
+	return frames
+}
+
+var profileSession = []string{
+	`{"id":"1","type":"HANDSHAKE","data":{"protocolVersion":3}}`,
+	`{"id":"2","type":"CREATE_PROFILE","token":"s3cret","data":{"name":"Jo"}}`,
+	`{not json`,
+	`{"id":"3","type":"UPDATE_PROFILE","data":{"id":"recorded-1","name":"Jo B"}}`,
+	`{"id":"4","type":"GET_PROFILES"}`,
+}
+
+func TestReplayMatchesRecording(t *testing.T) {
+	frames := recordSession(t, profileSession)
+	if len(frames) != 2*len(profileSession) {
+		t.Fatalf("Expected %d frames, got %d", 2*len(profileSession), len(frames))
+	}
+	if frames[4].Raw != "{not json" || frames[5].Direction != FrameOut {
+		t.Errorf("Expected the invalid frame kept raw and answered, got %+v and %+v", frames[4], frames[5])
+	}
+
+	// The replayed host names its profile replayed-1; updates must follow
+	result, err := Replay(frames, newProfileHost(newMemoryProfiles("replayed"), nil), nil)
+	if err != nil {
+		t.Fatalf("Expected the replay to finish, got %v", err)
+	}
+	if result.Requests != 5 || result.Messages != 5 {
+		t.Errorf("Expected 5 requests and 5 messages, got %+v", result)
+	}
+	for _, diff := range result.Diffs {
+		t.Errorf("Expected no differences, got %s", diff)
+	}
+}
+
+func TestReplayReportsDifferences(t *testing.T) {
+	frames := recordSession(t, profileSession)
+
+	profiles := newMemoryProfiles("replayed")
+	profiles.suffix = "!"
+	result, err := Replay(frames[:len(frames)-2], newProfileHost(profiles, nil), nil)
+	if err != nil {
+		t.Fatalf("Expected the replay to finish, got %v", err)
+	}
+
+	var diffs []string
+	for _, diff := range result.Diffs {
+		diffs = append(diffs, diff.String())
+	}
+	expected := []string{
+		`frame 4: PROFILE_CREATED 2: data.name: recorded "Jo", replayed "Jo!"`,
+		`frame 8: PROFILE_UPDATED 3: data.name: recorded "Jo B", replayed "Jo B!"`,
+	}
+	if strings.Join(diffs, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected differences\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(diffs, "\n"))
+	}
+
+	// A response that never comes and one that was not recorded
+	frames = append(frames[:6:6], RecordedFrame{Direction: FrameOut, Message: json.RawMessage(`{"id":"9","type":"STATUS_RESPONSE","success":true}`)},
+		RecordedFrame{Direction: FrameIn, Message: json.RawMessage(`{"id":"4","type":"GET_PROFILES"}`)})
+	result, err = Replay(frames, newProfileHost(newMemoryProfiles("replayed"), nil), &ReplayConfig{StepTimeout: 100 * time.Millisecond, Ignore: ReplayIgnoredFields})
+	if err != nil {
+		t.Fatalf("Expected the replay to finish, got %v", err)
+	}
+	if len(result.Diffs) != 2 || result.Diffs[0].Frame != 7 || result.Diffs[0].Replayed != nil ||
+		result.Diffs[1].Frame != 0 || result.Diffs[1].Type != "PROFILES_RESPONSE" {
+		t.Errorf("Expected a missing STATUS_RESPONSE and an unexpected PROFILES_RESPONSE, got %+v", result.Diffs)
+	}
+}
@@ -0,0 +1,444 @@
+package messaging
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Schema is the subset of JSON Schema (draft 2020-12) the protocol uses
+type Schema struct {
+	SchemaURI            string             `json:"$schema,omitempty"`
+	ID                   string             `json:"$id,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Const                string             `json:"const,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+
+	order []string // Property names in struct order, for TypeScript output
+}
+
+// schemaGenerator builds schemas of Go types from their json and schema
+// struct tags. Named structs become definitions referenced by name.
+//
+// The schema tag holds comma separated rules: required, enum=a|b,
+// minimum=N, minLength=N and minItems=N. Data sent by the extension only
+// requires fields tagged required, so older extensions that leave fields
+// out still validate; data sent by the host requires every field it
+// always writes, which is every field without omitempty.
+type schemaGenerator struct {
+	defs  map[string]*Schema
+	types map[string]reflect.Type
+	input map[string]bool // Definitions built from extension data
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{
+		defs:  make(map[string]*Schema),
+		types: make(map[string]reflect.Type),
+		input: make(map[string]bool),
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// schemaOf returns the schema of a Go type. Input schemas are for data
+// the extension sends.
+func (g *schemaGenerator) schemaOf(t reflect.Type, input bool) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "integer", Description: "Nanoseconds"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schemaOf(t.Elem(), input)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem(), input)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t, input)
+		}
+		return &Schema{Ref: "#/$defs/" + g.define(t, input)}
+	}
+
+	// interface{} and anything else accepts any value
+	return &Schema{}
+}
+
+// define adds a named struct to the definitions and returns its name. A
+// struct used by both sides keeps its input rules.
+func (g *schemaGenerator) define(t reflect.Type, input bool) string {
+	name := t.Name()
+	if existing, ok := g.types[name]; ok && existing != t {
+		// Same name in another package
+		pkg := pathBase(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+
+	if _, ok := g.defs[name]; ok && (g.input[name] || !input) {
+		return name
+	}
+
+	g.types[name] = t
+	g.input[name] = input
+	g.defs[name] = &Schema{} // Placeholder for recursive types
+	*g.defs[name] = *g.structSchema(t, input)
+	return name
+}
+
+// structSchema returns the object schema of a struct's fields. Embedded
+// structs without a json name are flattened like encoding/json does.
+func (g *schemaGenerator) structSchema(t reflect.Type, input bool) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addFields(schema, t, input, false)
+	return schema
+}
+
+// addFields adds a struct's fields to an object schema. The fields of a
+// struct embedded by pointer are optional, as a nil pointer omits them all.
+func (g *schemaGenerator) addFields(schema *Schema, t reflect.Type, input, optional bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			byPointer := embedded.Kind() == reflect.Pointer
+			if byPointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.addFields(schema, embedded, input, optional || byPointer)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := g.schemaOf(field.Type, input)
+		required := !input && !optional && !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer
+		for _, rule := range strings.Split(field.Tag.Get("schema"), ",") {
+			key, value, _ := strings.Cut(rule, "=")
+			switch key {
+			case "required":
+				required = true
+			case "enum":
+				property.Enum = strings.Split(value, "|")
+			case "minimum":
+				minimum, _ := strconv.ParseFloat(value, 64)
+				property.Minimum = &minimum
+			case "minLength":
+				minLength, _ := strconv.Atoi(value)
+				property.MinLength = &minLength
+			case "minItems":
+				minItems, _ := strconv.Atoi(value)
+				property.MinItems = &minItems
+			}
+		}
+
+		schema.Properties[name] = property
+		schema.order = append(schema.order, name)
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// pathBase returns the last element of a package path
+func pathBase(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// protocolSchema holds the generated schema of every message, built once
+var protocolSchema struct {
+	once     sync.Once
+	document *Schema
+	data     map[string]*Schema // Data schema of each message type
+}
+
+// ProtocolSchema returns the JSON Schema of the protocol: one definition
+// per data type and a oneOf over every message type
+func ProtocolSchema() *Schema {
+	buildProtocolSchema()
+	return protocolSchema.document
+}
+
+func buildProtocolSchema() {
+	protocolSchema.once.Do(func() {
+		g := newSchemaGenerator()
+		data := make(map[string]*Schema)
+
+		// Requests first so shared types keep their input rules
+		for _, kind := range []string{KindRequest, KindFrame, KindResponse, KindEvent} {
+			for _, spec := range MessageSpecs {
+				if spec.Kind == kind && spec.Data != nil {
+					data[spec.Type] = g.schemaOf(reflect.TypeOf(spec.Data), kind == KindRequest || kind == KindFrame)
+				}
+			}
+		}
+		g.define(reflect.TypeOf(FieldError{}), false)
+
+		var types []string
+		for _, spec := range MessageSpecs {
+			types = append(types, spec.Type)
+		}
+		envelope := &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"id":      {Type: "string", Description: "Set by the extension on requests and echoed on their responses and events"},
+				"type":    {Type: "string", Enum: types},
+				"data":    {},
+				"success": {Type: "boolean"},
+				"error":   {Type: "string", Description: "Human readable; set on ERROR"},
+				"code":    {Type: "string", Enum: ErrorCodes, Description: "Set on ERROR from protocol version 2"},
+				"details": {Type: "array", Items: &Schema{Ref: "#/$defs/FieldError"}, Description: "Set on INVALID_REQUEST errors"},
+				"token":   {Type: "string", Description: "Session token from PAIRED or AUTHENTICATED; needed on sensitive requests"},
+			},
+			Required: []string{"type"},
+			order:    []string{"id", "type", "data", "success", "error", "code", "details", "token"},
+		}
+		g.defs["NativeMessage"] = envelope
+
+		document := &Schema{
+			SchemaURI:   "https://json-schema.org/draft/2020-12/schema",
+			ID:          fmt.Sprintf("urn:ai-form-filler:native-messaging:v%d", ProtocolVersion),
+			Title:       fmt.Sprintf("AI Form Filler native messaging protocol, version %d", ProtocolVersion),
+			Description: fmt.Sprintf("Messages between the browser extension and %s. Generated by 'ai-form-filler native-messaging schema'.", HostName),
+			Defs:        g.defs,
+		}
+		for _, spec := range MessageSpecs {
+			description := strings.TrimSpace(spec.Kind + ". " + spec.Description)
+			if spec.Sensitive {
+				description += ". Needs the session token."
+			}
+			message := &Schema{
+				Description: description,
+				Properties:  map[string]*Schema{"type": {Const: spec.Type}},
+				Required:    []string{"type"},
+			}
+			if schema, ok := data[spec.Type]; ok {
+				message.Properties["data"] = schema
+				if (spec.Kind == KindRequest || spec.Kind == KindFrame) && len(requiredOf(schema, g.defs)) > 0 {
+					message.Required = append(message.Required, "data")
+				}
+			}
+			document.OneOf = append(document.OneOf, &Schema{AllOf: []*Schema{{Ref: "#/$defs/NativeMessage"}, message}})
+		}
+
+		protocolSchema.document = document
+		protocolSchema.data = data
+	})
+}
+
+// requiredOf returns the required properties of an object schema
+func requiredOf(schema *Schema, defs map[string]*Schema) []string {
+	return resolve(schema, defs).Required
+}
+
+// resolve follows a schema's reference
+func resolve(schema *Schema, defs map[string]*Schema) *Schema {
+	for schema.Ref != "" {
+		schema = defs[strings.TrimPrefix(schema.Ref, "#/$defs/")]
+	}
+	return schema
+}
+
+// ValidateRequest checks the data of a request or CHUNK against the schema
+// of its type. Unknown types and types without data are not checked.
+func ValidateRequest(msg *NativeMessage) error {
+	buildProtocolSchema()
+
+	spec, ok := findMessageSpec(msg.Type)
+	if !ok || (spec.Kind != KindRequest && spec.Kind != KindFrame) {
+		return nil
+	}
+	schema, ok := protocolSchema.data[msg.Type]
+	if !ok {
+		return nil
+	}
+
+	var details []FieldError
+	if msg.Data == nil {
+		if required := requiredOf(schema, protocolSchema.document.Defs); len(required) > 0 {
+			details = append(details, FieldError{Path: "data", Reason: "required", Message: "data is required"})
+		}
+	} else {
+		validateValue(msg.Data, schema, protocolSchema.document.Defs, "data", &details)
+	}
+	if len(details) == 0 {
+		return nil
+	}
+
+	err := NewProtocolError(ErrInvalidRequest, "invalid %s request: %s", msg.Type, details[0].Message)
+	if len(details) > 1 {
+		err.Message += fmt.Sprintf(" (and %d more)", len(details)-1)
+	}
+	err.Details = details
+	return err
+}
+
+// validateValue checks a decoded JSON value against a schema. Null is
+// accepted wherever a value is optional, as encoding/json decodes it as
+// the zero value.
+func validateValue(value interface{}, schema *Schema, defs map[string]*Schema, path string, details *[]FieldError) {
+	schema = resolve(schema, defs)
+	if value == nil {
+		return
+	}
+
+	fail := func(reason, format string, args ...interface{}) {
+		*details = append(*details, FieldError{Path: path, Reason: reason, Message: path + " " + fmt.Sprintf(format, args...)})
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("type", "must be an object, got %s", jsonType(value))
+			return
+		}
+		for _, name := range schema.Required {
+			if property, ok := object[name]; !ok || property == nil {
+				*details = append(*details, FieldError{Path: path + "." + name, Reason: "required", Message: path + "." + name + " is required"})
+			}
+		}
+
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := schema.Properties[name]; ok {
+				validateValue(object[name], property, defs, path+"."+name, details)
+			} else if schema.AdditionalProperties != nil {
+				validateValue(object[name], schema.AdditionalProperties, defs, path+"."+name, details)
+			}
+			// Other properties are ignored so newer extensions can send more
+		}
+
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			fail("type", "must be an array, got %s", jsonType(value))
+			return
+		}
+		if schema.MinItems != nil && len(array) < *schema.MinItems {
+			if *schema.MinItems == 1 {
+				fail("minItems", "must not be empty")
+			} else {
+				fail("minItems", "must have at least %d items", *schema.MinItems)
+			}
+		}
+		for i, item := range array {
+			validateValue(item, schema.Items, defs, fmt.Sprintf("%s[%d]", path, i), details)
+		}
+
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			fail("type", "must be a string, got %s", jsonType(value))
+			return
+		}
+		if schema.MinLength != nil && len(text) < *schema.MinLength {
+			if *schema.MinLength == 1 {
+				fail("minLength", "must not be empty")
+			} else {
+				fail("minLength", "must be at least %d characters, got %d", *schema.MinLength, len(text))
+			}
+			return
+		}
+		if len(schema.Enum) > 0 && !containsString(schema.Enum, text) {
+			fail("enum", "must be one of %s, got %q", strings.Join(schema.Enum, ", "), text)
+		}
+
+	case "integer", "number":
+		number, ok := value.(float64)
+		if !ok {
+			fail("type", "must be a number, got %s", jsonType(value))
+			return
+		}
+		if schema.Type == "integer" && number != math.Trunc(number) {
+			fail("type", "must be an integer, got %v", number)
+			return
+		}
+		if schema.Minimum != nil && number < *schema.Minimum {
+			fail("minimum", "must be at least %v, got %v", *schema.Minimum, number)
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("type", "must be a boolean, got %s", jsonType(value))
+		}
+	}
+}
+
+// jsonType names the JSON type of a decoded value
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	}
+	return "null"
+}
+
+// containsString reports whether a list contains a string
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
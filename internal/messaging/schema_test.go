@@ -0,0 +1,143 @@
+package messaging
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// documentationDir holds the generated protocol files the extension's
+// developers read
+var documentationDir = filepath.Join("..", "..", "project-documentation", "native-messaging")
+
+func TestValidateRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		request string
+		path    string // Of the first field error; empty when the request is valid
+		reason  string
+	}{
+		{"valid", `{"type":"CREATE_PROFILE","data":{"name":"Jo"}}`, "", ""},
+		{"no data", `{"type":"CREATE_PROFILE"}`, "data", "required"},
+		{"missing field", `{"type":"CREATE_PROFILE","data":{}}`, "data.name", "required"},
+		{"null field", `{"type":"DELETE_PROFILE","data":{"profileId":null}}`, "data.profileId", "required"},
+		{"wrong type", `{"type":"CREATE_PROFILE","data":{"name":42}}`, "data.name", "type"},
+		{"data not an object", `{"type":"CREATE_PROFILE","data":"Jo"}`, "data", "type"},
+		{"not an integer", `{"type":"HANDSHAKE","data":{"protocolVersion":1.5}}`, "data.protocolVersion", "type"},
+		{"below minimum", `{"type":"HANDSHAKE","data":{"protocolVersion":0}}`, "data.protocolVersion", "minimum"},
+		{"empty string", `{"type":"PAIR","data":{"code":""}}`, "data.code", "minLength"},
+		{"short string", `{"type":"CHUNK","data":{"transferId":"t","index":0,"total":1,"size":1,"sha256":"abc","data":"YQ=="}}`, "data.sha256", "minLength"},
+		{"not in enum", `{"type":"FILL_FORM","data":{"url":"https://example.com","mode":"guess"}}`, "data.mode", "enum"},
+		{"extra field", `{"type":"CREATE_PROFILE","data":{"name":"Jo","nickname":"J"}}`, "", ""},
+		{"no data needed", `{"type":"GET_PROFILES"}`, "", ""},
+		// Unknown types are answered with UNKNOWN_TYPE by the host, not validated
+		{"unknown type", `{"type":"MAKE_COFFEE","data":{"sugar":"lots"}}`, "", ""},
+		{"response type", `{"type":"PAIRED","data":{}}`, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var msg NativeMessage
+			if err := json.Unmarshal([]byte(tt.request), &msg); err != nil {
+				t.Fatalf("Expected a JSON request, got %v", err)
+			}
+
+			err := ValidateRequest(&msg)
+			if tt.path == "" {
+				if err != nil {
+					t.Errorf("Expected the request to be valid, got %v", err)
+				}
+				return
+			}
+			if ErrorCode(err) != ErrInvalidRequest {
+				t.Fatalf("Expected %s, got %v", ErrInvalidRequest, err)
+			}
+			details := errorDetails(err)
+			if len(details) == 0 || details[0].Path != tt.path || details[0].Reason != tt.reason {
+				t.Errorf("Expected %s to fail %s, got %+v", tt.path, tt.reason, details)
+			}
+		})
+	}
+}
+
+func TestHostAnswersUnknownTypes(t *testing.T) {
+	host := startPipeHost(t, newProfileHost(newMemoryProfiles("unknown"), DefaultNativeHostConfig()))
+	if response := host.exchange(`{"id":"1","type":"MAKE_COFFEE"}`); response.Code != ErrUnknownType {
+		t.Errorf("Expected %s, got %+v", ErrUnknownType, response)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name         string
+		version      int
+		wanted       []string
+		expected     int
+		capabilities []string
+		code         string
+	}{
+		{"no version", 0, nil, MinProtocolVersion, HostCapabilities, ""},
+		{"current version", ProtocolVersion, nil, ProtocolVersion, HostCapabilities, ""},
+		{"newer extension", ProtocolVersion + 3, nil, ProtocolVersion, HostCapabilities, ""},
+		{"some capabilities", ProtocolVersion, []string{CapabilityChunking, "teleport"}, ProtocolVersion, []string{CapabilityChunking}, ""},
+		{"unsupported version", -1, nil, 0, nil, ErrUnsupportedVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := newSession("")
+			version, capabilities, err := session.Negotiate(tt.version, tt.wanted)
+			if tt.code != "" {
+				if ErrorCode(err) != tt.code {
+					t.Fatalf("Expected %s, got %v", tt.code, err)
+				}
+				if session.Version() != MinProtocolVersion {
+					t.Errorf("Expected a refused handshake to keep version %d, got %d", MinProtocolVersion, session.Version())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected the handshake to be agreed, got %v", err)
+			}
+
+			if version != tt.expected || session.Version() != tt.expected {
+				t.Errorf("Expected version %d, got %d", tt.expected, version)
+			}
+			if len(capabilities) != len(tt.capabilities) {
+				t.Fatalf("Expected capabilities %v, got %v", tt.capabilities, capabilities)
+			}
+			for i, capability := range tt.capabilities {
+				if capabilities[i] != capability || !session.Enabled(capability) {
+					t.Errorf("Expected capabilities %v, got %v", tt.capabilities, capabilities)
+				}
+			}
+		})
+	}
+}
+
+// TestProtocolDocumentation fails when the documented protocol drifts from
+// the code. Regenerate it with
+//
+//	ai-form-filler native-messaging schema -o project-documentation/native-messaging/protocol.schema.json
+//	ai-form-filler native-messaging schema --format ts -o project-documentation/native-messaging/protocol.d.ts
+func TestProtocolDocumentation(t *testing.T) {
+	schema, err := json.MarshalIndent(ProtocolSchema(), "", "  ")
+	if err != nil {
+		t.Fatalf("Expected the schema to marshal, got %v", err)
+	}
+
+	generated := map[string]string{
+		"protocol.schema.json": string(schema) + "\n",
+		"protocol.d.ts":        ProtocolTypeScript(),
+	}
+	for name, expected := range generated {
+		documented, err := os.ReadFile(filepath.Join(documentationDir, name))
+		if err != nil {
+			t.Fatalf("Expected %s to exist, got %v", name, err)
+		}
+		if string(documented) != expected {
+			t.Errorf("Expected %s to match the protocol; regenerate it with 'ai-form-filler native-messaging schema'", name)
+		}
+	}
+}
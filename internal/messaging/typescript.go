@@ -0,0 +1,162 @@
+package messaging
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ProtocolTypeScript returns TypeScript definitions of the protocol for the
+// browser extension, generated from the same types as ProtocolSchema
+func ProtocolTypeScript() string {
+	document := ProtocolSchema()
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "// %s.\n", document.Title)
+	b.WriteString("// Generated by 'ai-form-filler native-messaging schema --format ts'; do not edit.\n\n")
+
+	fmt.Fprintf(&b, "export const HOST_NAME = %s;\n", strconv.Quote(HostName))
+	fmt.Fprintf(&b, "export const PROTOCOL_VERSION = %d;\n", ProtocolVersion)
+	fmt.Fprintf(&b, "export const MIN_PROTOCOL_VERSION = %d;\n\n", MinProtocolVersion)
+
+	fmt.Fprintf(&b, "export type Capability = %s;\n\n", tsUnion(HostCapabilities))
+	fmt.Fprintf(&b, "export type ErrorCode = %s;\n\n", tsUnion(ErrorCodes))
+
+	b.WriteString("export interface NativeMessage<T extends MessageType = MessageType> {\n")
+	b.WriteString("  /** Set by the extension on requests and echoed on their responses and events */\n")
+	b.WriteString("  id?: string;\n  type: T;\n  data?: MessageData[T];\n  success?: boolean;\n")
+	b.WriteString("  /** Human readable; set on ERROR */\n  error?: string;\n")
+	b.WriteString("  /** Set on ERROR from protocol version 2 */\n  code?: ErrorCode;\n")
+	b.WriteString("  /** Set on INVALID_REQUEST errors */\n  details?: FieldError[];\n")
+	b.WriteString("  /** Session token from PAIRED or AUTHENTICATED; needed on sensitive requests */\n  token?: string;\n}\n\n")
+
+	b.WriteString("/** The data of each message type */\nexport interface MessageData {\n")
+	for _, spec := range MessageSpecs {
+		if spec.Description != "" {
+			fmt.Fprintf(&b, "  /** %s: %s */\n", spec.Kind, spec.Description)
+		} else {
+			fmt.Fprintf(&b, "  /** %s */\n", spec.Kind)
+		}
+		dataType := "undefined"
+		if schema, ok := protocolSchema.data[spec.Type]; ok {
+			dataType = tsType(schema, "  ")
+		}
+		fmt.Fprintf(&b, "  %s: %s;\n", spec.Type, dataType)
+	}
+	b.WriteString("}\n\nexport type MessageType = keyof MessageData;\n\n")
+
+	var requests, sensitive []string
+	for _, spec := range MessageSpecs {
+		if spec.Kind == KindRequest {
+			requests = append(requests, spec.Type)
+		}
+		if spec.Sensitive {
+			sensitive = append(sensitive, spec.Type)
+		}
+	}
+	fmt.Fprintf(&b, "export type RequestType = %s;\n\n", tsUnion(requests))
+	b.WriteString("/** Requests that need the session token */\n")
+	fmt.Fprintf(&b, "export type SensitiveRequestType = %s;\n\n", tsUnion(sensitive))
+
+	b.WriteString("/** The success response of each request; any request may be answered with ERROR */\nexport interface ResponseType {\n")
+	for _, spec := range MessageSpecs {
+		if spec.Kind == KindRequest {
+			fmt.Fprintf(&b, "  %s: %s;\n", spec.Type, strconv.Quote(spec.Response))
+		}
+	}
+	b.WriteString("}\n\n")
+
+	b.WriteString("/** The events a request may be sent with its ID */\nexport interface EventType {\n")
+	for _, spec := range MessageSpecs {
+		if spec.Kind == KindRequest {
+			events := "never"
+			if len(spec.Events) > 0 {
+				events = tsUnion(spec.Events)
+			}
+			fmt.Fprintf(&b, "  %s: %s;\n", spec.Type, events)
+		}
+	}
+	b.WriteString("}\n")
+
+	names := make([]string, 0, len(document.Defs))
+	for name := range document.Defs {
+		if name != "NativeMessage" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		def := document.Defs[name]
+		fmt.Fprintf(&b, "\nexport interface %s {\n", name)
+		writeTSProperties(&b, def, "  ")
+		b.WriteString("}\n")
+	}
+
+	return b.String()
+}
+
+// writeTSProperties writes an object schema's properties in struct order
+func writeTSProperties(b *strings.Builder, schema *Schema, indent string) {
+	for _, name := range schema.order {
+		property := schema.Properties[name]
+		if property.Description != "" {
+			fmt.Fprintf(b, "%s/** %s */\n", indent, property.Description)
+		}
+		optional := "?"
+		if containsString(schema.Required, name) {
+			optional = ""
+		}
+		fmt.Fprintf(b, "%s%s%s: %s;\n", indent, name, optional, tsType(property, indent))
+	}
+}
+
+// tsType returns the TypeScript type of a schema
+func tsType(schema *Schema, indent string) string {
+	switch {
+	case schema.Ref != "":
+		return strings.TrimPrefix(schema.Ref, "#/$defs/")
+	case schema.Const != "":
+		return strconv.Quote(schema.Const)
+	case len(schema.Enum) > 0:
+		return tsUnion(schema.Enum)
+	}
+
+	switch schema.Type {
+	case "string":
+		return "string"
+	case "integer", "number":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "array":
+		item := tsType(schema.Items, indent)
+		if strings.Contains(item, " | ") {
+			item = "(" + item + ")"
+		}
+		return item + "[]"
+	case "object":
+		if len(schema.Properties) == 0 {
+			value := "unknown"
+			if schema.AdditionalProperties != nil {
+				value = tsType(schema.AdditionalProperties, indent)
+			}
+			return "Record<string, " + value + ">"
+		}
+		var b strings.Builder
+		b.WriteString("{\n")
+		writeTSProperties(&b, schema, indent+"  ")
+		b.WriteString(indent + "}")
+		return b.String()
+	}
+	return "unknown"
+}
+
+// tsUnion returns a union of string literal types
+func tsUnion(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = strconv.Quote(value)
+	}
+	return strings.Join(quoted, " | ")
+}
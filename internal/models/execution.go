@@ -53,6 +53,7 @@ type ExecutionResult struct {
 	ExecutionTime time.Duration `json:"executionTime"`
 	ErrorMessage  string        `json:"errorMessage,omitempty"`
 	ScreenshotPath string       `json:"screenshotPath,omitempty"`
+	Artifacts     *ExecutionArtifacts `json:"artifacts,omitempty"`
 	Timestamp     time.Time     `json:"timestamp"`
 }
 
@@ -63,6 +64,16 @@ type ExecutionError struct {
 	Message   string    `json:"message"`
 	Timestamp time.Time `json:"timestamp"`
 	Severity  string    `json:"severity"` // low, medium, high, critical
+	Artifacts *ExecutionArtifacts `json:"artifacts,omitempty"`
+}
+
+// ExecutionArtifacts links the debugging artifacts captured for a single URL
+type ExecutionArtifacts struct {
+	Dir              string `json:"dir"`
+	BeforeScreenshot string `json:"beforeScreenshot,omitempty"`
+	AfterScreenshot  string `json:"afterScreenshot,omitempty"`
+	Trace            string `json:"trace,omitempty"`
+	HAR              string `json:"har,omitempty"`
 }
 
 // ExecutionConfig contains configuration for an execution session
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/ai-form-filler/cli/internal/automation"
+)
+
+// ExecutionService follows execution sessions run from the CLI in another
+// process through their shared execution feed
+type ExecutionService struct {
+	feed     *automation.ExecutionFeed
+	interval time.Duration
+}
+
+// NewExecutionService creates an execution service that reads the feed in feedDir
+func NewExecutionService(feedDir string) *ExecutionService {
+	return &ExecutionService{
+		feed:     automation.NewExecutionFeed(feedDir),
+		interval: 500 * time.Millisecond,
+	}
+}
+
+// WatchExecutions sends each execution update until the context ends or
+// sending fails
+func (s *ExecutionService) WatchExecutions(ctx context.Context, send func(update automation.ExecutionUpdate) error) error {
+	return s.feed.Watch(ctx, s.interval, send)
+}
@@ -0,0 +1,332 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ai-form-filler/cli/internal/automation"
+	"github.com/ai-form-filler/cli/internal/messaging"
+	"github.com/ai-form-filler/cli/internal/models"
+)
+
+// FormService answers the browser extension's form requests from the stored
+// profiles and templates. The extension usually fills the user's live tab
+// itself from a fill plan; a browser is only started for fills the
+// extension asks the CLI to run.
+type FormService struct {
+	profiles  *ProfileService
+	templates *automation.TemplateManager
+	detector  *automation.FormDetector
+	mapper    *automation.FieldMapper
+
+	browserConfig  *automation.BrowserConfig
+	browserManager *automation.BrowserManager // Started by the first run fill
+	browserMutex   sync.Mutex
+}
+
+// NewFormService creates a form service
+func NewFormService(profiles *ProfileService, templates *automation.TemplateManager, detector *automation.FormDetector) *FormService {
+	// Run fills come one at a time from a single user's extension
+	browserConfig := automation.DefaultBrowserConfig()
+	browserConfig.MaxBrowsers = 1
+
+	return &FormService{
+		profiles:  profiles,
+		templates: templates,
+		detector:  detector,
+		mapper:    automation.NewFieldMapper(),
+
+		browserConfig: browserConfig,
+	}
+}
+
+// FillForm returns a plan of the value to fill into each field of the form
+// at the request's URL. The best matching template is used; without one
+// the fields captured by the extension are planned directly. In run mode
+// the form is filled in the CLI's browser instead.
+func (s *FormService) FillForm(ctx context.Context, request messaging.FillFormRequest) (*messaging.FillFormResponse, error) {
+	profile, err := s.findProfile(request.ProfileID, request.ProfileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Mode == messaging.FillModeRun {
+		return s.runFill(ctx, request, profile)
+	}
+
+	template, err := s.templates.FindBestTemplate(request.URL)
+	if err != nil {
+		if len(request.Fields) == 0 {
+			return nil, messaging.NewProtocolError(messaging.ErrNotFound, "no template for %s and no fields captured", request.URL)
+		}
+
+		// Plan the captured fields without saving a template; TRAIN_FORM does that
+		form := automation.DetectedForm{Fields: request.Fields, FormType: formType(request.FormType)}
+		template, err = s.detector.GenerateFormTemplate(form, request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build template from captured fields: %w", err)
+		}
+		template.ID = ""
+		template.Version = 0
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	plan := automation.PlanFill(template, profile, s.mapper)
+	plan.URL = request.URL
+	return &messaging.FillFormResponse{Mode: messaging.FillModePlan, FillPlan: plan}, nil
+}
+
+// runFill fills the form in the CLI's browser. Each field and step is
+// streamed to the extension as FILL_PROGRESS and FILL_STEP events, followed
+// by FILL_DONE before the response.
+func (s *FormService) runFill(ctx context.Context, request messaging.FillFormRequest, profile *models.ClientProfile) (*messaging.FillFormResponse, error) {
+	browserManager, err := s.browser()
+	if err != nil {
+		return nil, err
+	}
+
+	stream := messaging.StreamFromContext(ctx)
+	if stream != nil {
+		ctx = automation.WithFillProgress(ctx, func(event automation.FillEvent) {
+			messageType := "FILL_PROGRESS"
+			if event.Kind == automation.FillEventStep {
+				messageType = "FILL_STEP"
+			}
+			stream.Send(messageType, event)
+		})
+	}
+
+	config := automation.DefaultProfileFormFillerConfig()
+	config.Submit = request.Submit
+	detector := automation.NewFormDetector(browserManager, nil)
+	filler := automation.NewProfileFormFiller(automation.NewFormFiller(browserManager, nil), detector, s.templates, config)
+
+	result, err := filler.FillFormWithProfile(ctx, request.URL, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &messaging.FillSummary{
+		URL:          request.URL,
+		Success:      result.Success,
+		FilledFields: result.FilledFields,
+		TotalFields:  result.TotalFields,
+		FailedFields: append([]string{}, result.FailedFields...),
+		Errors:       append([]string{}, result.Errors...),
+		Submitted:    result.SubmissionResult != nil,
+	}
+	if result.TemplateUsed != nil {
+		summary.TemplateID = result.TemplateUsed.ID
+	}
+	if result.SubmissionResult != nil {
+		summary.SubmissionSuccess = &result.SubmissionResult.Success
+	}
+	if stream != nil {
+		stream.Send("FILL_DONE", summary)
+	}
+
+	return &messaging.FillFormResponse{Mode: messaging.FillModeRun, Result: summary}, nil
+}
+
+// browser returns the browser manager, starting it on first use
+func (s *FormService) browser() (*automation.BrowserManager, error) {
+	s.browserMutex.Lock()
+	defer s.browserMutex.Unlock()
+
+	if s.browserManager == nil {
+		browserManager, err := automation.NewBrowserManager(s.browserConfig)
+		if err != nil {
+			return nil, messaging.NewProtocolError(messaging.ErrUnavailable, "failed to start browser: %v", err)
+		}
+		s.browserManager = browserManager
+	}
+	return s.browserManager, nil
+}
+
+// Close stops the browser started by run fills
+func (s *FormService) Close() error {
+	s.browserMutex.Lock()
+	defer s.browserMutex.Unlock()
+
+	if s.browserManager == nil {
+		return nil
+	}
+	err := s.browserManager.Close()
+	s.browserManager = nil
+	return err
+}
+
+// TrainForm saves a template built from the fields the extension captured.
+// A template learned on the same URL with the same form type is updated
+// as a new version instead of adding another template.
+func (s *FormService) TrainForm(ctx context.Context, request messaging.TrainFormRequest) (*messaging.TrainFormResponse, error) {
+	form := automation.DetectedForm{
+		Fields:        request.Fields,
+		SubmitButtons: request.SubmitButtons,
+		FormType:      formType(request.FormType),
+	}
+	trained, err := s.detector.GenerateFormTemplate(form, request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build template: %w", err)
+	}
+	if len(request.SubmitButtons) > 0 && request.SubmitButtons[0].Selector != "" {
+		trained.Submit = &automation.SubmitAction{Selector: request.SubmitButtons[0].Selector}
+	}
+	if len(request.URLPatterns) > 0 {
+		trained.URLPatterns = request.URLPatterns
+	}
+	if len(request.Success) > 0 {
+		trained.Success = request.Success
+	}
+
+	existing, err := s.existingTemplate(request.TemplateID, request.URL, trained.FormType)
+	if err != nil {
+		return nil, err
+	}
+
+	template := trained
+	created := true
+	if existing != nil {
+		// Keep what the extension can't capture, such as steps and statistics
+		template = existing
+		template.Fields = trained.Fields
+		template.Selectors = trained.Selectors
+		if trained.Submit != nil {
+			template.Submit = trained.Submit
+		}
+		if len(request.URLPatterns) > 0 {
+			template.URLPatterns = trained.URLPatterns
+		}
+		if len(request.Success) > 0 {
+			template.Success = trained.Success
+		}
+		template.Stale = false
+		template.LastUpdated = time.Now()
+		created = false
+	}
+
+	if err := s.templates.ValidateTemplate(template); err != nil {
+		return nil, messaging.NewProtocolError(messaging.ErrInvalidRequest, "invalid template: %v", err)
+	}
+
+	// Don't save a template the extension has stopped waiting for
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.templates.SaveTemplateRevision(template, automation.TemplateAuthorExtension, "trained from "+request.URL); err != nil {
+		return nil, fmt.Errorf("failed to save template: %w", err)
+	}
+
+	return &messaging.TrainFormResponse{
+		Status:     "trained",
+		TemplateID: template.ID,
+		Version:    template.Version,
+		Created:    created,
+		Fields:     len(template.Fields),
+		Timestamp:  time.Now(),
+	}, nil
+}
+
+// AnalyzeForms reports, for the forms the extension found on a page, which
+// template would fill them and how many fields the template covers
+func (s *FormService) AnalyzeForms(ctx context.Context, request messaging.FormsDetectedRequest) (*messaging.FormsAnalyzedResponse, error) {
+	response := &messaging.FormsAnalyzedResponse{
+		Status:        "analyzed",
+		FormsDetected: len(request.Forms),
+		Timestamp:     time.Now(),
+	}
+
+	if request.URL == "" {
+		return response, nil
+	}
+
+	template, err := s.templates.FindBestTemplate(request.URL)
+	if err != nil {
+		response.TrainingSuggested = len(request.Forms) > 0
+		return response, nil
+	}
+
+	response.TemplateID = template.ID
+	response.TemplateVersion = template.Version
+	response.FormType = template.FormType
+
+	// Count the template fields the page still has, to spot drifted templates
+	for _, form := range request.Forms {
+		found := make(map[string]bool, len(form.Fields))
+		for _, field := range form.Fields {
+			found[field.Name] = true
+		}
+		matched := 0
+		for _, field := range template.Fields {
+			if found[field.Name] {
+				matched++
+			}
+		}
+		response.Forms = append(response.Forms, messaging.FormCoverage{
+			Index:         form.Index,
+			Fields:        len(form.Fields),
+			MatchedFields: matched,
+		})
+	}
+
+	return response, nil
+}
+
+// ProcessTrainingData saves training data sent without expecting a reply
+func (s *FormService) ProcessTrainingData(ctx context.Context, request messaging.TrainFormRequest) error {
+	_, err := s.TrainForm(ctx, request)
+	return err
+}
+
+// findProfile looks a profile up by ID, then by name. Without either the
+// only profile is used.
+func (s *FormService) findProfile(profileID, profileName string) (*models.ClientProfile, error) {
+	switch {
+	case profileID != "":
+		return s.profiles.GetClientProfile(profileID)
+	case profileName != "":
+		return s.profiles.GetProfileByName(profileName)
+	}
+
+	names := s.profiles.ListProfileNames()
+	if len(names) != 1 {
+		return nil, messaging.NewProtocolError(messaging.ErrInvalidRequest, "profileId is required when there are %d profiles", len(names))
+	}
+	return s.profiles.GetProfileByName(names[0])
+}
+
+// existingTemplate returns the template a training request updates, or
+// nil when it creates one. A template named by ID must exist.
+func (s *FormService) existingTemplate(templateID, url, formType string) (*automation.FormTemplate, error) {
+	if templateID != "" {
+		template, err := s.templates.LoadTemplate(templateID)
+		if err != nil {
+			return nil, messaging.NewProtocolError(messaging.ErrNotFound, "template %s not found", templateID)
+		}
+		return template, nil
+	}
+
+	matches, err := s.templates.MatchTemplates(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to match templates: %w", err)
+	}
+	for _, match := range matches {
+		if match.Kind == automation.MatchExactURL && match.Template.FormType == formType {
+			return match.Template, nil
+		}
+	}
+	return nil, nil
+}
+
+// formType returns the form type sent by the extension, or unknown
+func formType(value string) automation.FormType {
+	if value == "" {
+		return automation.FormTypeUnknown
+	}
+	return automation.FormType(value)
+}
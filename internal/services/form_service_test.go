@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/ai-form-filler/cli/internal/automation"
+	"github.com/ai-form-filler/cli/internal/messaging"
+	"github.com/ai-form-filler/cli/internal/models"
+)
+
+const signupURL = "https://example.com/signup"
+
+// newTestFormService returns a form service with one profile and no templates
+func newTestFormService(t *testing.T) (*FormService, *models.ClientProfile) {
+	dir := t.TempDir()
+	profiles, err := NewProfileService(filepath.Join(dir, "profiles"))
+	if err != nil {
+		t.Fatalf("Expected a profile service, got %v", err)
+	}
+	created, err := profiles.CreateProfile(map[string]interface{}{
+		"name": "Personal",
+		"personalData": map[string]interface{}{
+			"firstName": "Jane",
+			"lastName":  "Doe",
+			"email":     "jane@example.com",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected a profile, got %v", err)
+	}
+
+	templates, err := automation.NewTemplateManager(filepath.Join(dir, "templates"))
+	if err != nil {
+		t.Fatalf("Expected a template manager, got %v", err)
+	}
+	return NewFormService(profiles, templates, automation.NewFormDetector(nil, nil)), created.(*models.ClientProfile)
+}
+
+// plannedValues returns a plan's values by field name
+func plannedValues(plan *automation.FillPlan) map[string]string {
+	values := make(map[string]string, len(plan.Fields))
+	for _, field := range plan.Fields {
+		values[field.Name] = field.Value
+	}
+	return values
+}
+
+var capturedFields = []automation.DetectedField{
+	{Name: "email", Type: "email", Label: "Email", Selector: "#email"},
+	{Name: "first_name", Type: "text", Label: "First name", Selector: "#first_name"},
+}
+
+func TestFillFormPlansStoredTemplate(t *testing.T) {
+	service, profile := newTestFormService(t)
+	template := &automation.FormTemplate{
+		ID:       "signup",
+		URL:      signupURL,
+		Domain:   "example.com",
+		FormType: string(automation.FormTypeRegistration),
+		Version:  2,
+		Fields: []automation.FormField{
+			{Name: "contact", Type: "text", Selector: "#contact", Binding: "email"},
+		},
+		Selectors: map[string]string{"contact": "#contact"},
+	}
+	if err := service.templates.SaveTemplate(template); err != nil {
+		t.Fatalf("Expected the template to be saved, got %v", err)
+	}
+
+	// The template wins over the captured fields
+	response, err := service.FillForm(context.Background(), messaging.FillFormRequest{URL: signupURL, Fields: capturedFields})
+	if err != nil {
+		t.Fatalf("Expected a plan, got %v", err)
+	}
+	if response.Mode != messaging.FillModePlan || response.TemplateID != "signup" || response.TemplateVersion != 2 {
+		t.Errorf("Expected a plan of the stored template, got %+v", response.FillPlan)
+	}
+	if response.URL != signupURL || response.ProfileID != profile.ID {
+		t.Errorf("Expected the plan to name the URL and the only profile, got %+v", response.FillPlan)
+	}
+	if values := plannedValues(response.FillPlan); len(values) != 1 || values["contact"] != "jane@example.com" {
+		t.Errorf("Expected contact to be planned with the email, got %v", values)
+	}
+}
+
+func TestFillFormFallsBackToCapturedFields(t *testing.T) {
+	service, _ := newTestFormService(t)
+
+	response, err := service.FillForm(context.Background(), messaging.FillFormRequest{URL: signupURL, ProfileName: "Personal", Fields: capturedFields})
+	if err != nil {
+		t.Fatalf("Expected a plan, got %v", err)
+	}
+	if response.TemplateID != "" || response.TemplateVersion != 0 {
+		t.Errorf("Expected a plan of no stored template, got %+v", response.FillPlan)
+	}
+	values := plannedValues(response.FillPlan)
+	if values["email"] != "jane@example.com" || values["first_name"] != "Jane" {
+		t.Errorf("Expected the captured fields to be planned, got %v", values)
+	}
+	if templates, _ := service.templates.ListTemplates(10, 0); len(templates) != 0 {
+		t.Errorf("Expected no template to be saved, got %d", len(templates))
+	}
+
+	_, err = service.FillForm(context.Background(), messaging.FillFormRequest{URL: signupURL})
+	if messaging.ErrorCode(err) != messaging.ErrNotFound {
+		t.Errorf("Expected %s without a template or fields, got %v", messaging.ErrNotFound, err)
+	}
+}
+
+func TestTrainFormCreatesThenUpdates(t *testing.T) {
+	service, _ := newTestFormService(t)
+	request := messaging.TrainFormRequest{
+		URL:           signupURL,
+		FormType:      string(automation.FormTypeRegistration),
+		Fields:        capturedFields,
+		SubmitButtons: []automation.SubmitButton{{Text: "Sign up", Selector: "#submit", Type: "submit"}},
+	}
+
+	created, err := service.TrainForm(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Expected the template to be trained, got %v", err)
+	}
+	if !created.Created || created.TemplateID == "" || created.Fields != 2 {
+		t.Errorf("Expected a new template of 2 fields, got %+v", created)
+	}
+	template, err := service.templates.LoadTemplate(created.TemplateID)
+	if err != nil {
+		t.Fatalf("Expected the template to be saved, got %v", err)
+	}
+	if template.Submit == nil || template.Submit.Selector != "#submit" {
+		t.Errorf("Expected the submit button to be kept, got %+v", template.Submit)
+	}
+
+	// Training the same form again updates the template found by URL
+	request.Fields = capturedFields[:1]
+	updated, err := service.TrainForm(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Expected the template to be retrained, got %v", err)
+	}
+	if updated.Created || updated.TemplateID != created.TemplateID || updated.Version <= created.Version || updated.Fields != 1 {
+		t.Errorf("Expected a new version of %s with 1 field, got %+v", created.TemplateID, updated)
+	}
+
+	// So does naming it
+	request.TemplateID = created.TemplateID
+	request.URL = "https://example.com/join"
+	renamed, err := service.TrainForm(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Expected the named template to be retrained, got %v", err)
+	}
+	if renamed.Created || renamed.TemplateID != created.TemplateID {
+		t.Errorf("Expected %s to be updated, got %+v", created.TemplateID, renamed)
+	}
+}
+
+func TestTrainFormRefusesUnknownTemplate(t *testing.T) {
+	service, _ := newTestFormService(t)
+
+	_, err := service.TrainForm(context.Background(), messaging.TrainFormRequest{
+		URL:        signupURL,
+		TemplateID: "missing",
+		Fields:     capturedFields,
+	})
+	if messaging.ErrorCode(err) != messaging.ErrNotFound {
+		t.Errorf("Expected %s, got %v", messaging.ErrNotFound, err)
+	}
+	if templates, _ := service.templates.ListTemplates(10, 0); len(templates) != 0 {
+		t.Errorf("Expected no template to be created, got %d", len(templates))
+	}
+}
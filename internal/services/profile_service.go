@@ -7,9 +7,13 @@ import (
 	"path/filepath"
 	"sync"
 
+	"github.com/ai-form-filler/cli/internal/messaging"
 	"github.com/ai-form-filler/cli/internal/models"
 )
 
+// errProfileNotFound is returned for unknown profiles; the extension sees it as NOT_FOUND
+var errProfileNotFound = messaging.NewProtocolError(messaging.ErrNotFound, "profile not found")
+
 // ProfileService implements the profile management interface
 type ProfileService struct {
 	dataDir    string
@@ -77,7 +81,7 @@ func (s *ProfileService) CreateProfile(data map[string]interface{}) (interface{}
 
 	// Validate profile
 	if err := profile.Validate(); err != nil {
-		return nil, fmt.Errorf("profile validation failed: %w", err)
+		return nil, messaging.NewProtocolError(messaging.ErrInvalidRequest, "profile validation failed: %v", err)
 	}
 
 	// Save profile
@@ -101,7 +105,7 @@ func (s *ProfileService) UpdateProfile(data map[string]interface{}) (interface{}
 
 	profile, exists := s.profiles[profileID]
 	if !exists {
-		return nil, fmt.Errorf("profile not found")
+		return nil, errProfileNotFound
 	}
 
 	// Clone profile for update
@@ -114,7 +118,7 @@ func (s *ProfileService) UpdateProfile(data map[string]interface{}) (interface{}
 
 	// Validate updated profile
 	if err := updatedProfile.Validate(); err != nil {
-		return nil, fmt.Errorf("profile validation failed: %w", err)
+		return nil, messaging.NewProtocolError(messaging.ErrInvalidRequest, "profile validation failed: %v", err)
 	}
 
 	// Update timestamp
@@ -135,7 +139,7 @@ func (s *ProfileService) DeleteProfile(profileID string) error {
 	defer s.mutex.Unlock()
 
 	if _, exists := s.profiles[profileID]; !exists {
-		return fmt.Errorf("profile not found")
+		return errProfileNotFound
 	}
 
 	// Remove profile file
@@ -155,7 +159,7 @@ func (s *ProfileService) GetProfile(profileID string) (interface{}, error) {
 
 	profile, exists := s.profiles[profileID]
 	if !exists {
-		return nil, fmt.Errorf("profile not found")
+		return nil, errProfileNotFound
 	}
 
 	return profile, nil
@@ -171,7 +175,19 @@ func (s *ProfileService) GetProfileByName(name string) (*models.ClientProfile, e
 			return profile, nil
 		}
 	}
-	return nil, fmt.Errorf("profile not found")
+	return nil, errProfileNotFound
+}
+
+// GetClientProfile returns a profile by ID
+func (s *ProfileService) GetClientProfile(profileID string) (*models.ClientProfile, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	profile, exists := s.profiles[profileID]
+	if !exists {
+		return nil, errProfileNotFound
+	}
+	return profile, nil
 }
 
 // ListProfileNames returns a list of profile names
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"time"
+
+	"github.com/ai-form-filler/cli/internal/automation"
+	"github.com/ai-form-filler/cli/internal/messaging"
+)
+
+// StatusService reports the state of the native messaging host
+type StatusService struct {
+	version   string
+	startTime time.Time
+	profiles  *ProfileService
+	templates *automation.TemplateManager
+}
+
+// NewStatusService creates a status service; uptime counts from its creation
+func NewStatusService(version string, profiles *ProfileService, templates *automation.TemplateManager) *StatusService {
+	return &StatusService{
+		version:   version,
+		startTime: time.Now(),
+		profiles:  profiles,
+		templates: templates,
+	}
+}
+
+// GetStatus returns the host's version, uptime and what it has stored
+func (s *StatusService) GetStatus() (*messaging.StatusResponse, error) {
+	return &messaging.StatusResponse{
+		Status:          "running",
+		Version:         s.version,
+		ProtocolVersion: messaging.ProtocolVersion,
+		Uptime:          time.Since(s.startTime).Seconds(),
+		Profiles:        len(s.profiles.ListProfileNames()),
+		Templates:       s.templates.GetTemplateMetrics().TotalTemplates,
+		Timestamp:       time.Now(),
+	}, nil
+}
+
+// OpenDashboard is not supported: the host has no terminal to show it in
+func (s *StatusService) OpenDashboard() error {
+	return messaging.NewProtocolError(messaging.ErrUnavailable, "the dashboard cannot be opened from the browser; run 'ai-form-filler dashboard' in a terminal")
+}
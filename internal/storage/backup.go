@@ -253,7 +253,8 @@ func (bs *BackupService) backupProfiles(zipWriter *zip.Writer, encrypt bool) (in
 func (bs *BackupService) backupTemplates(zipWriter *zip.Writer, encrypt bool) (int, error) {
 	query := `
 		SELECT id, url, domain, form_type, fields, selectors, validation_rules, 
-		       success_rate, last_updated, version, created_at 
+		       success_rate, last_updated, version, created_at,
+		       COALESCE(data, ''), COALESCE(stale, 0)
 		FROM form_templates
 	`
 	rows, err := bs.db.GetDB().Query(query)
@@ -269,11 +270,13 @@ func (bs *BackupService) backupTemplates(zipWriter *zip.Writer, encrypt bool) (i
 		var template map[string]interface{} = make(map[string]interface{})
 		var id, url, domain, formType, fields, selectors, validationRules string
 		var successRate float64
-		var lastUpdated, createdAt string
+		var lastUpdated, createdAt, templateData string
 		var version int
+		var stale bool
 
 		err := rows.Scan(&id, &url, &domain, &formType, &fields, &selectors, 
-			&validationRules, &successRate, &lastUpdated, &version, &createdAt)
+			&validationRules, &successRate, &lastUpdated, &version, &createdAt,
+			&templateData, &stale)
 		if err != nil {
 			return 0, fmt.Errorf("failed to scan template: %w", err)
 		}
@@ -289,10 +292,26 @@ func (bs *BackupService) backupTemplates(zipWriter *zip.Writer, encrypt bool) (i
 		template["last_updated"] = lastUpdated
 		template["version"] = version
 		template["created_at"] = createdAt
+		template["data"] = templateData
+		template["stale"] = stale
 
 		templates = append(templates, template)
 		count++
 	}
+	rows.Close()
+
+	// URL pattern indices and statistics are read once the template rows are closed
+	store := NewFormTemplateStore(bs.db)
+	for _, template := range templates {
+		record, err := store.GetFormTemplate(template["id"].(string))
+		if err != nil {
+			return 0, err
+		}
+		template["patterns"] = record.Patterns
+		if record.Stats != nil {
+			template["stats"] = record.Stats
+		}
+	}
 
 	// Convert to JSON
 	data, err := json.MarshalIndent(templates, "", "  ")
@@ -702,18 +721,53 @@ func (bs *BackupService) restoreTemplates(file *zip.File, encrypted, overwriteEx
 		query := `
 			INSERT OR REPLACE INTO form_templates 
 			(id, url, domain, form_type, fields, selectors, validation_rules, 
-			 success_rate, last_updated, version, created_at)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			 success_rate, last_updated, version, created_at, data, stale)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		`
 
 		_, err := tx.Exec(query,
 			template["id"], template["url"], template["domain"], template["form_type"],
 			template["fields"], template["selectors"], template["validation_rules"],
 			template["success_rate"], template["last_updated"], template["version"],
-			template["created_at"])
+			template["created_at"], template["data"], template["stale"])
 		if err != nil {
 			return count, fmt.Errorf("failed to insert template: %w", err)
 		}
+
+		// Backups made before templates had URL patterns have none to restore
+		if raw, ok := template["patterns"]; ok && raw != nil {
+			encoded, err := json.Marshal(raw)
+			if err != nil {
+				return count, fmt.Errorf("failed to read template patterns: %w", err)
+			}
+			var patterns []TemplatePattern
+			if err := json.Unmarshal(encoded, &patterns); err != nil {
+				return count, fmt.Errorf("failed to read template patterns: %w", err)
+			}
+			id, _ := template["id"].(string)
+			if err := replaceTemplatePatterns(tx, id, patterns); err != nil {
+				return count, err
+			}
+		}
+
+		if raw, ok := template["stats"]; ok && raw != nil {
+			encoded, err := json.Marshal(raw)
+			if err != nil {
+				return count, fmt.Errorf("failed to read template statistics: %w", err)
+			}
+			var stats FormTemplateStats
+			if err := json.Unmarshal(encoded, &stats); err != nil {
+				return count, fmt.Errorf("failed to read template statistics: %w", err)
+			}
+			id, _ := template["id"].(string)
+			successRate, _ := template["success_rate"].(float64)
+			if _, err := tx.Exec("DELETE FROM form_template_field_failures WHERE template_id = ?", id); err != nil {
+				return count, fmt.Errorf("failed to clear field failures: %w", err)
+			}
+			if err := writeFormTemplateStats(tx, id, successRate, &stats); err != nil {
+				return count, err
+			}
+		}
 		count++
 	}
 
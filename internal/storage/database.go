@@ -50,7 +50,10 @@ func NewDatabaseManager(config *DatabaseConfig) (*DatabaseManager, error) {
 		if err := os.MkdirAll(dir, 0755); err != nil {
 			return nil, fmt.Errorf("failed to create database directory: %w", err)
 		}
-		dsn = config.DatabasePath
+		// Wait for concurrent writers instead of failing with "database is locked",
+		// and take the write lock when a transaction starts so read-modify-write
+		// transactions in separate processes can't deadlock
+		dsn = config.DatabasePath + "?_busy_timeout=5000&_txlock=immediate"
 	}
 
 	db, err := sql.Open("sqlite3", dsn)
@@ -58,6 +61,11 @@ func NewDatabaseManager(config *DatabaseConfig) (*DatabaseManager, error) {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
+	// Every connection to ":memory:" opens a separate, empty database
+	if config.InMemory {
+		db.SetMaxOpenConns(1)
+	}
+
 	// Test connection
 	if err := db.Ping(); err != nil {
 		db.Close()
@@ -87,6 +95,9 @@ func (dm *DatabaseManager) CreateTables() error {
 		createUsersTable,
 		createClientProfilesTable,
 		createFormTemplatesTable,
+		createFormTemplatePatternsTable,
+		createTemplateImportsTable,
+		createTemplateFieldFailuresTable,
 		createExecutionSessionsTable,
 		createLearningSessionsTable,
 		createEncryptedDataTable,
@@ -100,6 +111,57 @@ func (dm *DatabaseManager) CreateTables() error {
 		}
 	}
 
+	// Databases created before these columns existed
+	columns := []struct{ table, column, definition string }{
+		{"form_templates", "data", "TEXT"},
+		{"form_templates", "stale", "INTEGER DEFAULT 0"},
+		{"form_templates", "attempts", "INTEGER DEFAULT 0"},
+		{"form_templates", "fills", "INTEGER DEFAULT 0"},
+		{"form_templates", "submissions", "INTEGER DEFAULT 0"},
+		{"form_templates", "verified_submissions", "INTEGER DEFAULT 0"},
+		{"form_templates", "score_sum", "REAL DEFAULT 0.0"},
+		{"form_templates", "score_weight", "REAL DEFAULT 0.0"},
+		{"form_templates", "last_outcome_at", "DATETIME"},
+	}
+	for _, c := range columns {
+		if err := dm.addColumnIfMissing(c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addColumnIfMissing adds a column to an existing table
+func (dm *DatabaseManager) addColumnIfMissing(table, column, definition string) error {
+	rows, err := dm.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+
+	exists := false
+	for rows.Next() {
+		var cid, notNull, primaryKey int
+		var name, columnType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &primaryKey); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan columns of %s: %w", table, err)
+		}
+		if name == column {
+			exists = true
+		}
+	}
+	rows.Close()
+
+	if exists {
+		return nil
+	}
+
+	if _, err := dm.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+
 	return nil
 }
 
@@ -229,12 +291,45 @@ CREATE TABLE IF NOT EXISTS form_templates (
     fields TEXT NOT NULL, -- JSON
     selectors TEXT NOT NULL, -- JSON
     validation_rules TEXT, -- JSON
+    data TEXT, -- JSON of the whole template
     success_rate REAL DEFAULT 0.0,
+    stale INTEGER DEFAULT 0,
+    attempts INTEGER DEFAULT 0,
+    fills INTEGER DEFAULT 0,
+    submissions INTEGER DEFAULT 0,
+    verified_submissions INTEGER DEFAULT 0,
+    score_sum REAL DEFAULT 0.0, -- Decayed sum of run outcomes
+    score_weight REAL DEFAULT 0.0, -- Decayed number of runs
+    last_outcome_at DATETIME,
     last_updated DATETIME DEFAULT CURRENT_TIMESTAMP,
     version INTEGER DEFAULT 1,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );`
 
+const createFormTemplatePatternsTable = `
+CREATE TABLE IF NOT EXISTS form_template_patterns (
+    template_id TEXT NOT NULL,
+    host TEXT NOT NULL, -- Empty for patterns that can match any host
+    pattern TEXT NOT NULL,
+    FOREIGN KEY (template_id) REFERENCES form_templates(id) ON DELETE CASCADE
+);`
+
+const createTemplateFieldFailuresTable = `
+CREATE TABLE IF NOT EXISTS form_template_field_failures (
+    template_id TEXT NOT NULL,
+    field TEXT NOT NULL,
+    failures INTEGER DEFAULT 0,
+    PRIMARY KEY (template_id, field),
+    FOREIGN KEY (template_id) REFERENCES form_templates(id) ON DELETE CASCADE
+);`
+
+const createTemplateImportsTable = `
+CREATE TABLE IF NOT EXISTS template_imports (
+    name TEXT PRIMARY KEY,
+    template_count INTEGER DEFAULT 0,
+    imported_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);`
+
 const createExecutionSessionsTable = `
 CREATE TABLE IF NOT EXISTS execution_sessions (
     id TEXT PRIMARY KEY,
@@ -291,6 +386,8 @@ const createIndices = `
 CREATE INDEX IF NOT EXISTS idx_client_profiles_user_id ON client_profiles(user_id);
 CREATE INDEX IF NOT EXISTS idx_form_templates_domain ON form_templates(domain);
 CREATE INDEX IF NOT EXISTS idx_form_templates_url ON form_templates(url);
+CREATE INDEX IF NOT EXISTS idx_form_template_patterns_host ON form_template_patterns(host);
+CREATE INDEX IF NOT EXISTS idx_form_template_patterns_template_id ON form_template_patterns(template_id);
 CREATE INDEX IF NOT EXISTS idx_execution_sessions_user_id ON execution_sessions(user_id);
 CREATE INDEX IF NOT EXISTS idx_execution_sessions_profile_id ON execution_sessions(profile_id);
 CREATE INDEX IF NOT EXISTS idx_execution_sessions_status ON execution_sessions(status);
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// LearningSession records a template learning event, such as a template
+// being healed after its selectors stopped matching
+type LearningSession struct {
+	ID           string    `json:"id"`
+	URL          string    `json:"url"`
+	TemplateID   string    `json:"templateId"`
+	AnalysisData string    `json:"analysisData"` // JSON
+	Improvements string    `json:"improvements"` // JSON
+	SuccessRate  float64   `json:"successRate"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// LearningSessionStore persists learning sessions in the learning_sessions table
+type LearningSessionStore struct {
+	db *DatabaseManager
+}
+
+// NewLearningSessionStore creates a new learning session store
+func NewLearningSessionStore(db *DatabaseManager) *LearningSessionStore {
+	return &LearningSessionStore{
+		db: db,
+	}
+}
+
+// RecordLearningSession stores a learning session
+func (ls *LearningSessionStore) RecordLearningSession(session *LearningSession) error {
+	if session.ID == "" {
+		return fmt.Errorf("learning session ID cannot be empty")
+	}
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO learning_sessions
+		(id, url, template_id, analysis_data, improvements, success_rate, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := ls.db.GetDB().Exec(query,
+		session.ID,
+		session.URL,
+		session.TemplateID,
+		session.AnalysisData,
+		session.Improvements,
+		session.SuccessRate,
+		session.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record learning session: %w", err)
+	}
+
+	return nil
+}
+
+// ListLearningSessions returns the learning sessions for a template, newest first
+func (ls *LearningSessionStore) ListLearningSessions(templateID string) ([]*LearningSession, error) {
+	query := `
+		SELECT id, url, COALESCE(template_id, ''), analysis_data, COALESCE(improvements, ''),
+		       COALESCE(success_rate, 0), created_at
+		FROM learning_sessions
+		WHERE template_id = ?
+		ORDER BY created_at DESC
+	`
+
+	rows, err := ls.db.GetDB().Query(query, templateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list learning sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*LearningSession
+	for rows.Next() {
+		session := &LearningSession{}
+		if err := rows.Scan(
+			&session.ID,
+			&session.URL,
+			&session.TemplateID,
+			&session.AnalysisData,
+			&session.Improvements,
+			&session.SuccessRate,
+			&session.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan learning session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return sessions, nil
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLearningSessionStore(t *testing.T) {
+	dm, err := NewDatabaseManager(&DatabaseConfig{
+		InMemory:     true,
+		CreateTables: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database manager: %v", err)
+	}
+	defer dm.Close()
+
+	store := NewLearningSessionStore(dm)
+
+	older := &LearningSession{
+		ID:           "heal_1",
+		URL:          "https://example.com/signup",
+		TemplateID:   "template_1",
+		AnalysisData: `{"forms":1}`,
+		Improvements: `{"matches":[]}`,
+		SuccessRate:  50,
+		CreatedAt:    time.Now().Add(-time.Hour),
+	}
+	newer := &LearningSession{
+		ID:           "heal_2",
+		URL:          "https://example.com/signup",
+		TemplateID:   "template_1",
+		AnalysisData: `{"forms":1}`,
+		SuccessRate:  100,
+	}
+
+	for _, session := range []*LearningSession{older, newer} {
+		if err := store.RecordLearningSession(session); err != nil {
+			t.Fatalf("Failed to record learning session: %v", err)
+		}
+	}
+
+	if err := store.RecordLearningSession(&LearningSession{}); err == nil {
+		t.Error("Expected error for learning session without ID")
+	}
+
+	sessions, err := store.ListLearningSessions("template_1")
+	if err != nil {
+		t.Fatalf("Failed to list learning sessions: %v", err)
+	}
+
+	if len(sessions) != 2 {
+		t.Fatalf("Expected 2 learning sessions, got %d", len(sessions))
+	}
+
+	if sessions[0].ID != "heal_2" {
+		t.Errorf("Expected newest session first, got %s", sessions[0].ID)
+	}
+
+	if sessions[1].Improvements != `{"matches":[]}` {
+		t.Errorf("Expected improvements to round-trip, got %s", sessions[1].Improvements)
+	}
+}
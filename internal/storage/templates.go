@@ -0,0 +1,459 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrFormTemplateNotFound is returned when no form template has the requested ID
+var ErrFormTemplateNotFound = errors.New("form template not found")
+
+// FormTemplateRecord is a row of the form_templates table. The whole template
+// is kept as JSON in Data; the other columns are indexed or updated in place.
+type FormTemplateRecord struct {
+	ID              string             `json:"id"`
+	URL             string             `json:"url"`
+	Domain          string             `json:"domain"`
+	FormType        string             `json:"formType"`
+	Fields          string             `json:"fields"`          // JSON
+	Selectors       string             `json:"selectors"`       // JSON
+	ValidationRules string             `json:"validationRules"` // JSON
+	Data            string             `json:"data"`            // JSON of the whole template
+	Patterns        []TemplatePattern  `json:"patterns"`
+	Stats           *FormTemplateStats `json:"stats,omitempty"` // Nil when the template has no recorded runs
+	SuccessRate     float64            `json:"successRate"`
+	Stale           bool               `json:"stale"`
+	Version         int                `json:"version"`
+	LastUpdated     time.Time          `json:"lastUpdated"`
+	CreatedAt       time.Time          `json:"createdAt"`
+}
+
+// FormTemplateStats are the run counters of a template. They are only
+// changed through UpdateFormTemplateStats so concurrent runs aren't lost.
+type FormTemplateStats struct {
+	Attempts            int            `json:"attempts"`
+	Fills               int            `json:"fills"`
+	Submissions         int            `json:"submissions"`
+	VerifiedSubmissions int            `json:"verifiedSubmissions"`
+	ScoreSum            float64        `json:"scoreSum"`
+	ScoreWeight         float64        `json:"scoreWeight"`
+	LastOutcomeAt       time.Time      `json:"lastOutcomeAt"`
+	FieldFailures       map[string]int `json:"fieldFailures"`
+}
+
+// TemplatePattern indexes a template URL pattern by the host it applies to.
+// Host is empty for patterns that could match any host.
+type TemplatePattern struct {
+	Host    string `json:"host"`
+	Pattern string `json:"pattern"`
+}
+
+// FormTemplateStore persists form templates in the form_templates table
+type FormTemplateStore struct {
+	db *DatabaseManager
+}
+
+// NewFormTemplateStore creates a new form template store
+func NewFormTemplateStore(db *DatabaseManager) *FormTemplateStore {
+	return &FormTemplateStore{
+		db: db,
+	}
+}
+
+const selectFormTemplate = `
+	SELECT id, url, domain, form_type, fields, selectors, COALESCE(validation_rules, ''),
+	       COALESCE(data, ''), COALESCE(success_rate, 0), COALESCE(stale, 0), COALESCE(version, 1),
+	       last_updated, created_at,
+	       COALESCE(attempts, 0), COALESCE(fills, 0), COALESCE(submissions, 0), COALESCE(verified_submissions, 0),
+	       COALESCE(score_sum, 0), COALESCE(score_weight, 0), last_outcome_at
+	FROM form_templates
+`
+
+// SaveFormTemplate inserts or replaces a template and its URL patterns.
+// The success rate and statistics of an existing template are kept, since
+// they are updated in place by UpdateFormTemplateStats.
+func (fs *FormTemplateStore) SaveFormTemplate(record *FormTemplateRecord) error {
+	if record.ID == "" {
+		return fmt.Errorf("form template ID cannot be empty")
+	}
+	if record.LastUpdated.IsZero() {
+		record.LastUpdated = time.Now()
+	}
+
+	return fs.db.ExecuteInTransaction(func(tx *sql.Tx) error {
+		var exists int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM form_templates WHERE id = ?", record.ID).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check form template: %w", err)
+		}
+
+		query := `
+			INSERT INTO form_templates
+			(id, url, domain, form_type, fields, selectors, validation_rules, data,
+			 success_rate, stale, version, last_updated)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET
+				url = excluded.url,
+				domain = excluded.domain,
+				form_type = excluded.form_type,
+				fields = excluded.fields,
+				selectors = excluded.selectors,
+				validation_rules = excluded.validation_rules,
+				data = excluded.data,
+				stale = excluded.stale,
+				version = excluded.version,
+				last_updated = excluded.last_updated
+		`
+
+		_, err := tx.Exec(query,
+			record.ID,
+			record.URL,
+			record.Domain,
+			record.FormType,
+			record.Fields,
+			record.Selectors,
+			record.ValidationRules,
+			record.Data,
+			record.SuccessRate,
+			record.Stale,
+			record.Version,
+			record.LastUpdated,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save form template: %w", err)
+		}
+
+		// New templates start with the statistics they carry, e.g. when imported
+		if exists == 0 && record.Stats != nil {
+			if err := writeFormTemplateStats(tx, record.ID, record.SuccessRate, record.Stats); err != nil {
+				return err
+			}
+		}
+
+		return replaceTemplatePatterns(tx, record.ID, record.Patterns)
+	})
+}
+
+// replaceTemplatePatterns rewrites the pattern index of a template
+func replaceTemplatePatterns(tx *sql.Tx, templateID string, patterns []TemplatePattern) error {
+	if _, err := tx.Exec("DELETE FROM form_template_patterns WHERE template_id = ?", templateID); err != nil {
+		return fmt.Errorf("failed to clear template patterns: %w", err)
+	}
+
+	for _, pattern := range patterns {
+		_, err := tx.Exec(
+			"INSERT INTO form_template_patterns (template_id, host, pattern) VALUES (?, ?, ?)",
+			templateID, strings.ToLower(pattern.Host), pattern.Pattern,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save template pattern: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetFormTemplate loads a template by ID
+func (fs *FormTemplateStore) GetFormTemplate(id string) (*FormTemplateRecord, error) {
+	records, err := fs.queryFormTemplates(selectFormTemplate+" WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrFormTemplateNotFound, id)
+	}
+
+	return records[0], nil
+}
+
+// ListFormTemplates returns every template, most recently updated first
+func (fs *FormTemplateStore) ListFormTemplates() ([]*FormTemplateRecord, error) {
+	return fs.queryFormTemplates(selectFormTemplate + " ORDER BY last_updated DESC")
+}
+
+// ListFormTemplatesByDomain returns the templates learned on a domain
+func (fs *FormTemplateStore) ListFormTemplatesByDomain(domain string) ([]*FormTemplateRecord, error) {
+	return fs.queryFormTemplates(selectFormTemplate+" WHERE domain = ? ORDER BY success_rate DESC", domain)
+}
+
+// FindFormTemplateCandidates returns the templates that could apply to a
+// URL: those learned on it or on its host, and those with a URL pattern for
+// one of the given hosts or for any host. Callers pass the URL's host and
+// its parent domains so subdomain wildcards are found through the index.
+func (fs *FormTemplateStore) FindFormTemplateCandidates(url, domain string, patternHosts []string) ([]*FormTemplateRecord, error) {
+	hosts := append([]string{""}, patternHosts...)
+	placeholders := make([]string, len(hosts))
+	args := []interface{}{url, domain}
+	for i, host := range hosts {
+		placeholders[i] = "?"
+		args = append(args, strings.ToLower(host))
+	}
+
+	query := selectFormTemplate + `
+		WHERE url = ? OR domain = ? OR id IN (
+			SELECT template_id FROM form_template_patterns WHERE host IN (` + strings.Join(placeholders, ", ") + `)
+		)
+	`
+
+	return fs.queryFormTemplates(query, args...)
+}
+
+// UpdateFormTemplateStats reads a template's statistics, lets update change
+// them and writes them back in one transaction, so concurrent runs from any
+// process are all counted. update returns the template's new success rate.
+func (fs *FormTemplateStore) UpdateFormTemplateStats(id string, update func(stats *FormTemplateStats) float64) error {
+	return fs.db.ExecuteInTransaction(func(tx *sql.Tx) error {
+		stats := &FormTemplateStats{FieldFailures: make(map[string]int)}
+		var lastOutcome sql.NullTime
+
+		err := tx.QueryRow(`
+			SELECT COALESCE(attempts, 0), COALESCE(fills, 0), COALESCE(submissions, 0),
+			       COALESCE(verified_submissions, 0), COALESCE(score_sum, 0), COALESCE(score_weight, 0),
+			       last_outcome_at
+			FROM form_templates WHERE id = ?
+		`, id).Scan(
+			&stats.Attempts,
+			&stats.Fills,
+			&stats.Submissions,
+			&stats.VerifiedSubmissions,
+			&stats.ScoreSum,
+			&stats.ScoreWeight,
+			&lastOutcome,
+		)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: %s", ErrFormTemplateNotFound, id)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read template statistics: %w", err)
+		}
+		stats.LastOutcomeAt = lastOutcome.Time
+
+		failures, err := queryFieldFailures(tx, id)
+		if err != nil {
+			return err
+		}
+		stats.FieldFailures = failures
+
+		successRate := update(stats)
+
+		return writeFormTemplateStats(tx, id, successRate, stats)
+	})
+}
+
+// writeFormTemplateStats stores a template's statistics and success rate
+func writeFormTemplateStats(tx *sql.Tx, id string, successRate float64, stats *FormTemplateStats) error {
+	var lastOutcome interface{}
+	if !stats.LastOutcomeAt.IsZero() {
+		lastOutcome = stats.LastOutcomeAt
+	}
+
+	_, err := tx.Exec(`
+		UPDATE form_templates
+		SET attempts = ?, fills = ?, submissions = ?, verified_submissions = ?,
+		    score_sum = ?, score_weight = ?, last_outcome_at = ?, success_rate = ?
+		WHERE id = ?
+	`,
+		stats.Attempts,
+		stats.Fills,
+		stats.Submissions,
+		stats.VerifiedSubmissions,
+		stats.ScoreSum,
+		stats.ScoreWeight,
+		lastOutcome,
+		successRate,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update template statistics: %w", err)
+	}
+
+	for field, failures := range stats.FieldFailures {
+		_, err := tx.Exec(`
+			INSERT INTO form_template_field_failures (template_id, field, failures) VALUES (?, ?, ?)
+			ON CONFLICT(template_id, field) DO UPDATE SET failures = excluded.failures
+		`, id, field, failures)
+		if err != nil {
+			return fmt.Errorf("failed to update field failures: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// queryFieldFailures returns how often each field of a template failed
+func queryFieldFailures(q interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}, templateID string) (map[string]int, error) {
+	rows, err := q.Query("SELECT field, failures FROM form_template_field_failures WHERE template_id = ?", templateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query field failures: %w", err)
+	}
+	defer rows.Close()
+
+	failures := make(map[string]int)
+	for rows.Next() {
+		var field string
+		var count int
+		if err := rows.Scan(&field, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan field failures: %w", err)
+		}
+		failures[field] = count
+	}
+
+	return failures, rows.Err()
+}
+
+// SetFormTemplateStale sets or clears a template's stale flag
+func (fs *FormTemplateStore) SetFormTemplateStale(id string, stale bool) error {
+	res, err := fs.db.GetDB().Exec("UPDATE form_templates SET stale = ? WHERE id = ?", stale, id)
+	if err != nil {
+		return fmt.Errorf("failed to update template: %w", err)
+	}
+
+	if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("%w: %s", ErrFormTemplateNotFound, id)
+	}
+
+	return nil
+}
+
+// DeleteFormTemplate deletes a template and its URL patterns
+func (fs *FormTemplateStore) DeleteFormTemplate(id string) error {
+	return fs.db.ExecuteInTransaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM form_template_patterns WHERE template_id = ?", id); err != nil {
+			return fmt.Errorf("failed to delete template patterns: %w", err)
+		}
+		if _, err := tx.Exec("DELETE FROM form_template_field_failures WHERE template_id = ?", id); err != nil {
+			return fmt.Errorf("failed to delete field failures: %w", err)
+		}
+
+		res, err := tx.Exec("DELETE FROM form_templates WHERE id = ?", id)
+		if err != nil {
+			return fmt.Errorf("failed to delete form template: %w", err)
+		}
+
+		if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+			return fmt.Errorf("%w: %s", ErrFormTemplateNotFound, id)
+		}
+
+		return nil
+	})
+}
+
+// queryFormTemplates runs a template query and loads each row's patterns
+func (fs *FormTemplateStore) queryFormTemplates(query string, args ...interface{}) ([]*FormTemplateRecord, error) {
+	rows, err := fs.db.GetDB().Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query form templates: %w", err)
+	}
+
+	var records []*FormTemplateRecord
+	for rows.Next() {
+		record := &FormTemplateRecord{}
+		stats := &FormTemplateStats{}
+		var lastOutcome sql.NullTime
+		if err := rows.Scan(
+			&record.ID,
+			&record.URL,
+			&record.Domain,
+			&record.FormType,
+			&record.Fields,
+			&record.Selectors,
+			&record.ValidationRules,
+			&record.Data,
+			&record.SuccessRate,
+			&record.Stale,
+			&record.Version,
+			&record.LastUpdated,
+			&record.CreatedAt,
+			&stats.Attempts,
+			&stats.Fills,
+			&stats.Submissions,
+			&stats.VerifiedSubmissions,
+			&stats.ScoreSum,
+			&stats.ScoreWeight,
+			&lastOutcome,
+		); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan form template: %w", err)
+		}
+		if stats.Attempts > 0 {
+			stats.LastOutcomeAt = lastOutcome.Time
+			record.Stats = stats
+		}
+		records = append(records, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	rows.Close()
+
+	// Patterns and failures are loaded after the rows are closed so a single
+	// connection is enough
+	for _, record := range records {
+		patterns, err := fs.templatePatterns(record.ID)
+		if err != nil {
+			return nil, err
+		}
+		record.Patterns = patterns
+
+		if record.Stats != nil {
+			failures, err := queryFieldFailures(fs.db.GetDB(), record.ID)
+			if err != nil {
+				return nil, err
+			}
+			record.Stats.FieldFailures = failures
+		}
+	}
+
+	return records, nil
+}
+
+// templatePatterns returns the indexed URL patterns of a template
+func (fs *FormTemplateStore) templatePatterns(templateID string) ([]TemplatePattern, error) {
+	rows, err := fs.db.GetDB().Query(
+		"SELECT host, pattern FROM form_template_patterns WHERE template_id = ? ORDER BY rowid", templateID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query template patterns: %w", err)
+	}
+	defer rows.Close()
+
+	var patterns []TemplatePattern
+	for rows.Next() {
+		var pattern TemplatePattern
+		if err := rows.Scan(&pattern.Host, &pattern.Pattern); err != nil {
+			return nil, fmt.Errorf("failed to scan template pattern: %w", err)
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	return patterns, rows.Err()
+}
+
+// IsImportDone reports whether a one-time import has already run
+func (fs *FormTemplateStore) IsImportDone(name string) (bool, error) {
+	var count int
+	err := fs.db.GetDB().QueryRow("SELECT COUNT(*) FROM template_imports WHERE name = ?", name).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check template import: %w", err)
+	}
+	return count > 0, nil
+}
+
+// MarkImportDone records that a one-time import has run
+func (fs *FormTemplateStore) MarkImportDone(name string, count int) error {
+	_, err := fs.db.GetDB().Exec(
+		"INSERT OR REPLACE INTO template_imports (name, template_count, imported_at) VALUES (?, ?, ?)",
+		name, count, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record template import: %w", err)
+	}
+	return nil
+}
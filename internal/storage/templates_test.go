@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func newTemplateTestDatabase(t *testing.T) *DatabaseManager {
+	dm, err := NewDatabaseManager(&DatabaseConfig{
+		DatabasePath: filepath.Join(t.TempDir(), "test.db"),
+		CreateTables: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database manager: %v", err)
+	}
+	t.Cleanup(func() { dm.Close() })
+	return dm
+}
+
+func TestFormTemplateStore(t *testing.T) {
+	store := NewFormTemplateStore(newTemplateTestDatabase(t))
+
+	record := &FormTemplateRecord{
+		ID:        "contact",
+		URL:       "https://example.com/contact",
+		Domain:    "example.com",
+		FormType:  "contact",
+		Fields:    "[]",
+		Selectors: "{}",
+		Data:      `{"id":"contact"}`,
+		Version:   1,
+		Patterns:  []TemplatePattern{{Host: "Example.com", Pattern: "example.com/contact/**"}},
+	}
+	if err := store.SaveFormTemplate(record); err != nil {
+		t.Fatalf("Failed to save form template: %v", err)
+	}
+
+	record.Version = 2
+	record.Patterns = []TemplatePattern{{Host: "example.com", Pattern: "*.example.com/contact"}}
+	if err := store.SaveFormTemplate(record); err != nil {
+		t.Fatalf("Failed to update form template: %v", err)
+	}
+
+	loaded, err := store.GetFormTemplate("contact")
+	if err != nil {
+		t.Fatalf("Failed to load form template: %v", err)
+	}
+	if loaded.Version != 2 || loaded.Data != record.Data {
+		t.Errorf("Expected updated template, got version %d data %q", loaded.Version, loaded.Data)
+	}
+	if len(loaded.Patterns) != 1 || loaded.Patterns[0].Pattern != "*.example.com/contact" {
+		t.Errorf("Expected patterns to be replaced, got %+v", loaded.Patterns)
+	}
+
+	candidates, err := store.FindFormTemplateCandidates("https://eu.example.com/contact", "eu.example.com", []string{"eu.example.com", "example.com", "com"})
+	if err != nil || len(candidates) != 1 {
+		t.Errorf("Expected the template to be found through its pattern host, got %d: %v", len(candidates), err)
+	}
+
+	candidates, _ = store.FindFormTemplateCandidates("https://other.test/", "other.test", []string{"other.test", "test"})
+	if len(candidates) != 0 {
+		t.Errorf("Expected no candidates for another host, got %d", len(candidates))
+	}
+
+	if err := store.SetFormTemplateStale("contact", true); err != nil {
+		t.Fatalf("Failed to mark template stale: %v", err)
+	}
+	if loaded, _ := store.GetFormTemplate("contact"); !loaded.Stale {
+		t.Error("Expected template to be stale")
+	}
+
+	if err := store.DeleteFormTemplate("contact"); err != nil {
+		t.Fatalf("Failed to delete form template: %v", err)
+	}
+	if _, err := store.GetFormTemplate("contact"); !errors.Is(err, ErrFormTemplateNotFound) {
+		t.Errorf("Expected ErrFormTemplateNotFound, got %v", err)
+	}
+}
+
+func TestUpdateFormTemplateStatsConcurrent(t *testing.T) {
+	store := NewFormTemplateStore(newTemplateTestDatabase(t))
+
+	record := &FormTemplateRecord{ID: "signup", URL: "https://example.com/signup", Domain: "example.com", FormType: "registration", Fields: "[]", Selectors: "{}"}
+	if err := store.SaveFormTemplate(record); err != nil {
+		t.Fatalf("Failed to save form template: %v", err)
+	}
+
+	const updates = 20
+	var wg sync.WaitGroup
+	for i := 0; i < updates; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := store.UpdateFormTemplateStats("signup", func(stats *FormTemplateStats) float64 {
+				stats.Attempts++
+				stats.FieldFailures["email"]++
+				return float64(stats.Attempts)
+			})
+			if err != nil {
+				t.Errorf("Failed to update statistics: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every update reads the previous one, so none may be lost
+	loaded, _ := store.GetFormTemplate("signup")
+	if loaded.Stats == nil || loaded.Stats.Attempts != updates || loaded.Stats.FieldFailures["email"] != updates {
+		t.Fatalf("Expected %d attempts and email failures, got %+v", updates, loaded.Stats)
+	}
+	if loaded.SuccessRate != updates {
+		t.Errorf("Expected success rate %d, got %f", updates, loaded.SuccessRate)
+	}
+
+	// Saving the template again keeps its statistics
+	record.Version = 2
+	if err := store.SaveFormTemplate(record); err != nil {
+		t.Fatalf("Failed to update form template: %v", err)
+	}
+	if loaded, _ := store.GetFormTemplate("signup"); loaded.Stats == nil || loaded.Stats.Attempts != updates || loaded.SuccessRate != updates {
+		t.Errorf("Expected statistics to survive a save, got %+v", loaded.Stats)
+	}
+
+	err := store.UpdateFormTemplateStats("missing", func(stats *FormTemplateStats) float64 { return 0 })
+	if !errors.Is(err, ErrFormTemplateNotFound) {
+		t.Errorf("Expected ErrFormTemplateNotFound, got %v", err)
+	}
+}
+
+func TestTemplateImportMarker(t *testing.T) {
+	store := NewFormTemplateStore(newTemplateTestDatabase(t))
+
+	if done, err := store.IsImportDone("templates_dir:/tmp/templates"); err != nil || done {
+		t.Fatalf("Expected import not done, got %v %v", done, err)
+	}
+	if err := store.MarkImportDone("templates_dir:/tmp/templates", 3); err != nil {
+		t.Fatalf("Failed to mark import done: %v", err)
+	}
+	if done, _ := store.IsImportDone("templates_dir:/tmp/templates"); !done {
+		t.Error("Expected import to be done")
+	}
+}
@@ -0,0 +1,482 @@
+// Package sysmetrics reads host and container resource usage from /proc and
+// the cgroup filesystem on Linux
+package sysmetrics
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnsupported is returned on platforms without /proc
+var ErrUnsupported = errors.New("system metrics are not available on this platform")
+
+// v1 cgroups report "unlimited" memory as a very large page-aligned number
+const unlimitedMemory = int64(1) << 60
+
+// Snapshot is a point-in-time view of resource usage. When running inside a
+// cgroup with CPU or memory limits, the figures are relative to those limits.
+type Snapshot struct {
+	CPUPercent      float64    `json:"cpuPercent"`
+	CPUCores        float64    `json:"cpuCores"` // Cores available, fractional under a CPU quota
+	MemoryTotal     int64      `json:"memoryTotal"`
+	MemoryUsed      int64      `json:"memoryUsed"`
+	MemoryAvailable int64      `json:"memoryAvailable"`
+	MemoryPercent   float64    `json:"memoryPercent"`
+	LoadAverage     [3]float64 `json:"loadAverage"`
+	ChildRSS        int64      `json:"childRss"` // Resident memory of all descendant processes
+	ChildProcesses  int        `json:"childProcesses"`
+	CgroupVersion   int        `json:"cgroupVersion"` // 0 when no cgroup filesystem was found
+	CPULimited      bool       `json:"cpuLimited"`
+	MemoryLimited   bool       `json:"memoryLimited"`
+	Timestamp       time.Time  `json:"timestamp"`
+}
+
+// Sampler computes CPU utilisation from the difference between samples
+type Sampler struct {
+	procRoot   string
+	cgroupRoot string
+	rootPID    int
+	mutex      sync.Mutex
+	lastHost   cpuTimes
+	lastCgroup int64 // Cumulative cgroup CPU usage in nanoseconds
+	lastTime   time.Time
+}
+
+// cpuTimes holds cumulative jiffies from the aggregate line of /proc/stat
+type cpuTimes struct {
+	total uint64
+	idle  uint64
+}
+
+// cgroupInfo describes the cgroup the process runs in
+type cgroupInfo struct {
+	version    int
+	cpuDir     string
+	cpuacctDir string // v1 only; v2 reports usage in cpu.stat
+	memoryDir  string
+}
+
+// NewSampler creates a sampler for the current process
+func NewSampler() *Sampler {
+	return newSampler("/proc", "/sys/fs/cgroup", os.Getpid())
+}
+
+// newSampler creates a sampler reading from the given filesystem roots
+func newSampler(procRoot, cgroupRoot string, rootPID int) *Sampler {
+	return &Sampler{
+		procRoot:   procRoot,
+		cgroupRoot: cgroupRoot,
+		rootPID:    rootPID,
+	}
+}
+
+// Sample reads current resource usage. The first CPU figure is averaged
+// since boot; later ones cover the interval since the previous sample.
+func (s *Sampler) Sample() (*Snapshot, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	hostCPU, err := s.readCPUTimes()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) || runtime.GOOS != "linux" {
+			return nil, ErrUnsupported
+		}
+		return nil, err
+	}
+
+	memTotal, memAvailable, err := s.readMemInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	snapshot := &Snapshot{
+		CPUCores:        float64(runtime.NumCPU()),
+		MemoryTotal:     memTotal,
+		MemoryAvailable: memAvailable,
+		MemoryUsed:      memTotal - memAvailable,
+		Timestamp:       now,
+	}
+
+	// Host CPU utilisation
+	deltaTotal := hostCPU.total - s.lastHost.total
+	deltaIdle := hostCPU.idle - s.lastHost.idle
+	if deltaTotal > 0 {
+		snapshot.CPUPercent = float64(deltaTotal-deltaIdle) / float64(deltaTotal) * 100
+	}
+
+	// Container limits take precedence over host figures
+	cgroup := s.detectCgroup()
+	snapshot.CgroupVersion = cgroup.version
+
+	if quota, ok := s.readCPUQuota(cgroup); ok {
+		snapshot.CPULimited = true
+		snapshot.CPUCores = quota
+
+		usage, err := s.readCgroupCPUUsage(cgroup)
+		if err == nil {
+			if !s.lastTime.IsZero() {
+				elapsed := now.Sub(s.lastTime).Nanoseconds()
+				if elapsed > 0 {
+					snapshot.CPUPercent = float64(usage-s.lastCgroup) / (float64(elapsed) * quota) * 100
+				}
+			}
+			s.lastCgroup = usage
+		}
+	}
+
+	if limit, used, ok := s.readCgroupMemory(cgroup); ok && limit < memTotal {
+		snapshot.MemoryLimited = true
+		snapshot.MemoryTotal = limit
+		snapshot.MemoryUsed = used
+		snapshot.MemoryAvailable = limit - used
+		if snapshot.MemoryAvailable < 0 {
+			snapshot.MemoryAvailable = 0
+		}
+	}
+
+	if snapshot.MemoryTotal > 0 {
+		snapshot.MemoryPercent = float64(snapshot.MemoryUsed) / float64(snapshot.MemoryTotal) * 100
+	}
+	snapshot.CPUPercent = math.Max(0, math.Min(100, snapshot.CPUPercent))
+
+	if load, err := s.readLoadAverage(); err == nil {
+		snapshot.LoadAverage = load
+	}
+
+	snapshot.ChildRSS, snapshot.ChildProcesses = s.readChildRSS()
+
+	s.lastHost = hostCPU
+	s.lastTime = now
+
+	return snapshot, nil
+}
+
+// readCPUTimes parses the aggregate cpu line of /proc/stat
+func (s *Sampler) readCPUTimes() (cpuTimes, error) {
+	file, err := os.Open(filepath.Join(s.procRoot, "stat"))
+	if err != nil {
+		return cpuTimes{}, fmt.Errorf("failed to read cpu stats: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+
+		var times cpuTimes
+		for i, field := range fields[1:] {
+			value, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return cpuTimes{}, fmt.Errorf("failed to parse cpu stats: %w", err)
+			}
+			// guest and guest_nice are already included in user and nice
+			if i < 8 {
+				times.total += value
+			}
+			// idle and iowait
+			if i == 3 || i == 4 {
+				times.idle += value
+			}
+		}
+		return times, nil
+	}
+
+	return cpuTimes{}, fmt.Errorf("failed to parse cpu stats: no aggregate cpu line")
+}
+
+// readMemInfo returns total and available memory in bytes
+func (s *Sampler) readMemInfo() (total int64, available int64, err error) {
+	values, err := readKeyValueFile(filepath.Join(s.procRoot, "meminfo"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read memory info: %w", err)
+	}
+
+	total = values["MemTotal"] * 1024
+	if value, ok := values["MemAvailable"]; ok {
+		available = value * 1024
+	} else {
+		// Kernels before 3.14 do not report MemAvailable
+		available = (values["MemFree"] + values["Buffers"] + values["Cached"]) * 1024
+	}
+
+	return total, available, nil
+}
+
+// readLoadAverage parses /proc/loadavg
+func (s *Sampler) readLoadAverage() ([3]float64, error) {
+	var load [3]float64
+
+	data, err := os.ReadFile(filepath.Join(s.procRoot, "loadavg"))
+	if err != nil {
+		return load, err
+	}
+
+	fields := strings.Fields(string(data))
+	for i := 0; i < 3 && i < len(fields); i++ {
+		load[i], _ = strconv.ParseFloat(fields[i], 64)
+	}
+
+	return load, nil
+}
+
+// detectCgroup locates the CPU and memory controllers for this process
+func (s *Sampler) detectCgroup() cgroupInfo {
+	paths := make(map[string]string)
+	if data, err := os.ReadFile(filepath.Join(s.procRoot, "self", "cgroup")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			parts := strings.SplitN(line, ":", 3)
+			if len(parts) != 3 {
+				continue
+			}
+			for _, controller := range strings.Split(parts[1], ",") {
+				paths[controller] = parts[2]
+			}
+		}
+	}
+
+	// Inside a container the cgroup namespace usually makes the process's
+	// own cgroup the root of the mount, so fall back to the root directory
+	resolve := func(base, path string) string {
+		if path != "" && path != "/" {
+			if dir := filepath.Join(base, path); isDir(dir) {
+				return dir
+			}
+		}
+		return base
+	}
+
+	if fileExists(filepath.Join(s.cgroupRoot, "cgroup.controllers")) {
+		dir := resolve(s.cgroupRoot, paths[""])
+		return cgroupInfo{version: 2, cpuDir: dir, memoryDir: dir}
+	}
+
+	info := cgroupInfo{version: 1}
+	for _, name := range []string{"cpu", "cpu,cpuacct", "cpuacct,cpu"} {
+		if dir := filepath.Join(s.cgroupRoot, name); isDir(dir) {
+			info.cpuDir = resolve(dir, paths["cpu"])
+			break
+		}
+	}
+	for _, name := range []string{"cpuacct", "cpu,cpuacct", "cpuacct,cpu"} {
+		if dir := filepath.Join(s.cgroupRoot, name); isDir(dir) {
+			info.cpuacctDir = resolve(dir, paths["cpuacct"])
+			break
+		}
+	}
+	if dir := filepath.Join(s.cgroupRoot, "memory"); isDir(dir) {
+		info.memoryDir = resolve(dir, paths["memory"])
+	}
+	if info.cpuDir == "" && info.memoryDir == "" {
+		info.version = 0
+	}
+
+	return info
+}
+
+// readCPUQuota returns the number of cores allowed by a CPU quota
+func (s *Sampler) readCPUQuota(cgroup cgroupInfo) (float64, bool) {
+	var quota, period float64
+
+	switch cgroup.version {
+	case 2:
+		fields := strings.Fields(readString(filepath.Join(cgroup.cpuDir, "cpu.max")))
+		if len(fields) != 2 || fields[0] == "max" {
+			return 0, false
+		}
+		quota, _ = strconv.ParseFloat(fields[0], 64)
+		period, _ = strconv.ParseFloat(fields[1], 64)
+	case 1:
+		quota, _ = strconv.ParseFloat(readString(filepath.Join(cgroup.cpuDir, "cpu.cfs_quota_us")), 64)
+		period, _ = strconv.ParseFloat(readString(filepath.Join(cgroup.cpuDir, "cpu.cfs_period_us")), 64)
+	default:
+		return 0, false
+	}
+
+	if quota <= 0 || period <= 0 {
+		return 0, false
+	}
+
+	return quota / period, true
+}
+
+// readCgroupCPUUsage returns cumulative cgroup CPU time in nanoseconds
+func (s *Sampler) readCgroupCPUUsage(cgroup cgroupInfo) (int64, error) {
+	switch cgroup.version {
+	case 2:
+		values, err := readKeyValueFile(filepath.Join(cgroup.cpuDir, "cpu.stat"))
+		if err != nil {
+			return 0, err
+		}
+		return values["usage_usec"] * 1000, nil
+	case 1:
+		if value := readString(filepath.Join(cgroup.cpuacctDir, "cpuacct.usage")); value != "" {
+			return strconv.ParseInt(value, 10, 64)
+		}
+	}
+
+	return 0, fmt.Errorf("cgroup cpu usage not available")
+}
+
+// readCgroupMemory returns the cgroup memory limit and working set in bytes.
+// Inactive file cache is excluded because the kernel reclaims it under pressure.
+func (s *Sampler) readCgroupMemory(cgroup cgroupInfo) (limit int64, used int64, ok bool) {
+	var limitFile, usageFile, inactiveKey string
+
+	switch cgroup.version {
+	case 2:
+		limitFile, usageFile, inactiveKey = "memory.max", "memory.current", "inactive_file"
+	case 1:
+		limitFile, usageFile, inactiveKey = "memory.limit_in_bytes", "memory.usage_in_bytes", "total_inactive_file"
+	default:
+		return 0, 0, false
+	}
+
+	limitValue := readString(filepath.Join(cgroup.memoryDir, limitFile))
+	if limitValue == "" || limitValue == "max" {
+		return 0, 0, false
+	}
+
+	limit, err := strconv.ParseInt(limitValue, 10, 64)
+	if err != nil || limit <= 0 || limit >= unlimitedMemory {
+		return 0, 0, false
+	}
+
+	used, err = strconv.ParseInt(readString(filepath.Join(cgroup.memoryDir, usageFile)), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	if stats, err := readKeyValueFile(filepath.Join(cgroup.memoryDir, "memory.stat")); err == nil {
+		if inactive := stats[inactiveKey]; inactive < used {
+			used -= inactive
+		}
+	}
+
+	return limit, used, true
+}
+
+// readChildRSS sums the resident memory of all descendants of the root
+// process, which covers the Playwright driver and its browser processes
+func (s *Sampler) readChildRSS() (int64, int) {
+	entries, err := os.ReadDir(s.procRoot)
+	if err != nil {
+		return 0, 0
+	}
+
+	children := make(map[int][]int)
+	rss := make(map[int]int64)
+	pageSize := int64(os.Getpagesize())
+
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		ppid, pages, err := parseProcessStat(readString(filepath.Join(s.procRoot, entry.Name(), "stat")))
+		if err != nil {
+			continue
+		}
+
+		children[ppid] = append(children[ppid], pid)
+		rss[pid] = pages * pageSize
+	}
+
+	var total int64
+	count := 0
+	queue := append([]int(nil), children[s.rootPID]...)
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+
+		total += rss[pid]
+		count++
+		queue = append(queue, children[pid]...)
+	}
+
+	return total, count
+}
+
+// parseProcessStat extracts the parent PID and resident pages from
+// /proc/<pid>/stat. The command name may contain spaces and parentheses.
+func parseProcessStat(stat string) (ppid int, rssPages int64, err error) {
+	end := strings.LastIndex(stat, ")")
+	if end < 0 {
+		return 0, 0, fmt.Errorf("malformed process stat")
+	}
+
+	// Fields after the command name start at field 3 (state)
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 22 {
+		return 0, 0, fmt.Errorf("malformed process stat")
+	}
+
+	ppid, err = strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	rssPages, err = strconv.ParseInt(fields[21], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return ppid, rssPages, nil
+}
+
+// readKeyValueFile parses files of "key value [unit]" or "key: value [unit]" lines
+func readKeyValueFile(path string) (map[string]int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := make(map[string]int64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		if value, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			values[strings.TrimSuffix(fields[0], ":")] = value
+		}
+	}
+
+	return values, scanner.Err()
+}
+
+// readString returns the trimmed contents of a file, or "" if unreadable
+func readString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// fileExists reports whether path exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// isDir reports whether path is a directory
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,165 @@
+package sysmetrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFile creates a file and its parent directories under root
+func writeFile(t *testing.T, root, name, content string) {
+	t.Helper()
+
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+}
+
+// processStat builds a /proc/<pid>/stat line with the given parent and RSS pages
+func processStat(pid, comm, ppid, rssPages string) string {
+	return pid + " (" + comm + ") S " + ppid + " 1 1 0 -1 4194560 100 0 0 0 10 5 0 0 20 0 1 0 100 1000000 " + rssPages + " 18446744073709551615\n"
+}
+
+// newFakeProc creates a minimal /proc tree
+func newFakeProc(t *testing.T) string {
+	procRoot := t.TempDir()
+
+	writeFile(t, procRoot, "stat", "cpu  100 0 100 700 100 0 0 0 0 0\ncpu0 100 0 100 700 100 0 0 0 0 0\n")
+	writeFile(t, procRoot, "meminfo", "MemTotal:       8000000 kB\nMemFree:        1000000 kB\nMemAvailable:   6000000 kB\n")
+	writeFile(t, procRoot, "loadavg", "0.50 1.25 2.00 1/100 1234\n")
+
+	// 100 is this process, 200 the driver, 300 a renderer with spaces in its name
+	writeFile(t, procRoot, "100/stat", processStat("100", "ai-form-filler", "1", "1000"))
+	writeFile(t, procRoot, "200/stat", processStat("200", "node", "100", "2000"))
+	writeFile(t, procRoot, "300/stat", processStat("300", "chrome (renderer)", "200", "3000"))
+	writeFile(t, procRoot, "400/stat", processStat("400", "unrelated", "1", "9000"))
+
+	return procRoot
+}
+
+func TestSampleHost(t *testing.T) {
+	procRoot := newFakeProc(t)
+	sampler := newSampler(procRoot, t.TempDir(), 100)
+
+	snapshot, err := sampler.Sample()
+	if err != nil {
+		t.Fatalf("Failed to sample: %v", err)
+	}
+
+	if snapshot.CPUPercent != 20 {
+		t.Errorf("Expected 20%% CPU since boot, got %.2f", snapshot.CPUPercent)
+	}
+
+	if snapshot.MemoryTotal != 8000000*1024 || snapshot.MemoryUsed != 2000000*1024 {
+		t.Errorf("Unexpected memory figures: total=%d used=%d", snapshot.MemoryTotal, snapshot.MemoryUsed)
+	}
+
+	if snapshot.MemoryPercent != 25 {
+		t.Errorf("Expected 25%% memory usage, got %.2f", snapshot.MemoryPercent)
+	}
+
+	if snapshot.LoadAverage != [3]float64{0.5, 1.25, 2} {
+		t.Errorf("Unexpected load average: %v", snapshot.LoadAverage)
+	}
+
+	if snapshot.ChildProcesses != 2 {
+		t.Errorf("Expected 2 child processes, got %d", snapshot.ChildProcesses)
+	}
+
+	expectedRSS := int64(5000 * os.Getpagesize())
+	if snapshot.ChildRSS != expectedRSS {
+		t.Errorf("Expected child RSS %d, got %d", expectedRSS, snapshot.ChildRSS)
+	}
+
+	// The second sample only covers the time since the first
+	writeFile(t, procRoot, "stat", "cpu  150 0 150 750 150 0 0 0 0 0\n")
+	snapshot, err = sampler.Sample()
+	if err != nil {
+		t.Fatalf("Failed to sample: %v", err)
+	}
+
+	if snapshot.CPUPercent != 50 {
+		t.Errorf("Expected 50%% CPU between samples, got %.2f", snapshot.CPUPercent)
+	}
+}
+
+func TestSampleCgroupV2(t *testing.T) {
+	procRoot := newFakeProc(t)
+	cgroupRoot := t.TempDir()
+
+	writeFile(t, procRoot, "self/cgroup", "0::/\n")
+	writeFile(t, cgroupRoot, "cgroup.controllers", "cpu memory\n")
+	writeFile(t, cgroupRoot, "cpu.max", "150000 100000\n")
+	writeFile(t, cgroupRoot, "cpu.stat", "usage_usec 1000\n")
+	writeFile(t, cgroupRoot, "memory.max", "2147483648\n")
+	writeFile(t, cgroupRoot, "memory.current", "1207959552\n")
+	writeFile(t, cgroupRoot, "memory.stat", "anon 1000\ninactive_file 134217728\n")
+
+	snapshot, err := newSampler(procRoot, cgroupRoot, 100).Sample()
+	if err != nil {
+		t.Fatalf("Failed to sample: %v", err)
+	}
+
+	if snapshot.CgroupVersion != 2 || !snapshot.CPULimited || !snapshot.MemoryLimited {
+		t.Errorf("Expected cgroup v2 limits to be detected, got %+v", snapshot)
+	}
+
+	if snapshot.CPUCores != 1.5 {
+		t.Errorf("Expected 1.5 cores, got %.2f", snapshot.CPUCores)
+	}
+
+	if snapshot.MemoryTotal != 2147483648 || snapshot.MemoryUsed != 1073741824 {
+		t.Errorf("Unexpected cgroup memory: total=%d used=%d", snapshot.MemoryTotal, snapshot.MemoryUsed)
+	}
+
+	if snapshot.MemoryPercent != 50 {
+		t.Errorf("Expected 50%% memory usage, got %.2f", snapshot.MemoryPercent)
+	}
+}
+
+func TestSampleCgroupV1Unlimited(t *testing.T) {
+	procRoot := newFakeProc(t)
+	cgroupRoot := t.TempDir()
+
+	writeFile(t, procRoot, "self/cgroup", "4:memory:/docker/abc\n3:cpu,cpuacct:/docker/abc\n")
+	writeFile(t, cgroupRoot, "cpu,cpuacct/cpu.cfs_quota_us", "-1\n")
+	writeFile(t, cgroupRoot, "cpu,cpuacct/cpu.cfs_period_us", "100000\n")
+	writeFile(t, cgroupRoot, "memory/memory.limit_in_bytes", "9223372036854771712\n")
+	writeFile(t, cgroupRoot, "memory/memory.usage_in_bytes", "1000\n")
+
+	snapshot, err := newSampler(procRoot, cgroupRoot, 100).Sample()
+	if err != nil {
+		t.Fatalf("Failed to sample: %v", err)
+	}
+
+	if snapshot.CgroupVersion != 1 {
+		t.Errorf("Expected cgroup v1, got %d", snapshot.CgroupVersion)
+	}
+
+	if snapshot.CPULimited || snapshot.MemoryLimited {
+		t.Errorf("Expected unlimited cgroup to fall back to host figures, got %+v", snapshot)
+	}
+
+	if snapshot.MemoryTotal != 8000000*1024 {
+		t.Errorf("Expected host memory total, got %d", snapshot.MemoryTotal)
+	}
+}
+
+func TestParseProcessStat(t *testing.T) {
+	ppid, pages, err := parseProcessStat(processStat("42", "Web Content (x)", "7", "123"))
+	if err != nil {
+		t.Fatalf("Failed to parse stat: %v", err)
+	}
+
+	if ppid != 7 || pages != 123 {
+		t.Errorf("Expected ppid 7 and 123 pages, got %d and %d", ppid, pages)
+	}
+
+	if _, _, err := parseProcessStat("garbage"); err == nil {
+		t.Error("Expected error for malformed stat")
+	}
+}
@@ -207,6 +207,24 @@ func (m *ErrorReporterModel) renderErrorDetails() string {
 	details.WriteString(fmt.Sprintf("URL: %s\n", m.selectedError.URL))
 	details.WriteString(fmt.Sprintf("Message: %s\n", m.selectedError.Message))
 
+	// Debugging artifacts captured for the failing URL
+	if artifacts := m.selectedError.Artifacts; artifacts != nil {
+		details.WriteString("\nArtifacts:\n")
+		details.WriteString(fmt.Sprintf("Directory: %s\n", artifacts.Dir))
+		if artifacts.BeforeScreenshot != "" {
+			details.WriteString(fmt.Sprintf("Before fill: %s\n", artifacts.BeforeScreenshot))
+		}
+		if artifacts.AfterScreenshot != "" {
+			details.WriteString(fmt.Sprintf("After fill: %s\n", artifacts.AfterScreenshot))
+		}
+		if artifacts.Trace != "" {
+			details.WriteString(fmt.Sprintf("Trace: %s (npx playwright show-trace)\n", artifacts.Trace))
+		}
+		if artifacts.HAR != "" {
+			details.WriteString(fmt.Sprintf("HAR: %s\n", artifacts.HAR))
+		}
+	}
+
 	// Recovery suggestions
 	details.WriteString("\nRecovery Suggestions:\n")
 	suggestions := m.getRecoverySuggestions(m.selectedError)
@@ -264,6 +282,20 @@ func (m *ErrorReporterModel) getRecoverySuggestions(err *models.ExecutionError)
 			"Reduce concurrent executions",
 			"Verify system resources")
 
+	case "submission_failed":
+		suggestions = append(suggestions,
+			"Check whether the form was partially submitted before retrying",
+			"Verify the submit button selector",
+			"Review the after-fill screenshot for validation messages",
+			"Submit manually if the site requires it")
+
+	case "browser_crashed":
+		suggestions = append(suggestions,
+			"Reduce concurrent executions",
+			"Check available memory and shared memory (/dev/shm)",
+			"Reinstall Playwright browsers",
+			"Retry the operation")
+
 	case "rate_limit_exceeded":
 		suggestions = append(suggestions,
 			"Reduce execution frequency",
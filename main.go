@@ -12,6 +12,8 @@ import (
 	"runtime"
 	"sync"
 	"time"
+
+	"github.com/ai-form-filler/cli/internal/sysmetrics"
 )
 
 //go:embed web/*
@@ -53,6 +55,11 @@ type SystemResourceUsage struct {
 	MemoryFree     uint64    `json:"memoryFree"`
 	GoroutineCount int       `json:"goroutineCount"`
 	NumCPU         int       `json:"numCPU"`
+	CPUCores       float64   `json:"cpuCores"`
+	LoadAverage    [3]float64 `json:"loadAverage"`
+	BrowserMemory  int64     `json:"browserMemory"`
+	Containerized  bool      `json:"containerized"`
+	Measured       bool      `json:"measured"`
 	OSType         string    `json:"osType"`
 	GoVersion      string    `json:"goVersion"`
 	Uptime         float64   `json:"uptime"`
@@ -66,6 +73,7 @@ type App struct {
 	mu        sync.RWMutex
 	dataDir   string
 	startTime time.Time
+	metrics   *sysmetrics.Sampler
 }
 
 // NewApp creates a new application instance
@@ -79,6 +87,7 @@ func NewApp() *App {
 		templates: make(map[string]*FormTemplate),
 		dataDir:   dataDir,
 		startTime: time.Now(),
+		metrics:   sysmetrics.NewSampler(),
 	}
 
 	app.loadData()
@@ -253,42 +262,36 @@ func (a *App) handleMonitoring(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get memory stats
-	var memStats runtime.MemStats
-	runtime.ReadMemStats(&memStats)
-
-	// Calculate memory usage
-	memoryUsagePercent := float64(memStats.Sys) / float64(memStats.TotalAlloc) * 100
-	if memoryUsagePercent > 100 {
-		memoryUsagePercent = 100
-	}
-
-	// Estimate CPU usage (simplified approach)
 	numCPU := runtime.NumCPU()
-	numGoroutines := runtime.NumGoroutine()
-	cpuUsage := float64(numGoroutines) / float64(numCPU*10) * 100
-	if cpuUsage > 100 {
-		cpuUsage = 100
-	}
 
 	// Calculate uptime
 	uptime := time.Since(a.startTime).Seconds()
 
 	// Create resource usage response
 	resources := SystemResourceUsage{
-		CPUUsage:       cpuUsage,
-		MemoryUsage:    memoryUsagePercent,
-		MemoryTotal:    memStats.TotalAlloc,
-		MemoryUsed:     memStats.Sys,
-		MemoryFree:     memStats.TotalAlloc - memStats.Sys,
-		GoroutineCount: numGoroutines,
+		GoroutineCount: runtime.NumGoroutine(),
 		NumCPU:         numCPU,
+		CPUCores:       float64(numCPU),
 		OSType:         runtime.GOOS,
 		GoVersion:      runtime.Version(),
 		Uptime:         uptime,
 		Timestamp:      time.Now(),
 	}
 
+	// Read host or container metrics; they are unavailable on platforms without /proc
+	if snapshot, err := a.metrics.Sample(); err == nil {
+		resources.CPUUsage = snapshot.CPUPercent
+		resources.MemoryUsage = snapshot.MemoryPercent
+		resources.MemoryTotal = uint64(snapshot.MemoryTotal)
+		resources.MemoryUsed = uint64(snapshot.MemoryUsed)
+		resources.MemoryFree = uint64(snapshot.MemoryAvailable)
+		resources.CPUCores = snapshot.CPUCores
+		resources.LoadAverage = snapshot.LoadAverage
+		resources.BrowserMemory = snapshot.ChildRSS
+		resources.Containerized = snapshot.CPULimited || snapshot.MemoryLimited
+		resources.Measured = true
+	}
+
 	// Return JSON response
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resources)
@@ -0,0 +1,504 @@
+// AI Form Filler native messaging protocol, version 4.
+// Generated by 'ai-form-filler native-messaging schema --format ts'; do not edit.
+
+export const HOST_NAME = "com.ai_form_filler.cli";
+export const PROTOCOL_VERSION = 4;
+export const MIN_PROTOCOL_VERSION = 1;
+
+export type Capability = "form_filling" | "training" | "profile_management" | "fill_progress" | "execution_updates" | "pairing" | "chunking";
+
+export type ErrorCode = "INVALID_MESSAGE" | "UNKNOWN_TYPE" | "INVALID_REQUEST" | "UNSUPPORTED_VERSION" | "DUPLICATE_REQUEST" | "UNAUTHORIZED" | "TOO_LARGE" | "NOT_FOUND" | "TIMEOUT" | "CANCELLED" | "UNAVAILABLE" | "INTERNAL";
+
+export interface NativeMessage<T extends MessageType = MessageType> {
+  /** Set by the extension on requests and echoed on their responses and events */
+  id?: string;
+  type: T;
+  data?: MessageData[T];
+  success?: boolean;
+  /** Human readable; set on ERROR */
+  error?: string;
+  /** Set on ERROR from protocol version 2 */
+  code?: ErrorCode;
+  /** Set on INVALID_REQUEST errors */
+  details?: FieldError[];
+  /** Session token from PAIRED or AUTHENTICATED; needed on sensitive requests */
+  token?: string;
+}
+
+/** The data of each message type */
+export interface MessageData {
+  /** request: Agrees on the protocol version and capabilities; sent first */
+  HANDSHAKE: HandshakeRequest;
+  /** request: Pairs the extension with a code from 'ai-form-filler native-messaging pair' and authenticates the session */
+  PAIR: PairRequest;
+  /** request: Authenticates the session as a paired extension by signing the handshake's challenge: base64 HMAC-SHA256 keyed with the base64-decoded secret */
+  AUTHENTICATE: AuthenticateRequest;
+  /** request: Lists the profiles */
+  GET_PROFILES: undefined;
+  /** request: Creates a profile */
+  CREATE_PROFILE: CreateProfileRequest;
+  /** request: Changes the fields sent of a profile */
+  UPDATE_PROFILE: UpdateProfileRequest;
+  /** request: Deletes a profile */
+  DELETE_PROFILE: DeleteProfileRequest;
+  /** request: Plans the values of a form's fields, or fills it in the CLI's browser in run mode */
+  FILL_FORM: FillFormRequest;
+  /** request: Saves a template learned from the fields the extension captured */
+  TRAIN_FORM: TrainFormRequest;
+  /** request: Reports which template covers the forms found on a page */
+  FORMS_DETECTED: FormsDetectedRequest;
+  /** request: Saves training data like TRAIN_FORM without a result */
+  TRAINING_DATA: TrainFormRequest;
+  /** request: Reports the host's version, uptime and storage */
+  GET_STATUS: undefined;
+  /** request: Opens the CLI dashboard */
+  OPEN_CLI_DASHBOARD: undefined;
+  /** request: Streams the progress of execution sessions run from the CLI */
+  SUBSCRIBE_EXECUTIONS: undefined;
+  /** request: Stops a subscription */
+  UNSUBSCRIBE_EXECUTIONS: UnsubscribeRequest;
+  /** request: Aborts a request in progress; answered at once */
+  CANCEL: CancelRequest;
+  /** response */
+  HANDSHAKE_RESPONSE: HandshakeResponse;
+  /** response */
+  PAIRED: PairResponse;
+  /** response */
+  AUTHENTICATED: AuthenticateResponse;
+  /** response */
+  PROFILES_RESPONSE: ClientProfile[];
+  /** response */
+  PROFILE_CREATED: ClientProfile;
+  /** response */
+  PROFILE_UPDATED: ClientProfile;
+  /** response */
+  PROFILE_DELETED: ProfileDeletedResponse;
+  /** response */
+  FORM_FILL_RESPONSE: FillFormResponse;
+  /** response */
+  TRAINING_RESPONSE: TrainFormResponse;
+  /** response */
+  FORMS_ANALYZED: FormsAnalyzedResponse;
+  /** response */
+  TRAINING_DATA_PROCESSED: undefined;
+  /** response */
+  STATUS_RESPONSE: StatusResponse;
+  /** response */
+  DASHBOARD_OPENED: undefined;
+  /** response */
+  EXECUTIONS_SUBSCRIBED: SubscriptionResponse;
+  /** response */
+  EXECUTIONS_UNSUBSCRIBED: UnsubscribeResponse;
+  /** response */
+  CANCEL_RESPONSE: CancelResponse;
+  /** response: Answers a request whose handler returned no response */
+  SUCCESS: undefined;
+  /** response: Answers a request that failed; see error, code and details */
+  ERROR: undefined;
+  /** event: A field of a run fill was filled or failed */
+  FILL_PROGRESS: FillEvent;
+  /** event: A template step other than fill ran */
+  FILL_STEP: FillEvent;
+  /** event: A run fill finished; sent before its response */
+  FILL_DONE: FillSummary;
+  /** event: An execution session changed */
+  EXECUTION_UPDATE: ExecutionUpdate;
+  /** event: A subscription ended because the execution feed failed */
+  EXECUTION_WATCH_FAILED: WatchFailedEvent;
+  /** frame: Part of a message larger than one frame, with the message's ID; the base64-decoded parts joined in order are its JSON */
+  CHUNK: Chunk;
+}
+
+export type MessageType = keyof MessageData;
+
+export type RequestType = "HANDSHAKE" | "PAIR" | "AUTHENTICATE" | "GET_PROFILES" | "CREATE_PROFILE" | "UPDATE_PROFILE" | "DELETE_PROFILE" | "FILL_FORM" | "TRAIN_FORM" | "FORMS_DETECTED" | "TRAINING_DATA" | "GET_STATUS" | "OPEN_CLI_DASHBOARD" | "SUBSCRIBE_EXECUTIONS" | "UNSUBSCRIBE_EXECUTIONS" | "CANCEL";
+
+/** Requests that need the session token */
+export type SensitiveRequestType = "GET_PROFILES" | "CREATE_PROFILE" | "UPDATE_PROFILE" | "DELETE_PROFILE" | "FILL_FORM" | "TRAIN_FORM" | "FORMS_DETECTED" | "TRAINING_DATA" | "OPEN_CLI_DASHBOARD" | "SUBSCRIBE_EXECUTIONS" | "UNSUBSCRIBE_EXECUTIONS";
+
+/** The success response of each request; any request may be answered with ERROR */
+export interface ResponseType {
+  HANDSHAKE: "HANDSHAKE_RESPONSE";
+  PAIR: "PAIRED";
+  AUTHENTICATE: "AUTHENTICATED";
+  GET_PROFILES: "PROFILES_RESPONSE";
+  CREATE_PROFILE: "PROFILE_CREATED";
+  UPDATE_PROFILE: "PROFILE_UPDATED";
+  DELETE_PROFILE: "PROFILE_DELETED";
+  FILL_FORM: "FORM_FILL_RESPONSE";
+  TRAIN_FORM: "TRAINING_RESPONSE";
+  FORMS_DETECTED: "FORMS_ANALYZED";
+  TRAINING_DATA: "TRAINING_DATA_PROCESSED";
+  GET_STATUS: "STATUS_RESPONSE";
+  OPEN_CLI_DASHBOARD: "DASHBOARD_OPENED";
+  SUBSCRIBE_EXECUTIONS: "EXECUTIONS_SUBSCRIBED";
+  UNSUBSCRIBE_EXECUTIONS: "EXECUTIONS_UNSUBSCRIBED";
+  CANCEL: "CANCEL_RESPONSE";
+}
+
+/** The events a request may be sent with its ID */
+export interface EventType {
+  HANDSHAKE: never;
+  PAIR: never;
+  AUTHENTICATE: never;
+  GET_PROFILES: never;
+  CREATE_PROFILE: never;
+  UPDATE_PROFILE: never;
+  DELETE_PROFILE: never;
+  FILL_FORM: "FILL_PROGRESS" | "FILL_STEP" | "FILL_DONE";
+  TRAIN_FORM: never;
+  FORMS_DETECTED: never;
+  TRAINING_DATA: never;
+  GET_STATUS: never;
+  OPEN_CLI_DASHBOARD: never;
+  SUBSCRIBE_EXECUTIONS: "EXECUTION_UPDATE" | "EXECUTION_WATCH_FAILED";
+  UNSUBSCRIBE_EXECUTIONS: never;
+  CANCEL: never;
+}
+
+export interface Address {
+  street1: string;
+  street2?: string;
+  city: string;
+  state: string;
+  postalCode: string;
+  country: string;
+}
+
+export interface AddressFields {
+  street1?: string;
+  street2?: string;
+  city?: string;
+  state?: string;
+  postalCode?: string;
+  country?: string;
+}
+
+export interface AuthenticateRequest {
+  pairingId: string;
+  signature: string;
+}
+
+export interface AuthenticateResponse {
+  pairingId: string;
+  token: string;
+}
+
+export interface CancelRequest {
+  requestId: string;
+}
+
+export interface CancelResponse {
+  requestId: string;
+  cancelled: boolean;
+}
+
+export interface Chunk {
+  transferId: string;
+  index: number;
+  total: number;
+  size: number;
+  sha256: string;
+  data: string;
+}
+
+export interface ClientProfile {
+  id: string;
+  name: string;
+  personalData: PersonalData;
+  preferences: Preferences;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export interface CreateProfileRequest {
+  name: string;
+  personalData?: PersonalDataFields;
+  preferences?: PreferenceFields;
+}
+
+export interface DeleteProfileRequest {
+  profileId: string;
+}
+
+export interface DetectedField {
+  name?: string;
+  type?: string;
+  label?: string;
+  selector?: string;
+  required?: boolean;
+  placeholder?: string;
+  validationPattern?: string;
+  value?: string;
+  candidates?: SelectorCandidate[];
+}
+
+export interface DetectedForm {
+  index?: number;
+  fields?: DetectedField[];
+  submitButtons?: SubmitButton[];
+  formType?: string;
+  confidence?: number;
+  selector?: string;
+  action?: string;
+  method?: string;
+}
+
+export interface ExecutionProgress {
+  totalUrls: number;
+  completedUrls: number;
+  failedUrls: number;
+  skippedUrls: number;
+  percentage: number;
+  currentUrl?: string;
+  estimatedTime?: string;
+}
+
+export interface ExecutionUpdate {
+  event: string;
+  sessionId: string;
+  profileName: string;
+  status: string;
+  progress: ExecutionProgress;
+  url?: string;
+  error?: string;
+  timestamp: string;
+}
+
+export interface FieldError {
+  path: string;
+  reason: "required" | "type" | "enum" | "minimum" | "minLength" | "minItems";
+  message: string;
+}
+
+export interface FillEvent {
+  kind: string;
+  field?: string;
+  step?: number;
+  action?: string;
+  selector?: string;
+  success: boolean;
+  error?: string;
+  completed: number;
+  total: number;
+}
+
+export interface FillFormRequest {
+  url: string;
+  profileId?: string;
+  profileName?: string;
+  fields?: DetectedField[];
+  formType?: string;
+  mode?: "plan" | "run";
+  submit?: boolean;
+}
+
+export interface FillFormResponse {
+  mode: "plan" | "run";
+  url?: string;
+  templateId?: string;
+  templateVersion?: number;
+  profileId?: string;
+  fields?: PlannedField[];
+  steps?: TemplateStep[];
+  submit?: SubmitAction;
+  unmappedFields?: string[];
+  confidence?: number;
+  result?: FillSummary;
+}
+
+export interface FillSummary {
+  url: string;
+  success: boolean;
+  filledFields: number;
+  totalFields: number;
+  failedFields: string[];
+  errors: string[];
+  submitted: boolean;
+  submissionSuccess?: boolean;
+  templateId?: string;
+}
+
+export interface FormCoverage {
+  index: number;
+  fields: number;
+  matchedFields: number;
+}
+
+export interface FormsAnalyzedResponse {
+  status: "analyzed";
+  formsDetected: number;
+  templateId?: string;
+  templateVersion?: number;
+  formType?: string;
+  trainingSuggested: boolean;
+  forms?: FormCoverage[];
+  timestamp: string;
+}
+
+export interface FormsDetectedRequest {
+  url?: string;
+  forms?: DetectedForm[];
+}
+
+export interface HandshakeRequest {
+  protocolVersion?: number;
+  extensionId?: string;
+  version?: string;
+  capabilities?: string[];
+}
+
+export interface HandshakeResponse {
+  cliVersion: string;
+  protocolVersion: number;
+  minProtocolVersion: number;
+  maxProtocolVersion: number;
+  status: "connected";
+  capabilities: string[];
+  challenge: string;
+  timestamp: number;
+}
+
+export interface PairRequest {
+  code: string;
+  name?: string;
+}
+
+export interface PairResponse {
+  pairingId: string;
+  secret: string;
+  token: string;
+}
+
+export interface PersonalData {
+  firstName: string;
+  lastName: string;
+  email: string;
+  phone: string;
+  address: Address;
+  dateOfBirth: string;
+  customFields?: Record<string, unknown>;
+}
+
+export interface PersonalDataFields {
+  firstName?: string;
+  lastName?: string;
+  email?: string;
+  phone?: string;
+  dateOfBirth?: string;
+  address?: AddressFields;
+}
+
+export interface PlannedField {
+  name: string;
+  type: string;
+  selectors: string[];
+  value: string;
+  source: string;
+}
+
+export interface PreferenceFields {
+  autoFill?: boolean;
+  skipValidation?: boolean;
+  defaultTimeout?: number;
+  preferredBrowser?: string;
+}
+
+export interface Preferences {
+  autoFill: boolean;
+  skipValidation: boolean;
+  defaultTimeout: number;
+  preferredBrowser: string;
+}
+
+export interface ProfileDeletedResponse {
+  profileId: string;
+}
+
+export interface SelectorCandidate {
+  selector?: string;
+  strategy?: string;
+  stability?: number;
+}
+
+export interface StatusResponse {
+  status: "running";
+  version: string;
+  protocolVersion: number;
+  uptime: number;
+  profiles: number;
+  templates: number;
+  timestamp: string;
+}
+
+export interface SubmitAction {
+  selector: string;
+  wait_for?: string;
+  /** Nanoseconds */
+  timeout?: number;
+}
+
+export interface SubmitButton {
+  text?: string;
+  selector?: string;
+  type?: string;
+}
+
+export interface SubscriptionResponse {
+  subscriptionId: string;
+}
+
+export interface SuccessRule {
+  type?: string;
+  pattern?: string;
+  selector?: string;
+  text?: string;
+}
+
+export interface TemplateStep {
+  action: string;
+  selector?: string;
+  field?: string;
+  value?: string;
+  /** Nanoseconds */
+  timeout?: number;
+}
+
+export interface TrainFormRequest {
+  url: string;
+  templateId?: string;
+  formType?: string;
+  fields: DetectedField[];
+  submitButtons?: SubmitButton[];
+  urlPatterns?: string[];
+  success?: SuccessRule[];
+}
+
+export interface TrainFormResponse {
+  status: "trained";
+  templateId: string;
+  version: number;
+  created: boolean;
+  fields: number;
+  timestamp: string;
+}
+
+export interface UnsubscribeRequest {
+  subscriptionId: string;
+}
+
+export interface UnsubscribeResponse {
+  subscriptionId: string;
+  found: boolean;
+}
+
+export interface UpdateProfileRequest {
+  id: string;
+  name?: string;
+  personalData?: PersonalDataFields;
+  preferences?: PreferenceFields;
+}
+
+export interface WatchFailedEvent {
+  error: string;
+}